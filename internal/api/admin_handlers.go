@@ -2,149 +2,359 @@ package api
 
 import (
 	"farm/internal/models"
+	"farm/internal/validate"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
+type updateCreditsRequest struct {
+	Credits int `json:"credits"`
+}
+
+func (r *updateCreditsRequest) Validate(c *validate.Checker) {
+	c.NotNegative("credits", r.Credits)
+}
+
 func (h *Handler) UpdateCredits(c echo.Context) error {
+	ctx := c.Request().Context()
 	// Only Admin (Middleware applied in routes)
 	id := c.Param("id")
-	type Request struct {
-		Credits int `json:"credits"`
+	var req updateCreditsRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
-	var req Request
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
 	}
 
-	updated, err := h.store.UpdateCustomerCredits(id, req.Credits)
+	updated, err := h.audited(c, models.AuditActionUpdateCredits, models.AuditTargetCustomer, id).UpdateCustomerCredits(ctx, id, req.Credits, version)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		return err
 	}
+	setETag(c, updated.Version)
 	return c.JSON(http.StatusOK, updated)
 }
 
+type updateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (r *updateRoleRequest) Validate(c *validate.Checker) {
+	validate.OneOf(c, "role", r.Role, models.RoleAdmin, models.RoleStaff, models.RoleCustomer)
+}
+
 func (h *Handler) UpdateRole(c echo.Context) error {
+	ctx := c.Request().Context()
 	id := c.Param("id")
-	type Request struct {
-		Role string `json:"role"`
+	var req updateRoleRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
-	var req Request
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
 	}
 
-	if req.Role != models.RoleAdmin && req.Role != models.RoleCustomer {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid role"})
+	updated, err := h.audited(c, models.AuditActionUpdateRole, models.AuditTargetCustomer, id).UpdateCustomerRole(ctx, id, req.Role, version)
+	if err != nil {
+		return err
 	}
+	setETag(c, updated.Version)
+	return c.JSON(http.StatusOK, updated)
+}
 
-	updated, err := h.store.UpdateCustomerRole(id, req.Role)
+func (h *Handler) LiftBan(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	updated, err := h.audited(c, models.AuditActionLiftBan, models.AuditTargetCustomer, id).ClearCustomerBan(ctx, id)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		return err
 	}
 	return c.JSON(http.StatusOK, updated)
 }
 
 func (h *Handler) DeleteUser(c echo.Context) error {
+	ctx := c.Request().Context()
 	id := c.Param("id")
-	if err := h.store.DeleteCustomer(id); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := h.audited(c, models.AuditActionDelete, models.AuditTargetCustomer, id).DeleteCustomer(ctx, id); err != nil {
+		return err
 	}
 	return c.NoContent(http.StatusNoContent)
 }
 
+func (h *Handler) RestoreUser(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	restored, err := h.audited(c, models.AuditActionRestore, models.AuditTargetCustomer, id).RestoreCustomer(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, restored)
+}
+
+func (h *Handler) GetProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	p, err := h.store.GetProduct(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	setETag(c, p.Version)
+	return c.JSON(http.StatusOK, p)
+}
+
 func (h *Handler) CreateProduct(c echo.Context) error {
+	ctx := c.Request().Context()
 	var p models.Product
-	if err := c.Bind(&p); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	if err := bind(c, &p); err != nil {
+		return err
 	}
 	if p.ID == "" {
 		p.ID = uuid.New().String()
 	}
-	if err := h.store.AddProduct(&p); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := h.audited(c, models.AuditActionCreate, models.AuditTargetProduct, p.ID).AddProduct(ctx, &p); err != nil {
+		return err
 	}
+	setETag(c, p.Version)
 	return c.JSON(http.StatusCreated, p)
 }
 
 func (h *Handler) UpdateProduct(c echo.Context) error {
+	ctx := c.Request().Context()
 	id := c.Param("id")
 	var p models.Product
-	if err := c.Bind(&p); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	if err := bind(c, &p); err != nil {
+		return err
+	}
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+	p.ID, p.Version = id, version
+	if err := h.audited(c, models.AuditActionUpdate, models.AuditTargetProduct, id).UpdateProduct(ctx, &p); err != nil {
+		return err
+	}
+	setETag(c, p.Version)
+	return c.JSON(http.StatusOK, p)
+}
+
+// PatchProduct applies a JSON merge patch, changing only the fields supplied.
+func (h *Handler) PatchProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
 	}
-	p.ID = id
-	if err := h.store.UpdateProduct(&p); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	patch, err := readMergePatch(c, "id", "deleted_at", "version")
+	if err != nil {
+		return err
+	}
+	p, err := h.audited(c, models.AuditActionUpdate, models.AuditTargetProduct, id).PatchProduct(ctx, id, version, func(p *models.Product) error {
+		if err := applyMergePatch(p, patch); err != nil {
+			return err
+		}
+		return c.Validate(p)
+	})
+	if err != nil {
+		return err
 	}
+	setETag(c, p.Version)
+	return c.JSON(http.StatusOK, p)
+}
+
+type adjustRequest struct {
+	Adjust int `json:"adjust"`
+}
+
+func (r *adjustRequest) Validate(c *validate.Checker) {
+	if r.Adjust == 0 {
+		c.Fail("adjust", "cannot be zero")
+	}
+}
+
+// AdjustStock adds to or takes from a product's stock relative to whatever it
+// is now, so it needs no If-Match and can't undo concurrent reservations.
+func (h *Handler) AdjustStock(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	var req adjustRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+	p, err := h.audited(c, models.AuditActionAdjustStock, models.AuditTargetProduct, id).AdjustProductStock(ctx, id, req.Adjust)
+	if err != nil {
+		return err
+	}
+	setETag(c, p.Version)
 	return c.JSON(http.StatusOK, p)
 }
 
 func (h *Handler) DeleteProduct(c echo.Context) error {
+	ctx := c.Request().Context()
 	id := c.Param("id")
-	if err := h.store.DeleteProduct(id); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := h.audited(c, models.AuditActionDelete, models.AuditTargetProduct, id).DeleteProduct(ctx, id); err != nil {
+		return err
 	}
 	return c.NoContent(http.StatusNoContent)
 }
 
+func (h *Handler) RestoreProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	restored, err := h.audited(c, models.AuditActionRestore, models.AuditTargetProduct, id).RestoreProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, restored)
+}
+
+func (h *Handler) GetActivity(c echo.Context) error {
+	ctx := c.Request().Context()
+	a, err := h.store.GetActivity(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	setETag(c, a.Version)
+	return c.JSON(http.StatusOK, a)
+}
+
 func (h *Handler) CreateActivity(c echo.Context) error {
+	ctx := c.Request().Context()
 	var a models.Activity
-	if err := c.Bind(&a); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	if err := bind(c, &a); err != nil {
+		return err
 	}
 	if a.ID == "" {
 		a.ID = uuid.New().String()
 	}
-	if err := h.store.AddActivity(&a); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := h.audited(c, models.AuditActionCreate, models.AuditTargetActivity, a.ID).AddActivity(ctx, &a); err != nil {
+		return err
 	}
+	setETag(c, a.Version)
 	return c.JSON(http.StatusCreated, a)
 }
 
 func (h *Handler) UpdateActivity(c echo.Context) error {
+	ctx := c.Request().Context()
 	id := c.Param("id")
 	var a models.Activity
-	if err := c.Bind(&a); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	if err := bind(c, &a); err != nil {
+		return err
 	}
-	a.ID = id
-	if err := h.store.UpdateActivity(&a); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+	a.ID, a.Version = id, version
+	if err := h.audited(c, models.AuditActionUpdate, models.AuditTargetActivity, id).UpdateActivity(ctx, &a); err != nil {
+		return err
 	}
+	setETag(c, a.Version)
+	return c.JSON(http.StatusOK, a)
+}
+
+// PatchActivity applies a JSON merge patch; see PatchProduct.
+func (h *Handler) PatchActivity(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+	patch, err := readMergePatch(c, "id", "deleted_at", "version")
+	if err != nil {
+		return err
+	}
+	a, err := h.audited(c, models.AuditActionUpdate, models.AuditTargetActivity, id).PatchActivity(ctx, id, version, func(a *models.Activity) error {
+		if err := applyMergePatch(a, patch); err != nil {
+			return err
+		}
+		return c.Validate(a)
+	})
+	if err != nil {
+		return err
+	}
+	setETag(c, a.Version)
+	return c.JSON(http.StatusOK, a)
+}
+
+// AdjustCapacity is AdjustStock for an activity's remaining places.
+func (h *Handler) AdjustCapacity(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	var req adjustRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+	a, err := h.audited(c, models.AuditActionAdjustStock, models.AuditTargetActivity, id).AdjustActivityCapacity(ctx, id, req.Adjust)
+	if err != nil {
+		return err
+	}
+	setETag(c, a.Version)
 	return c.JSON(http.StatusOK, a)
 }
 
 func (h *Handler) DeleteActivity(c echo.Context) error {
+	ctx := c.Request().Context()
 	id := c.Param("id")
-	if err := h.store.DeleteActivity(id); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := h.audited(c, models.AuditActionDelete, models.AuditTargetActivity, id).DeleteActivity(ctx, id); err != nil {
+		return err
 	}
 	return c.NoContent(http.StatusNoContent)
 }
 
+func (h *Handler) RestoreActivity(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	restored, err := h.audited(c, models.AuditActionRestore, models.AuditTargetActivity, id).RestoreActivity(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, restored)
+}
+
 func (h *Handler) ListReservations(c echo.Context) error {
-	list, err := h.store.GetAllReservations()
+	ctx := c.Request().Context()
+	list, err := h.store.GetAllReservations(ctx)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, list)
 }
 
 func (h *Handler) DeleteReservation(c echo.Context) error {
+	ctx := c.Request().Context()
 	id := c.Param("id")
-	if err := h.store.DeleteReservation(id); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := h.audited(c, models.AuditActionDelete, models.AuditTargetReservation, id).DeleteReservation(ctx, id); err != nil {
+		return err
 	}
 	return c.NoContent(http.StatusNoContent)
 }
 
+func (h *Handler) GetUser(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, err := h.store.GetCustomer(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	setETag(c, u.Version)
+	return c.JSON(http.StatusOK, u)
+}
+
 func (h *Handler) ListUsers(c echo.Context) error {
-	list, err := h.store.GetAllCustomers()
+	ctx := c.Request().Context()
+	var list []*models.Customer
+	var err error
+	if c.QueryParam("deleted") == "true" {
+		list, err = h.store.GetDeletedCustomers(ctx)
+	} else {
+		list, err = h.store.GetAllCustomers(ctx)
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	// Sanitize passwords
 	for _, u := range list {
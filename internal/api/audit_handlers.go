@@ -0,0 +1,86 @@
+package api
+
+import (
+	"farm/internal/auth"
+	"farm/internal/models"
+	"farm/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// audited returns the store as seen by a mutating admin or staff request:
+// changes made through it are written to the audit log with the acting user,
+// request IDs and client IP.
+func (h *Handler) audited(c echo.Context, action, targetType, targetID string) store.Repository {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+	return h.store.WithAudit(&models.AuditEntry{
+		ActorID:         claims.UserID,
+		Action:          action,
+		TargetType:      targetType,
+		TargetID:        targetID,
+		RequestID:       c.Response().Header().Get(echo.HeaderXRequestID),
+		ClientRequestID: c.Request().Header.Get(echo.HeaderXRequestID),
+		ClientIP:        c.RealIP(),
+	})
+}
+
+func (h *Handler) ListAuditLog(c echo.Context) error {
+	ctx := c.Request().Context()
+	f := models.AuditFilter{
+		ActorID:    c.QueryParam("actor_id"),
+		Action:     c.QueryParam("action"),
+		TargetType: c.QueryParam("target_type"),
+		TargetID:   c.QueryParam("target_id"),
+		Limit:      defaultAuditLimit,
+	}
+
+	var err error
+	if f.From, err = queryTime(c.QueryParam("from")); err != nil {
+		return badRequest("invalid from")
+	}
+	if f.To, err = queryTime(c.QueryParam("to")); err != nil {
+		return badRequest("invalid to")
+	}
+	if v := c.QueryParam("before_id"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return badRequest("invalid before_id")
+		}
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			return badRequest("invalid limit")
+		}
+		f.Limit = min(f.Limit, maxAuditLimit)
+	}
+
+	entries, err := h.store.GetAuditEntries(ctx, f)
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []*models.AuditEntry{}
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// queryTime parses an RFC 3339 timestamp or a YYYY-MM-DD date (server local
+// midnight). Empty input yields the zero time.
+func queryTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, v, time.Local)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"farm/internal/auth"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// newContext returns a context for a request made by userID with role.
+func newContext(req *http.Request, userID, role string) (echo.Context, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user", &jwt.Token{Claims: &auth.JWTClaims{UserID: userID, Role: role}})
+	return c, rec
+}
+
+func TestAuditedChangesAndListing(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{}
+	s := memory.NewMemoryStore(cfg)
+	for _, id := range []string{"eggs", "milk"} {
+		if err := s.AddProduct(ctx, &models.Product{ID: id, Name: id, Quantity: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandler(s, cfg, events.NewBus(8), nil)
+
+	for _, id := range []string{"eggs", "milk"} {
+		req := httptest.NewRequest(http.MethodDelete, "/api/admin/products/"+id, nil)
+		req.Header.Set(echo.HeaderXRequestID, "client-"+id)
+		req.RemoteAddr = "192.0.2.7:1234"
+		c, rec := newContext(req, "admin", models.RoleAdmin)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		rec.Header().Set(echo.HeaderXRequestID, "server-"+id)
+		if err := h.DeleteProduct(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(query string) ([]*models.AuditEntry, error) {
+		c, rec := newContext(httptest.NewRequest(http.MethodGet, "/api/admin/audit?"+query, nil), "admin", models.RoleAdmin)
+		if err := h.ListAuditLog(c); err != nil {
+			return nil, err
+		}
+		var entries []*models.AuditEntry
+		if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+			t.Fatal(err)
+		}
+		return entries, nil
+	}
+
+	entries, err := list("target_id=eggs&action=delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.ActorID != "admin" || e.TargetType != models.AuditTargetProduct || e.RequestID != "server-eggs" || e.ClientRequestID != "client-eggs" || e.ClientIP != "192.0.2.7" {
+		t.Errorf("entry = %+v", e)
+	}
+	if entries, err := list("limit=1"); err != nil || len(entries) != 1 || entries[0].TargetID != "milk" {
+		t.Errorf("newest entry = %+v, %v; want milk's", entries, err)
+	}
+	if entries, err := list("actor_id=someone-else"); err != nil || entries == nil || len(entries) != 0 {
+		t.Errorf("entries for another actor = %#v, %v; want an empty list", entries, err)
+	}
+
+	for _, query := range []string{"from=yesterday", "to=2025-13-01", "before_id=x", "limit=0", "limit=-3"} {
+		_, err := list(query)
+		var p *problem
+		if !errors.As(err, &p) || p.Status != http.StatusBadRequest {
+			t.Errorf("%s: err = %v, want 400", query, err)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
-	"database/sql"
+	"errors"
 	"farm/internal/auth"
 	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/validate"
+	"fmt"
 	"net/http"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,41 +14,54 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+type signupRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+}
+
+func (r *signupRequest) Validate(c *validate.Checker) {
+	c.Email("email", r.Email)
+	c.Password("password", r.Password)
+	validateCustomerName(c, r.Name)
+}
+
 func (h *Handler) Signup(c echo.Context) error {
-	type Request struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-		Name     string `json:"name"`
-	}
-	var req Request
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	ctx := c.Request().Context()
+	var req signupRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
 	// Generate a unique salt for the user
 	salt, err := auth.GenerateSalt()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error generating salt"})
+		return fmt.Errorf("generating salt: %w", err)
 	}
 
 	// Hash password with Salt
 	hash, err := auth.HashPassword(req.Password, salt)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error processing password"})
+		return fmt.Errorf("hashing password: %w", err)
 	}
 
 	customer := &models.Customer{
-		ID:       uuid.New().String(),
-		Email:    req.Email,
-		Password: hash,
-		Salt:     salt,
-		Name:     req.Name,
-		Credits:  0,
-		Role:     models.RoleCustomer, // Default role
-	}
-
-	if err := h.store.AddCustomer(customer); err != nil {
-		return c.JSON(http.StatusConflict, map[string]string{"error": "user likely already exists"})
+		ID:          uuid.New().String(),
+		Email:       req.Email,
+		Password:    hash,
+		Salt:        salt,
+		Name:        req.Name,
+		Credits:     0,
+		Role:        models.RoleCustomer, // Default role
+		NotifyEmail: true,
+		NotifyInApp: true,
+	}
+
+	if err := h.store.AddCustomer(ctx, customer); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return newProblem(http.StatusConflict, CodeConflict, "email already registered")
+		}
+		return err
 	}
 
 	// Don't return sensitive info
@@ -54,71 +70,90 @@ func (h *Handler) Signup(c echo.Context) error {
 	return c.JSON(http.StatusCreated, customer)
 }
 
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Validate only checks presence: the password policy may have changed since
+// the account was created.
+func (r *loginRequest) Validate(c *validate.Checker) {
+	c.Required("email", r.Email)
+	c.Required("password", r.Password)
+}
+
 func (h *Handler) Login(c echo.Context) error {
-	type Request struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-	var req Request
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	ctx := c.Request().Context()
+	var req loginRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	customer, err := h.store.GetCustomerByEmail(req.Email)
+	customer, err := h.store.GetCustomerByEmail(ctx, req.Email)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid credentials"})
+		if errors.Is(err, store.ErrNotFound) {
+			return newProblem(http.StatusUnauthorized, CodeInvalidCredentials, "invalid credentials")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "server error"})
+		return err
 	}
 
 	// Verify password + salt
 	if !auth.CheckPasswordHash(req.Password, customer.Salt, customer.Password) {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid credentials"})
+		return newProblem(http.StatusUnauthorized, CodeInvalidCredentials, "invalid credentials")
 	}
 
 	token, err := auth.GenerateToken(customer.ID, customer.Role, h.config.JWTSecret)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not generate token"})
+		return fmt.Errorf("generating token: %w", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"token": token})
 }
 
 func (h *Handler) GetMe(c echo.Context) error {
+	ctx := c.Request().Context()
 	user := c.Get("user").(*jwt.Token)
 	claims := user.Claims.(*auth.JWTClaims)
 
-	customer, err := h.store.GetCustomer(claims.UserID)
+	customer, err := h.store.GetCustomer(ctx, claims.UserID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+		return err
 	}
 
 	// Sanitize
 	customer.Password = ""
 	customer.Salt = ""
+	setETag(c, customer.Version)
 	return c.JSON(http.StatusOK, customer)
 }
 
+type updateMeRequest struct {
+	Name string `json:"name"`
+}
+
+func (r *updateMeRequest) Validate(c *validate.Checker) {
+	validateCustomerName(c, r.Name)
+}
+
+func validateCustomerName(c *validate.Checker, name string) {
+	if c.Required("name", name) {
+		c.MaxLength("name", name, validate.MaxNameLength)
+	}
+}
+
 func (h *Handler) UpdateMe(c echo.Context) error {
+	ctx := c.Request().Context()
 	user := c.Get("user").(*jwt.Token)
 	claims := user.Claims.(*auth.JWTClaims)
 
-	type Request struct {
-		Name string `json:"name"`
-	}
-	var req Request
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
-	}
-
-	if req.Name == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name cannot be empty"})
+	var req updateMeRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	updated, err := h.store.UpdateCustomerName(claims.UserID, req.Name)
+	updated, err := h.store.UpdateCustomerName(ctx, claims.UserID, req.Name)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not update user"})
+		return err
 	}
 
 	updated.Password = ""
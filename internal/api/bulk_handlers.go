@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"farm/internal/bulk"
+	"farm/internal/logger"
+	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/validate"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type importFunc func(ctx context.Context, s store.Repository, v *validate.Validator, r io.Reader, format bulk.Format, dryRun bool) (*models.ImportResult, error)
+
+// importFormat picks the import format from ?format=, falling back to the
+// request's Content-Type and then CSV.
+func importFormat(c echo.Context) (bulk.Format, bool) {
+	switch c.QueryParam("format") {
+	case "csv":
+		return bulk.FormatCSV, true
+	case "jsonl":
+		return bulk.FormatJSONL, true
+	case "":
+	default:
+		return "", false
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+		return bulk.FormatJSONL, true
+	}
+	return bulk.FormatCSV, true
+}
+
+// runImport reads the request body with fn. Responses are 200 with the result
+// when every row is valid, and 422 listing the errors when any is not.
+func (h *Handler) runImport(c echo.Context, fn importFunc, targetType string) error {
+	ctx := c.Request().Context()
+	format, ok := importFormat(c)
+	if !ok {
+		return badRequest("format must be csv or jsonl")
+	}
+	dryRun := c.QueryParam("dry_run") == "true"
+
+	result, err := fn(ctx, h.audited(c, models.AuditActionImport, targetType, ""), validate.New(h.config.Passwords), c.Request().Body, format, dryRun)
+	if err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) ImportProducts(c echo.Context) error {
+	return h.runImport(c, bulk.ImportProducts, models.AuditTargetProduct)
+}
+
+func (h *Handler) ImportActivities(c echo.Context) error {
+	return h.runImport(c, bulk.ImportActivities, models.AuditTargetActivity)
+}
+
+func (h *Handler) ImportUsers(c echo.Context) error {
+	return h.runImport(c, bulk.ImportCustomers, models.AuditTargetCustomer)
+}
+
+// streamCSV writes a CSV attachment, calling each to produce rows straight from
+// the store. Once the first byte is sent the status can't change, so a later
+// failure is logged and the download is cut short.
+func streamCSV(c echo.Context, filename string, header []string, each func(w *csv.Writer) error) error {
+	ctx := c.Request().Context()
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(c.Response())
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	err := each(w)
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("CSV export failed", "file", filename, "error", err)
+	}
+	return nil
+}
+
+func (h *Handler) ExportProducts(c echo.Context) error {
+	ctx := c.Request().Context()
+	return streamCSV(c, "products.csv", bulk.ProductColumns, func(w *csv.Writer) error {
+		return h.store.EachProduct(ctx, func(p *models.Product) error {
+			return w.Write(bulk.ProductRecord(p))
+		})
+	})
+}
+
+func (h *Handler) ExportActivities(c echo.Context) error {
+	ctx := c.Request().Context()
+	return streamCSV(c, "activities.csv", bulk.ActivityColumns, func(w *csv.Writer) error {
+		return h.store.EachActivity(ctx, func(a *models.Activity) error {
+			return w.Write(bulk.ActivityRecord(a))
+		})
+	})
+}
+
+func (h *Handler) ExportUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+	return streamCSV(c, "users.csv", bulk.CustomerColumns, func(w *csv.Writer) error {
+		return h.store.EachCustomer(ctx, func(cu *models.Customer) error {
+			return w.Write(bulk.CustomerRecord(cu))
+		})
+	})
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"farm/internal/bulk"
+	"farm/internal/logger"
+	"farm/internal/store"
+	"farm/internal/validate"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Error codes identify what went wrong independently of the status and the
+// human-readable detail, so clients can switch on them. They are part of the
+// API: add new ones freely, but don't rename or reuse them.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeForbidden            = "forbidden"
+	CodeSuspended            = "reservations_suspended"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeOutOfStock           = "out_of_stock"
+	CodeFullyBooked          = "fully_booked"
+	CodeSlotFull             = "slot_full"
+	CodeInvalidTransition    = "invalid_transition"
+	CodeActiveReservations   = "active_reservations"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeIdempotencyKeyInUse  = "idempotency_key_in_use"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeInternal             = "internal_error"
+	CodeUnavailable          = "unavailable"
+	CodeTimeout              = "timeout"
+)
+
+// problem is an RFC 7807 problem details response, extended with a stable
+// error code, the request ID and, for validation failures, the invalid
+// fields. Handlers return one as their error and ErrorHandler writes it.
+type problem struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	Code      string                 `json:"code"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Errors    []*validate.FieldError `json:"errors,omitempty"`
+}
+
+func newProblem(status int, code, detail string) *problem {
+	return &problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Code: code, Detail: detail}
+}
+
+func (p *problem) Error() string {
+	return fmt.Sprintf("%d %s: %s", p.Status, p.Code, p.Detail)
+}
+
+func badRequest(detail string) *problem {
+	return newProblem(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+func notFound(detail string) *problem {
+	return newProblem(http.StatusNotFound, CodeNotFound, detail)
+}
+
+// knownErrors maps the errors the stores and importers define to responses.
+// Their messages never come from the database, so they are used as the
+// detail.
+var knownErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{store.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{store.ErrConflict, http.StatusConflict, CodeConflict},
+	{store.ErrInvalid, http.StatusBadRequest, CodeInvalidRequest},
+	{store.ErrOutOfStock, http.StatusConflict, CodeOutOfStock},
+	{store.ErrFullyBooked, http.StatusConflict, CodeFullyBooked},
+	{store.ErrSlotFull, http.StatusConflict, CodeSlotFull},
+	{store.ErrInvalidTransition, http.StatusConflict, CodeInvalidTransition},
+	{store.ErrActiveReservations, http.StatusConflict, CodeActiveReservations},
+	{store.ErrStale, http.StatusPreconditionFailed, CodePreconditionFailed},
+	{bulk.ErrMalformed, http.StatusBadRequest, CodeInvalidRequest},
+}
+
+// toProblem turns any error a handler returns into a response. Errors it
+// doesn't recognise are database or programming failures: the client gets a
+// generic 500 and the error itself is only logged.
+func toProblem(err error) *problem {
+	var p *problem
+	if errors.As(err, &p) {
+		return p
+	}
+	var ve validate.Errors
+	if errors.As(err, &ve) {
+		p := newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, "request body has invalid fields")
+		p.Errors = ve
+		return p
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		detail, ok := he.Message.(string)
+		if !ok {
+			detail = http.StatusText(he.Code)
+		}
+		return newProblem(he.Code, statusCode(he.Code), detail)
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return newProblem(http.StatusGatewayTimeout, CodeTimeout, "database timed out")
+	case errors.Is(err, context.Canceled):
+		return newProblem(http.StatusServiceUnavailable, CodeUnavailable, "request cancelled")
+	}
+	for _, k := range knownErrors {
+		if errors.Is(err, k.err) {
+			return newProblem(k.status, k.code, err.Error())
+		}
+	}
+	return newProblem(http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+// statusCode names the errors Echo and its middleware raise themselves, such
+// as unknown routes and missing tokens, after their status.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// ErrorHandler is the Echo HTTPErrorHandler. It writes every error as
+// application/problem+json.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	p := *toProblem(err)
+	p.Instance = c.Request().URL.Path
+	p.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/problem+json")
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.Status)
+	} else {
+		err = c.JSON(p.Status, p)
+	}
+	if err != nil {
+		ctx := c.Request().Context()
+		logger.FromContext(ctx).Error("Failed to write error response", "error", err)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"farm/internal/bulk"
+	"farm/internal/store"
+	"farm/internal/validate"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestToProblem(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{fmt.Errorf("product %w", store.ErrNotFound), http.StatusNotFound, CodeNotFound, "product not found"},
+		{store.ErrConflict, http.StatusConflict, CodeConflict, "already exists"},
+		{store.ErrInvalid, http.StatusBadRequest, CodeInvalidRequest, "invalid request"},
+		{store.ErrOutOfStock, http.StatusConflict, CodeOutOfStock, "product out of stock"},
+		{store.ErrFullyBooked, http.StatusConflict, CodeFullyBooked, "activity fully booked"},
+		{store.ErrSlotFull, http.StatusConflict, CodeSlotFull, "pickup slot full"},
+		{store.ErrInvalidTransition, http.StatusConflict, CodeInvalidTransition, "invalid reservation status transition"},
+		{store.ErrActiveReservations, http.StatusConflict, CodeActiveReservations, "active reservations exist"},
+		{store.CheckVersion("product", 3, 2), http.StatusPreconditionFailed, CodePreconditionFailed, "product has been modified since it was read (now version 3)"},
+		{fmt.Errorf("%w: bare quote", bulk.ErrMalformed), http.StatusBadRequest, CodeInvalidRequest, "malformed input: bare quote"},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeTimeout, "database timed out"},
+		{context.Canceled, http.StatusServiceUnavailable, CodeUnavailable, "request cancelled"},
+		{echo.ErrNotFound, http.StatusNotFound, CodeNotFound, "Not Found"},
+		{echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt"), http.StatusUnauthorized, CodeUnauthorized, "missing or malformed jwt"},
+		{echo.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type", "Unsupported Media Type"},
+		{echo.NewHTTPError(http.StatusBadGateway), http.StatusBadGateway, CodeInternal, "Bad Gateway"},
+		{badRequest("invalid limit"), http.StatusBadRequest, CodeInvalidRequest, "invalid limit"},
+		{validate.Errors{{Field: "name", Message: "is required"}}, http.StatusUnprocessableEntity, CodeValidationFailed, "request body has invalid fields"},
+		// Database errors aren't shown
+		{fmt.Errorf("insert: %w", sql.ErrConnDone), http.StatusInternalServerError, CodeInternal, "internal server error"},
+		{errors.New(`pq: relation "products" does not exist`), http.StatusInternalServerError, CodeInternal, "internal server error"},
+	}
+	for _, tt := range tests {
+		p := toProblem(tt.err)
+		if p.Status != tt.status || p.Code != tt.code || p.Detail != tt.detail {
+			t.Errorf("toProblem(%v) = %d %s %q; want %d %s %q", tt.err, p.Status, p.Code, p.Detail, tt.status, tt.code, tt.detail)
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/products", nil)
+	rec := httptest.NewRecorder()
+	rec.Header().Set(echo.HeaderXRequestID, "req-1")
+	c := echo.New().NewContext(req, rec)
+
+	ErrorHandler(validate.Errors{{Field: "name", Message: "is required"}}, c)
+	if rec.Code != http.StatusUnprocessableEntity || rec.Header().Get(echo.HeaderContentType) != "application/problem+json" {
+		t.Errorf("response %d %s", rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["instance"] != "/api/admin/products" || body["request_id"] != "req-1" || body["code"] != CodeValidationFailed || body["type"] != "about:blank" {
+		t.Errorf("problem = %v", body)
+	}
+	if errs, _ := body["errors"].([]any); len(errs) != 1 {
+		t.Errorf("problem errors = %v, want one", body["errors"])
+	}
+
+	// Nothing is written once the response has started
+	ErrorHandler(store.ErrNotFound, c)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("second error changed the status to %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	ErrorHandler(store.ErrNotFound, echo.New().NewContext(httptest.NewRequest(http.MethodHead, "/api/products/eggs", nil), rec))
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("HEAD response = %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+}
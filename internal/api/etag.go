@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Products, activities and customers carry a version that every change
+// increments. Reads send it as a strong ETag, and admin updates must send it
+// back in If-Match, so two admins editing the same record can't silently
+// overwrite each other.
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+func setETag(c echo.Context, version int) {
+	c.Response().Header().Set(headerETag, strconv.Quote(strconv.Itoa(version)))
+}
+
+// ifMatch returns the version the request's If-Match header names, or 0 for
+// "*", which matches any version.
+func ifMatch(c echo.Context) (int, error) {
+	h := c.Request().Header.Get(headerIfMatch)
+	switch h {
+	case "":
+		return 0, newProblem(http.StatusPreconditionRequired, CodePreconditionRequired,
+			"If-Match is required; send the ETag from your last read")
+	case "*":
+		return 0, nil
+	}
+	s, err := strconv.Unquote(h)
+	if err != nil {
+		return 0, newProblem(http.StatusPreconditionFailed, CodePreconditionFailed, "If-Match must be a single ETag")
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 {
+		return 0, newProblem(http.StatusPreconditionFailed, CodePreconditionFailed, "If-Match doesn't name a version of this record")
+	}
+	return v, nil
+}
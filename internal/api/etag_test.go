@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"farm/internal/validate"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int
+		status  int
+		code    string
+	}{
+		{"", 0, http.StatusPreconditionRequired, CodePreconditionRequired},
+		{"*", 0, 0, ""},
+		{`"3"`, 3, 0, ""},
+		{`"12"`, 12, 0, ""},
+		{"3", 0, http.StatusPreconditionFailed, CodePreconditionFailed},        // Unquoted
+		{`W/"3"`, 0, http.StatusPreconditionFailed, CodePreconditionFailed},    // Weak tags can't match
+		{`"1", "2"`, 0, http.StatusPreconditionFailed, CodePreconditionFailed}, // Lists aren't supported
+		{`"0"`, 0, http.StatusPreconditionFailed, CodePreconditionFailed},      // Versions start at 1
+		{`"-1"`, 0, http.StatusPreconditionFailed, CodePreconditionFailed},
+		{`"eggs"`, 0, http.StatusPreconditionFailed, CodePreconditionFailed},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPatch, "/", nil)
+		if tt.header != "" {
+			req.Header.Set(headerIfMatch, tt.header)
+		}
+		v, err := ifMatch(echo.New().NewContext(req, httptest.NewRecorder()))
+		if tt.status == 0 {
+			if err != nil || v != tt.version {
+				t.Errorf("ifMatch(%s) = %d, %v; want %d", tt.header, v, err, tt.version)
+			}
+			continue
+		}
+		if p := toProblem(err); p.Status != tt.status || p.Code != tt.code {
+			t.Errorf("ifMatch(%s) = %v; want %d %s", tt.header, err, tt.status, tt.code)
+		}
+	}
+}
+
+func TestSetETag(t *testing.T) {
+	rec := httptest.NewRecorder()
+	setETag(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), 7)
+	if got := rec.Header().Get(headerETag); got != `"7"` {
+		t.Errorf("ETag = %s, want \"7\"", got)
+	}
+}
+
+func TestAdjustStock(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{}
+	s := memory.NewMemoryStore(cfg)
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Quantity: 2}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(s, cfg, events.NewBus(8), nil)
+
+	adjust := func(body string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/products/eggs/adjust", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c, rec := newContext(req, "admin", models.RoleAdmin)
+		c.Echo().Validator = validate.New(config.PasswordPolicy{})
+		c.SetParamNames("id")
+		c.SetParamValues("eggs")
+		return rec, h.AdjustStock(c)
+	}
+
+	// No If-Match needed, whatever the version is now
+	rec, err := adjust(`{"adjust":3}`)
+	if err != nil || rec.Header().Get(headerETag) != `"2"` {
+		t.Fatalf("adjust = %v with ETag %s", err, rec.Header().Get(headerETag))
+	}
+	if _, err := adjust(`{"adjust":-6}`); toProblem(err).Code != CodeOutOfStock {
+		t.Errorf("removing more than left = %v, want out of stock", err)
+	}
+	if _, err := adjust(`{"adjust":0}`); toProblem(err).Status != http.StatusUnprocessableEntity {
+		t.Errorf("zero adjustment = %v, want 422", err)
+	}
+	if p, _ := s.GetProduct(ctx, "eggs"); p.Quantity != 5 {
+		t.Errorf("quantity = %d, want 5", p.Quantity)
+	}
+}
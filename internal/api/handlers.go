@@ -3,21 +3,30 @@ package api
 import (
 	"farm/internal/auth"
 	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/metrics"
 	"farm/internal/models"
 	"farm/internal/store"
+	"farm/internal/validate"
 	"net/http"
+	"sync"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
-	store  store.Repository
-	config *config.Config
+	store   store.Repository
+	config  *config.Config
+	bus     *events.Bus
+	metrics *metrics.Metrics // nil when metrics are disabled
+
+	draining  chan struct{} // Closed by Drain
+	drainOnce sync.Once
 }
 
-func NewHandler(store store.Repository, cfg *config.Config) *Handler {
-	return &Handler{store: store, config: cfg}
+func NewHandler(store store.Repository, cfg *config.Config, bus *events.Bus, m *metrics.Metrics) *Handler {
+	return &Handler{store: store, config: cfg, bus: bus, metrics: m, draining: make(chan struct{})}
 }
 
 // --- Middleware Helpers ---
@@ -27,8 +36,39 @@ func (h *Handler) AdminOnly(next echo.HandlerFunc) echo.HandlerFunc {
 		user := c.Get("user").(*jwt.Token)
 		claims := user.Claims.(*auth.JWTClaims)
 		if claims.Role != models.RoleAdmin {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": "admin access required"})
+			return newProblem(http.StatusForbidden, CodeForbidden, "admin access required")
+		}
+		return next(c)
+	}
+}
+
+func (h *Handler) StaffOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(*auth.JWTClaims)
+		if claims.Role != models.RoleAdmin && claims.Role != models.RoleStaff {
+			return newProblem(http.StatusForbidden, CodeForbidden, "staff access required")
 		}
 		return next(c)
 	}
 }
+
+// Session tells the store which customer a request is made for, so reads
+// right after their own writes see them even when other reads go to replicas.
+func (h *Handler) Session(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims := c.Get("user").(*jwt.Token).Claims.(*auth.JWTClaims)
+		req := c.Request()
+		c.SetRequest(req.WithContext(store.WithCustomer(req.Context(), claims.UserID)))
+		return next(c)
+	}
+}
+
+// bind decodes the request into req and runs its validation, so handlers only
+// ever see payloads that passed.
+func bind(c echo.Context, req validate.Validatable) error {
+	if err := c.Bind(req); err != nil {
+		return badRequest("invalid request")
+	}
+	return c.Validate(req)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"farm/internal/logger"
+	"farm/internal/version"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// Drain marks the server as shutting down: /readyz starts failing so load
+// balancers stop sending traffic, and open event streams are closed.
+func (h *Handler) Drain() {
+	h.drainOnce.Do(func() { close(h.draining) })
+}
+
+func (h *Handler) isDraining() bool {
+	select {
+	case <-h.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// Healthz reports that the process is up and serving requests.
+func (h *Handler) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the server should receive traffic: it isn't shutting
+// down, the database answers and its schema is fully migrated.
+func (h *Handler) Readyz(c echo.Context) error {
+	checks := map[string]string{}
+	causes := map[string]string{} // Logged, not shown
+	ready := true
+	fail := func(name, msg string, err error) {
+		checks[name] = msg
+		if err != nil {
+			causes[name] = err.Error()
+		}
+		ready = false
+	}
+
+	if h.isDraining() {
+		fail("shutdown", "server is shutting down", nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessTimeout)
+	defer cancel()
+	if err := h.store.Ping(ctx); err != nil {
+		fail("database", "unreachable", err)
+	} else {
+		checks["database"] = "ok"
+		applied, latest, err := h.store.MigrationStatus(ctx)
+		switch {
+		case err != nil:
+			fail("migrations", "status unavailable", err)
+		case applied != latest:
+			fail("migrations", fmt.Sprintf("schema at version %d, expected %d", applied, latest), nil)
+		default:
+			checks["migrations"] = "ok"
+		}
+	}
+
+	if !ready {
+		logger.FromContext(ctx).Warn("Not ready", "checks", checks, "errors", causes)
+		return c.JSON(http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "checks": checks})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"status": "ok", "checks": checks})
+}
+
+func (h *Handler) Version(c echo.Context) error {
+	return c.JSON(http.StatusOK, version.Get())
+}
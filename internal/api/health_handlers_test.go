@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/store"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// probed is a store whose health checks report what the test sets.
+type probed struct {
+	store.Repository
+	pingErr         error
+	applied, latest int
+}
+
+func (s *probed) Ping(ctx context.Context) error { return s.pingErr }
+
+func (s *probed) MigrationStatus(ctx context.Context) (int, int, error) {
+	return s.applied, s.latest, nil
+}
+
+func TestReadyz(t *testing.T) {
+	cfg := &config.Config{}
+	tests := []struct {
+		name   string
+		store  *probed
+		drain  bool
+		status int
+		checks map[string]string
+	}{
+		{"ready", &probed{applied: 3, latest: 3}, false, http.StatusOK, map[string]string{"database": "ok", "migrations": "ok"}},
+		{"database down", &probed{pingErr: errors.New("dial tcp 10.0.0.5:5432: connection refused")}, false, http.StatusServiceUnavailable, map[string]string{"database": "unreachable"}},
+		{"migrations pending", &probed{applied: 2, latest: 3}, false, http.StatusServiceUnavailable, map[string]string{"database": "ok", "migrations": "schema at version 2, expected 3"}},
+		{"draining", &probed{applied: 3, latest: 3}, true, http.StatusServiceUnavailable, map[string]string{"shutdown": "server is shutting down", "database": "ok", "migrations": "ok"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.store.Repository = memory.NewMemoryStore(cfg)
+			h := NewHandler(tt.store, cfg, events.NewBus(8), nil)
+			if tt.drain {
+				h.Drain()
+				h.Drain()
+			}
+			rec := httptest.NewRecorder()
+			if err := h.Readyz(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/readyz", nil), rec)); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if strings.Contains(rec.Body.String(), "10.0.0.5") {
+				t.Errorf("body %s shows the database error", rec.Body)
+			}
+			var body struct {
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Checks) != len(tt.checks) {
+				t.Errorf("checks = %v, want %v", body.Checks, tt.checks)
+			}
+			for k, v := range tt.checks {
+				if body.Checks[k] != v {
+					t.Errorf("checks = %v, want %v", body.Checks, tt.checks)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"farm/internal/auth"
+	"farm/internal/logger"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// replayedHeaders are the response headers stored with an idempotency key and
+// sent again on replay.
+var replayedHeaders = []string{echo.HeaderContentType, echo.HeaderContentDisposition, echo.HeaderLocation, headerETag}
+
+// Idempotent lets clients retry mutating requests safely. A request carrying
+// an Idempotency-Key header runs once per customer and key; retries within
+// the configured TTL get the stored response back with Idempotent-Replayed
+// set instead of running again. Reusing a key for a different request is
+// rejected, and server errors aren't stored so the request can be retried.
+func (h *Handler) Idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		key := req.Header.Get(headerIdempotencyKey)
+		if key == "" || !isMutating(req.Method) {
+			return next(c)
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return badRequest(fmt.Sprintf("%s must be at most %d characters", headerIdempotencyKey, maxIdempotencyKeyLength))
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return badRequest("invalid request")
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		claims := c.Get("user").(*jwt.Token).Claims.(*auth.JWTClaims)
+		now := time.Now()
+		k := &models.IdempotencyKey{
+			CustomerID:  claims.UserID,
+			Key:         key,
+			RequestHash: requestHash(req, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(time.Duration(h.config.Idempotency.TTL)),
+		}
+		ctx := req.Context()
+		existing, err := h.store.ClaimIdempotencyKey(ctx, k)
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return keyInUse()
+		case err != nil:
+			return err
+		case existing != nil:
+			return replay(c, existing, k.RequestHash)
+		}
+
+		rec := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = rec
+		err = next(c)
+		if err != nil {
+			// Write the error response here rather than in ErrorHandler
+			// later, so that it is recorded too
+			c.Error(err)
+		}
+
+		// The outcome is stored even if the client has gone away; that's
+		// when it will retry.
+		ctx = context.WithoutCancel(ctx)
+		status := c.Response().Status
+		if !c.Response().Committed || status >= http.StatusInternalServerError {
+			if rerr := h.store.ReleaseIdempotencyKey(ctx, k.CustomerID, k.Key); rerr != nil {
+				logger.FromContext(ctx).Error("Failed to release idempotency key", "error", rerr)
+			}
+			return err
+		}
+
+		k.Status = status
+		k.Headers = map[string]string{}
+		for _, name := range replayedHeaders {
+			if v := c.Response().Header().Get(name); v != "" {
+				k.Headers[name] = v
+			}
+		}
+		k.Body = rec.body.Bytes()
+		if cerr := h.store.CompleteIdempotencyKey(ctx, k); cerr != nil {
+			logger.FromContext(ctx).Error("Failed to store idempotent response", "error", cerr)
+		}
+		return err
+	}
+}
+
+// replay answers a retried request with the response stored for its key.
+func replay(c echo.Context, k *models.IdempotencyKey, hash string) error {
+	switch {
+	case k.RequestHash != hash:
+		return newProblem(http.StatusUnprocessableEntity, CodeIdempotencyKeyReused,
+			"Idempotency-Key was already used for a different request")
+	case k.Status == 0:
+		return keyInUse()
+	}
+
+	header := c.Response().Header()
+	for name, v := range k.Headers {
+		header.Set(name, v)
+	}
+	header.Set(headerIdempotentReplayed, "true")
+	if len(k.Body) == 0 {
+		return c.NoContent(k.Status)
+	}
+	header.Set(echo.HeaderContentLength, strconv.Itoa(len(k.Body)))
+	c.Response().WriteHeader(k.Status)
+	_, err := c.Response().Write(k.Body)
+	return err
+}
+
+func keyInUse() *problem {
+	return newProblem(http.StatusConflict, CodeIdempotencyKeyInUse,
+		"a request with this Idempotency-Key is still in progress")
+}
+
+// requestHash fingerprints a request so a key can't be replayed for another.
+func requestHash(req *http.Request, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"farm/internal/auth"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func TestIdempotent(t *testing.T) {
+	cfg := &config.Config{Idempotency: config.IdempotencyConfig{TTL: config.Duration(time.Hour)}}
+	h := NewHandler(memory.NewMemoryStore(cfg), cfg, events.NewBus(8), nil)
+
+	var (
+		runs    atomic.Int32
+		fail    atomic.Bool
+		started = make(chan struct{})
+		finish  = make(chan struct{})
+	)
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user", &jwt.Token{Claims: &auth.JWTClaims{UserID: c.Request().Header.Get("X-User")}})
+			return next(c)
+		}
+	})
+	e.POST("/reservations", func(c echo.Context) error {
+		n := runs.Add(1)
+		if fail.Load() {
+			return errors.New("database is down")
+		}
+		c.Response().Header().Set(echo.HeaderLocation, "/reservations/1")
+		return c.JSON(http.StatusCreated, map[string]int32{"run": n})
+	}, h.Idempotent)
+	e.POST("/slow", func(c echo.Context) error {
+		close(started)
+		<-finish
+		return c.NoContent(http.StatusNoContent)
+	}, h.Idempotent)
+
+	post := func(target, user, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-User", user)
+		if key != "" {
+			req.Header.Set(headerIdempotencyKey, key)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post("/reservations", "alice", "k1", `{"product_id":"eggs"}`)
+	again := post("/reservations", "alice", "k1", `{"product_id":"eggs"}`)
+	if runs.Load() != 1 {
+		t.Fatalf("handler ran %d times, want once", runs.Load())
+	}
+	if first.Code != http.StatusCreated || first.Header().Get(headerIdempotentReplayed) != "" {
+		t.Errorf("first response = %d, replayed %q", first.Code, first.Header().Get(headerIdempotentReplayed))
+	}
+	if again.Code != first.Code || again.Body.String() != first.Body.String() ||
+		again.Header().Get(echo.HeaderLocation) != "/reservations/1" || again.Header().Get(headerIdempotentReplayed) != "true" {
+		t.Errorf("replay = %d %s %v; want the first response", again.Code, again.Body, again.Header())
+	}
+
+	// Keys belong to one customer, and requests without one always run
+	post("/reservations", "bob", "k1", `{"product_id":"eggs"}`)
+	post("/reservations", "alice", "", `{"product_id":"eggs"}`)
+	if runs.Load() != 3 {
+		t.Errorf("handler ran %d times, want 3", runs.Load())
+	}
+
+	rec := post("/reservations", "alice", "k1", `{"product_id":"milk"}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), CodeIdempotencyKeyReused) {
+		t.Errorf("key reused for another body = %d %s", rec.Code, rec.Body)
+	}
+	if rec := post("/reservations", "alice", strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("long key = %d, want 400", rec.Code)
+	}
+
+	// Server errors release the key so the retry runs
+	fail.Store(true)
+	if rec := post("/reservations", "alice", "k2", `{}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("failing request = %d, want 500", rec.Code)
+	}
+	fail.Store(false)
+	before := runs.Load()
+	if rec := post("/reservations", "alice", "k2", `{}`); rec.Code != http.StatusCreated || runs.Load() != before+1 {
+		t.Errorf("retry after 500 = %d, ran %d times", rec.Code, runs.Load()-before)
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		if rec := post("/slow", "alice", "k3", `{}`); rec.Code != http.StatusNoContent {
+			t.Errorf("slow request = %d", rec.Code)
+		}
+	})
+	<-started
+	rec = post("/slow", "alice", "k3", `{}`)
+	close(finish)
+	wg.Wait()
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), CodeIdempotencyKeyInUse) {
+		t.Errorf("retry while in progress = %d %s", rec.Code, rec.Body)
+	}
+	if rec := post("/slow", "alice", "k3", `{}`); rec.Code != http.StatusNoContent || rec.Header().Get(headerIdempotentReplayed) != "true" {
+		t.Errorf("retry after completion = %d, replayed %q", rec.Code, rec.Header().Get(headerIdempotentReplayed))
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"farm/internal/auth"
+	"farm/internal/models"
+	"farm/internal/validate"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handler) ListMyNotifications(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	list, err := h.store.GetNotifications(ctx, claims.UserID, c.QueryParam("unread") == "true")
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []*models.Notification{}
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func (h *Handler) MarkNotificationRead(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	if err := h.store.MarkNotificationRead(ctx, claims.UserID, c.Param("id")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) MarkAllNotificationsRead(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	if err := h.store.MarkAllNotificationsRead(ctx, claims.UserID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+type preferencesRequest struct {
+	NotifyEmail *bool `json:"notify_email"`
+	NotifyInApp *bool `json:"notify_in_app"`
+}
+
+// Validate accepts any combination: omitted preferences are left unchanged.
+func (r *preferencesRequest) Validate(*validate.Checker) {}
+
+func (h *Handler) UpdateMyPreferences(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	var req preferencesRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	customer, err := h.store.GetCustomer(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if req.NotifyEmail != nil {
+		customer.NotifyEmail = *req.NotifyEmail
+	}
+	if req.NotifyInApp != nil {
+		customer.NotifyInApp = *req.NotifyInApp
+	}
+
+	updated, err := h.store.UpdateCustomerPreferences(ctx, customer.ID, customer.NotifyEmail, customer.NotifyInApp)
+	if err != nil {
+		return err
+	}
+	updated.Password = ""
+	updated.Salt = ""
+	return c.JSON(http.StatusOK, updated)
+}
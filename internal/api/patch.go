@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"farm/internal/validate"
+	"io"
+	"mime"
+
+	"github.com/labstack/echo/v4"
+)
+
+// mergePatchType is the media type of an RFC 7396 JSON Merge Patch. Plain
+// application/json is accepted too.
+const mergePatchType = "application/merge-patch+json"
+
+// readMergePatch reads a merge patch from the request body. Only objects make
+// sense as patches for a record, and fields in readOnly are rejected rather
+// than silently ignored.
+func readMergePatch(c echo.Context, readOnly ...string) (map[string]any, error) {
+	mt, _, err := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if err != nil || (mt != mergePatchType && mt != echo.MIMEApplicationJSON) {
+		return nil, echo.ErrUnsupportedMediaType
+	}
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return nil, badRequest("invalid request")
+	}
+	var patch map[string]any
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, badRequest("merge patch must be a JSON object")
+	}
+	var errs validate.Errors
+	for _, f := range readOnly {
+		if _, ok := patch[f]; ok {
+			errs = append(errs, &validate.FieldError{Field: f, Message: "cannot be changed"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return patch, nil
+}
+
+// applyMergePatch applies patch to *v: supplied fields replace the current
+// values, null resets a field to its zero value, and everything else is left
+// alone. Unknown fields are an error.
+func applyMergePatch[T any](v *T, patch map[string]any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if b, err = json.Marshal(mergeObject(doc, patch)); err != nil {
+		return err
+	}
+
+	// Decode into a fresh value so removed fields end up zero
+	var merged T
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&merged); err != nil {
+		return badRequest("invalid merge patch: " + err.Error())
+	}
+	*v = merged
+	return nil
+}
+
+// mergeObject implements the merge step of RFC 7396 for one object.
+func mergeObject(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = map[string]any{}
+	}
+	for k, pv := range patch {
+		switch pv := pv.(type) {
+		case nil:
+			delete(target, k)
+		case map[string]any:
+			tv, _ := target[k].(map[string]any)
+			target[k] = mergeObject(tv, pv)
+		default:
+			target[k] = pv
+		}
+	}
+	return target
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"farm/internal/validate"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func object(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMergeObject(t *testing.T) {
+	tests := []struct {
+		name, target, patch, want string
+	}{
+		{"replace", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"null removes", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"null for missing field", `{"a":"b"}`, `{"c":null}`, `{"a":"b"}`},
+		{"nested", `{"a":{"b":"c","d":"e"}}`, `{"a":{"b":"x","d":null}}`, `{"a":{"b":"x"}}`},
+		{"object replaces scalar", `{"a":"b"}`, `{"a":{"c":"d","e":null}}`, `{"a":{"c":"d"}}`},
+		{"array replaced whole", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"empty patch", `{"a":"b"}`, `{}`, `{"a":"b"}`},
+	}
+	for _, tt := range tests {
+		if got, want := mergeObject(object(t, tt.target), object(t, tt.patch)), object(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	eggs := models.Product{ID: "eggs", Name: "Eggs", Description: "Free range", Quantity: 12, Visible: true, Version: 3}
+	tests := []struct {
+		name  string
+		patch string
+		want  models.Product
+		err   string
+	}{
+		{"change", `{"quantity":6,"visible":false}`, models.Product{ID: "eggs", Name: "Eggs", Description: "Free range", Quantity: 6, Version: 3}, ""},
+		{"null zeroes", `{"description":null}`, models.Product{ID: "eggs", Name: "Eggs", Quantity: 12, Visible: true, Version: 3}, ""},
+		{"unknown field", `{"colour":"brown"}`, eggs, `invalid merge patch: json: unknown field "colour"`},
+		{"wrong type", `{"quantity":"six"}`, eggs, "invalid merge patch"},
+	}
+	for _, tt := range tests {
+		p := eggs
+		err := applyMergePatch(&p, object(t, tt.patch))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.HasPrefix(toProblem(err).Detail, tt.err)) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+		}
+		if p != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, p, tt.want)
+		}
+	}
+}
+
+func TestReadMergePatch(t *testing.T) {
+	tests := []struct {
+		name, contentType, body string
+		status                  int
+	}{
+		{"merge patch", "application/merge-patch+json", `{"name":"Eggs"}`, 0},
+		{"json", "application/json; charset=utf-8", `{"name":"Eggs"}`, 0},
+		{"no content type", "", `{"name":"Eggs"}`, http.StatusUnsupportedMediaType},
+		{"json patch", "application/json-patch+json", `[{"op":"remove","path":"/name"}]`, http.StatusUnsupportedMediaType},
+		{"array", "application/merge-patch+json", `[]`, http.StatusBadRequest},
+		{"null", "application/merge-patch+json", `null`, http.StatusBadRequest},
+		{"malformed", "application/merge-patch+json", `{"name":`, http.StatusBadRequest},
+		{"read-only", "application/merge-patch+json", `{"id":"milk","version":null,"name":"Milk"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.body))
+		req.Header.Set(echo.HeaderContentType, tt.contentType)
+		_, err := readMergePatch(echo.New().NewContext(req, httptest.NewRecorder()), "id", "version")
+		if tt.status == 0 && err != nil || tt.status != 0 && (err == nil || toProblem(err).Status != tt.status) {
+			t.Errorf("%s: error = %v, want status %d", tt.name, err, tt.status)
+		}
+	}
+
+	// Every read-only field supplied is reported
+	req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"id":"milk","version":null}`))
+	req.Header.Set(echo.HeaderContentType, mergePatchType)
+	_, err := readMergePatch(echo.New().NewContext(req, httptest.NewRecorder()), "id", "deleted_at", "version")
+	var errs validate.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Field != "id" || errs[1].Field != "version" {
+		t.Errorf("read-only fields reported as %v", err)
+	}
+}
+
+func TestPatchProduct(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{}
+	s := memory.NewMemoryStore(cfg)
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Description: "Free range", Quantity: 12}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(s, cfg, events.NewBus(8), nil)
+
+	patch := func(ifMatch, body string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPatch, "/api/admin/products/eggs", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, mergePatchType)
+		if ifMatch != "" {
+			req.Header.Set(headerIfMatch, ifMatch)
+		}
+		c, rec := newContext(req, "admin", models.RoleAdmin)
+		c.Echo().Validator = validate.New(config.PasswordPolicy{})
+		c.SetParamNames("id")
+		c.SetParamValues("eggs")
+		return rec, h.PatchProduct(c)
+	}
+
+	rec, err := patch(`"1"`, `{"quantity":6,"description":null}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p models.Product
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Quantity != 6 || p.Description != "" || p.Name != "Eggs" || p.Version != 2 || rec.Header().Get(headerETag) != `"2"` {
+		t.Errorf("patched to %+v with ETag %s", p, rec.Header().Get(headerETag))
+	}
+
+	for _, tt := range []struct {
+		name, ifMatch, body string
+		status              int
+	}{
+		{"no If-Match", "", `{"quantity":1}`, http.StatusPreconditionRequired},
+		{"stale", `"1"`, `{"quantity":1}`, http.StatusPreconditionFailed},
+		{"read-only", `"2"`, `{"version":9}`, http.StatusUnprocessableEntity},
+		{"invalid result", `"2"`, `{"name":null}`, http.StatusUnprocessableEntity},
+		{"unknown field", "*", `{"colour":"brown"}`, http.StatusBadRequest},
+	} {
+		if _, err := patch(tt.ifMatch, tt.body); toProblem(err).Status != tt.status {
+			t.Errorf("%s: error = %v, want status %d", tt.name, err, tt.status)
+		}
+	}
+	if got, _ := s.GetProduct(ctx, "eggs"); got.Quantity != 6 || got.Version != 2 {
+		t.Errorf("failed patches changed the product to %+v", got)
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"farm/internal/models"
+	"farm/internal/pickup"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// pickupDay parses the "date" query parameter (YYYY-MM-DD, server local
+// time), defaulting to today.
+func pickupDay(c echo.Context) (time.Time, error) {
+	date := c.QueryParam("date")
+	if date == "" {
+		y, m, d := time.Now().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.Local), nil
+	}
+	return time.ParseInLocation(time.DateOnly, date, time.Local)
+}
+
+func (h *Handler) ListPickupSlots(c echo.Context) error {
+	ctx := c.Request().Context()
+	day, err := pickupDay(c)
+	if err != nil {
+		return badRequest("invalid date")
+	}
+	slots, err := pickup.Slots(&h.config.Pickup, day)
+	if err != nil {
+		return err
+	}
+
+	type SlotAvailability struct {
+		*models.PickupSlot
+		Remaining *int `json:"remaining,omitempty"` // Omitted for windows without a capacity
+	}
+	list := make([]SlotAvailability, 0, len(slots))
+	for _, slot := range slots {
+		a := SlotAvailability{PickupSlot: slot}
+		if slot.Capacity > 0 {
+			booked, err := h.store.CountPickupReservations(ctx, slot.LocationID, slot.Start)
+			if err != nil {
+				return err
+			}
+			remaining := max(slot.Capacity-booked, 0)
+			a.Remaining = &remaining
+		}
+		list = append(list, a)
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+// PickupManifest lists the day's product reservations grouped by pickup slot,
+// so staff can prepare orders per collection window.
+func (h *Handler) PickupManifest(c echo.Context) error {
+	ctx := c.Request().Context()
+	day, err := pickupDay(c)
+	if err != nil {
+		return badRequest("invalid date")
+	}
+	slots, err := pickup.Slots(&h.config.Pickup, day)
+	if err != nil {
+		return err
+	}
+	reservations, err := h.store.GetReservationsByPickupRange(ctx, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	type ManifestEntry struct {
+		ReservationID string                   `json:"reservation_id"`
+		Status        models.ReservationStatus `json:"status"`
+		CustomerID    string                   `json:"customer_id"`
+		CustomerName  string                   `json:"customer_name"`
+		CustomerEmail string                   `json:"customer_email"`
+		ProductID     string                   `json:"product_id"`
+		ProductName   string                   `json:"product_name"`
+	}
+	type ManifestSlot struct {
+		*models.PickupSlot
+		Reservations []ManifestEntry `json:"reservations"`
+	}
+
+	manifest := make([]*ManifestSlot, 0, len(slots))
+	bySlot := make(map[string]*ManifestSlot)
+	// Keyed by instant: stores may read times back in another zone
+	slotKey := func(locationID string, start time.Time) string {
+		return locationID + "|" + start.UTC().Format(time.RFC3339)
+	}
+	for _, slot := range slots {
+		ms := &ManifestSlot{PickupSlot: slot, Reservations: []ManifestEntry{}}
+		manifest = append(manifest, ms)
+		bySlot[slotKey(slot.LocationID, slot.Start)] = ms
+	}
+
+	customers := make(map[string]*models.Customer)
+	products := make(map[string]*models.Product)
+	for _, r := range reservations {
+		key := slotKey(r.Pickup.LocationID, r.Pickup.Start)
+		ms, ok := bySlot[key]
+		if !ok {
+			// Slot no longer configured; keep the booking visible to staff.
+			ms = &ManifestSlot{PickupSlot: r.Pickup, Reservations: []ManifestEntry{}}
+			manifest = append(manifest, ms)
+			bySlot[key] = ms
+		}
+
+		entry := ManifestEntry{
+			ReservationID: r.ID,
+			Status:        r.Status,
+			CustomerID:    r.CustomerID,
+			ProductID:     r.ItemID,
+		}
+		customer, ok := customers[r.CustomerID]
+		if !ok {
+			customer, _ = h.store.GetCustomer(ctx, r.CustomerID)
+			customers[r.CustomerID] = customer
+		}
+		if customer != nil {
+			entry.CustomerName = customer.Name
+			entry.CustomerEmail = customer.Email
+		}
+		product, ok := products[r.ItemID]
+		if !ok {
+			product, _ = h.store.GetProduct(ctx, r.ItemID)
+			products[r.ItemID] = product
+		}
+		if product != nil {
+			entry.ProductName = product.Name
+		}
+		ms.Reservations = append(ms.Reservations, entry)
+	}
+	return c.JSON(http.StatusOK, manifest)
+}
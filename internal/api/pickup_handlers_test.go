@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListPickupSlots(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Pickup: config.PickupConfig{Locations: []config.PickupLocationConfig{{
+		ID: "barn",
+		Windows: []config.PickupWindowConfig{
+			{Start: "09:00", End: "10:00", Capacity: 2},
+			{Start: "16:00", End: "17:00"},
+		},
+	}}}}
+	s := memory.NewMemoryStore(cfg)
+	if err := s.AddCustomer(ctx, &models.Customer{ID: "alice", Name: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Quantity: 5}); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2030, 6, 3, 0, 0, 0, 0, time.Local)
+	err := s.ReserveItem(ctx, &models.Reservation{
+		ID: "r1", CustomerID: "alice", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: time.Now(),
+		Pickup: &models.PickupSlot{LocationID: "barn", Start: day.Add(9 * time.Hour), End: day.Add(10 * time.Hour), Capacity: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(s, cfg, events.NewBus(8), nil)
+
+	c, rec := newContext(httptest.NewRequest(http.MethodGet, "/api/pickups?date=2030-06-03", nil), "alice", models.RoleCustomer)
+	if err := h.ListPickupSlots(c); err != nil {
+		t.Fatal(err)
+	}
+	var slots []struct {
+		Capacity  int  `json:"capacity"`
+		Remaining *int `json:"remaining"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &slots); err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 2 || slots[0].Remaining == nil || *slots[0].Remaining != 1 || slots[1].Remaining != nil {
+		t.Errorf("slots = %s; want 1 place left in the morning and no limit in the afternoon", rec.Body)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"farm/internal/auth"
+	"farm/internal/logger"
+	"farm/internal/privacy"
+	"farm/internal/validate"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// ExportMe returns everything held about the caller, as JSON or, with
+// ?format=zip, as a ZIP download.
+func (h *Handler) ExportMe(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	bundle, err := privacy.Export(ctx, h.store, claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	switch c.QueryParam("format") {
+	case "", "json":
+		return c.JSON(http.StatusOK, bundle)
+	case "zip":
+		c.Response().Header().Set(echo.HeaderContentType, "application/zip")
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="farm-export.zip"`)
+		c.Response().WriteHeader(http.StatusOK)
+		return bundle.WriteZip(c.Response())
+	default:
+		return badRequest("format must be json or zip")
+	}
+}
+
+type deleteMeRequest struct {
+	Password string `json:"password"`
+}
+
+func (r *deleteMeRequest) Validate(c *validate.Checker) {
+	c.Required("password", r.Password)
+}
+
+// DeleteMe erases the caller's account after re-checking their password. With
+// a grace period configured the erasure is only scheduled and can be cancelled
+// until it runs.
+func (h *Handler) DeleteMe(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	var req deleteMeRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	customer, err := h.store.GetCustomer(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if !auth.CheckPasswordHash(req.Password, customer.Salt, customer.Password) {
+		return newProblem(http.StatusUnauthorized, CodeUnauthorized, "invalid password")
+	}
+
+	grace := time.Duration(h.config.Privacy.ErasureGracePeriod)
+	if grace == 0 {
+		if _, err := privacy.Erase(ctx, h.store, customer.ID); err != nil {
+			return err
+		}
+		logger.FromContext(ctx).Info("Customer account erased", "customer_id", customer.ID)
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	at := time.Now().Add(grace)
+	updated, err := h.store.ScheduleErasure(ctx, customer.ID, &at)
+	if err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("Customer account erasure scheduled", "customer_id", customer.ID, "erase_after", at)
+	updated.Password = ""
+	updated.Salt = ""
+	return c.JSON(http.StatusAccepted, updated)
+}
+
+// CancelErasure withdraws a pending account deletion request.
+func (h *Handler) CancelErasure(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	updated, err := h.store.ScheduleErasure(ctx, claims.UserID, nil)
+	if err != nil {
+		return err
+	}
+	updated.Password = ""
+	updated.Salt = ""
+	return c.JSON(http.StatusOK, updated)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"farm/internal/models"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultReportRange = 30 * 24 * time.Hour
+	defaultReportLimit = 10
+	maxReportLimit     = 1000
+)
+
+// reportFilter reads the query parameters shared by the reports: from and to
+// (to exclusive, defaulting to the last 30 days), period, type, rank and
+// limit.
+func reportFilter(c echo.Context) (models.ReportFilter, error) {
+	f := models.ReportFilter{Period: models.PeriodDay, Rank: -1, Limit: defaultReportLimit}
+
+	var err error
+	if f.From, err = queryTime(c.QueryParam("from")); err != nil {
+		return f, fmt.Errorf("invalid from")
+	}
+	if f.To, err = queryTime(c.QueryParam("to")); err != nil {
+		return f, fmt.Errorf("invalid to")
+	}
+	if f.To.IsZero() {
+		f.To = time.Now()
+	}
+	if f.From.IsZero() {
+		f.From = f.To.Add(-defaultReportRange)
+	}
+	if !f.From.Before(f.To) {
+		return f, fmt.Errorf("from must be before to")
+	}
+
+	switch p := c.QueryParam("period"); p {
+	case "":
+	case models.PeriodDay, models.PeriodWeek, models.PeriodMonth:
+		f.Period = p
+	default:
+		return f, fmt.Errorf("period must be day, week or month")
+	}
+
+	switch t := models.ReservationType(c.QueryParam("type")); t {
+	case "", models.ReservationProduct, models.ReservationActivity:
+		f.Type = t
+	default:
+		return f, fmt.Errorf("type must be product or activity")
+	}
+
+	if v := c.QueryParam("rank"); v != "" {
+		found := false
+		for _, r := range []models.Rank{models.RankBronze, models.RankSilver, models.RankGold} {
+			if strings.EqualFold(v, r.String()) || v == strconv.Itoa(int(r)) {
+				f.Rank, found = r, true
+			}
+		}
+		if !found {
+			return f, fmt.Errorf("rank must be bronze, silver or gold")
+		}
+	}
+
+	if v := c.QueryParam("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			return f, fmt.Errorf("invalid limit")
+		}
+		f.Limit = min(f.Limit, maxReportLimit)
+	}
+	return f, nil
+}
+
+// report runs a report and writes it as JSON or, with ?format=csv, as a CSV
+// download whose columns are the JSON field names.
+func report[T any](c echo.Context, name string, run func(ctx context.Context, f models.ReportFilter) ([]T, error)) error {
+	f, err := reportFilter(c)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" {
+		return badRequest("format must be json or csv")
+	}
+
+	rows, err := run(c.Request().Context(), f)
+	if err != nil {
+		return err
+	}
+	if format != "csv" {
+		return c.JSON(http.StatusOK, rows)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+name+`.csv"`)
+	c.Response().WriteHeader(http.StatusOK)
+	w := csv.NewWriter(c.Response())
+	var zero T
+	if err := w.Write(csvHeader(reflect.TypeOf(zero))); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := w.Write(csvRecord(reflect.ValueOf(row))); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func csvHeader(t reflect.Type) []string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	header := make([]string, t.NumField())
+	for i := range header {
+		header[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	return header
+}
+
+func csvRecord(v reflect.Value) []string {
+	v = reflect.Indirect(v)
+	record := make([]string, v.NumField())
+	for i := range record {
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Int:
+			record[i] = strconv.FormatInt(f.Int(), 10) // Not Rank.String, to match the JSON
+		case reflect.Float64:
+			record[i] = strconv.FormatFloat(f.Float(), 'f', 4, 64)
+		default:
+			record[i] = fmt.Sprint(f.Interface())
+		}
+	}
+	return record
+}
+
+func (h *Handler) ReservationsReport(c echo.Context) error {
+	return report(c, "reservations", h.store.GetReservationCounts)
+}
+
+func (h *Handler) ActivityFillReport(c echo.Context) error {
+	return report(c, "activity-fill", h.store.GetActivityFill)
+}
+
+func (h *Handler) SellThroughReport(c echo.Context) error {
+	return report(c, "sell-through", h.store.GetProductSellThrough)
+}
+
+func (h *Handler) RankDistributionReport(c echo.Context) error {
+	return report(c, "ranks", func(ctx context.Context, _ models.ReportFilter) ([]*models.RankCount, error) {
+		return h.store.GetRankDistribution(ctx)
+	})
+}
+
+func (h *Handler) NoShowReport(c echo.Context) error {
+	return report(c, "no-shows", h.store.GetNoShowRates)
+}
+
+func (h *Handler) TopCustomersReport(c echo.Context) error {
+	return report(c, "top-customers", h.store.GetTopCustomers)
+}
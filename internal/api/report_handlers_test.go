@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReportFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  models.ReportFilter
+		err   string
+	}{
+		{
+			query: "from=2025-06-01T00:00:00Z&to=2025-07-01T00:00:00Z&period=week&type=activity&rank=silver&limit=5000",
+			want: models.ReportFilter{
+				From: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+				Period: models.PeriodWeek, Type: models.ReservationActivity, Rank: models.RankSilver, Limit: maxReportLimit,
+			},
+		},
+		{
+			query: "from=2025-06-01T00:00:00Z&to=2025-06-02T00:00:00Z&rank=2",
+			want: models.ReportFilter{
+				From: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+				Period: models.PeriodDay, Rank: models.RankGold, Limit: defaultReportLimit,
+			},
+		},
+		{query: "from=2025-06-02&to=2025-06-01", err: "from must be before to"},
+		{query: "from=soon", err: "invalid from"},
+		{query: "period=year", err: "period must be day, week or month"},
+		{query: "type=customer", err: "type must be product or activity"},
+		{query: "rank=platinum", err: "rank must be bronze, silver or gold"},
+		{query: "limit=0", err: "invalid limit"},
+	}
+	for _, tt := range tests {
+		c, _ := newContext(httptest.NewRequest(http.MethodGet, "/api/admin/reports/reservations?"+tt.query, nil), "admin", models.RoleAdmin)
+		f, err := reportFilter(c)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: err = %v, want %q", tt.query, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+		} else if f != tt.want {
+			t.Errorf("%s: filter = %+v, want %+v", tt.query, f, tt.want)
+		}
+	}
+
+	c, _ := newContext(httptest.NewRequest(http.MethodGet, "/api/admin/reports/reservations", nil), "admin", models.RoleAdmin)
+	f, err := reportFilter(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.To.Sub(f.From) != defaultReportRange || time.Since(f.To) > time.Minute || f.Rank != -1 {
+		t.Errorf("default filter = %+v; want the last 30 days and any rank", f)
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Ranks: config.RankConfig{BronzeMax: 99, SilverMax: 499}}
+	s := memory.NewMemoryStore(cfg)
+	for id, credits := range map[string]int{"alice": 0, "bob": 10, "carol": 200} {
+		c := &models.Customer{ID: id, Email: id + "@example.com", Name: id, Role: models.RoleCustomer, Credits: credits}
+		if err := s.AddCustomer(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandler(s, cfg, events.NewBus(8), nil)
+
+	c, rec := newContext(httptest.NewRequest(http.MethodGet, "/api/admin/reports/ranks?format=csv", nil), "admin", models.RoleAdmin)
+	if err := h.RankDistributionReport(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="ranks.csv"` {
+		t.Errorf("Content-Disposition = %s", got)
+	}
+	want := "rank,rank_name,customers\n0,Bronze,2\n1,Silver,1\n2,Gold,0\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("CSV = %q, want %q", got, want)
+	}
+
+	c, _ = newContext(httptest.NewRequest(http.MethodGet, "/api/admin/reports/ranks?format=xml", nil), "admin", models.RoleAdmin)
+	if err := h.RankDistributionReport(c); toProblem(err).Status != http.StatusBadRequest {
+		t.Errorf("format=xml: err = %v, want 400", err)
+	}
+}
@@ -1,8 +1,13 @@
 package api
 
 import (
+	"errors"
 	"farm/internal/auth"
+	"farm/internal/metrics"
 	"farm/internal/models"
+	"farm/internal/pickup"
+	"farm/internal/store"
+	"farm/internal/validate"
 	"net/http"
 	"time"
 
@@ -11,23 +16,47 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+type reservationRequest struct {
+	ItemID   string                 `json:"item_id"`
+	Type     models.ReservationType `json:"type"`
+	Waitlist bool                   `json:"waitlist"` // Join the waitlist if nothing is left
+	Pickup   *struct {
+		LocationID string    `json:"location_id"`
+		Start      time.Time `json:"start"`
+	} `json:"pickup"`
+}
+
+func (r *reservationRequest) Validate(c *validate.Checker) {
+	c.Required("item_id", r.ItemID)
+	validate.OneOf(c, "type", r.Type, models.ReservationProduct, models.ReservationActivity)
+	if r.Pickup == nil {
+		return
+	}
+	if r.Type == models.ReservationActivity {
+		c.Fail("pickup", "applies to product reservations only")
+	}
+	c.Required("pickup.location_id", r.Pickup.LocationID)
+	c.RequiredTime("pickup.start", r.Pickup.Start)
+}
+
 func (h *Handler) CreateReservation(c echo.Context) error {
+	ctx := c.Request().Context()
 	user := c.Get("user").(*jwt.Token)
 	claims := user.Claims.(*auth.JWTClaims)
 
-	type Request struct {
-		ItemID string                 `json:"item_id"`
-		Type   models.ReservationType `json:"type"`
-	}
-	var req Request
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	var req reservationRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
 	// Fetch customer to get rank - using ID from token
-	customer, err := h.store.GetCustomer(claims.UserID)
+	customer, err := h.store.GetCustomer(ctx, claims.UserID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		return err
+	}
+	if customer.Banned(time.Now()) {
+		return newProblem(http.StatusForbidden, CodeSuspended,
+			"reservations suspended until "+customer.BannedUntil.Format(time.RFC3339))
 	}
 
 	reservation := &models.Reservation{
@@ -37,23 +66,120 @@ func (h *Handler) CreateReservation(c echo.Context) error {
 		Type:         req.Type,
 		PriorityRank: customer.Rank,
 		Timestamp:    time.Now(),
-		Status:       "pending",
+		Status:       models.StatusPending,
+	}
+	if req.Waitlist {
+		reservation.Status = models.StatusWaitlist
 	}
 
-	if err := h.store.ReserveItem(reservation); err != nil {
-		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	if req.Pickup != nil {
+		slot, err := pickup.Find(&h.config.Pickup, req.Pickup.LocationID, req.Pickup.Start)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+		if slot.End.Before(time.Now()) {
+			return badRequest("pickup slot has already passed")
+		}
+		reservation.Pickup = slot
+	}
+
+	if err := h.store.ReserveItem(ctx, reservation); err != nil {
+		outcome := metrics.OutcomeRejected
+		if errors.Is(err, store.ErrOutOfStock) || errors.Is(err, store.ErrFullyBooked) {
+			outcome = metrics.OutcomeOutOfStock
+		}
+		h.metrics.ObserveReservation(string(req.Type), outcome)
+		return err
+	}
+	outcome := metrics.OutcomeConfirmed
+	if reservation.Status == models.StatusWaitlist {
+		outcome = metrics.OutcomeWaitlisted
 	}
+	h.metrics.ObserveReservation(string(reservation.Type), outcome)
 
 	return c.JSON(http.StatusCreated, reservation)
 }
 
 func (h *Handler) ListMyReservations(c echo.Context) error {
+	ctx := c.Request().Context()
 	user := c.Get("user").(*jwt.Token)
 	claims := user.Claims.(*auth.JWTClaims)
 
-	reservations, err := h.store.GetReservationsByCustomerID(claims.UserID)
+	reservations, err := h.store.GetReservationsByCustomerID(ctx, claims.UserID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, reservations)
 }
+
+func (h *Handler) CancelMyReservation(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	r, err := h.store.GetReservation(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	if r.CustomerID != claims.UserID {
+		return notFound("reservation not found")
+	}
+	return h.transitionReservation(c, r.ID, models.StatusCancelled)
+}
+
+func (h *Handler) GetReservation(c echo.Context) error {
+	ctx := c.Request().Context()
+	r, err := h.store.GetReservation(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func (h *Handler) CheckInReservation(c echo.Context) error {
+	return h.transitionReservation(c, c.Param("id"), models.StatusCheckedIn)
+}
+
+func (h *Handler) FulfilReservation(c echo.Context) error {
+	return h.transitionReservation(c, c.Param("id"), models.StatusFulfilled)
+}
+
+func (h *Handler) MarkNoShow(c echo.Context) error {
+	return h.transitionReservation(c, c.Param("id"), models.StatusNoShow)
+}
+
+type statusRequest struct {
+	Status models.ReservationStatus `json:"status"`
+}
+
+// Validate only checks the status is one the API knows; whether the
+// reservation can move to it is the store's decision.
+func (r *statusRequest) Validate(c *validate.Checker) {
+	validate.OneOf(c, "status", r.Status,
+		models.StatusPending, models.StatusConfirmed, models.StatusWaitlist, models.StatusCheckedIn,
+		models.StatusFulfilled, models.StatusCancelled, models.StatusNoShow, models.StatusExpired)
+}
+
+func (h *Handler) UpdateReservationStatus(c echo.Context) error {
+	var req statusRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+	return h.transitionReservation(c, c.Param("id"), req.Status)
+}
+
+func (h *Handler) transitionReservation(c echo.Context, id string, to models.ReservationStatus) error {
+	ctx := c.Request().Context()
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+
+	repo := h.store
+	if claims.Role != models.RoleCustomer {
+		repo = h.audited(c, models.AuditActionTransition, models.AuditTargetReservation, id)
+	}
+	r, err := repo.TransitionReservation(ctx, id, to, claims.UserID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, r)
+}
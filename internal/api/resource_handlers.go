@@ -1,41 +1,58 @@
 package api
 
 import (
+	"farm/internal/models"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 )
 
 func (h *Handler) ListProducts(c echo.Context) error {
-	products, err := h.store.GetAllProducts(true)
+	ctx := c.Request().Context()
+	products, err := h.store.GetAllProducts(ctx, true)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, products)
 }
 
 func (h *Handler) ListActivities(c echo.Context) error {
-	activities, err := h.store.GetAllActivities(true)
+	ctx := c.Request().Context()
+	activities, err := h.store.GetAllActivities(ctx, true)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, activities)
 }
 
 func (h *Handler) ListAllProducts(c echo.Context) error {
-	// Admin handler - returns all
-	products, err := h.store.GetAllProducts(false)
+	ctx := c.Request().Context()
+	// Admin handler - returns all, or only deleted ones with ?deleted=true
+	var products []*models.Product
+	var err error
+	if c.QueryParam("deleted") == "true" {
+		products, err = h.store.GetDeletedProducts(ctx)
+	} else {
+		products, err = h.store.GetAllProducts(ctx, false)
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, products)
 }
 
 func (h *Handler) ListAllActivities(c echo.Context) error {
-	// Admin handler - returns all
-	activities, err := h.store.GetAllActivities(false)
+	ctx := c.Request().Context()
+	// Admin handler - returns all, or only deleted ones with ?deleted=true
+	var activities []*models.Activity
+	var err error
+	if c.QueryParam("deleted") == "true" {
+		activities, err = h.store.GetDeletedActivities(ctx)
+	} else {
+		activities, err = h.store.GetAllActivities(ctx, false)
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, activities)
 }
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestDeleteAndRestoreProduct(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{}
+	s := memory.NewMemoryStore(cfg)
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Quantity: 1, Visible: true}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(s, cfg, events.NewBus(8), nil)
+
+	call := func(method, target string, handler echo.HandlerFunc) (*httptest.ResponseRecorder, error) {
+		c, rec := newContext(httptest.NewRequest(method, target, nil), "admin", models.RoleAdmin)
+		c.SetParamNames("id")
+		c.SetParamValues("eggs")
+		return rec, handler(c)
+	}
+	listed := func(target string) int {
+		t.Helper()
+		rec, err := call(http.MethodGet, target, h.ListAllProducts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var products []*models.Product
+		if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+			t.Fatal(err)
+		}
+		return len(products)
+	}
+
+	if rec, err := call(http.MethodDelete, "/api/admin/products/eggs", h.DeleteProduct); err != nil || rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d, %v", rec.Code, err)
+	}
+	if n := listed("/api/admin/products"); n != 0 {
+		t.Errorf("%d products listed after delete", n)
+	}
+	if n := listed("/api/admin/products?deleted=true"); n != 1 {
+		t.Errorf("%d deleted products listed, want 1", n)
+	}
+	if _, err := call(http.MethodDelete, "/api/admin/products/eggs", h.DeleteProduct); toProblem(err).Status != http.StatusNotFound {
+		t.Errorf("second delete = %v, want 404", err)
+	}
+
+	if rec, err := call(http.MethodPost, "/api/admin/products/eggs/restore", h.RestoreProduct); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("restore = %d, %v", rec.Code, err)
+	}
+	if n := listed("/api/admin/products"); n != 1 {
+		t.Errorf("%d products listed after restore, want 1", n)
+	}
+	if _, err := call(http.MethodPost, "/api/admin/products/eggs/restore", h.RestoreProduct); toProblem(err).Status != http.StatusNotFound {
+		t.Errorf("second restore = %v, want 404", err)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"farm/internal/auth"
+	"farm/internal/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// StreamInventory pushes stock and seat counts as Server-Sent Events. The
+// stream opens with a "snapshot" event holding every item, followed by an
+// "inventory" event per change. Customers only see visible items; an item
+// that is hidden or deleted is announced once with "removed".
+func (h *Handler) StreamInventory(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JWTClaims)
+	visibleOnly := claims.Role != models.RoleAdmin && claims.Role != models.RoleStaff
+
+	ctx := c.Request().Context()
+	sub := h.bus.Subscribe()
+	defer h.bus.Unsubscribe(sub)
+
+	products, err := h.store.GetAllProducts(ctx, visibleOnly)
+	if err != nil {
+		return err
+	}
+	activities, err := h.store.GetAllActivities(ctx, visibleOnly)
+	if err != nil {
+		return err
+	}
+	snapshot := make([]models.InventoryChange, 0, len(products)+len(activities))
+	for _, p := range products {
+		snapshot = append(snapshot, models.InventoryChange{
+			Type: models.ReservationProduct, ItemID: p.ID, Name: p.Name, Available: p.Quantity, Visible: p.Visible,
+		})
+	}
+	for _, a := range activities {
+		snapshot = append(snapshot, models.InventoryChange{
+			Type: models.ReservationActivity, ItemID: a.ID, Name: a.Name, Available: a.Capacity, Visible: a.Visible,
+		})
+	}
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeEvent(w, "", "snapshot", snapshot); err != nil {
+		return nil
+	}
+
+	// A nil channel never fires, so without a heartbeat nothing is sent
+	// between events.
+	var pings <-chan time.Time
+	if d := time.Duration(h.config.Stream.Heartbeat); d > 0 {
+		heartbeat := time.NewTicker(d)
+		defer heartbeat.Stop()
+		pings = heartbeat.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.draining:
+			// Shutting down; the client reconnects to another instance.
+			return nil
+		case <-pings:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			w.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// starts again from a fresh snapshot.
+				return nil
+			}
+			var change models.InventoryChange
+			if err := json.Unmarshal(e.Payload, &change); err != nil {
+				continue
+			}
+			id := fmt.Sprint(e.ID)
+			if visibleOnly && (change.Deleted || !change.Visible) {
+				err = writeEvent(w, id, "removed", map[string]any{"type": change.Type, "item_id": change.ItemID})
+			} else {
+				err = writeEvent(w, id, "inventory", change)
+			}
+			if err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func writeEvent(w *echo.Response, id, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"farm/internal/auth"
+	"farm/internal/config"
+	"farm/internal/events"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func TestStreamInventoryWithoutHeartbeat(t *testing.T) {
+	cfg := &config.Config{Stream: config.StreamConfig{Heartbeat: 0}}
+	s := memory.NewMemoryStore(cfg)
+	if err := s.AddProduct(context.Background(), &models.Product{ID: "eggs", Name: "Eggs", Quantity: 3, Visible: true}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(s, cfg, events.NewBus(8), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/inventory", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user", &jwt.Token{Claims: &auth.JWTClaims{UserID: "alice", Role: models.RoleCustomer}})
+
+	if err := h.StreamInventory(c); err != nil {
+		t.Fatal(err)
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "event: snapshot\n") || !strings.Contains(body, `"item_id":"eggs"`) {
+		t.Errorf("stream = %q, want a snapshot holding eggs", body)
+	}
+	if strings.Contains(body, ": ping") {
+		t.Errorf("stream = %q, want no heartbeat", body)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"farm/internal/models"
+	"farm/internal/validate"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+func (r *webhookRequest) Validate(c *validate.Checker) {
+	c.URL("url", r.URL, true)
+	for i, e := range r.Events {
+		validate.OneOf(c, fmt.Sprintf("events[%d]", i), e, models.EventTypes...)
+	}
+}
+
+func (h *Handler) CreateWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req webhookRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	if req.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("generating secret: %w", err)
+		}
+		req.Secret = hex.EncodeToString(b)
+	}
+
+	w := &models.Webhook{
+		ID:        uuid.New().String(),
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    req.Events,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if err := h.audited(c, models.AuditActionCreate, models.AuditTargetWebhook, w.ID).AddWebhook(ctx, w); err != nil {
+		return err
+	}
+	// The secret is only ever shown here
+	return c.JSON(http.StatusCreated, w)
+}
+
+func (h *Handler) ListWebhooks(c echo.Context) error {
+	ctx := c.Request().Context()
+	list, err := h.store.GetAllWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	// Sanitize secrets
+	for _, w := range list {
+		w.Secret = ""
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func (h *Handler) GetWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+	w, err := h.store.GetWebhook(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	w.Secret = ""
+	return c.JSON(http.StatusOK, w)
+}
+
+func (h *Handler) DeleteWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	if err := h.audited(c, models.AuditActionDelete, models.AuditTargetWebhook, id).DeleteWebhook(ctx, id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) ListWebhookDeliveries(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	if _, err := h.store.GetWebhook(ctx, id); err != nil {
+		return err
+	}
+
+	limit := 100
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			return badRequest("limit must be between 1 and 1000")
+		}
+		limit = n
+	}
+
+	list, err := h.store.GetWebhookDeliveries(ctx, id, limit)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []*models.WebhookDelivery{}
+	}
+	return c.JSON(http.StatusOK, list)
+}
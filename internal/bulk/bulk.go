@@ -0,0 +1,256 @@
+// Package bulk reads and writes products, activities and customers as CSV or
+// JSON Lines for spreadsheet-based catalogue maintenance.
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"farm/internal/models"
+	"farm/internal/validate"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Format string
+
+// ErrMalformed wraps errors from input that can't be parsed at all, such as
+// broken CSV quoting.
+var ErrMalformed = errors.New("malformed input")
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+// record is one parsed input row, keyed by column name. Absent columns and
+// JSON nulls are missing from fields.
+type record struct {
+	row    int
+	fields map[string]string
+}
+
+// readRecords parses r and returns its rows along with errors for rows that
+// couldn't be read at all. Every column must be in allowed.
+func readRecords(r io.Reader, format Format, allowed []string) ([]record, []*models.ImportRowError, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, allowed)
+	case FormatJSONL:
+		return readJSONL(r, allowed)
+	default:
+		return nil, nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func readCSV(r io.Reader, allowed []string) ([]record, []*models.ImportRowError, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1 // Checked per row below
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, []*models.ImportRowError{{Message: "missing header row"}}, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []*models.ImportRowError
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) // Spreadsheets often add a BOM
+		header[i] = name
+		if !slices.Contains(allowed, name) {
+			errs = append(errs, &models.ImportRowError{Field: name, Message: "unknown column"})
+		}
+	}
+	if errs != nil {
+		return nil, errs, nil
+	}
+
+	var records []record
+	for row := 1; ; row++ {
+		values, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(values) != len(header) {
+			errs = append(errs, &models.ImportRowError{Row: row, Message: fmt.Sprintf("has %d columns, header has %d", len(values), len(header))})
+			continue
+		}
+		rec := record{row: row, fields: make(map[string]string, len(header))}
+		for i, v := range values {
+			rec.fields[header[i]] = strings.TrimSpace(v)
+		}
+		records = append(records, rec)
+	}
+	return records, errs, nil
+}
+
+func readJSONL(r io.Reader, allowed []string) ([]record, []*models.ImportRowError, error) {
+	var records []record
+	var errs []*models.ImportRowError
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	row := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row++
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(line, &obj); err != nil {
+			errs = append(errs, &models.ImportRowError{Row: row, Message: "invalid JSON: " + err.Error()})
+			continue
+		}
+		rec := record{row: row, fields: make(map[string]string, len(obj))}
+		for name, raw := range obj {
+			if !slices.Contains(allowed, name) {
+				errs = append(errs, &models.ImportRowError{Row: row, Field: name, Message: "unknown field"})
+				continue
+			}
+			var s string
+			switch {
+			case string(raw) == "null":
+				continue
+			case len(raw) > 0 && raw[0] == '"':
+				if err := json.Unmarshal(raw, &s); err != nil {
+					errs = append(errs, &models.ImportRowError{Row: row, Field: name, Message: "invalid string"})
+					continue
+				}
+			default:
+				s = string(raw) // Numbers and booleans parse like their CSV text
+			}
+			rec.fields[name] = strings.TrimSpace(s)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return records, errs, nil
+}
+
+// fieldReader converts a record's fields, collecting an error for each one that
+// is missing or malformed.
+type fieldReader struct {
+	rec  record
+	errs []*models.ImportRowError
+}
+
+func (f *fieldReader) fail(field, message string) {
+	f.errs = append(f.errs, &models.ImportRowError{Row: f.rec.row, Field: field, Message: message})
+}
+
+// check records the fields of item that v rejects, other than those that
+// already failed to parse.
+func (f *fieldReader) check(v *validate.Validator, item validate.Validatable) {
+	for _, e := range v.Check(item) {
+		if !f.failed(e.Field) {
+			f.fail(e.Field, e.Message)
+		}
+	}
+}
+
+// failed reports whether field already has an error.
+func (f *fieldReader) failed(field string) bool {
+	return slices.ContainsFunc(f.errs, func(e *models.ImportRowError) bool { return e.Field == field })
+}
+
+func (f *fieldReader) optional(name string) string {
+	return f.rec.fields[name]
+}
+
+func (f *fieldReader) required(name string) string {
+	v, ok := f.rec.fields[name]
+	if !ok || v == "" {
+		f.fail(name, "is required")
+	}
+	return v
+}
+
+func (f *fieldReader) count(name string) int {
+	v := f.required(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		f.fail(name, "must be a whole number")
+	} else if n < 0 {
+		f.fail(name, "cannot be negative")
+	}
+	return n
+}
+
+// version reads an optional record version; 0 means none was given.
+func (f *fieldReader) version(name string) int {
+	v := f.optional(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		f.fail(name, "must be a positive whole number")
+		return 0
+	}
+	return n
+}
+
+func (f *fieldReader) bool(name string) bool {
+	v := f.required(name)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		f.fail(name, "must be true or false")
+	}
+	return b
+}
+
+// parse reads every record and converts it with convert, which also returns
+// the ID the row gave, if any, so repeats can be reported. The result lists
+// every row error; items are only usable when there are none.
+func parse[T any](r io.Reader, format Format, columns []string, convert func(f *fieldReader) (T, string)) ([]T, *models.ImportResult, error) {
+	records, errs, err := readRecords(r, format, columns)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	result := &models.ImportResult{Errors: errs}
+	for _, e := range errs {
+		result.Rows = max(result.Rows, e.Row)
+	}
+
+	items := make([]T, 0, len(records))
+	seen := make(map[string]bool, len(records))
+	for _, rec := range records {
+		f := &fieldReader{rec: rec}
+		item, id := convert(f)
+		if id != "" {
+			if seen[id] {
+				f.fail("id", "duplicate id in file")
+			}
+			seen[id] = true
+		}
+		result.Errors = append(result.Errors, f.errs...)
+		result.Rows = max(result.Rows, rec.row)
+		items = append(items, item)
+	}
+	if result.Errors == nil {
+		result.Errors = []*models.ImportRowError{}
+	}
+	slices.SortStableFunc(result.Errors, func(a, b *models.ImportRowError) int { return a.Row - b.Row })
+	return items, result, nil
+}
+
+func formatBool(b bool) string {
+	return strconv.FormatBool(b)
+}
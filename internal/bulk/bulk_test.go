@@ -0,0 +1,229 @@
+package bulk
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"farm/internal/validate"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var checker = validate.New(config.PasswordPolicy{MinLength: 8, RequireDigit: true})
+
+// rowErrors renders result's errors as "row field: message" for comparison.
+func rowErrors(result *models.ImportResult) []string {
+	var errs []string
+	for _, e := range result.Errors {
+		errs = append(errs, fmt.Sprintf("%d %s: %s", e.Row, e.Field, e.Message))
+	}
+	return errs
+}
+
+func TestImportProducts(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		want   []string // Row errors; none means the import succeeds
+	}{
+		{
+			name:  "valid",
+			input: "id,name,quantity,visible\neggs,Eggs,3,true\n,Milk,1,false\n",
+		},
+		{
+			name:  "byte order mark and header case",
+			input: "\ufeffID, Name ,quantity,visible\neggs,Eggs,3,true\n",
+		},
+		{
+			name:  "unknown column",
+			input: "id,name,quantity,visible,colour\neggs,Eggs,3,true,brown\n",
+			want:  []string{"0 colour: unknown column"},
+		},
+		{
+			name:  "column count",
+			input: "id,name,quantity,visible\neggs,Eggs,3\nmilk,Milk,1,true\n",
+			want:  []string{"1 : has 3 columns, header has 4"},
+		},
+		{
+			name:  "duplicate id",
+			input: "id,name,quantity,visible\neggs,Eggs,3,true\neggs,More eggs,1,true\n",
+			want:  []string{"2 id: duplicate id in file"},
+		},
+		{
+			name:  "malformed fields",
+			input: "id,name,quantity,visible,version\neggs,,-1,maybe,0\n",
+			want: []string{
+				"1 name: is required",
+				"1 quantity: cannot be negative",
+				"1 visible: must be true or false",
+				"1 version: must be a positive whole number",
+			},
+		},
+		{
+			name:  "checked like the API",
+			input: "id,name,image_url,quantity,visible\neggs," + strings.Repeat("x", validate.MaxNameLength+1) + ",ftp://example.com/eggs.png,3,true\n",
+			want: []string{
+				"1 name: must be at most 200 characters",
+				"1 image_url: must be an absolute http(s) URL",
+			},
+		},
+		{
+			name:   "JSON Lines",
+			format: FormatJSONL,
+			input:  `{"id":"eggs","name":"Eggs","quantity":3,"visible":true,"description":null}` + "\n\n" + `{"name":"Milk","quantity":1,"visible":false}`,
+		},
+		{
+			name:   "JSON Lines errors",
+			format: FormatJSONL,
+			input:  "{\"id\":\"eggs\",\"name\":\"Eggs\",\"quantity\":3,\"visible\":true,\"colour\":\"brown\"}\nnot json\n",
+			want:   []string{"1 colour: unknown field", "2 : invalid JSON: invalid character 'o' in literal null (expecting 'u')"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := tt.format
+			if format == "" {
+				format = FormatCSV
+			}
+			s := memory.NewMemoryStore(&config.Config{})
+			result, err := ImportProducts(context.Background(), s, checker, strings.NewReader(tt.input), format, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rowErrors(result); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+			products, err := s.GetAllProducts(context.Background(), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil && len(products) == 0 {
+				t.Error("nothing was imported")
+			}
+			if tt.want != nil && len(products) != 0 {
+				t.Errorf("%d products imported from an invalid file", len(products))
+			}
+		})
+	}
+}
+
+func TestImportMalformedCSV(t *testing.T) {
+	s := memory.NewMemoryStore(&config.Config{})
+	_, err := ImportProducts(context.Background(), s, checker, strings.NewReader("id,name\n\"eggs,Eggs\n"), FormatCSV, false)
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("err = %v, want ErrMalformed", err)
+	}
+}
+
+func TestImportCustomers(t *testing.T) {
+	const header = "email,name,credits,role,notify_email,notify_in_app,password\n"
+	tests := []struct {
+		name string
+		rows string
+		want []string
+	}{
+		{
+			name: "valid",
+			rows: "alice@example.com,Alice,0,customer,true,true,secret123\nbob@example.com,Bob,10,staff,false,true,\n",
+		},
+		{
+			name: "invalid fields",
+			rows: "not an address,Alice,0,boss,true,true,\n",
+			want: []string{"1 email: is not a valid address", "1 role: must be one of customer, staff, admin"},
+		},
+		{
+			name: "password policy",
+			rows: "alice@example.com,Alice,0,customer,true,true,short\nbob@example.com,Bob,0,customer,true,true,nodigitshere\n",
+			want: []string{"1 password: must be at least 8 characters", "2 password: must contain a digit"},
+		},
+		{
+			name: "duplicate email",
+			rows: "alice@example.com,Alice,0,customer,true,true,\nalice@example.com,Alice again,0,customer,true,true,\n",
+			want: []string{"2 email: duplicate email in file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := memory.NewMemoryStore(&config.Config{})
+			result, err := ImportCustomers(context.Background(), s, checker, strings.NewReader(header+tt.rows), FormatCSV, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rowErrors(result); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// export writes rows as an export does.
+func export[T any](t *testing.T, header []string, items []T, record func(T) []string) string {
+	t.Helper()
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(header)
+	for _, item := range items {
+		w.Write(record(item))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemoryStore(&config.Config{})
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs, a dozen", Description: "Free \"range\"", Quantity: 3, Visible: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCustomer(ctx, &models.Customer{ID: "alice", Email: "alice@example.com", Name: "Alice", Role: models.RoleCustomer, NotifyEmail: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	products, err := s.GetAllProducts(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := export(t, ProductColumns, products, ProductRecord)
+	result, err := ImportProducts(ctx, s, checker, strings.NewReader(strings.Replace(file, ",3,true,", ",5,true,", 1)), FormatCSV, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) != 0 || result.Updated != 1 {
+		t.Errorf("reimporting products = %+v", result)
+	}
+	p, err := s.GetProduct(ctx, "eggs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Eggs, a dozen" || p.Description != "Free \"range\"" || p.Quantity != 5 {
+		t.Errorf("after reimport eggs = %+v", p)
+	}
+	// The file is now stale
+	result, err = ImportProducts(ctx, s, checker, strings.NewReader(file), FormatCSV, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rowErrors(result); len(got) != 1 || !strings.HasPrefix(got[0], "1 version:") {
+		t.Errorf("stale reimport errors = %q", got)
+	}
+
+	customers, err := s.GetAllCustomers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = ImportCustomers(ctx, s, checker, strings.NewReader(export(t, CustomerColumns, customers, CustomerRecord)), FormatCSV, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) != 0 || result.Updated != 1 {
+		t.Errorf("reimporting customers = %+v", result)
+	}
+}
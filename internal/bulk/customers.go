@@ -0,0 +1,102 @@
+package bulk
+
+import (
+	"context"
+	"farm/internal/auth"
+	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/validate"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CustomerColumns is the CSV header written on export. Imports accept the same
+// columns plus an optional password; rank, no_show_count and banned_until are
+// derived and ignored on import.
+var CustomerColumns = []string{"id", "email", "name", "credits", "rank", "role", "no_show_count", "banned_until", "notify_email", "notify_in_app"}
+
+var customerImportColumns = append(slices.Clone(CustomerColumns), "password")
+
+// customerRow is an imported customer with the password it gives, checked as
+// signups and admin edits are.
+type customerRow struct {
+	*models.Customer
+	password string
+}
+
+func (r *customerRow) Validate(c *validate.Checker) {
+	c.Email("email", r.Email)
+	if c.Required("name", r.Name) {
+		c.MaxLength("name", r.Name, validate.MaxNameLength)
+	}
+	validate.OneOf(c, "role", r.Role, models.RoleCustomer, models.RoleStaff, models.RoleAdmin)
+	if r.password != "" {
+		c.Password("password", r.password)
+	}
+}
+
+// ImportCustomers parses customers from r and upserts them by ID; see
+// ImportProducts. A password, if given, must meet the password policy, and is
+// hashed and replaces the current one; new customers imported without one
+// cannot sign in until it is set.
+func ImportCustomers(ctx context.Context, s store.Repository, v *validate.Validator, r io.Reader, format Format, dryRun bool) (*models.ImportResult, error) {
+	emails := make(map[string]bool)
+	customers, result, err := parse(r, format, customerImportColumns, func(f *fieldReader) (*models.Customer, string) {
+		c := &models.Customer{
+			ID:          f.optional("id"),
+			Email:       f.required("email"),
+			Name:        f.required("name"),
+			Credits:     f.count("credits"),
+			Role:        f.required("role"),
+			NotifyEmail: f.bool("notify_email"),
+			NotifyInApp: f.bool("notify_in_app"),
+		}
+		password := f.optional("password")
+		f.check(v, &customerRow{Customer: c, password: password})
+		if !f.failed("email") {
+			if emails[c.Email] {
+				f.fail("email", "duplicate email in file")
+			}
+			emails[c.Email] = true
+		}
+		if password != "" && len(f.errs) == 0 {
+			salt, err := auth.GenerateSalt()
+			if err == nil {
+				c.Password, err = auth.HashPassword(password, salt)
+			}
+			if err != nil {
+				f.fail("password", "could not be hashed")
+			}
+			c.Salt = salt
+		}
+
+		given := c.ID
+		if c.ID == "" {
+			c.ID = uuid.New().String()
+		}
+		return c, given
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		result.DryRun = dryRun
+		return result, nil
+	}
+	return s.ImportCustomers(ctx, customers, dryRun)
+}
+
+func CustomerRecord(c *models.Customer) []string {
+	bannedUntil := ""
+	if c.BannedUntil != nil {
+		bannedUntil = c.BannedUntil.Format(time.RFC3339)
+	}
+	return []string{
+		c.ID, c.Email, c.Name, strconv.Itoa(c.Credits), strconv.Itoa(int(c.Rank)), c.Role,
+		strconv.Itoa(c.NoShowCount), bannedUntil, formatBool(c.NotifyEmail), formatBool(c.NotifyInApp),
+	}
+}
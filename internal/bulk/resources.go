@@ -0,0 +1,90 @@
+package bulk
+
+import (
+	"context"
+	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/validate"
+	"io"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ProductColumns and ActivityColumns are the CSV headers written on export and
+// accepted on import. A blank or missing id creates a new item. The version an
+// item was exported at is checked on import; without it, quantity and
+// capacity can't be changed (see store.CheckImport).
+var (
+	ProductColumns  = []string{"id", "name", "description", "image_url", "quantity", "visible", "version"}
+	ActivityColumns = []string{"id", "name", "description", "image_url", "capacity", "visible", "version"}
+)
+
+// ImportProducts parses products from r and upserts them by ID. Rows are
+// checked with v as the API checks request bodies. If any row is invalid
+// nothing is written and the result lists every error.
+func ImportProducts(ctx context.Context, s store.Repository, v *validate.Validator, r io.Reader, format Format, dryRun bool) (*models.ImportResult, error) {
+	products, result, err := parse(r, format, ProductColumns, func(f *fieldReader) (*models.Product, string) {
+		p := &models.Product{
+			ID:          f.optional("id"),
+			Name:        f.required("name"),
+			Description: f.optional("description"),
+			ImageURL:    f.optional("image_url"),
+			Quantity:    f.count("quantity"),
+			Visible:     f.bool("visible"),
+			Version:     f.version("version"),
+		}
+		f.check(v, p)
+		given := p.ID
+		if p.ID == "" {
+			p.ID = uuid.New().String()
+		}
+		return p, given
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		result.DryRun = dryRun
+		return result, nil
+	}
+	return s.ImportProducts(ctx, products, dryRun)
+}
+
+// ImportActivities parses activities from r and upserts them by ID; see
+// ImportProducts.
+func ImportActivities(ctx context.Context, s store.Repository, v *validate.Validator, r io.Reader, format Format, dryRun bool) (*models.ImportResult, error) {
+	activities, result, err := parse(r, format, ActivityColumns, func(f *fieldReader) (*models.Activity, string) {
+		a := &models.Activity{
+			ID:          f.optional("id"),
+			Name:        f.required("name"),
+			Description: f.optional("description"),
+			ImageURL:    f.optional("image_url"),
+			Capacity:    f.count("capacity"),
+			Visible:     f.bool("visible"),
+			Version:     f.version("version"),
+		}
+		f.check(v, a)
+		given := a.ID
+		if a.ID == "" {
+			a.ID = uuid.New().String()
+		}
+		return a, given
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		result.DryRun = dryRun
+		return result, nil
+	}
+	return s.ImportActivities(ctx, activities, dryRun)
+}
+
+func ProductRecord(p *models.Product) []string {
+	return []string{p.ID, p.Name, p.Description, p.ImageURL, strconv.Itoa(p.Quantity), formatBool(p.Visible), strconv.Itoa(p.Version)}
+}
+
+func ActivityRecord(a *models.Activity) []string {
+	return []string{a.ID, a.Name, a.Description, a.ImageURL, strconv.Itoa(a.Capacity), formatBool(a.Visible), strconv.Itoa(a.Version)}
+}
@@ -2,16 +2,79 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"time"
 )
 
+// Duration is a time.Duration written in config files as a Go duration
+// string such as "90m" or "48h".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30m\": %w", err)
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
 type ServerConfig struct {
-	Port string `json:"port"`
+	Port            string   `json:"port"`
+	ShutdownDelay   Duration `json:"shutdown_delay"`   // Time /readyz fails before the listener closes, so load balancers can react; defaults to 5s
+	ShutdownTimeout Duration `json:"shutdown_timeout"` // Time allowed for in-flight requests to finish; defaults to 15s
+	TrustedProxies  []string `json:"trusted_proxies"`  // CIDRs of proxies whose X-Forwarded-For is believed; empty uses the connecting address
 }
 
 type DatabaseConfig struct {
-	Driver           string `json:"driver"`
-	ConnectionString string `json:"connection_string"`
+	Driver           string   `json:"driver"`
+	ConnectionString string   `json:"connection_string"`
+	QueryTimeout     Duration `json:"query_timeout"` // Longest a read or single-statement write may take; defaults to 5s, 0 disables
+	TxTimeout        Duration `json:"tx_timeout"`    // Longest a transaction may take; defaults to 15s, 0 disables
+
+	MaxOpenConns    int      `json:"max_open_conns"`     // 0 is unlimited
+	MaxIdleConns    int      `json:"max_idle_conns"`     // 0 keeps none idle
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`  // 0 never retires connections by age
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"` // 0 never closes idle connections by time
+
+	SQLite   SQLiteConfig   `json:"sqlite"`
+	Postgres PostgresConfig `json:"postgres"`
+	Replicas ReplicaConfig  `json:"replicas"`
+}
+
+// SQLiteConfig holds pragmas applied to every SQLite connection.
+type SQLiteConfig struct {
+	JournalMode string   `json:"journal_mode"` // delete, truncate, persist, memory, wal or off
+	BusyTimeout Duration `json:"busy_timeout"` // How long to wait for a lock before failing with SQLITE_BUSY
+	Synchronous string   `json:"synchronous"`  // off, normal, full or extra
+}
+
+// PostgresConfig holds session settings applied to every Postgres connection.
+type PostgresConfig struct {
+	StatementTimeout Duration `json:"statement_timeout"` // Server-side limit per statement; 0 leaves the server default
+	ApplicationName  string   `json:"application_name"`  // Shown in pg_stat_activity
+}
+
+// ReplicaConfig lists read-only Postgres replicas that listings, reports and
+// exports are spread across. The pool settings above apply to each.
+type ReplicaConfig struct {
+	ConnectionStrings []string `json:"connection_strings"`
+	HealthInterval    Duration `json:"health_interval"` // How often each replica is checked; defaults to 2s
+	MaxLag            Duration `json:"max_lag"`         // Replicas further behind the primary are skipped; defaults to 5s, 0 disables
+	PinDuration       Duration `json:"pin_duration"`    // How long a customer's reads stay on the primary after they write; defaults to 10s
 }
 
 type RankConfig struct {
@@ -26,12 +89,125 @@ type LoggingConfig struct {
 	FilePath string `json:"file_path"` // path to log file if output is file
 }
 
+type PickupWindowConfig struct {
+	Start    string   `json:"start"`    // HH:MM, server local time
+	End      string   `json:"end"`      // HH:MM, server local time
+	Capacity int      `json:"capacity"` // max reservations confirmed or collected per window; 0 means unlimited
+	Weekdays []string `json:"weekdays"` // mon..sun; empty means every day
+}
+
+type PickupLocationConfig struct {
+	ID      string               `json:"id"`
+	Name    string               `json:"name"`
+	Address string               `json:"address"`
+	Windows []PickupWindowConfig `json:"windows"`
+}
+
+type PickupConfig struct {
+	Locations []PickupLocationConfig `json:"locations"`
+}
+
+type ReservationConfig struct {
+	HoldPeriod      Duration `json:"hold_period"`        // Uncollected product reservations expire this long after pickup (or booking); 0 disables
+	ExpiryInterval  Duration `json:"expiry_interval"`    // How often the expiry job runs; defaults to 5m
+	NoShowPenalty   int      `json:"no_show_penalty"`    // Credits deducted per no-show
+	BanAfterNoShows int      `json:"ban_after_no_shows"` // Suspend reservations once a customer reaches this many no-shows; 0 disables
+	BanDuration     Duration `json:"ban_duration"`       // Length of the suspension
+}
+
+type RetentionConfig struct {
+	PurgeAfter    Duration `json:"purge_after"`    // Soft-deleted products, activities and customers are hard-deleted after this; 0 keeps them forever
+	PurgeInterval Duration `json:"purge_interval"` // How often the purge job runs; defaults to 1h
+}
+
+type PrivacyConfig struct {
+	ErasureGracePeriod Duration `json:"erasure_grace_period"` // Delay between an account deletion request and erasure; 0 erases immediately
+	ErasureInterval    Duration `json:"erasure_interval"`     // How often the erasure job runs; defaults to 1h
+}
+
+type MetricsConfig struct {
+	Enabled bool   `json:"enabled"` // Serve Prometheus metrics at /metrics
+	Token   string `json:"token"`   // Bearer token scrapers must send; empty leaves /metrics open
+}
+
+type TracingConfig struct {
+	Enabled     bool    `json:"enabled"`      // Export spans to an OpenTelemetry collector over OTLP/HTTP
+	Endpoint    string  `json:"endpoint"`     // Collector host:port; defaults to localhost:4318
+	Insecure    bool    `json:"insecure"`     // Plain HTTP instead of TLS; defaults to true for a local collector
+	ServiceName string  `json:"service_name"` // Reported service.name; defaults to farm
+	SampleRatio float64 `json:"sample_ratio"` // Fraction of new traces sampled; defaults to 1. Requests with a traceparent follow the caller's decision
+}
+
+type WebhookConfig struct {
+	PollInterval Duration `json:"poll_interval"` // How often the outbox and retry queue are polled; defaults to 5s
+	Timeout      Duration `json:"timeout"`       // Per-request timeout; defaults to 10s
+	MaxAttempts  int      `json:"max_attempts"`  // Give up after this many attempts; defaults to 8
+	RetryBackoff Duration `json:"retry_backoff"` // Delay before the first retry, doubled on each attempt; defaults to 30s
+}
+
+// OutboxConfig governs how the event outbox feeding webhooks, notifications and
+// the inventory stream is read.
+type OutboxConfig struct {
+	Settle        Duration `json:"settle"`         // How long a gap in event IDs is waited on before it's taken as a rolled-back write; must exceed the transaction timeout; defaults to 30s
+	Retention     Duration `json:"retention"`      // Events, and webhook deliveries no longer pending, are deleted after this; defaults to 168h, 0 keeps them forever
+	PurgeInterval Duration `json:"purge_interval"` // How often old events are deleted; defaults to 1h
+}
+
+type StreamConfig struct {
+	PollInterval Duration `json:"poll_interval"` // How often new inventory events are picked up; defaults to 1s
+	Heartbeat    Duration `json:"heartbeat"`     // Interval between keep-alive comments; defaults to 15s, 0 disables
+	ClientBuffer int      `json:"client_buffer"` // Events queued per client before it is dropped; defaults to 64
+}
+
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+// PasswordPolicy is applied to passwords chosen at signup. Existing passwords
+// keep working when it is tightened.
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`     // Defaults to 8
+	MaxLength     int  `json:"max_length"`     // Defaults to 128; 0 disables the limit
+	RequireUpper  bool `json:"require_upper"`  // At least one uppercase letter
+	RequireLower  bool `json:"require_lower"`  // At least one lowercase letter
+	RequireDigit  bool `json:"require_digit"`  // At least one digit
+	RequireSymbol bool `json:"require_symbol"` // At least one character that is not a letter, digit or space
+}
+
+type IdempotencyConfig struct {
+	TTL           Duration `json:"ttl"`            // How long a key's response is replayed; defaults to 24h
+	PurgeInterval Duration `json:"purge_interval"` // How often expired keys are deleted; defaults to 1h
+}
+
+type NotificationConfig struct {
+	Sender       string     `json:"sender"`        // smtp, console, or empty to disable email
+	PollInterval Duration   `json:"poll_interval"` // How often new events are turned into notifications; defaults to 5s
+	ConsolePath  string     `json:"console_path"`  // File the console sender appends to; stdout if empty
+	SMTP         SMTPConfig `json:"smtp"`
+}
+
 type Config struct {
-	Server    ServerConfig   `json:"server"`
-	Database  DatabaseConfig `json:"database"`
-	Ranks     RankConfig     `json:"ranks"`
-	Logging   LoggingConfig  `json:"logging"`
-	JWTSecret string         `json:"jwt_secret"`
+	Server        ServerConfig       `json:"server"`
+	Database      DatabaseConfig     `json:"database"`
+	Ranks         RankConfig         `json:"ranks"`
+	Logging       LoggingConfig      `json:"logging"`
+	Pickup        PickupConfig       `json:"pickup"`
+	Reservations  ReservationConfig  `json:"reservations"`
+	Retention     RetentionConfig    `json:"retention"`
+	Privacy       PrivacyConfig      `json:"privacy"`
+	Outbox        OutboxConfig       `json:"outbox"`
+	Webhooks      WebhookConfig      `json:"webhooks"`
+	Stream        StreamConfig       `json:"stream"`
+	Notifications NotificationConfig `json:"notifications"`
+	Metrics       MetricsConfig      `json:"metrics"`
+	Tracing       TracingConfig      `json:"tracing"`
+	Passwords     PasswordPolicy     `json:"password_policy"`
+	Idempotency   IdempotencyConfig  `json:"idempotency"`
+	JWTSecret     string             `json:"jwt_secret"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -41,7 +217,79 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	defer file.Close()
 
-	var cfg Config
+	cfg := Config{
+		Server: ServerConfig{
+			ShutdownDelay:   Duration(5 * time.Second),
+			ShutdownTimeout: Duration(15 * time.Second),
+		},
+		Database: DatabaseConfig{
+			QueryTimeout: Duration(5 * time.Second),
+			TxTimeout:    Duration(15 * time.Second),
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: Duration(time.Hour),
+			ConnMaxIdleTime: Duration(10 * time.Minute),
+
+			SQLite: SQLiteConfig{
+				JournalMode: "wal",
+				BusyTimeout: Duration(5 * time.Second),
+				Synchronous: "normal",
+			},
+			Postgres: PostgresConfig{
+				ApplicationName: "farm",
+			},
+			Replicas: ReplicaConfig{
+				HealthInterval: Duration(2 * time.Second),
+				MaxLag:         Duration(5 * time.Second),
+				PinDuration:    Duration(10 * time.Second),
+			},
+		},
+		Reservations: ReservationConfig{
+			ExpiryInterval: Duration(5 * time.Minute),
+		},
+		Retention: RetentionConfig{
+			PurgeInterval: Duration(time.Hour),
+		},
+		Privacy: PrivacyConfig{
+			ErasureGracePeriod: Duration(14 * 24 * time.Hour),
+			ErasureInterval:    Duration(time.Hour),
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "farm",
+			SampleRatio: 1,
+		},
+		Outbox: OutboxConfig{
+			Settle:        Duration(30 * time.Second),
+			Retention:     Duration(7 * 24 * time.Hour),
+			PurgeInterval: Duration(time.Hour),
+		},
+		Webhooks: WebhookConfig{
+			PollInterval: Duration(5 * time.Second),
+			Timeout:      Duration(10 * time.Second),
+			MaxAttempts:  8,
+			RetryBackoff: Duration(30 * time.Second),
+		},
+		Notifications: NotificationConfig{
+			PollInterval: Duration(5 * time.Second),
+			SMTP:         SMTPConfig{Port: 587},
+		},
+		Stream: StreamConfig{
+			PollInterval: Duration(time.Second),
+			Heartbeat:    Duration(15 * time.Second),
+			ClientBuffer: 64,
+		},
+		Passwords: PasswordPolicy{
+			MinLength: 8,
+			MaxLength: 128,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:           Duration(24 * time.Hour),
+			PurgeInterval: Duration(time.Hour),
+		},
+	}
 	decoder := json.NewDecoder(file)
 	if err := decoder.Decode(&cfg); err != nil {
 		return nil, err
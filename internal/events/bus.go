@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"farm/internal/models"
+	"farm/internal/store"
+	"log/slog"
+	"sync"
+)
+
+// Bus fans events out to in-process subscribers such as SSE clients.
+// Publishing never blocks: a subscriber whose buffer is full is dropped and
+// its channel closed, so one slow client cannot hold up the others.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	buffer int
+}
+
+type Subscription struct {
+	C  <-chan *models.Event
+	ch chan *models.Event
+}
+
+func NewBus(buffer int) *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{}), buffer: buffer}
+}
+
+func (b *Bus) Subscribe() *Subscription {
+	ch := make(chan *models.Event, b.buffer)
+	sub := &Subscription{C: ch, ch: ch}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe removes sub and closes its channel. It is safe to call more
+// than once.
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+func (b *Bus) Publish(e *models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			slog.Warn("Dropping slow event subscriber", "buffer", b.buffer)
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Relay tails the outbox and publishes new events on a Bus. Its cursor is
+// kept in memory, so every server instance relays every event to its own
+// clients.
+type Relay struct {
+	store  store.Repository
+	bus    *Bus
+	types  map[string]bool
+	lastID int64
+	primed bool
+}
+
+// NewRelay relays events of the given types; none means all.
+func NewRelay(s store.Repository, bus *Bus, types ...string) *Relay {
+	r := &Relay{store: s, bus: bus}
+	if len(types) > 0 {
+		r.types = make(map[string]bool)
+		for _, t := range types {
+			r.types[t] = true
+		}
+	}
+	return r
+}
+
+// Run publishes events recorded since the previous run. The first run only
+// positions the cursor, so history is not replayed on start up.
+func (r *Relay) Run(ctx context.Context) error {
+	if !r.primed {
+		id, err := r.store.GetLatestOutboxEventID(ctx)
+		if err != nil {
+			return err
+		}
+		r.lastID, r.primed = id, true
+		return nil
+	}
+
+	for ctx.Err() == nil {
+		batch, err := r.store.GetOutboxEventsAfter(ctx, r.lastID, 500)
+		if err != nil {
+			return err
+		}
+		for _, e := range batch {
+			r.lastID = e.ID
+			if r.types == nil || r.types[e.Type] {
+				r.bus.Publish(e)
+			}
+		}
+		if len(batch) < 500 {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"testing"
+)
+
+func TestBusDropsSlowSubscribers(t *testing.T) {
+	b := NewBus(1)
+	fast, slow := b.Subscribe(), b.Subscribe()
+
+	b.Publish(&models.Event{ID: 1})
+	if e := <-fast.C; e.ID != 1 {
+		t.Errorf("fast subscriber got event %d", e.ID)
+	}
+	b.Publish(&models.Event{ID: 2}) // slow still holds event 1
+	if e := <-fast.C; e.ID != 2 {
+		t.Errorf("fast subscriber got event %d", e.ID)
+	}
+	if e := <-slow.C; e.ID != 1 {
+		t.Errorf("slow subscriber got event %d", e.ID)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Error("slow subscriber's channel is still open")
+	}
+
+	b.Unsubscribe(fast)
+	b.Unsubscribe(fast)
+	b.Unsubscribe(slow)
+	if _, ok := <-fast.C; ok {
+		t.Error("unsubscribed channel is still open")
+	}
+	b.Publish(&models.Event{ID: 3})
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemoryStore(&config.Config{})
+	addProduct := func(id string) {
+		t.Helper()
+		if err := s.AddProduct(ctx, &models.Product{ID: id, Name: id, Quantity: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addProduct("history")
+
+	b := NewBus(16)
+	sub := b.Subscribe()
+	r := NewRelay(s, b, models.EventInventoryChanged)
+	if err := r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(sub.C) != 0 {
+		t.Errorf("first run replayed %d events", len(sub.C))
+	}
+
+	addProduct("eggs")
+	if err := s.AddCustomer(ctx, &models.Customer{ID: "alice", Email: "alice@example.com", Name: "Alice", Role: models.RoleCustomer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateCustomerCredits(ctx, "alice", 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(sub.C) != 1 {
+		t.Fatalf("relayed %d events, want 1", len(sub.C))
+	}
+	if e := <-sub.C; e.Type != models.EventInventoryChanged {
+		t.Errorf("relayed %s", e.Type)
+	}
+	if err := r.Run(ctx); err != nil || len(sub.C) != 0 {
+		t.Errorf("second run relayed %d events again, err %v", len(sub.C), err)
+	}
+}
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying l.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// Middleware gives each request a logger carrying its request ID, the
+// caller's own if it sent one, and its trace and span IDs. It must run after
+// the request ID and tracing middleware.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			l := slog.Default().With("request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+			if id := req.Header.Get(echo.HeaderXRequestID); id != "" {
+				l = l.With("client_request_id", id)
+			}
+			if sc := trace.SpanContextFromContext(req.Context()); sc.IsValid() {
+				l = l.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+			}
+			c.SetRequest(req.WithContext(NewContext(req.Context(), l)))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	def := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(def) })
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set(echo.HeaderXRequestID, "client-1")
+	rec := httptest.NewRecorder()
+	rec.Header().Set(echo.HeaderXRequestID, "server-1")
+
+	handler := Middleware()(func(c echo.Context) error {
+		FromContext(c.Request().Context()).Info("hello")
+		return nil
+	})
+	if err := handler(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"msg":               "hello",
+		"request_id":        "server-1",
+		"client_request_id": "client-1",
+		"trace_id":          traceID.String(),
+		"span_id":           spanID.String(),
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %s", k, entry[k], v)
+		}
+	}
+}
+
+func TestFromContextDefault(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("FromContext without a logger isn't the default logger")
+	}
+	l := slog.New(slog.DiscardHandler)
+	if FromContext(NewContext(context.Background(), l)) != l {
+		t.Error("FromContext didn't return the context's logger")
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"farm/internal/store"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterStock adds a gauge of each product's stock and each activity's free
+// seats, read from s on every scrape.
+func (m *Metrics) RegisterStock(s store.Repository) {
+	m.registry.MustRegister(&stockCollector{store: s})
+}
+
+var (
+	productStockDesc = prometheus.NewDesc("farm_product_stock", "Units in stock per product.",
+		[]string{"product_id", "name"}, nil)
+	activitySeatsDesc = prometheus.NewDesc("farm_activity_seats_available", "Seats left per activity.",
+		[]string{"activity_id", "name"}, nil)
+)
+
+type stockCollector struct {
+	store store.Repository
+}
+
+func (c *stockCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- productStockDesc
+	ch <- activitySeatsDesc
+}
+
+func (c *stockCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+	products, err := c.store.GetAllProducts(ctx, false)
+	if err != nil {
+		slog.Error("Failed to collect product stock", "error", err)
+		ch <- prometheus.NewInvalidMetric(productStockDesc, err)
+	}
+	for _, p := range products {
+		ch <- prometheus.MustNewConstMetric(productStockDesc, prometheus.GaugeValue, float64(p.Quantity), p.ID, p.Name)
+	}
+
+	activities, err := c.store.GetAllActivities(ctx, false)
+	if err != nil {
+		slog.Error("Failed to collect activity seats", "error", err)
+		ch <- prometheus.NewInvalidMetric(activitySeatsDesc, err)
+	}
+	for _, a := range activities {
+		ch <- prometheus.MustNewConstMetric(activitySeatsDesc, prometheus.GaugeValue, float64(a.Capacity), a.ID, a.Name)
+	}
+}
+
+// statser is implemented by stores backed by a database/sql pool.
+type statser interface {
+	Stats() sql.DBStats
+}
+
+// RegisterDBStats adds connection pool metrics if s is backed by a
+// database/sql pool, and reports whether it was.
+func (m *Metrics) RegisterDBStats(s store.Repository) bool {
+	db, ok := s.(statser)
+	if !ok {
+		return false
+	}
+	m.registry.MustRegister(&dbStatsCollector{db: db})
+	return true
+}
+
+var (
+	dbMaxOpenDesc = prometheus.NewDesc("farm_db_max_open_connections", "Maximum number of open connections to the database.", nil, nil)
+	dbOpenDesc    = prometheus.NewDesc("farm_db_open_connections", "Established connections, in use or idle.", nil, nil)
+	dbInUseDesc   = prometheus.NewDesc("farm_db_in_use_connections", "Connections currently in use.", nil, nil)
+	dbIdleDesc    = prometheus.NewDesc("farm_db_idle_connections", "Idle connections.", nil, nil)
+	dbWaitsDesc   = prometheus.NewDesc("farm_db_wait_count_total", "Times a caller waited for a connection.", nil, nil)
+	dbWaitDesc    = prometheus.NewDesc("farm_db_wait_duration_seconds_total", "Time spent waiting for a connection.", nil, nil)
+	dbClosedDesc  = prometheus.NewDesc("farm_db_closed_connections_total", "Connections closed, by reason.", []string{"reason"}, nil)
+)
+
+type dbStatsCollector struct {
+	db statser
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{dbMaxOpenDesc, dbOpenDesc, dbInUseDesc, dbIdleDesc, dbWaitsDesc, dbWaitDesc, dbClosedDesc} {
+		ch <- d
+	}
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenDesc, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(dbWaitsDesc, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDesc, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(dbClosedDesc, prometheus.CounterValue, float64(s.MaxIdleClosed), "max_idle")
+	ch <- prometheus.MustNewConstMetric(dbClosedDesc, prometheus.CounterValue, float64(s.MaxIdleTimeClosed), "max_idle_time")
+	ch <- prometheus.MustNewConstMetric(dbClosedDesc, prometheus.CounterValue, float64(s.MaxLifetimeClosed), "max_lifetime")
+}
@@ -0,0 +1,146 @@
+// Package metrics exposes Prometheus metrics for HTTP requests, store calls,
+// reservations, stock and the database connection pool.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"farm/internal/store"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Reservation outcomes.
+const (
+	OutcomeConfirmed  = "confirmed"
+	OutcomeWaitlisted = "waitlisted"
+	OutcomeOutOfStock = "out_of_stock"
+	OutcomeRejected   = "rejected" // Any other failure, such as an unknown item
+)
+
+type Metrics struct {
+	registry      *prometheus.Registry
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	storeDuration *prometheus.HistogramVec
+	storeErrors   *prometheus.CounterVec
+	reservations  *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "farm_http_requests_total",
+			Help: "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "farm_http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "farm_store_call_duration_seconds",
+			Help:    "Repository call latency by method.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"method"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "farm_store_errors_total",
+			Help: "Repository calls that failed, by method. Not-found results are not counted.",
+		}, []string{"method"}),
+		reservations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "farm_reservations_total",
+			Help: "Reservation attempts by item type and outcome.",
+		}, []string{"type", "outcome"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.storeDuration, m.storeErrors, m.reservations,
+	)
+	return m
+}
+
+// Instrument wraps s so every call is timed. Register stock metrics with the
+// store before it is wrapped so scrapes don't show up as store traffic.
+func (m *Metrics) Instrument(s store.Repository) store.Repository {
+	return store.Instrument(s, m.observeStore)
+}
+
+func (m *Metrics) observeStore(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		m.storeDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			m.storeErrors.WithLabelValues(method).Inc()
+		}
+	}
+}
+
+// ObserveReservation counts one reservation attempt. It is a no-op on a nil
+// Metrics, so callers needn't check whether metrics are enabled.
+func (m *Metrics) ObserveReservation(itemType, outcome string) {
+	if m == nil {
+		return
+	}
+	m.reservations.WithLabelValues(itemType, outcome).Inc()
+}
+
+// Middleware records request counts and latency. Routes are the registered
+// path templates, such as /api/admin/products/:id, to keep label values few.
+func (m *Metrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				var he *echo.HTTPError
+				if errors.As(err, &he) {
+					status = he.Code
+				} else if !c.Response().Committed {
+					status = http.StatusInternalServerError
+				}
+			}
+			route := c.Path()
+			if route == "" || status == http.StatusNotFound && route == "/*" {
+				route = "unmatched"
+			}
+			labels := []string{c.Request().Method, route, strconv.Itoa(status)}
+			m.httpRequests.WithLabelValues(labels...).Inc()
+			m.httpDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// Handler serves the metrics in Prometheus text format. With a token set,
+// scrapers must send it as a bearer token.
+func (m *Metrics) Handler(token string) echo.HandlerFunc {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorLog: slogErrorLog{}})
+	return func(c echo.Context) error {
+		if token != "" {
+			got := c.Request().Header.Get(echo.HeaderAuthorization)
+			if subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid metrics token")
+			}
+		}
+		h.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+}
+
+type slogErrorLog struct{}
+
+func (slogErrorLog) Println(v ...any) {
+	slog.Error("Metrics collection failed", "error", v)
+}
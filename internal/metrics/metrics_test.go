@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// scrape returns the metrics text, failing the test on any other status.
+func scrape(t *testing.T, e *echo.Echo, token string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape = %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemoryStore(&config.Config{})
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Quantity: 4}); err != nil {
+		t.Fatal(err)
+	}
+	m := New()
+	m.RegisterStock(s)
+	if m.RegisterDBStats(s) {
+		t.Error("memory store registered database pool stats")
+	}
+	instrumented := m.Instrument(s)
+
+	e := echo.New()
+	e.Use(m.Middleware())
+	e.GET("/metrics", m.Handler("secret"))
+	e.GET("/products/:id", func(c echo.Context) error {
+		p, err := instrumented.GetProduct(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return c.NoContent(http.StatusNotFound) // As the error handler would
+		}
+		return c.JSON(http.StatusOK, p)
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		_, err := instrumented.PatchProduct(c.Request().Context(), "eggs", 0, func(*models.Product) error { return errors.New("boom") })
+		return err
+	})
+	for _, path := range []string{"/products/eggs", "/products/milk", "/fail", "/nowhere"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	m.ObserveReservation(string(models.ReservationProduct), OutcomeConfirmed)
+	var disabled *Metrics
+	disabled.ObserveReservation(string(models.ReservationProduct), OutcomeConfirmed)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("scrape without token = %d, want 401", rec.Code)
+	}
+
+	body := scrape(t, e, "secret")
+	for _, want := range []string{
+		`farm_http_requests_total{method="GET",route="/products/:id",status="200"} 1`,
+		`farm_http_requests_total{method="GET",route="/products/:id",status="404"} 1`,
+		`farm_http_requests_total{method="GET",route="/fail",status="500"} 1`,
+		`farm_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`farm_store_call_duration_seconds_count{method="GetProduct"} 2`,
+		`farm_store_errors_total{method="PatchProduct"} 1`,
+		`farm_reservations_total{outcome="confirmed",type="product"} 1`,
+		`farm_product_stock{name="Eggs",product_id="eggs"} 4`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	// Not-found results aren't store errors
+	if strings.Contains(body, `farm_store_errors_total{method="GetProduct"}`) {
+		t.Error("not found counted as a store error")
+	}
+}
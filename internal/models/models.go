@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"farm/internal/validate"
+	"fmt"
 	"time"
 )
 
@@ -14,6 +17,7 @@ const (
 
 const (
 	RoleAdmin    = "admin"
+	RoleStaff    = "staff"
 	RoleCustomer = "customer"
 )
 
@@ -39,6 +43,29 @@ type Customer struct {
 	Credits  int    `json:"credits"`
 	Rank     Rank   `json:"rank"`
 	Role     string `json:"role"`
+
+	NoShowCount int        `json:"no_show_count"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"` // Reservations suspended until then
+
+	NotifyEmail bool `json:"notify_email"`
+	NotifyInApp bool `json:"notify_in_app"`
+
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	EraseAfter *time.Time `json:"erase_after,omitempty"` // Account erasure requested; happens at this time
+
+	Version int `json:"version"` // Incremented on every change; served as the ETag
+}
+
+// Erased customers are replaced by a pseudonymous placeholder with this name
+// and an address at this reserved domain.
+const (
+	ErasedName        = "Erased customer"
+	ErasedEmailDomain = "@erased.invalid"
+)
+
+// Banned reports whether the customer is barred from reserving at t.
+func (c *Customer) Banned(t time.Time) bool {
+	return c.BannedUntil != nil && t.Before(*c.BannedUntil)
 }
 
 type Product struct {
@@ -48,6 +75,9 @@ type Product struct {
 	ImageURL    string `json:"image_url"`
 	Quantity    int    `json:"quantity"`
 	Visible     bool   `json:"visible"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"` // Incremented on every change; served as the ETag
 }
 
 type Activity struct {
@@ -57,6 +87,27 @@ type Activity struct {
 	ImageURL    string `json:"image_url"`
 	Capacity    int    `json:"capacity"`
 	Visible     bool   `json:"visible"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"` // Incremented on every change; served as the ETag
+}
+
+func (p *Product) Validate(c *validate.Checker) {
+	validateItem(c, p.Name, p.Description, p.ImageURL)
+	c.NotNegative("quantity", p.Quantity)
+}
+
+func (a *Activity) Validate(c *validate.Checker) {
+	validateItem(c, a.Name, a.Description, a.ImageURL)
+	c.NotNegative("capacity", a.Capacity)
+}
+
+func validateItem(c *validate.Checker, name, description, imageURL string) {
+	if c.Required("name", name) {
+		c.MaxLength("name", name, validate.MaxNameLength)
+	}
+	c.MaxLength("description", description, validate.MaxTextLength)
+	c.URL("image_url", imageURL, false)
 }
 
 type ReservationType string
@@ -67,11 +118,380 @@ const (
 )
 
 type Reservation struct {
-	ID           string          `json:"id"`
-	CustomerID   string          `json:"customer_id"`
-	ItemID       string          `json:"item_id"` // ProductID or ActivityID
+	ID           string            `json:"id"`
+	CustomerID   string            `json:"customer_id"`
+	ItemID       string            `json:"item_id"` // ProductID or ActivityID
+	Type         ReservationType   `json:"type"`
+	PriorityRank Rank              `json:"priority_rank"`
+	Timestamp    time.Time         `json:"timestamp"`
+	Status       ReservationStatus `json:"status"`
+	Pickup       *PickupSlot       `json:"pickup,omitempty"`  // Product reservations only
+	History      []*Transition     `json:"history,omitempty"` // Populated by GetReservation
+}
+
+type ReservationStatus string
+
+const (
+	StatusPending   ReservationStatus = "pending"
+	StatusConfirmed ReservationStatus = "confirmed"
+	StatusWaitlist  ReservationStatus = "waitlist"
+	StatusCheckedIn ReservationStatus = "checked_in" // Activities only
+	StatusFulfilled ReservationStatus = "fulfilled"  // Products only
+	StatusCancelled ReservationStatus = "cancelled"
+	StatusNoShow    ReservationStatus = "no_show"
+	StatusExpired   ReservationStatus = "expired"
+)
+
+// ActorSystem is the actor recorded for transitions made by the server itself,
+// such as promoting a waitlisted reservation.
+const ActorSystem = "system"
+
+var transitions = map[ReservationStatus][]ReservationStatus{
+	StatusPending:   {StatusConfirmed, StatusWaitlist, StatusCancelled, StatusExpired},
+	StatusWaitlist:  {StatusConfirmed, StatusCancelled, StatusExpired},
+	StatusConfirmed: {StatusCheckedIn, StatusFulfilled, StatusCancelled, StatusNoShow, StatusExpired},
+}
+
+// CanTransition reports whether r may move from its current status to next.
+// Check-in applies to activities and fulfilment to products.
+func (r *Reservation) CanTransition(next ReservationStatus) bool {
+	switch next {
+	case StatusCheckedIn:
+		if r.Type != ReservationActivity {
+			return false
+		}
+	case StatusFulfilled:
+		if r.Type != ReservationProduct {
+			return false
+		}
+	}
+	for _, s := range transitions[r.Status] {
+		if s == next {
+			return true
+		}
+	}
+	return false
+}
+
+// HoldsStock reports whether a reservation in this status has a unit of
+// stock or an activity seat set aside for it.
+func (s ReservationStatus) HoldsStock() bool {
+	return s == StatusConfirmed
+}
+
+// StatusChange is the payload of reservation status events.
+type StatusChange struct {
+	ReservationID string            `json:"reservation_id"`
+	CustomerID    string            `json:"customer_id"`
+	ItemID        string            `json:"item_id"`
+	Type          ReservationType   `json:"type"`
+	From          ReservationStatus `json:"from"`
+	To            ReservationStatus `json:"to"`
+	ActorID       string            `json:"actor_id"`
+	At            time.Time         `json:"at"`
+}
+
+// Transition records one status change of a reservation.
+type Transition struct {
+	From    ReservationStatus `json:"from"`
+	To      ReservationStatus `json:"to"`
+	ActorID string            `json:"actor_id"`
+	At      time.Time         `json:"at"`
+}
+
+// PickupSlot is one collection window at a pickup location on a given day.
+type PickupSlot struct {
+	LocationID   string    `json:"location_id"`
+	LocationName string    `json:"location_name,omitempty"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Capacity     int       `json:"capacity,omitempty"` // Enforced by ReserveItem; 0 means unlimited
+}
+
+// Event is a domain event recorded in the outbox in the same transaction as
+// the change it describes. Consumers such as webhook delivery read it later.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+const (
+	EventReservationCreated       = "reservation.created"
+	EventReservationCancelled     = "reservation.cancelled"
+	EventReservationStatusChanged = "reservation.status_changed"
+	EventProductOutOfStock        = "product.out_of_stock"
+	EventInventoryChanged         = "inventory.changed"
+	EventCreditsUpdated           = "customer.credits_updated"
+)
+
+// EventTypes lists every event type a webhook may subscribe to.
+var EventTypes = []string{
+	EventReservationCreated,
+	EventReservationCancelled,
+	EventReservationStatusChanged,
+	EventProductOutOfStock,
+	EventInventoryChanged,
+	EventCreditsUpdated,
+}
+
+const (
+	CreditReasonAdmin  = "admin"
+	CreditReasonNoShow = "no_show"
+	CreditReasonImport = "import"
+)
+
+// CreditChange is an entry in a customer's credit history and the payload of
+// customer.credits_updated events.
+type CreditChange struct {
+	CustomerID string    `json:"customer_id"`
+	Previous   int       `json:"previous"`
+	Credits    int       `json:"credits"`
+	Rank       Rank      `json:"rank"`
+	Reason     string    `json:"reason"`
+	At         time.Time `json:"at"`
+}
+
+// InventoryChange is the payload of inventory.changed events: the current
+// stock of a product or seats left on an activity.
+type InventoryChange struct {
+	Type      ReservationType `json:"type"`
+	ItemID    string          `json:"item_id"`
+	Name      string          `json:"name,omitempty"`
+	Available int             `json:"available"` // Product quantity or activity capacity
+	Visible   bool            `json:"visible"`
+	Deleted   bool            `json:"deleted,omitempty"`
+}
+
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // Only returned on creation
+	Events    []string  `json:"events"`           // Empty subscribes to every event
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Wants reports whether the webhook subscribes to eventType.
+func (w *Webhook) Wants(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed" // Gave up after the maximum number of attempts
+)
+
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventID       int64           `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"-"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  int             `json:"response_code,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Notification is a message in a customer's in-app inbox.
+type Notification struct {
+	ID         string     `json:"id"`
+	CustomerID string     `json:"customer_id"`
+	Event      string     `json:"event"`
+	Subject    string     `json:"subject"`
+	Body       string     `json:"body"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReadAt     *time.Time `json:"read_at,omitempty"`
+}
+
+// IdempotencyKey is a key a customer sent with a mutating request and, once
+// the request has finished, the response to replay if it is retried. Keys are
+// scoped to the customer, so two customers can't collide.
+type IdempotencyKey struct {
+	CustomerID  string
+	Key         string
+	RequestHash string            // SHA-256 of the method, URI and body
+	Status      int               // Zero while the request is in progress
+	Headers     map[string]string // Response headers worth replaying
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Audit targets.
+const (
+	AuditTargetCustomer    = "customer"
+	AuditTargetProduct     = "product"
+	AuditTargetActivity    = "activity"
+	AuditTargetReservation = "reservation"
+	AuditTargetWebhook     = "webhook"
+)
+
+// Audit actions.
+const (
+	AuditActionCreate        = "create"
+	AuditActionUpdate        = "update"
+	AuditActionDelete        = "delete"
+	AuditActionRestore       = "restore"
+	AuditActionUpdateCredits = "update_credits"
+	AuditActionUpdateRole    = "update_role"
+	AuditActionLiftBan       = "lift_ban"
+	AuditActionTransition    = "transition"
+	AuditActionImport        = "import"
+	AuditActionAdjustStock   = "adjust_stock"
+)
+
+// AuditEntry records one admin or staff mutation. Before and After are JSON
+// snapshots of the target; Before is absent on creation and After on deletion.
+// RequestID is generated by the server; ClientRequestID is the X-Request-ID
+// the caller sent, if any.
+type AuditEntry struct {
+	ID              int64           `json:"id"`
+	ActorID         string          `json:"actor_id"`
+	Action          string          `json:"action"`
+	TargetType      string          `json:"target_type"`
+	TargetID        string          `json:"target_id"`
+	Before          json.RawMessage `json:"before,omitempty"`
+	After           json.RawMessage `json:"after,omitempty"`
+	RequestID       string          `json:"request_id,omitempty"`
+	ClientRequestID string          `json:"client_request_id,omitempty"`
+	ClientIP        string          `json:"client_ip,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit entries, newest first. Empty fields match
+// everything; BeforeID pages backwards from a previous result.
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	BeforeID   int64
+	Limit      int
+}
+
+// ImportRowError explains why one row of a bulk import was rejected. Rows are
+// numbered from 1, not counting a CSV header; row 0 refers to the file as a
+// whole.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ImportRowError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s: %s", e.Row, e.Field, e.Message)
+}
+
+// ImportResult summarises a bulk import. Nothing is written when Errors is
+// non-empty or on a dry run; Created and Updated then count what would have
+// been.
+type ImportResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Rows    int               `json:"rows"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Errors  []*ImportRowError `json:"errors"`
+}
+
+// Report periods for time-bucketed reports. Weeks start on Monday.
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// ReportFilter narrows a report to reservations made in [From, To). Rank is
+// the customer's rank when they reserved; a negative Rank or empty Type
+// matches all.
+type ReportFilter struct {
+	From   time.Time
+	To     time.Time
+	Period string
+	Type   ReservationType
+	Rank   Rank
+	Limit  int
+}
+
+// ReservationCount is the number of reservations of one item in one period.
+type ReservationCount struct {
+	Period       string          `json:"period"` // First day of the period, YYYY-MM-DD
+	ItemID       string          `json:"item_id"`
+	ItemName     string          `json:"item_name"`
 	Type         ReservationType `json:"type"`
-	PriorityRank Rank            `json:"priority_rank"`
-	Timestamp    time.Time       `json:"timestamp"`
-	Status       string          `json:"status"` // "confirmed", "waitlist"
+	Reservations int             `json:"reservations"`
+	Cancelled    int             `json:"cancelled"`
+}
+
+// ActivityFill compares seats taken on an activity with those still free.
+// Booked counts confirmed and checked-in reservations; no-shows gave their
+// seat back.
+type ActivityFill struct {
+	ActivityID string  `json:"activity_id"`
+	Name       string  `json:"name"`
+	Booked     int     `json:"booked"`
+	CheckedIn  int     `json:"checked_in"`
+	NoShows    int     `json:"no_shows"`
+	SeatsLeft  int     `json:"seats_left"`
+	FillRate   float64 `json:"fill_rate"` // Booked / (Booked + SeatsLeft)
+}
+
+// ProductSellThrough compares units collected with those still held or in
+// stock.
+type ProductSellThrough struct {
+	ProductID   string  `json:"product_id"`
+	Name        string  `json:"name"`
+	Fulfilled   int     `json:"fulfilled"`
+	Held        int     `json:"held"` // Confirmed, awaiting pickup
+	Expired     int     `json:"expired"`
+	OnHand      int     `json:"on_hand"`
+	SellThrough float64 `json:"sell_through"` // Fulfilled / (Fulfilled + Held + OnHand)
+}
+
+// RankCount is the number of active customers holding a rank.
+type RankCount struct {
+	Rank      Rank   `json:"rank"`
+	RankName  string `json:"rank_name"`
+	Customers int    `json:"customers"`
+}
+
+// NoShowRate is the share of an item's confirmed reservations whose customer
+// didn't turn up.
+type NoShowRate struct {
+	ItemID   string          `json:"item_id"`
+	ItemName string          `json:"item_name"`
+	Type     ReservationType `json:"type"`
+	Attended int             `json:"attended"` // Checked in or fulfilled
+	NoShows  int             `json:"no_shows"`
+	Rate     float64         `json:"rate"` // NoShows / (Attended + NoShows)
+}
+
+// TopCustomer ranks a customer by reservations made in the report range.
+type TopCustomer struct {
+	CustomerID   string `json:"customer_id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Rank         Rank   `json:"rank"`
+	Reservations int    `json:"reservations"`
+	Completed    int    `json:"completed"` // Checked in or fulfilled
+	NoShows      int    `json:"no_shows"`
 }
@@ -0,0 +1,47 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	statuses := []ReservationStatus{
+		StatusPending, StatusConfirmed, StatusWaitlist, StatusCheckedIn,
+		StatusFulfilled, StatusCancelled, StatusNoShow, StatusExpired,
+	}
+	allowed := map[ReservationType]map[ReservationStatus][]ReservationStatus{
+		ReservationProduct: {
+			StatusPending:   {StatusConfirmed, StatusWaitlist, StatusCancelled, StatusExpired},
+			StatusWaitlist:  {StatusConfirmed, StatusCancelled, StatusExpired},
+			StatusConfirmed: {StatusFulfilled, StatusCancelled, StatusNoShow, StatusExpired},
+		},
+		ReservationActivity: {
+			StatusPending:   {StatusConfirmed, StatusWaitlist, StatusCancelled, StatusExpired},
+			StatusWaitlist:  {StatusConfirmed, StatusCancelled, StatusExpired},
+			StatusConfirmed: {StatusCheckedIn, StatusCancelled, StatusNoShow, StatusExpired},
+		},
+	}
+	for typ, from := range allowed {
+		for _, current := range statuses {
+			want := make(map[ReservationStatus]bool)
+			for _, next := range from[current] {
+				want[next] = true
+			}
+			r := &Reservation{Type: typ, Status: current}
+			for _, next := range statuses {
+				if got := r.CanTransition(next); got != want[next] {
+					t.Errorf("%s reservation %s -> %s: CanTransition = %v, want %v", typ, current, next, got, want[next])
+				}
+			}
+		}
+	}
+}
+
+func TestHoldsStock(t *testing.T) {
+	for _, s := range []ReservationStatus{StatusPending, StatusWaitlist, StatusCheckedIn, StatusFulfilled, StatusCancelled, StatusNoShow, StatusExpired} {
+		if s.HoldsStock() {
+			t.Errorf("%s holds stock", s)
+		}
+	}
+	if !StatusConfirmed.HoldsStock() {
+		t.Error("confirmed doesn't hold stock")
+	}
+}
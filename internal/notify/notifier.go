@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"farm/internal/config"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is an email-style notification addressed to one customer.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages outside the application, e.g. by email.
+type Notifier interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewNotifier builds the sender selected in config. It returns nil when
+// email notifications are disabled.
+func NewNotifier(cfg *config.NotificationConfig) (Notifier, error) {
+	switch cfg.Sender {
+	case "":
+		return nil, nil
+	case "smtp":
+		return NewSMTPNotifier(cfg.SMTP), nil
+	case "console":
+		if cfg.ConsolePath == "" {
+			return NewConsoleNotifier(os.Stdout), nil
+		}
+		f, err := os.OpenFile(cfg.ConsolePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return NewConsoleNotifier(f), nil
+	default:
+		return nil, fmt.Errorf("unsupported notification sender: %s", cfg.Sender)
+	}
+}
+
+// SMTPNotifier sends plain-text email through an SMTP relay, using STARTTLS
+// when the server offers it.
+type SMTPNotifier struct {
+	cfg config.SMTPConfig
+}
+
+func NewSMTPNotifier(cfg config.SMTPConfig) *SMTPNotifier {
+	return &SMTPNotifier{cfg: cfg}
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, msg *Message) error {
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	// net/smtp has no context support; run it aside so callers can give up.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, n.cfg.From, []string{msg.To}, []byte(b.String()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ConsoleNotifier writes messages to a writer instead of sending them, for
+// local development and testing.
+type ConsoleNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewConsoleNotifier(w io.Writer) *ConsoleNotifier {
+	return &ConsoleNotifier{w: w}
+}
+
+func (n *ConsoleNotifier) Send(ctx context.Context, msg *Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err := fmt.Fprintf(n.w, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
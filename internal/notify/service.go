@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"farm/internal/logger"
+	"farm/internal/models"
+	"farm/internal/store"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Consumer is the outbox cursor name used by customer notifications.
+const Consumer = "notifications"
+
+// leaseDuration is how long an instance keeps the outbox to itself after each
+// event. Sending one message takes at most half of it.
+const leaseDuration = time.Minute
+
+// Service turns reservation and credit events from the outbox into inbox
+// entries and, when the customer allows it, messages sent by a Notifier.
+// Every server instance runs one, but only the instance holding the outbox
+// lease reads events, so each is notified once.
+type Service struct {
+	store    store.Repository
+	notifier Notifier // nil disables outbound messages
+	id       string   // Identifies this instance's lease
+}
+
+func NewService(s store.Repository, notifier Notifier) *Service {
+	return &Service{store: s, notifier: notifier, id: uuid.New().String()}
+}
+
+// Run notifies customers about events recorded since the previous run. The
+// inbox is written before the cursor moves; outbound messages are best effort.
+func (s *Service) Run(ctx context.Context) error {
+	if leased, err := s.lease(ctx); err != nil || !leased {
+		return err
+	}
+	events, err := s.store.GetOutboxEvents(ctx, Consumer, 100)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	for _, e := range events {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.handle(ctx, e); err != nil {
+			return err
+		}
+		if err := s.store.AdvanceOutboxCursor(ctx, Consumer, e.ID); err != nil {
+			return err
+		}
+		// Renewed as the batch goes, as each message may wait on the mail
+		// server; if another instance has taken over, it carries on.
+		if leased, err := s.lease(ctx); err != nil || !leased {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) lease(ctx context.Context) (bool, error) {
+	now := time.Now()
+	return s.store.LeaseOutboxConsumer(ctx, Consumer, s.id, now, now.Add(leaseDuration))
+}
+
+func (s *Service) handle(ctx context.Context, e *models.Event) error {
+	var customerID, kind string
+	data := &templateData{}
+
+	switch e.Type {
+	case models.EventReservationCreated:
+		var r models.Reservation
+		if err := json.Unmarshal(e.Payload, &r); err != nil {
+			return err
+		}
+		customerID, kind = r.CustomerID, "reservation."+string(r.Status)
+		s.describeItem(ctx, data, r.Type, r.ItemID)
+		if r.Pickup != nil {
+			data.PickupStart = r.Pickup.Start.Local().Format("Mon 2 Jan 15:04")
+		}
+	case models.EventReservationStatusChanged:
+		var change models.StatusChange
+		if err := json.Unmarshal(e.Payload, &change); err != nil {
+			return err
+		}
+		customerID, kind = change.CustomerID, "reservation."+string(change.To)
+		if change.From == models.StatusWaitlist && change.To == models.StatusConfirmed {
+			kind = "reservation.promoted"
+		}
+		// Don't echo a customer's own cancellation back to them.
+		if change.To == models.StatusCancelled && change.ActorID == change.CustomerID {
+			return nil
+		}
+		s.describeItem(ctx, data, change.Type, change.ItemID)
+	case models.EventCreditsUpdated:
+		var change models.CreditChange
+		if err := json.Unmarshal(e.Payload, &change); err != nil {
+			return err
+		}
+		customerID, kind = change.CustomerID, "credits.updated"
+		data.Credits, data.Previous, data.Rank, data.Reason = change.Credits, change.Previous, change.Rank.String(), change.Reason
+	default:
+		return nil
+	}
+
+	if _, ok := templates[kind]; !ok {
+		return nil
+	}
+
+	customer, err := s.store.GetCustomer(ctx, customerID)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Skipping notification for unknown customer", "customer_id", customerID, "event_id", e.ID)
+		return nil
+	}
+	data.CustomerName = customer.Name
+	subject, body, err := render(kind, data)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to render notification", "kind", kind, "error", err)
+		return nil
+	}
+
+	if customer.NotifyInApp {
+		n := &models.Notification{
+			ID:         uuid.New().String(),
+			CustomerID: customer.ID,
+			Event:      kind,
+			Subject:    subject,
+			Body:       body,
+			CreatedAt:  time.Now(),
+		}
+		if err := s.store.AddNotification(ctx, n); err != nil {
+			return err
+		}
+	}
+
+	if customer.NotifyEmail && s.notifier != nil {
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := s.notifier.Send(sendCtx, &Message{To: customer.Email, Subject: subject, Body: body}); err != nil {
+			logger.FromContext(ctx).Error("Failed to send notification", "customer_id", customer.ID, "kind", kind, "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) describeItem(ctx context.Context, data *templateData, itemType models.ReservationType, itemID string) {
+	data.ItemType = string(itemType)
+	data.ItemName = itemID
+	switch itemType {
+	case models.ReservationProduct:
+		if p, err := s.store.GetProduct(ctx, itemID); err == nil {
+			data.ItemName = p.Name
+		}
+	case models.ReservationActivity:
+		if a, err := s.store.GetActivity(ctx, itemID); err == nil {
+			data.ItemName = a.Name
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu   sync.Mutex
+	sent []*Message
+}
+
+func (r *recorder) Send(ctx context.Context, msg *Message) error {
+	time.Sleep(50 * time.Millisecond) // Long enough for another service to read the same batch
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func TestServicesShareEvents(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemoryStore(&config.Config{Ranks: config.RankConfig{BronzeMax: 99, SilverMax: 499}})
+	alice := &models.Customer{ID: "alice", Email: "alice@example.com", Name: "Alice", NotifyEmail: true, NotifyInApp: true}
+	if err := s.AddCustomer(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateCustomerCredits(ctx, "alice", 100, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{}
+	services := []*Service{NewService(s, r), NewService(s, r)}
+	for range 3 {
+		var wg sync.WaitGroup
+		for _, svc := range services {
+			wg.Go(func() {
+				if err := svc.Run(ctx); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+		wg.Wait()
+	}
+
+	if len(r.sent) != 1 || r.sent[0].To != alice.Email {
+		t.Errorf("messages sent = %+v, want one to %s", r.sent, alice.Email)
+	}
+	inbox, err := s.GetNotifications(ctx, "alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inbox) != 1 || inbox[0].Event != "credits.updated" {
+		t.Errorf("inbox = %+v, want one credits.updated entry", inbox)
+	}
+}
+
+func TestNotificationsFollowReservations(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemoryStore(&config.Config{})
+	alice := &models.Customer{ID: "alice", Email: "alice@example.com", Name: "Alice", NotifyEmail: true, NotifyInApp: false}
+	bob := &models.Customer{ID: "bob", Email: "bob@example.com", Name: "Bob", NotifyEmail: false, NotifyInApp: true}
+	for _, c := range []*models.Customer{alice, bob} {
+		if err := s.AddCustomer(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Free-range eggs", Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*models.Reservation{
+		{ID: "r1", CustomerID: "alice", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: time.Now()},
+		{ID: "r2", CustomerID: "bob", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: time.Now(), Status: models.StatusWaitlist},
+	} {
+		if err := s.ReserveItem(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Alice's own cancellation isn't echoed back; Bob's promotion is news
+	if _, err := s.TransitionReservation(ctx, "r1", models.StatusCancelled, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{}
+	if err := NewService(s, r).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.sent) != 1 || r.sent[0].To != alice.Email || r.sent[0].Subject != "Your reservation for Free-range eggs is confirmed" {
+		t.Errorf("messages sent = %+v, want Alice's confirmation", r.sent)
+	}
+	inbox, err := s.GetNotifications(ctx, "bob", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, n := range inbox {
+		kinds = append(kinds, n.Event)
+	}
+	if len(kinds) != 2 || !slices.Contains(kinds, "reservation.waitlist") || !slices.Contains(kinds, "reservation.promoted") {
+		t.Errorf("Bob's inbox = %v, want waitlist and promoted", kinds)
+	}
+	if inbox, err := s.GetNotifications(ctx, "alice", false); err != nil || len(inbox) != 0 {
+		t.Errorf("Alice's inbox = %+v, %v; Alice only wants email", inbox, err)
+	}
+}
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"strings"
+	"text/template"
+)
+
+// templateData is available to every message template.
+type templateData struct {
+	CustomerName string
+	ItemName     string
+	ItemType     string
+	Status       string
+	PickupStart  string
+	Credits      int
+	Previous     int
+	Rank         string
+	Reason       string
+}
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func mustTemplate(subject, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(strings.TrimSpace(body))),
+	}
+}
+
+// templates are keyed by notification kind, as chosen in Service.handle.
+var templates = map[string]messageTemplate{
+	"reservation.confirmed": mustTemplate(
+		"Your reservation for {{.ItemName}} is confirmed",
+		`Hi {{.CustomerName}},
+
+Your reservation for {{.ItemName}} is confirmed.{{if .PickupStart}}
+Please collect it from {{.PickupStart}}.{{end}}`),
+	"reservation.waitlist": mustTemplate(
+		"You're on the waitlist for {{.ItemName}}",
+		`Hi {{.CustomerName}},
+
+{{.ItemName}} is fully booked right now, so you're on the waitlist. We'll let you know as soon as a spot opens up.`),
+	"reservation.promoted": mustTemplate(
+		"Good news: your reservation for {{.ItemName}} is confirmed",
+		`Hi {{.CustomerName}},
+
+A spot opened up and your waitlisted reservation for {{.ItemName}} is now confirmed.{{if .PickupStart}}
+Please collect it from {{.PickupStart}}.{{end}}`),
+	"reservation.cancelled": mustTemplate(
+		"Your reservation for {{.ItemName}} was cancelled",
+		`Hi {{.CustomerName}},
+
+Your reservation for {{.ItemName}} has been cancelled.`),
+	"reservation.expired": mustTemplate(
+		"Your reservation for {{.ItemName}} has expired",
+		`Hi {{.CustomerName}},
+
+Your reservation for {{.ItemName}} was not collected in time and has expired. It has been counted as a no-show.`),
+	"reservation.no_show": mustTemplate(
+		"You missed your reservation for {{.ItemName}}",
+		`Hi {{.CustomerName}},
+
+You were marked as a no-show for {{.ItemName}}. Repeated no-shows may lead to penalties or a temporary suspension of reservations.`),
+	"credits.updated": mustTemplate(
+		"Your credit balance is now {{.Credits}}",
+		`Hi {{.CustomerName}},
+
+Your credit balance changed from {{.Previous}} to {{.Credits}}{{if eq .Reason "no_show"}} after a no-show{{end}}. Your rank is {{.Rank}}.`),
+}
+
+func render(kind string, data *templateData) (subject, body string, err error) {
+	t := templates[kind]
+	var s, b strings.Builder
+	if err := t.subject.Execute(&s, data); err != nil {
+		return "", "", err
+	}
+	if err := t.body.Execute(&b, data); err != nil {
+		return "", "", err
+	}
+	return s.String(), b.String(), nil
+}
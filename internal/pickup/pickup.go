@@ -0,0 +1,97 @@
+package pickup
+
+import (
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownLocation = errors.New("unknown pickup location")
+	ErrUnknownWindow   = errors.New("no pickup window at that time")
+)
+
+// Slots returns every slot offered on the day containing t, ordered by
+// location as configured and then by window.
+func Slots(cfg *config.PickupConfig, t time.Time) ([]*models.PickupSlot, error) {
+	var slots []*models.PickupSlot
+	for _, loc := range cfg.Locations {
+		for _, w := range loc.Windows {
+			slot, ok, err := windowSlot(loc, w, t)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	return slots, nil
+}
+
+// Find resolves the slot at locationID that starts exactly at start.
+func Find(cfg *config.PickupConfig, locationID string, start time.Time) (*models.PickupSlot, error) {
+	for _, loc := range cfg.Locations {
+		if loc.ID != locationID {
+			continue
+		}
+		for _, w := range loc.Windows {
+			slot, ok, err := windowSlot(loc, w, start)
+			if err != nil {
+				return nil, err
+			}
+			if ok && slot.Start.Equal(start) {
+				return slot, nil
+			}
+		}
+		return nil, ErrUnknownWindow
+	}
+	return nil, ErrUnknownLocation
+}
+
+// windowSlot materialises window w on the local day containing t. ok is false
+// when the window is not offered on that weekday.
+func windowSlot(loc config.PickupLocationConfig, w config.PickupWindowConfig, t time.Time) (*models.PickupSlot, bool, error) {
+	day := t.In(time.Local)
+	if len(w.Weekdays) > 0 && !offeredOn(w.Weekdays, day.Weekday()) {
+		return nil, false, nil
+	}
+	start, err := clock(day, w.Start)
+	if err != nil {
+		return nil, false, fmt.Errorf("pickup location %s: %w", loc.ID, err)
+	}
+	end, err := clock(day, w.End)
+	if err != nil {
+		return nil, false, fmt.Errorf("pickup location %s: %w", loc.ID, err)
+	}
+	return &models.PickupSlot{
+		LocationID:   loc.ID,
+		LocationName: loc.Name,
+		Start:        start,
+		End:          end,
+		Capacity:     w.Capacity,
+	}, true, nil
+}
+
+func offeredOn(weekdays []string, wd time.Weekday) bool {
+	short := strings.ToLower(wd.String()[:3])
+	for _, d := range weekdays {
+		if strings.ToLower(d) == short {
+			return true
+		}
+	}
+	return false
+}
+
+// clock returns the instant at HH:MM on the same local day as day.
+func clock(day time.Time, hhmm string) (time.Time, error) {
+	c, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid window time %q", hhmm)
+	}
+	y, m, d := day.Date()
+	return time.Date(y, m, d, c.Hour(), c.Minute(), 0, 0, time.Local), nil
+}
@@ -0,0 +1,108 @@
+package pickup
+
+import (
+	"errors"
+	"farm/internal/config"
+	"testing"
+	"time"
+)
+
+var cfg = &config.PickupConfig{Locations: []config.PickupLocationConfig{
+	{ID: "barn", Name: "Barn", Windows: []config.PickupWindowConfig{
+		{Start: "09:00", End: "10:00", Capacity: 5},
+		{Start: "16:00", End: "17:30", Capacity: 2, Weekdays: []string{"Mon", "wed"}},
+	}},
+	{ID: "market", Name: "Market", Windows: []config.PickupWindowConfig{
+		{Start: "08:00", End: "12:00", Capacity: 20, Weekdays: []string{"sat"}},
+	}},
+}}
+
+// inZone runs the test with time.Local set to a zone away from UTC, so slot
+// times can't pass by accident.
+func inZone(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestSlots(t *testing.T) {
+	inZone(t)
+	tests := []struct {
+		day  time.Time
+		want []string
+	}{
+		{time.Date(2025, 6, 2, 12, 0, 0, 0, time.Local), []string{"barn 09:00-10:00", "barn 16:00-17:30"}}, // Monday
+		{time.Date(2025, 6, 3, 0, 0, 0, 0, time.Local), []string{"barn 09:00-10:00"}},
+		{time.Date(2025, 6, 7, 23, 59, 0, 0, time.Local), []string{"barn 09:00-10:00", "market 08:00-12:00"}},
+		// 23:00 UTC on Friday is already Saturday here
+		{time.Date(2025, 6, 6, 23, 0, 0, 0, time.UTC), []string{"barn 09:00-10:00", "market 08:00-12:00"}},
+	}
+	for _, tt := range tests {
+		slots, err := Slots(cfg, tt.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range slots {
+			if s.Start.Location() != time.Local || !s.End.After(s.Start) {
+				t.Errorf("%v: slot %v-%v", tt.day, s.Start, s.End)
+			}
+			got = append(got, s.LocationID+" "+s.Start.Format("15:04")+"-"+s.End.Format("15:04"))
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Slots(%v) = %v, want %v", tt.day, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Slots(%v) = %v, want %v", tt.day, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	inZone(t)
+	monday := time.Date(2025, 6, 2, 16, 0, 0, 0, time.Local)
+
+	slot, err := Find(cfg, "barn", monday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot.LocationName != "Barn" || slot.Capacity != 2 || !slot.Start.Equal(monday) || !slot.End.Equal(monday.Add(90*time.Minute)) {
+		t.Errorf("Find = %+v", slot)
+	}
+	// The same instant given in UTC, as the SQL stores return it
+	if slot, err := Find(cfg, "barn", monday.UTC()); err != nil || !slot.Start.Equal(monday) {
+		t.Errorf("Find in UTC = %+v, %v", slot, err)
+	}
+
+	tests := []struct {
+		location string
+		start    time.Time
+		want     error
+	}{
+		{"barn", monday.Add(time.Minute), ErrUnknownWindow},
+		{"barn", monday.AddDate(0, 0, 1), ErrUnknownWindow}, // Not offered on Tuesdays
+		{"market", monday, ErrUnknownWindow},
+		{"shed", monday, ErrUnknownLocation},
+	}
+	for _, tt := range tests {
+		if _, err := Find(cfg, tt.location, tt.start); !errors.Is(err, tt.want) {
+			t.Errorf("Find(%s, %v) = %v, want %v", tt.location, tt.start, err, tt.want)
+		}
+	}
+}
+
+func TestInvalidWindow(t *testing.T) {
+	bad := &config.PickupConfig{Locations: []config.PickupLocationConfig{
+		{ID: "barn", Windows: []config.PickupWindowConfig{{Start: "9am", End: "10:00"}}},
+	}}
+	if _, err := Slots(bad, time.Now()); err == nil {
+		t.Error("Slots accepted an invalid window time")
+	}
+	if _, err := Find(bad, "barn", time.Now()); err == nil {
+		t.Error("Find accepted an invalid window time")
+	}
+}
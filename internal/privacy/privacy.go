@@ -0,0 +1,102 @@
+// Package privacy implements customer data export and account erasure.
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"farm/internal/models"
+	"farm/internal/store"
+	"io"
+	"time"
+)
+
+// Bundle is everything held about one customer.
+type Bundle struct {
+	ExportedAt    time.Time              `json:"exported_at"`
+	Profile       *models.Customer       `json:"profile"`
+	Reservations  []*models.Reservation  `json:"reservations"`
+	CreditHistory []*models.CreditChange `json:"credit_history"`
+	Notifications []*models.Notification `json:"notifications"`
+}
+
+// Export collects the customer's profile, reservations with their status
+// history, credit history and notifications.
+func Export(ctx context.Context, s store.Repository, customerID string) (*Bundle, error) {
+	customer, err := s.GetCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	customer.Password = ""
+	customer.Salt = ""
+
+	list, err := s.GetReservationsByCustomerID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	reservations := []*models.Reservation{}
+	for _, r := range list {
+		full, err := s.GetReservation(ctx, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, full)
+	}
+
+	history, err := s.GetCreditHistory(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []*models.CreditChange{}
+	}
+	notifications, err := s.GetNotifications(ctx, customerID, false)
+	if err != nil {
+		return nil, err
+	}
+	if notifications == nil {
+		notifications = []*models.Notification{}
+	}
+
+	return &Bundle{
+		ExportedAt:    time.Now(),
+		Profile:       customer,
+		Reservations:  reservations,
+		CreditHistory: history,
+		Notifications: notifications,
+	}, nil
+}
+
+// WriteZip writes the bundle as a ZIP archive with one JSON file per section.
+func (b *Bundle) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", b.Profile},
+		{"reservations.json", b.Reservations},
+		{"credit_history.json", b.CreditHistory},
+		{"notifications.json", b.Notifications},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: b.ExportedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.v); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// Erase cancels the customer's outstanding reservations, returning held stock,
+// and replaces their account with a pseudonymous placeholder. The store does
+// both in one transaction, so an erasure that fails changes nothing and can
+// be retried. The returned ID is the placeholder's.
+func Erase(ctx context.Context, s store.Repository, customerID string) (string, error) {
+	return s.EraseCustomer(ctx, customerID)
+}
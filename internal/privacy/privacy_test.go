@@ -0,0 +1,115 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/store/memory"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setup(t *testing.T) store.Repository {
+	t.Helper()
+	ctx := context.Background()
+	s := memory.NewMemoryStore(&config.Config{})
+	steps := []error{
+		s.AddCustomer(ctx, &models.Customer{ID: "alice", Email: "alice@example.com", Name: "Alice", Role: models.RoleCustomer, Password: "hash", Salt: "salt", NotifyInApp: true}),
+		s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Quantity: 2}),
+		s.ReserveItem(ctx, &models.Reservation{ID: "r1", CustomerID: "alice", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: time.Now()}),
+		s.AddNotification(ctx, &models.Notification{ID: "n1", CustomerID: "alice", Event: "test", Subject: "Hello", CreatedAt: time.Now()}),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.UpdateCustomerCredits(ctx, "alice", 30, 0); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestExport(t *testing.T) {
+	s := setup(t)
+	b, err := Export(context.Background(), s, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Profile.Email != "alice@example.com" || b.Profile.Password != "" || b.Profile.Salt != "" {
+		t.Errorf("profile = %+v; want it without password hash or salt", b.Profile)
+	}
+	if len(b.Reservations) != 1 || len(b.Reservations[0].History) == 0 {
+		t.Errorf("reservations = %+v; want r1 with its history", b.Reservations)
+	}
+	if len(b.CreditHistory) != 1 || len(b.Notifications) != 1 {
+		t.Errorf("credit history %d, notifications %d; want 1 each", len(b.CreditHistory), len(b.Notifications))
+	}
+
+	var buf bytes.Buffer
+	if err := b.WriteZip(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(body) {
+			t.Errorf("%s isn't JSON: %s", f.Name, body)
+		}
+		if bytes.Contains(body, []byte("hash")) {
+			t.Errorf("%s contains the password hash", f.Name)
+		}
+	}
+	if got := strings.Join(names, ","); got != "profile.json,reservations.json,credit_history.json,notifications.json" {
+		t.Errorf("archive holds %s", got)
+	}
+
+	if _, err := Export(context.Background(), s, "bob"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("export of unknown customer = %v, want ErrNotFound", err)
+	}
+}
+
+func TestErase(t *testing.T) {
+	ctx := context.Background()
+	s := setup(t)
+	pseudonym, err := Erase(ctx, s, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pseudonym == "" || pseudonym == "alice" {
+		t.Fatalf("pseudonym = %q", pseudonym)
+	}
+	if _, err := s.GetCustomer(ctx, "alice"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("erased customer still readable: %v", err)
+	}
+	r, err := s.GetReservation(ctx, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != models.StatusCancelled || r.CustomerID != pseudonym {
+		t.Errorf("reservation = %+v; want it cancelled and kept under %s", r, pseudonym)
+	}
+	if p, err := s.GetProduct(ctx, "eggs"); err != nil || p.Quantity != 2 {
+		t.Errorf("eggs = %+v, %v; want the held unit returned", p, err)
+	}
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"farm/internal/logger"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a unit of background work run on a fixed interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs in their own goroutines until its context
+// is cancelled. A job never overlaps with itself.
+type Scheduler struct {
+	jobs []Job
+	wg   sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registers a job. Jobs with a non-positive interval are ignored, so
+// features can be disabled from config by leaving their interval unset.
+func (s *Scheduler) Add(name string, interval time.Duration, run func(ctx context.Context) error) {
+	if interval <= 0 {
+		slog.Info("Scheduled job disabled", "job", name)
+		return
+	}
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Run: run})
+}
+
+// Start launches every job. Each runs once immediately and then on its
+// interval.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+}
+
+// Wait blocks until all jobs have returned after the context was cancelled.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	slog.Info("Scheduled job started", "job", job.Name, "interval", job.Interval.String())
+	ctx = logger.NewContext(ctx, slog.With("job", job.Name))
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Scheduled job failed", "job", job.Name, "error", err)
+		} else {
+			slog.Debug("Scheduled job finished", "job", job.Name, "duration", time.Since(start).String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if ctx.Err() != nil { // select picks at random when both are ready
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs, disabled, running atomic.Int32
+	s := New()
+	s.Add("tick", time.Millisecond, func(ctx context.Context) error {
+		if running.Add(1) != 1 {
+			t.Error("job overlapped with itself")
+		}
+		defer running.Add(-1)
+		if runs.Add(1) == 3 {
+			cancel()
+		}
+		time.Sleep(2 * time.Millisecond) // Longer than the interval
+		return errors.New("failures don't stop the job")
+	})
+	s.Add("disabled", 0, func(ctx context.Context) error {
+		disabled.Add(1)
+		return nil
+	})
+	s.Start(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait didn't return after the context was cancelled")
+	}
+	if n := runs.Load(); n != 3 {
+		t.Errorf("job ran %d times, want 3", n)
+	}
+	if n := disabled.Load(); n != 0 {
+		t.Errorf("disabled job ran %d times", n)
+	}
+}
+
+func TestSchedulerRunsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{})
+	s := New()
+	s.Add("hourly", time.Hour, func(ctx context.Context) error {
+		close(ran)
+		return nil
+	})
+	s.Start(ctx)
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Error("job didn't run on start")
+	}
+	cancel()
+	s.Wait()
+}
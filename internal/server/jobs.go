@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"farm/internal/logger"
+	"farm/internal/models"
+	"farm/internal/privacy"
+	"time"
+)
+
+// expireReservations expires confirmed product reservations that were not
+// collected within the hold period, returning their units to stock.
+func (s *Server) expireReservations(ctx context.Context) error {
+	cutoff := time.Now().Add(-time.Duration(s.cfg.Reservations.HoldPeriod))
+	expired, err := s.store.GetExpiredReservations(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range expired {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := s.store.TransitionReservation(ctx, r.ID, models.StatusExpired, models.ActorSystem); err != nil {
+			logger.FromContext(ctx).Error("Failed to expire reservation", "reservation_id", r.ID, "error", err)
+			continue
+		}
+		logger.FromContext(ctx).Info("Reservation expired", "reservation_id", r.ID, "customer_id", r.CustomerID, "item_id", r.ItemID)
+	}
+	return nil
+}
+
+// purgeDeleted permanently removes rows that have been soft-deleted for longer
+// than the retention period.
+func (s *Server) purgeDeleted(ctx context.Context) error {
+	cutoff := time.Now().Add(-time.Duration(s.cfg.Retention.PurgeAfter))
+	n, err := s.store.PurgeDeleted(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logger.FromContext(ctx).Info("Purged deleted records", "count", n, "cutoff", cutoff)
+	}
+	return nil
+}
+
+// purgeIdempotencyKeys deletes idempotency keys whose responses are no longer
+// replayed.
+func (s *Server) purgeIdempotencyKeys(ctx context.Context) error {
+	n, err := s.store.PurgeIdempotencyKeys(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logger.FromContext(ctx).Info("Purged expired idempotency keys", "count", n)
+	}
+	return nil
+}
+
+// purgeOutbox deletes events and webhook deliveries older than the outbox
+// retention.
+func (s *Server) purgeOutbox(ctx context.Context) error {
+	n, err := s.store.PurgeOutbox(ctx, time.Now().Add(-time.Duration(s.cfg.Outbox.Retention)))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logger.FromContext(ctx).Info("Purged old outbox events and webhook deliveries", "count", n)
+	}
+	return nil
+}
+
+// eraseCustomers erases accounts whose deletion grace period has ended.
+func (s *Server) eraseCustomers(ctx context.Context) error {
+	due, err := s.store.GetCustomersDueForErasure(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, c := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := privacy.Erase(ctx, s.store, c.ID); err != nil {
+			logger.FromContext(ctx).Error("Failed to erase customer", "customer_id", c.ID, "error", err)
+			continue
+		}
+		logger.FromContext(ctx).Info("Customer account erased", "customer_id", c.ID)
+	}
+	return nil
+}
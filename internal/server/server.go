@@ -5,23 +5,45 @@ import (
 	"farm/internal/api"
 	"farm/internal/auth"
 	"farm/internal/config"
+	"farm/internal/events"
 	"farm/internal/logger"
+	"farm/internal/metrics"
+	"farm/internal/models"
+	"farm/internal/notify"
+	"farm/internal/scheduler"
 	"farm/internal/store"
+	"farm/internal/store/memory"
+	"farm/internal/store/mysql"
 	"farm/internal/store/postgres"
 	"farm/internal/store/sqlite"
+	"farm/internal/tracing"
+	"farm/internal/validate"
+	"farm/internal/webhook"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
 type Server struct {
-	e     *echo.Echo
-	cfg   *config.Config
-	store store.Repository
+	e               *echo.Echo
+	cfg             *config.Config
+	store           store.Repository
+	handler         *api.Handler
+	sched           *scheduler.Scheduler
+	shutdownTracing func(context.Context) error
 }
 
 func New(configPath string) (*Server, error) {
@@ -35,6 +57,10 @@ func New(configPath string) (*Server, error) {
 	if err := logger.Setup(&cfg.Logging); err != nil {
 		return nil, fmt.Errorf("failed to setup logger: %w", err)
 	}
+	shutdownTracing, err := tracing.Setup(context.Background(), &cfg.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup tracing: %w", err)
+	}
 
 	// 3. Init Store
 	slog.Info("Connecting to database", "driver", cfg.Database.Driver, "connection_string", cfg.Database.ConnectionString)
@@ -47,6 +73,10 @@ func New(configPath string) (*Server, error) {
 		s, errStore = sqlite.NewSQLiteStore(cfg)
 	case "postgres":
 		s, errStore = postgres.NewPostgresStore(cfg)
+	case "mysql":
+		s, errStore = mysql.NewMySQLStore(cfg)
+	case "memory":
+		s = memory.NewMemoryStore(cfg)
 	default:
 		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Database.Driver)
 	}
@@ -54,39 +84,94 @@ func New(configPath string) (*Server, error) {
 	if errStore != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", errStore)
 	}
+	logDatabaseSettings(&cfg.Database)
+
+	notifier, err := notify.NewNotifier(&cfg.Notifications)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup notifications: %w", err)
+	}
+
+	var m *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		m = metrics.New()
+		m.RegisterDBStats(s) // Before wrapping, which hides the pool
+	}
+
+	s = store.WithTimeouts(s, time.Duration(cfg.Database.QueryTimeout), time.Duration(cfg.Database.TxTimeout))
+	if cfg.Tracing.Enabled {
+		s = tracing.Instrument(s, cfg.Database.Driver)
+	}
+	if m != nil {
+		m.RegisterStock(s)
+		s = m.Instrument(s)
+	}
 
 	// 4. Init Handlers
-	handler := api.NewHandler(s, cfg)
+	bus := events.NewBus(cfg.Stream.ClientBuffer)
+	handler := api.NewHandler(s, cfg, bus, m)
 
 	// 5. Init Echo
 	e := echo.New()
 	e.HideBanner = true
+	e.HTTPErrorHandler = api.ErrorHandler // RFC 7807 problem details
+	e.Validator = validate.New(cfg.Passwords)
+	if e.IPExtractor, err = newIPExtractor(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
 
 	// Middleware: Recovery
 	e.Use(middleware.Recover())
 
+	// Middleware: Prometheus request metrics
+	if m != nil {
+		e.Use(m.Middleware())
+		e.GET("/metrics", m.Handler(cfg.Metrics.Token))
+	}
+
+	// Middleware: Request ID (returned in X-Request-ID and recorded in the
+	// audit log with the caller's own, if any)
+	e.Use(requestID())
+
+	// Middleware: Trace context from traceparent, and a request-scoped
+	// logger carrying the request and trace IDs
+	e.Use(tracing.Middleware())
+	e.Use(logger.Middleware())
+
 	// Middleware: Request Logger (slog integration)
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		Skipper: func(c echo.Context) bool {
+			// Probes arrive every few seconds; failures are logged by the handler
+			return c.Path() == "/healthz" || c.Path() == "/readyz"
+		},
 		LogStatus:   true,
-		LogURI:      true,
 		LogMethod:   true,
 		HandleError: true, // Forward error to the global error handler
 		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
 			attrs := []slog.Attr{
-				slog.String("uri", v.URI),
+				slog.String("uri", redactURI(c.Request().URL)),
 				slog.String("method", v.Method),
 				slog.Int("status", v.Status),
 			}
+			ctx := c.Request().Context()
+			level := slog.LevelInfo
 			if v.Error != nil {
+				// Handlers report client errors, such as a missing record,
+				// as errors too; only server failures are logged as such.
 				attrs = append(attrs, slog.String("err", v.Error.Error()))
-				slog.LogAttrs(context.Background(), slog.LevelError, "http_request", attrs...)
-			} else {
-				slog.LogAttrs(context.Background(), slog.LevelInfo, "http_request", attrs...)
+				if v.Status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
 			}
+			logger.FromContext(ctx).LogAttrs(ctx, level, "http_request", attrs...)
 			return nil
 		},
 	}))
 
+	// Probes
+	e.GET("/healthz", handler.Healthz)
+	e.GET("/readyz", handler.Readyz)
+	e.GET("/version", handler.Version)
+
 	// Public Routes
 	e.POST("/signup", handler.Signup)
 	e.POST("/login", handler.Login)
@@ -98,43 +183,244 @@ func New(configPath string) (*Server, error) {
 		},
 		SigningKey: []byte(cfg.JWTSecret),
 	}
+
+	// Browsers' EventSource cannot set headers, so streams also accept the
+	// token as ?access_token=.
+	streamJWTConfig := jwtConfig
+	streamJWTConfig.TokenLookup = "header:Authorization:Bearer ,query:access_token"
+	e.GET("/api/stream/inventory", handler.StreamInventory, echojwt.WithConfig(streamJWTConfig))
+
 	r := e.Group("/api")
 	r.Use(echojwt.WithConfig(jwtConfig))
+	r.Use(handler.Session)
+	r.Use(handler.Idempotent) // Replays retried requests that carry an Idempotency-Key
 
 	r.GET("/me", handler.GetMe)
 	r.PUT("/me", handler.UpdateMe)
+	r.DELETE("/me", handler.DeleteMe)
+	r.GET("/me/export", handler.ExportMe)
+	r.POST("/me/erasure/cancel", handler.CancelErasure)
+	r.PUT("/me/preferences", handler.UpdateMyPreferences)
+	r.GET("/me/notifications", handler.ListMyNotifications)
+	r.POST("/me/notifications/read", handler.MarkAllNotificationsRead)
+	r.POST("/me/notifications/:id/read", handler.MarkNotificationRead)
 	r.GET("/reservations", handler.ListMyReservations)
+	r.POST("/reservations/:id/cancel", handler.CancelMyReservation)
 	r.GET("/products", handler.ListProducts)
 	r.GET("/activities", handler.ListActivities)
 	r.POST("/reserve", handler.CreateReservation)
+	r.GET("/pickups", handler.ListPickupSlots)
+
+	// Staff Routes (admins included)
+	staff := r.Group("/staff")
+	staff.Use(handler.StaffOnly)
+
+	staff.GET("/pickups", handler.PickupManifest)
+	staff.GET("/reservations/:id", handler.GetReservation)
+	staff.POST("/reservations/:id/check-in", handler.CheckInReservation)
+	staff.POST("/reservations/:id/fulfil", handler.FulfilReservation)
+	staff.POST("/reservations/:id/no-show", handler.MarkNoShow)
 
 	// Admin Routes
 	admin := r.Group("/admin")
 	admin.Use(handler.AdminOnly)
 
 	admin.POST("/products", handler.CreateProduct)
+	admin.GET("/products/:id", handler.GetProduct)
 	admin.PUT("/products/:id", handler.UpdateProduct)
+	admin.PATCH("/products/:id", handler.PatchProduct)
+	admin.POST("/products/:id/stock", handler.AdjustStock)
 	admin.DELETE("/products/:id", handler.DeleteProduct)
+	admin.POST("/products/:id/restore", handler.RestoreProduct)
 	admin.GET("/products", handler.ListAllProducts)
+	admin.POST("/products/import", handler.ImportProducts)
+	admin.GET("/products/export", handler.ExportProducts)
 	admin.POST("/activities", handler.CreateActivity)
+	admin.GET("/activities/:id", handler.GetActivity)
 	admin.PUT("/activities/:id", handler.UpdateActivity)
+	admin.PATCH("/activities/:id", handler.PatchActivity)
+	admin.POST("/activities/:id/capacity", handler.AdjustCapacity)
 	admin.DELETE("/activities/:id", handler.DeleteActivity)
+	admin.POST("/activities/:id/restore", handler.RestoreActivity)
 	admin.GET("/activities", handler.ListAllActivities)
+	admin.POST("/activities/import", handler.ImportActivities)
+	admin.GET("/activities/export", handler.ExportActivities)
 	admin.GET("/reservations", handler.ListReservations)
+	admin.GET("/reservations/:id", handler.GetReservation)
+	admin.POST("/reservations/:id/status", handler.UpdateReservationStatus)
 	admin.DELETE("/reservations/:id", handler.DeleteReservation)
+	admin.GET("/pickups", handler.PickupManifest)
 	admin.GET("/users", handler.ListUsers)
+	admin.GET("/users/:id", handler.GetUser)
 	admin.DELETE("/users/:id", handler.DeleteUser)
+	admin.POST("/users/:id/restore", handler.RestoreUser)
+	admin.POST("/users/import", handler.ImportUsers)
+	admin.GET("/users/export", handler.ExportUsers)
 	admin.POST("/users/:id/credits", handler.UpdateCredits)
 	admin.POST("/users/:id/role", handler.UpdateRole)
+	admin.DELETE("/users/:id/ban", handler.LiftBan)
+	admin.POST("/webhooks", handler.CreateWebhook)
+	admin.GET("/webhooks", handler.ListWebhooks)
+	admin.GET("/webhooks/:id", handler.GetWebhook)
+	admin.DELETE("/webhooks/:id", handler.DeleteWebhook)
+	admin.GET("/webhooks/:id/deliveries", handler.ListWebhookDeliveries)
+	admin.GET("/audit", handler.ListAuditLog)
+	admin.GET("/reports/reservations", handler.ReservationsReport)
+	admin.GET("/reports/activity-fill", handler.ActivityFillReport)
+	admin.GET("/reports/sell-through", handler.SellThroughReport)
+	admin.GET("/reports/ranks", handler.RankDistributionReport)
+	admin.GET("/reports/no-shows", handler.NoShowReport)
+	admin.GET("/reports/top-customers", handler.TopCustomersReport)
+
+	srv := &Server{
+		e:               e,
+		cfg:             cfg,
+		store:           s,
+		handler:         handler,
+		sched:           scheduler.New(),
+		shutdownTracing: shutdownTracing,
+	}
+
+	// 6. Background Jobs
+	if cfg.Reservations.HoldPeriod > 0 {
+		srv.sched.Add("expire_reservations", time.Duration(cfg.Reservations.ExpiryInterval), srv.expireReservations)
+	}
+	if cfg.Retention.PurgeAfter > 0 {
+		srv.sched.Add("purge_deleted", time.Duration(cfg.Retention.PurgeInterval), srv.purgeDeleted)
+	}
+	srv.sched.Add("erase_customers", time.Duration(cfg.Privacy.ErasureInterval), srv.eraseCustomers)
+	if cfg.Outbox.Retention > 0 {
+		srv.sched.Add("purge_outbox", time.Duration(cfg.Outbox.PurgeInterval), srv.purgeOutbox)
+	}
+	srv.sched.Add("purge_idempotency_keys", time.Duration(cfg.Idempotency.PurgeInterval), srv.purgeIdempotencyKeys)
+	srv.sched.Add("relay_inventory_events", time.Duration(cfg.Stream.PollInterval), events.NewRelay(s, bus, models.EventInventoryChanged).Run)
+	srv.sched.Add("notify_customers", time.Duration(cfg.Notifications.PollInterval), notify.NewService(s, notifier).Run)
+	srv.sched.Add("deliver_webhooks", time.Duration(cfg.Webhooks.PollInterval), webhook.NewDispatcher(s, cfg.Webhooks).Run)
 
-	return &Server{
-		e:     e,
-		cfg:   cfg,
-		store: s,
-	}, nil
+	return srv, nil
 }
 
+// Start serves until SIGINT or SIGTERM, then shuts down gracefully: /readyz
+// fails for the shutdown delay, in-flight requests get the shutdown timeout to
+// finish, and background jobs are stopped last.
 func (s *Server) Start() error {
-	slog.Info("Starting server", "port", s.cfg.Server.Port)
-	return s.e.Start(s.cfg.Server.Port)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		s.sched.Wait()
+	}()
+	s.sched.Start(ctx)
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "port", s.cfg.Server.Port)
+		errc <- s.e.Start(s.cfg.Server.Port)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-sigCtx.Done():
+	}
+	stop() // A second signal kills the process
+
+	delay := time.Duration(s.cfg.Server.ShutdownDelay)
+	slog.Info("Shutting down", "delay", delay.String())
+	s.handler.Drain()
+	time.Sleep(delay)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Duration(s.cfg.Server.ShutdownTimeout))
+	defer cancelShutdown()
+	if err := s.e.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Graceful shutdown timed out, closing connections", "error", err)
+		s.e.Close()
+	}
+	<-errc
+	if err := s.shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Server stopped")
+	return nil
+}
+
+// redactURI returns the request URI to log, masking any access_token: the
+// inventory stream takes the JWT in the query string, as EventSource can't
+// send headers.
+func redactURI(u *url.URL) string {
+	q := u.Query()
+	if !q.Has("access_token") {
+		return u.RequestURI()
+	}
+	q.Set("access_token", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = q.Encode()
+	return redacted.RequestURI()
+}
+
+// logDatabaseSettings records the pool and dialect settings the store was
+// opened with.
+func logDatabaseSettings(db *config.DatabaseConfig) {
+	attrs := []any{
+		"driver", db.Driver,
+		"max_open_conns", db.MaxOpenConns,
+		"max_idle_conns", db.MaxIdleConns,
+		"conn_max_lifetime", time.Duration(db.ConnMaxLifetime).String(),
+		"conn_max_idle_time", time.Duration(db.ConnMaxIdleTime).String(),
+		"query_timeout", time.Duration(db.QueryTimeout).String(),
+		"tx_timeout", time.Duration(db.TxTimeout).String(),
+	}
+	switch db.Driver {
+	case "sqlite":
+		attrs = append(attrs, slog.Group("sqlite",
+			"journal_mode", db.SQLite.JournalMode,
+			"busy_timeout", time.Duration(db.SQLite.BusyTimeout).String(),
+			"synchronous", db.SQLite.Synchronous))
+	case "postgres":
+		attrs = append(attrs, slog.Group("postgres",
+			"statement_timeout", time.Duration(db.Postgres.StatementTimeout).String(),
+			"application_name", db.Postgres.ApplicationName,
+			"replicas", len(db.Replicas.ConnectionStrings)))
+	}
+	slog.Info("Database connected", attrs...)
+}
+
+// newIPExtractor returns how a request's client IP is found: the connecting
+// address, or with proxies configured, the last X-Forwarded-For hop outside
+// them. Private and loopback addresses are only trusted when listed.
+func newIPExtractor(proxies []string) (echo.IPExtractor, error) {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, p := range proxies {
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, echo.TrustIPRange(network))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+// maxClientRequestID bounds the caller's X-Request-ID, which is logged and
+// audited.
+const maxClientRequestID = 128
+
+// requestID gives every request a server-generated ID in the X-Request-ID
+// response header. The caller's own X-Request-ID stays on the request so it
+// can be logged and audited too, unless it is too long or not printable.
+func requestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if id := req.Header.Get(echo.HeaderXRequestID); len(id) > maxClientRequestID || strings.ContainsFunc(id, func(r rune) bool { return r < 0x20 || r > 0x7e }) {
+				req.Header.Del(echo.HeaderXRequestID)
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, uuid.NewString())
+			return next(c)
+		}
+	}
 }
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRedactURI(t *testing.T) {
+	tests := []struct{ uri, want string }{
+		{"/api/products", "/api/products"},
+		{"/api/products?visible=true", "/api/products?visible=true"},
+		{"/api/stream/inventory?access_token=eyJhbGciOi.x.y", "/api/stream/inventory?access_token=REDACTED"},
+		{"/api/stream/inventory?a=1&access_token=t&access_token=u", "/api/stream/inventory?a=1&access_token=REDACTED"},
+	}
+	for _, tt := range tests {
+		u, err := url.ParseRequestURI(tt.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := redactURI(u); got != tt.want {
+			t.Errorf("redactURI(%s) = %s, want %s", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestNewIPExtractor(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		remote  string
+		xff     string
+		want    string
+	}{
+		{"direct ignores XFF", nil, "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"direct from a private address", nil, "10.0.0.5:1234", "198.51.100.1", "10.0.0.5"},
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "198.51.100.1", "198.51.100.1"},
+		{"client spoofing behind a proxy", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "192.0.2.9, 198.51.100.1", "198.51.100.1"},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"loopback only when listed", []string{"10.0.0.0/8"}, "127.0.0.1:1234", "198.51.100.1", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extract, err := newIPExtractor(tt.proxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set(echo.HeaderXForwardedFor, tt.xff)
+			if got := extract(req); got != tt.want {
+				t.Errorf("client IP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := newIPExtractor([]string{"10.0.0.1"}); err == nil {
+		t.Error("newIPExtractor accepted an address without a prefix length")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name, sent, wantClient string
+	}{
+		{"none sent", "", ""},
+		{"kept alongside", "my-req-123", "my-req-123"},
+		{"too long", strings.Repeat("a", maxClientRequestID+1), ""},
+		{"not printable", "a\x00b", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			var client string
+			handler := requestID()(func(c echo.Context) error {
+				client = c.Request().Header.Get(echo.HeaderXRequestID)
+				return c.NoContent(http.StatusNoContent)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.sent != "" {
+				req.Header.Set(echo.HeaderXRequestID, tt.sent)
+			}
+			rec := httptest.NewRecorder()
+			if err := handler(e.NewContext(req, rec)); err != nil {
+				t.Fatal(err)
+			}
+			id := rec.Header().Get(echo.HeaderXRequestID)
+			if id == "" || id == tt.sent {
+				t.Errorf("X-Request-ID = %q; want one generated by the server", id)
+			}
+			if client != tt.wantClient {
+				t.Errorf("caller's request ID = %q, want %q", client, tt.wantClient)
+			}
+		})
+	}
+}
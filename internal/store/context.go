@@ -0,0 +1,18 @@
+package store
+
+import "context"
+
+type customerKey struct{}
+
+// WithCustomer returns a copy of ctx acting for customerID. Stores that read
+// from replicas send the customer's reads to the primary for a while after
+// they write, so they see their own changes.
+func WithCustomer(ctx context.Context, customerID string) context.Context {
+	return context.WithValue(ctx, customerKey{}, customerID)
+}
+
+// CustomerFromContext returns the customer set by WithCustomer, or "".
+func CustomerFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(customerKey{}).(string)
+	return id
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"farm/internal/models"
+)
+
+// RedactCustomer returns doc, a JSON document such as an audit snapshot or an
+// event payload, with every mention of the erased customer id replaced by
+// pseudonym. Objects describing the customer also have their name and email
+// replaced, as EraseCustomer replaces the customer's own record. Stores run
+// each document naming the customer through it as they erase them.
+func RedactCustomer(doc []byte, id, pseudonym string) ([]byte, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber() // Keep large IDs and exact amounts as written
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redact(v, id, pseudonym))
+}
+
+func redact(v any, id, pseudonym string) any {
+	switch v := v.(type) {
+	case string:
+		if v == id {
+			return pseudonym
+		}
+	case []any:
+		for i := range v {
+			v[i] = redact(v[i], id, pseudonym)
+		}
+	case map[string]any:
+		if v["id"] == id || v["customer_id"] == id {
+			if _, ok := v["email"]; ok {
+				v["email"] = pseudonym + models.ErasedEmailDomain
+			}
+			if _, ok := v["name"]; ok {
+				v["name"] = models.ErasedName
+			}
+		}
+		for k := range v {
+			v[k] = redact(v[k], id, pseudonym)
+		}
+	}
+	return v
+}
@@ -0,0 +1,46 @@
+package store
+
+import "testing"
+
+func TestRedactCustomer(t *testing.T) {
+	tests := []struct {
+		name, doc, want string
+	}{
+		{
+			"customer snapshot",
+			`{"id":"alice","email":"alice@example.com","name":"Alice","credits":120,"version":3}`,
+			`{"credits":120,"email":"p1@erased.invalid","id":"p1","name":"Erased customer","version":3}`,
+		},
+		{
+			"reservation",
+			`{"id":"r1","customer_id":"alice","item_id":"eggs","history":[{"actor_id":"alice","to_status":"cancelled"}]}`,
+			`{"customer_id":"p1","history":[{"actor_id":"p1","to_status":"cancelled"}],"id":"r1","item_id":"eggs"}`,
+		},
+		{
+			"webhook body",
+			`{"id":12345678901234567890,"type":"customer.credits_updated","data":{"customer_id":"alice","credits":5}}`,
+			`{"data":{"credits":5,"customer_id":"p1"},"id":12345678901234567890,"type":"customer.credits_updated"}`,
+		},
+		{
+			"someone else",
+			`{"id":"bob","email":"bob@example.com","name":"Bob"}`,
+			`{"email":"bob@example.com","id":"bob","name":"Bob"}`,
+		},
+		{"product", `{"id":"eggs","name":"Eggs"}`, `{"id":"eggs","name":"Eggs"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RedactCustomer([]byte(tt.doc), "alice", "p1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("RedactCustomer = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := RedactCustomer([]byte(`{"id":`), "alice", "p1"); err == nil {
+		t.Error("invalid JSON redacted without error")
+	}
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by Repository implementations, possibly wrapped with more
+// detail; test for them with errors.Is. Their messages are written here or by
+// the stores, never by the database, so they are safe to show to clients. Any
+// other error is a database failure.
+var (
+	// ErrNotFound is returned when the record asked for doesn't exist or is
+	// deleted.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write would duplicate a unique key, such
+	// as an ID or a customer's email.
+	ErrConflict = errors.New("already exists")
+	// ErrInvalid is returned for requests no state of the database could
+	// satisfy, such as an unknown reservation type.
+	ErrInvalid = errors.New("invalid request")
+
+	// ErrOutOfStock and ErrFullyBooked are returned by ReserveItem when
+	// nothing is left and the customer didn't ask to join the waitlist.
+	ErrOutOfStock  = errors.New("product out of stock")
+	ErrFullyBooked = errors.New("activity fully booked")
+	// ErrSlotFull is returned by ReserveItem when the pickup slot has no room.
+	ErrSlotFull = errors.New("pickup slot full")
+
+	ErrInvalidTransition = errors.New("invalid reservation status transition")
+
+	// ErrActiveReservations is returned when deleting an item or customer that
+	// pending, waitlisted or confirmed reservations still depend on.
+	ErrActiveReservations = errors.New("active reservations exist")
+
+	// ErrStale is returned when a conditional update names a version of the
+	// record that is no longer current.
+	ErrStale = errors.New("has been modified since it was read")
+)
+
+// CheckVersion returns ErrStale, naming what, unless the record's current
+// version is the one the caller expects. A zero expected version skips the
+// check.
+func CheckVersion(what string, current, expected int) error {
+	if expected != 0 && current != expected {
+		return fmt.Errorf("%s %w (now version %d)", what, ErrStale, current)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"farm/internal/models"
+	"strconv"
+)
+
+// CheckImport returns the error, if any, for import row updating a record now
+// at version current. A row giving the version it was exported at must match
+// it. A row without one may not change the record's stock or capacity, named
+// by field, since reservations move it while the file is being edited;
+// changesUnits says whether the row would.
+func CheckImport(row int, field string, current, version int, changesUnits bool) *models.ImportRowError {
+	switch {
+	case version != 0 && version != current:
+		return &models.ImportRowError{Row: row, Field: "version", Message: "is now " + strconv.Itoa(current) + "; export again and reapply the change"}
+	case version == 0 && changesUnits:
+		return &models.ImportRowError{Row: row, Field: field, Message: "can only be changed by a row with the version column"}
+	}
+	return nil
+}
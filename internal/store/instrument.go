@@ -0,0 +1,526 @@
+package store
+
+import (
+	"context"
+	"farm/internal/models"
+	"time"
+)
+
+// Observer is called as each Repository call made through an instrumented
+// store starts. It returns the context to make the call with and a function
+// that receives the call's error when it returns.
+type Observer func(ctx context.Context, method string) (context.Context, func(err error))
+
+// Instrument wraps next so every call is reported to observe.
+func Instrument(next Repository, observe Observer) Repository {
+	return &instrumented{next: next, observe: observe}
+}
+
+// instrumented is maintained by hand, one method per Repository method; the
+// compiler flags methods added to the interface that are missing here.
+type instrumented struct {
+	next    Repository
+	observe Observer
+}
+
+func (s *instrumented) Ping(ctx context.Context) (err error) {
+	ctx, done := s.observe(ctx, "Ping")
+	defer func() { done(err) }()
+	return s.next.Ping(ctx)
+}
+
+func (s *instrumented) MigrationStatus(ctx context.Context) (_ int, _ int, err error) {
+	ctx, done := s.observe(ctx, "MigrationStatus")
+	defer func() { done(err) }()
+	return s.next.MigrationStatus(ctx)
+}
+
+func (s *instrumented) AddCustomer(ctx context.Context, c *models.Customer) (err error) {
+	ctx, done := s.observe(ctx, "AddCustomer")
+	defer func() { done(err) }()
+	return s.next.AddCustomer(ctx, c)
+}
+
+func (s *instrumented) GetCustomer(ctx context.Context, id string) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "GetCustomer")
+	defer func() { done(err) }()
+	return s.next.GetCustomer(ctx, id)
+}
+
+func (s *instrumented) GetCustomerByEmail(ctx context.Context, email string) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "GetCustomerByEmail")
+	defer func() { done(err) }()
+	return s.next.GetCustomerByEmail(ctx, email)
+}
+
+func (s *instrumented) GetAllCustomers(ctx context.Context) (_ []*models.Customer, err error) {
+	ctx, done := s.observe(ctx, "GetAllCustomers")
+	defer func() { done(err) }()
+	return s.next.GetAllCustomers(ctx)
+}
+
+func (s *instrumented) UpdateCustomerCredits(ctx context.Context, id string, credits, version int) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "UpdateCustomerCredits")
+	defer func() { done(err) }()
+	return s.next.UpdateCustomerCredits(ctx, id, credits, version)
+}
+
+func (s *instrumented) UpdateCustomerRole(ctx context.Context, id string, role string, version int) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "UpdateCustomerRole")
+	defer func() { done(err) }()
+	return s.next.UpdateCustomerRole(ctx, id, role, version)
+}
+
+func (s *instrumented) UpdateCustomerName(ctx context.Context, id string, name string) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "UpdateCustomerName")
+	defer func() { done(err) }()
+	return s.next.UpdateCustomerName(ctx, id, name)
+}
+
+func (s *instrumented) UpdateCustomerPreferences(ctx context.Context, id string, notifyEmail, notifyInApp bool) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "UpdateCustomerPreferences")
+	defer func() { done(err) }()
+	return s.next.UpdateCustomerPreferences(ctx, id, notifyEmail, notifyInApp)
+}
+
+func (s *instrumented) ClearCustomerBan(ctx context.Context, id string) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "ClearCustomerBan")
+	defer func() { done(err) }()
+	return s.next.ClearCustomerBan(ctx, id)
+}
+
+func (s *instrumented) GetCreditHistory(ctx context.Context, customerID string) (_ []*models.CreditChange, err error) {
+	ctx, done := s.observe(ctx, "GetCreditHistory")
+	defer func() { done(err) }()
+	return s.next.GetCreditHistory(ctx, customerID)
+}
+
+func (s *instrumented) ScheduleErasure(ctx context.Context, id string, at *time.Time) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "ScheduleErasure")
+	defer func() { done(err) }()
+	return s.next.ScheduleErasure(ctx, id, at)
+}
+
+func (s *instrumented) GetCustomersDueForErasure(ctx context.Context, now time.Time) (_ []*models.Customer, err error) {
+	ctx, done := s.observe(ctx, "GetCustomersDueForErasure")
+	defer func() { done(err) }()
+	return s.next.GetCustomersDueForErasure(ctx, now)
+}
+
+func (s *instrumented) EraseCustomer(ctx context.Context, id string) (_ string, err error) {
+	ctx, done := s.observe(ctx, "EraseCustomer")
+	defer func() { done(err) }()
+	return s.next.EraseCustomer(ctx, id)
+}
+
+func (s *instrumented) AddProduct(ctx context.Context, p *models.Product) (err error) {
+	ctx, done := s.observe(ctx, "AddProduct")
+	defer func() { done(err) }()
+	return s.next.AddProduct(ctx, p)
+}
+
+func (s *instrumented) GetProduct(ctx context.Context, id string) (_ *models.Product, err error) {
+	ctx, done := s.observe(ctx, "GetProduct")
+	defer func() { done(err) }()
+	return s.next.GetProduct(ctx, id)
+}
+
+func (s *instrumented) GetAllProducts(ctx context.Context, visibleOnly bool) (_ []*models.Product, err error) {
+	ctx, done := s.observe(ctx, "GetAllProducts")
+	defer func() { done(err) }()
+	return s.next.GetAllProducts(ctx, visibleOnly)
+}
+
+func (s *instrumented) UpdateProduct(ctx context.Context, p *models.Product) (err error) {
+	ctx, done := s.observe(ctx, "UpdateProduct")
+	defer func() { done(err) }()
+	return s.next.UpdateProduct(ctx, p)
+}
+
+func (s *instrumented) PatchProduct(ctx context.Context, id string, version int, patch func(*models.Product) error) (_ *models.Product, err error) {
+	ctx, done := s.observe(ctx, "PatchProduct")
+	defer func() { done(err) }()
+	return s.next.PatchProduct(ctx, id, version, patch)
+}
+
+func (s *instrumented) AdjustProductStock(ctx context.Context, id string, delta int) (_ *models.Product, err error) {
+	ctx, done := s.observe(ctx, "AdjustProductStock")
+	defer func() { done(err) }()
+	return s.next.AdjustProductStock(ctx, id, delta)
+}
+
+func (s *instrumented) AddActivity(ctx context.Context, a *models.Activity) (err error) {
+	ctx, done := s.observe(ctx, "AddActivity")
+	defer func() { done(err) }()
+	return s.next.AddActivity(ctx, a)
+}
+
+func (s *instrumented) GetActivity(ctx context.Context, id string) (_ *models.Activity, err error) {
+	ctx, done := s.observe(ctx, "GetActivity")
+	defer func() { done(err) }()
+	return s.next.GetActivity(ctx, id)
+}
+
+func (s *instrumented) GetAllActivities(ctx context.Context, visibleOnly bool) (_ []*models.Activity, err error) {
+	ctx, done := s.observe(ctx, "GetAllActivities")
+	defer func() { done(err) }()
+	return s.next.GetAllActivities(ctx, visibleOnly)
+}
+
+func (s *instrumented) UpdateActivity(ctx context.Context, a *models.Activity) (err error) {
+	ctx, done := s.observe(ctx, "UpdateActivity")
+	defer func() { done(err) }()
+	return s.next.UpdateActivity(ctx, a)
+}
+
+func (s *instrumented) PatchActivity(ctx context.Context, id string, version int, patch func(*models.Activity) error) (_ *models.Activity, err error) {
+	ctx, done := s.observe(ctx, "PatchActivity")
+	defer func() { done(err) }()
+	return s.next.PatchActivity(ctx, id, version, patch)
+}
+
+func (s *instrumented) AdjustActivityCapacity(ctx context.Context, id string, delta int) (_ *models.Activity, err error) {
+	ctx, done := s.observe(ctx, "AdjustActivityCapacity")
+	defer func() { done(err) }()
+	return s.next.AdjustActivityCapacity(ctx, id, delta)
+}
+
+func (s *instrumented) AddReservation(ctx context.Context, r *models.Reservation) (err error) {
+	ctx, done := s.observe(ctx, "AddReservation")
+	defer func() { done(err) }()
+	return s.next.AddReservation(ctx, r)
+}
+
+func (s *instrumented) GetAllReservations(ctx context.Context) (_ []*models.Reservation, err error) {
+	ctx, done := s.observe(ctx, "GetAllReservations")
+	defer func() { done(err) }()
+	return s.next.GetAllReservations(ctx)
+}
+
+func (s *instrumented) GetReservationsByCustomerID(ctx context.Context, customerID string) (_ []*models.Reservation, err error) {
+	ctx, done := s.observe(ctx, "GetReservationsByCustomerID")
+	defer func() { done(err) }()
+	return s.next.GetReservationsByCustomerID(ctx, customerID)
+}
+
+func (s *instrumented) GetReservationsByPickupRange(ctx context.Context, from, to time.Time) (_ []*models.Reservation, err error) {
+	ctx, done := s.observe(ctx, "GetReservationsByPickupRange")
+	defer func() { done(err) }()
+	return s.next.GetReservationsByPickupRange(ctx, from, to)
+}
+
+func (s *instrumented) CountPickupReservations(ctx context.Context, locationID string, start time.Time) (_ int, err error) {
+	ctx, done := s.observe(ctx, "CountPickupReservations")
+	defer func() { done(err) }()
+	return s.next.CountPickupReservations(ctx, locationID, start)
+}
+
+func (s *instrumented) GetReservation(ctx context.Context, id string) (_ *models.Reservation, err error) {
+	ctx, done := s.observe(ctx, "GetReservation")
+	defer func() { done(err) }()
+	return s.next.GetReservation(ctx, id)
+}
+
+func (s *instrumented) ReserveItem(ctx context.Context, r *models.Reservation) (err error) {
+	ctx, done := s.observe(ctx, "ReserveItem")
+	defer func() { done(err) }()
+	return s.next.ReserveItem(ctx, r)
+}
+
+func (s *instrumented) TransitionReservation(ctx context.Context, id string, to models.ReservationStatus, actorID string) (_ *models.Reservation, err error) {
+	ctx, done := s.observe(ctx, "TransitionReservation")
+	defer func() { done(err) }()
+	return s.next.TransitionReservation(ctx, id, to, actorID)
+}
+
+func (s *instrumented) GetExpiredReservations(ctx context.Context, cutoff time.Time) (_ []*models.Reservation, err error) {
+	ctx, done := s.observe(ctx, "GetExpiredReservations")
+	defer func() { done(err) }()
+	return s.next.GetExpiredReservations(ctx, cutoff)
+}
+
+func (s *instrumented) GetOutboxEvents(ctx context.Context, consumer string, limit int) (_ []*models.Event, err error) {
+	ctx, done := s.observe(ctx, "GetOutboxEvents")
+	defer func() { done(err) }()
+	return s.next.GetOutboxEvents(ctx, consumer, limit)
+}
+
+func (s *instrumented) GetOutboxEventsAfter(ctx context.Context, afterID int64, limit int) (_ []*models.Event, err error) {
+	ctx, done := s.observe(ctx, "GetOutboxEventsAfter")
+	defer func() { done(err) }()
+	return s.next.GetOutboxEventsAfter(ctx, afterID, limit)
+}
+
+func (s *instrumented) GetLatestOutboxEventID(ctx context.Context) (_ int64, err error) {
+	ctx, done := s.observe(ctx, "GetLatestOutboxEventID")
+	defer func() { done(err) }()
+	return s.next.GetLatestOutboxEventID(ctx)
+}
+
+func (s *instrumented) AdvanceOutboxCursor(ctx context.Context, consumer string, lastID int64) (err error) {
+	ctx, done := s.observe(ctx, "AdvanceOutboxCursor")
+	defer func() { done(err) }()
+	return s.next.AdvanceOutboxCursor(ctx, consumer, lastID)
+}
+
+func (s *instrumented) LeaseOutboxConsumer(ctx context.Context, consumer, holder string, now, until time.Time) (_ bool, err error) {
+	ctx, done := s.observe(ctx, "LeaseOutboxConsumer")
+	defer func() { done(err) }()
+	return s.next.LeaseOutboxConsumer(ctx, consumer, holder, now, until)
+}
+
+func (s *instrumented) PurgeOutbox(ctx context.Context, cutoff time.Time) (_ int64, err error) {
+	ctx, done := s.observe(ctx, "PurgeOutbox")
+	defer func() { done(err) }()
+	return s.next.PurgeOutbox(ctx, cutoff)
+}
+
+func (s *instrumented) AddWebhook(ctx context.Context, w *models.Webhook) (err error) {
+	ctx, done := s.observe(ctx, "AddWebhook")
+	defer func() { done(err) }()
+	return s.next.AddWebhook(ctx, w)
+}
+
+func (s *instrumented) GetWebhook(ctx context.Context, id string) (_ *models.Webhook, err error) {
+	ctx, done := s.observe(ctx, "GetWebhook")
+	defer func() { done(err) }()
+	return s.next.GetWebhook(ctx, id)
+}
+
+func (s *instrumented) GetAllWebhooks(ctx context.Context) (_ []*models.Webhook, err error) {
+	ctx, done := s.observe(ctx, "GetAllWebhooks")
+	defer func() { done(err) }()
+	return s.next.GetAllWebhooks(ctx)
+}
+
+func (s *instrumented) DeleteWebhook(ctx context.Context, id string) (err error) {
+	ctx, done := s.observe(ctx, "DeleteWebhook")
+	defer func() { done(err) }()
+	return s.next.DeleteWebhook(ctx, id)
+}
+
+func (s *instrumented) AddWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) (err error) {
+	ctx, done := s.observe(ctx, "AddWebhookDeliveries")
+	defer func() { done(err) }()
+	return s.next.AddWebhookDeliveries(ctx, deliveries)
+}
+
+func (s *instrumented) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) (_ []*models.WebhookDelivery, err error) {
+	ctx, done := s.observe(ctx, "GetDueWebhookDeliveries")
+	defer func() { done(err) }()
+	return s.next.GetDueWebhookDeliveries(ctx, now, limit)
+}
+
+func (s *instrumented) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) (_ bool, err error) {
+	ctx, done := s.observe(ctx, "ClaimWebhookDelivery")
+	defer func() { done(err) }()
+	return s.next.ClaimWebhookDelivery(ctx, id, now, until)
+}
+
+func (s *instrumented) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) (_ []*models.WebhookDelivery, err error) {
+	ctx, done := s.observe(ctx, "GetWebhookDeliveries")
+	defer func() { done(err) }()
+	return s.next.GetWebhookDeliveries(ctx, webhookID, limit)
+}
+
+func (s *instrumented) UpdateWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) (err error) {
+	ctx, done := s.observe(ctx, "UpdateWebhookDelivery")
+	defer func() { done(err) }()
+	return s.next.UpdateWebhookDelivery(ctx, d)
+}
+
+func (s *instrumented) AddNotification(ctx context.Context, n *models.Notification) (err error) {
+	ctx, done := s.observe(ctx, "AddNotification")
+	defer func() { done(err) }()
+	return s.next.AddNotification(ctx, n)
+}
+
+func (s *instrumented) GetNotifications(ctx context.Context, customerID string, unreadOnly bool) (_ []*models.Notification, err error) {
+	ctx, done := s.observe(ctx, "GetNotifications")
+	defer func() { done(err) }()
+	return s.next.GetNotifications(ctx, customerID, unreadOnly)
+}
+
+func (s *instrumented) MarkNotificationRead(ctx context.Context, customerID, id string) (err error) {
+	ctx, done := s.observe(ctx, "MarkNotificationRead")
+	defer func() { done(err) }()
+	return s.next.MarkNotificationRead(ctx, customerID, id)
+}
+
+func (s *instrumented) MarkAllNotificationsRead(ctx context.Context, customerID string) (err error) {
+	ctx, done := s.observe(ctx, "MarkAllNotificationsRead")
+	defer func() { done(err) }()
+	return s.next.MarkAllNotificationsRead(ctx, customerID)
+}
+
+func (s *instrumented) ClaimIdempotencyKey(ctx context.Context, k *models.IdempotencyKey) (_ *models.IdempotencyKey, err error) {
+	ctx, done := s.observe(ctx, "ClaimIdempotencyKey")
+	defer func() { done(err) }()
+	return s.next.ClaimIdempotencyKey(ctx, k)
+}
+
+func (s *instrumented) CompleteIdempotencyKey(ctx context.Context, k *models.IdempotencyKey) (err error) {
+	ctx, done := s.observe(ctx, "CompleteIdempotencyKey")
+	defer func() { done(err) }()
+	return s.next.CompleteIdempotencyKey(ctx, k)
+}
+
+func (s *instrumented) ReleaseIdempotencyKey(ctx context.Context, customerID, key string) (err error) {
+	ctx, done := s.observe(ctx, "ReleaseIdempotencyKey")
+	defer func() { done(err) }()
+	return s.next.ReleaseIdempotencyKey(ctx, customerID, key)
+}
+
+func (s *instrumented) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (_ int64, err error) {
+	ctx, done := s.observe(ctx, "PurgeIdempotencyKeys")
+	defer func() { done(err) }()
+	return s.next.PurgeIdempotencyKeys(ctx, now)
+}
+
+func (s *instrumented) WithAudit(e *models.AuditEntry) Repository {
+	return &instrumented{next: s.next.WithAudit(e), observe: s.observe}
+}
+
+func (s *instrumented) GetAuditEntries(ctx context.Context, f models.AuditFilter) (_ []*models.AuditEntry, err error) {
+	ctx, done := s.observe(ctx, "GetAuditEntries")
+	defer func() { done(err) }()
+	return s.next.GetAuditEntries(ctx, f)
+}
+
+func (s *instrumented) DeleteProduct(ctx context.Context, id string) (err error) {
+	ctx, done := s.observe(ctx, "DeleteProduct")
+	defer func() { done(err) }()
+	return s.next.DeleteProduct(ctx, id)
+}
+
+func (s *instrumented) DeleteActivity(ctx context.Context, id string) (err error) {
+	ctx, done := s.observe(ctx, "DeleteActivity")
+	defer func() { done(err) }()
+	return s.next.DeleteActivity(ctx, id)
+}
+
+func (s *instrumented) DeleteReservation(ctx context.Context, id string) (err error) {
+	ctx, done := s.observe(ctx, "DeleteReservation")
+	defer func() { done(err) }()
+	return s.next.DeleteReservation(ctx, id)
+}
+
+func (s *instrumented) DeleteCustomer(ctx context.Context, id string) (err error) {
+	ctx, done := s.observe(ctx, "DeleteCustomer")
+	defer func() { done(err) }()
+	return s.next.DeleteCustomer(ctx, id)
+}
+
+func (s *instrumented) GetDeletedProducts(ctx context.Context) (_ []*models.Product, err error) {
+	ctx, done := s.observe(ctx, "GetDeletedProducts")
+	defer func() { done(err) }()
+	return s.next.GetDeletedProducts(ctx)
+}
+
+func (s *instrumented) GetDeletedActivities(ctx context.Context) (_ []*models.Activity, err error) {
+	ctx, done := s.observe(ctx, "GetDeletedActivities")
+	defer func() { done(err) }()
+	return s.next.GetDeletedActivities(ctx)
+}
+
+func (s *instrumented) GetDeletedCustomers(ctx context.Context) (_ []*models.Customer, err error) {
+	ctx, done := s.observe(ctx, "GetDeletedCustomers")
+	defer func() { done(err) }()
+	return s.next.GetDeletedCustomers(ctx)
+}
+
+func (s *instrumented) RestoreProduct(ctx context.Context, id string) (_ *models.Product, err error) {
+	ctx, done := s.observe(ctx, "RestoreProduct")
+	defer func() { done(err) }()
+	return s.next.RestoreProduct(ctx, id)
+}
+
+func (s *instrumented) RestoreActivity(ctx context.Context, id string) (_ *models.Activity, err error) {
+	ctx, done := s.observe(ctx, "RestoreActivity")
+	defer func() { done(err) }()
+	return s.next.RestoreActivity(ctx, id)
+}
+
+func (s *instrumented) RestoreCustomer(ctx context.Context, id string) (_ *models.Customer, err error) {
+	ctx, done := s.observe(ctx, "RestoreCustomer")
+	defer func() { done(err) }()
+	return s.next.RestoreCustomer(ctx, id)
+}
+
+func (s *instrumented) PurgeDeleted(ctx context.Context, cutoff time.Time) (_ int64, err error) {
+	ctx, done := s.observe(ctx, "PurgeDeleted")
+	defer func() { done(err) }()
+	return s.next.PurgeDeleted(ctx, cutoff)
+}
+
+func (s *instrumented) GetReservationCounts(ctx context.Context, f models.ReportFilter) (_ []*models.ReservationCount, err error) {
+	ctx, done := s.observe(ctx, "GetReservationCounts")
+	defer func() { done(err) }()
+	return s.next.GetReservationCounts(ctx, f)
+}
+
+func (s *instrumented) GetActivityFill(ctx context.Context, f models.ReportFilter) (_ []*models.ActivityFill, err error) {
+	ctx, done := s.observe(ctx, "GetActivityFill")
+	defer func() { done(err) }()
+	return s.next.GetActivityFill(ctx, f)
+}
+
+func (s *instrumented) GetProductSellThrough(ctx context.Context, f models.ReportFilter) (_ []*models.ProductSellThrough, err error) {
+	ctx, done := s.observe(ctx, "GetProductSellThrough")
+	defer func() { done(err) }()
+	return s.next.GetProductSellThrough(ctx, f)
+}
+
+func (s *instrumented) GetRankDistribution(ctx context.Context) (_ []*models.RankCount, err error) {
+	ctx, done := s.observe(ctx, "GetRankDistribution")
+	defer func() { done(err) }()
+	return s.next.GetRankDistribution(ctx)
+}
+
+func (s *instrumented) GetNoShowRates(ctx context.Context, f models.ReportFilter) (_ []*models.NoShowRate, err error) {
+	ctx, done := s.observe(ctx, "GetNoShowRates")
+	defer func() { done(err) }()
+	return s.next.GetNoShowRates(ctx, f)
+}
+
+func (s *instrumented) GetTopCustomers(ctx context.Context, f models.ReportFilter) (_ []*models.TopCustomer, err error) {
+	ctx, done := s.observe(ctx, "GetTopCustomers")
+	defer func() { done(err) }()
+	return s.next.GetTopCustomers(ctx, f)
+}
+
+func (s *instrumented) ImportProducts(ctx context.Context, products []*models.Product, dryRun bool) (_ *models.ImportResult, err error) {
+	ctx, done := s.observe(ctx, "ImportProducts")
+	defer func() { done(err) }()
+	return s.next.ImportProducts(ctx, products, dryRun)
+}
+
+func (s *instrumented) ImportActivities(ctx context.Context, activities []*models.Activity, dryRun bool) (_ *models.ImportResult, err error) {
+	ctx, done := s.observe(ctx, "ImportActivities")
+	defer func() { done(err) }()
+	return s.next.ImportActivities(ctx, activities, dryRun)
+}
+
+func (s *instrumented) ImportCustomers(ctx context.Context, customers []*models.Customer, dryRun bool) (_ *models.ImportResult, err error) {
+	ctx, done := s.observe(ctx, "ImportCustomers")
+	defer func() { done(err) }()
+	return s.next.ImportCustomers(ctx, customers, dryRun)
+}
+
+func (s *instrumented) EachProduct(ctx context.Context, fn func(*models.Product) error) (err error) {
+	ctx, done := s.observe(ctx, "EachProduct")
+	defer func() { done(err) }()
+	return s.next.EachProduct(ctx, fn)
+}
+
+func (s *instrumented) EachActivity(ctx context.Context, fn func(*models.Activity) error) (err error) {
+	ctx, done := s.observe(ctx, "EachActivity")
+	defer func() { done(err) }()
+	return s.next.EachActivity(ctx, fn)
+}
+
+func (s *instrumented) EachCustomer(ctx context.Context, fn func(*models.Customer) error) (err error) {
+	ctx, done := s.observe(ctx, "EachCustomer")
+	defer func() { done(err) }()
+	return s.next.EachCustomer(ctx, fn)
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"farm/internal/models"
+	"farm/internal/store"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Audit Implementation
+
+// WithAudit returns a view of the store that appends e to the audit log, with
+// before/after snapshots of the target, in the same transaction as each
+// mutation made through it.
+func (s *MemoryStore) WithAudit(e *models.AuditEntry) store.Repository {
+	view := *s
+	view.audit = e
+	return &view
+}
+
+// recordAudit writes the view's pending audit entry, if any, within tx. A nil
+// before or after is left empty.
+func (s *MemoryStore) recordAudit(tx *tx, targetID string, before, after any) error {
+	if s.audit == nil {
+		return nil
+	}
+	e := *s.audit // Imports record many targets through one view
+	if e.TargetID == "" {
+		e.TargetID = targetID
+	}
+	var err error
+	if e.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if e.After, err = snapshot(after); err != nil {
+		return err
+	}
+	e.ID = 1
+	if n := len(s.data.auditLog); n > 0 {
+		e.ID = s.data.auditLog[n-1].ID + 1
+	}
+	e.CreatedAt = time.Now()
+	appendRow(tx, &s.data.auditLog, e)
+	return nil
+}
+
+// snapshot serialises a target for the audit log, leaving out secrets.
+func snapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, nil
+	}
+	if w, ok := v.(*models.Webhook); ok {
+		redacted := *w
+		redacted.Secret = ""
+		v = &redacted
+	}
+	return json.Marshal(v)
+}
+
+func (s *MemoryStore) GetAuditEntries(ctx context.Context, f models.AuditFilter) ([]*models.AuditEntry, error) {
+	var entries []*models.AuditEntry
+	err := s.view(ctx, func() error {
+		for _, e := range slices.Backward(s.data.auditLog) {
+			if (f.ActorID != "" && e.ActorID != f.ActorID) || (f.Action != "" && e.Action != f.Action) ||
+				(f.TargetType != "" && e.TargetType != f.TargetType) || (f.TargetID != "" && e.TargetID != f.TargetID) {
+				continue
+			}
+			if e.CreatedAt.Before(f.From) || (!f.To.IsZero() && !e.CreatedAt.Before(f.To)) {
+				continue
+			}
+			if f.BeforeID > 0 && e.ID >= f.BeforeID {
+				continue
+			}
+			entries = append(entries, &e)
+		}
+		entries = limit(entries, f.Limit)
+		return nil
+	})
+	return entries, err
+}
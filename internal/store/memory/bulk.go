@@ -0,0 +1,234 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"farm/internal/models"
+	"farm/internal/store"
+	"slices"
+	"time"
+)
+
+// Bulk Import Implementation
+
+// ImportProducts upserts products by ID in a single transaction. Rows naming a
+// deleted product, or failing store.CheckImport, are rejected; if any row is,
+// or on a dry run, nothing is committed. Added stock goes to waitlisted
+// reservations first.
+func (s *MemoryStore) ImportProducts(ctx context.Context, products []*models.Product, dryRun bool) (*models.ImportResult, error) {
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(products), Errors: []*models.ImportRowError{}}
+	err := s.update(ctx, func(tx *tx) error {
+		for i, p := range products {
+			var old *models.Product
+			stored, ok := s.data.products.get(p.ID)
+			switch {
+			case !ok:
+				stored = models.Product{ID: p.ID, Version: 1}
+				result.Created++
+			case stored.DeletedAt != nil:
+				result.Errors = append(result.Errors, &models.ImportRowError{Row: i + 1, Field: "id", Message: "product is deleted; restore it first"})
+				continue
+			default:
+				if rowErr := store.CheckImport(i+1, "quantity", stored.Version, p.Version, p.Quantity != stored.Quantity); rowErr != nil {
+					result.Errors = append(result.Errors, rowErr)
+					continue
+				}
+				quantity, err := s.data.setUnits(tx, models.ReservationProduct, p.ID, stored.Quantity, p.Quantity, time.Now())
+				if err != nil {
+					return err
+				}
+				before := stored
+				old = &before
+				p.Quantity = quantity
+				stored.Version++
+				result.Updated++
+			}
+			stored.Name, stored.Description, stored.ImageURL, stored.Quantity, stored.Visible = p.Name, p.Description, p.ImageURL, p.Quantity, p.Visible
+			s.data.products.put(tx, p.ID, stored)
+			if err := s.data.enqueueInventory(tx, models.ReservationProduct, p.ID); err != nil {
+				return err
+			}
+			if old != nil && old.Quantity > 0 && p.Quantity <= 0 {
+				if err := s.data.enqueueOutOfStock(tx, p.ID); err != nil {
+					return err
+				}
+			}
+			if err := s.recordAudit(tx, p.ID, old, p); err != nil {
+				return err
+			}
+		}
+		if dryRun || len(result.Errors) > 0 {
+			return errDiscard
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportActivities upserts activities by ID; see ImportProducts.
+func (s *MemoryStore) ImportActivities(ctx context.Context, activities []*models.Activity, dryRun bool) (*models.ImportResult, error) {
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(activities), Errors: []*models.ImportRowError{}}
+	err := s.update(ctx, func(tx *tx) error {
+		for i, a := range activities {
+			var old *models.Activity
+			stored, ok := s.data.activities.get(a.ID)
+			switch {
+			case !ok:
+				stored = models.Activity{ID: a.ID, Version: 1}
+				result.Created++
+			case stored.DeletedAt != nil:
+				result.Errors = append(result.Errors, &models.ImportRowError{Row: i + 1, Field: "id", Message: "activity is deleted; restore it first"})
+				continue
+			default:
+				if rowErr := store.CheckImport(i+1, "capacity", stored.Version, a.Version, a.Capacity != stored.Capacity); rowErr != nil {
+					result.Errors = append(result.Errors, rowErr)
+					continue
+				}
+				capacity, err := s.data.setUnits(tx, models.ReservationActivity, a.ID, stored.Capacity, a.Capacity, time.Now())
+				if err != nil {
+					return err
+				}
+				before := stored
+				old = &before
+				a.Capacity = capacity
+				stored.Version++
+				result.Updated++
+			}
+			stored.Name, stored.Description, stored.ImageURL, stored.Capacity, stored.Visible = a.Name, a.Description, a.ImageURL, a.Capacity, a.Visible
+			s.data.activities.put(tx, a.ID, stored)
+			if err := s.data.enqueueInventory(tx, models.ReservationActivity, a.ID); err != nil {
+				return err
+			}
+			if err := s.recordAudit(tx, a.ID, old, a); err != nil {
+				return err
+			}
+		}
+		if dryRun || len(result.Errors) > 0 {
+			return errDiscard
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportCustomers upserts customers by ID; see ImportProducts. An empty
+// Password leaves an existing customer's password unchanged. Rows whose email
+// belongs to another customer are rejected, and credit changes are recorded
+// in the credit history.
+func (s *MemoryStore) ImportCustomers(ctx context.Context, customers []*models.Customer, dryRun bool) (*models.ImportResult, error) {
+	now := time.Now()
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(customers), Errors: []*models.ImportRowError{}}
+	err := s.update(ctx, func(tx *tx) error {
+		for i, c := range customers {
+			if s.data.emailTaken(c.Email, c.ID) {
+				result.Errors = append(result.Errors, &models.ImportRowError{Row: i + 1, Field: "email", Message: "email already in use"})
+				continue
+			}
+
+			c.Rank = store.RankFor(&s.Config.Ranks, c.Credits)
+			previous := 0
+			var old *models.Customer
+			stored, ok := s.data.customers.get(c.ID)
+			switch {
+			case !ok:
+				stored = models.Customer{ID: c.ID, Password: c.Password, Salt: c.Salt, Version: 1}
+				result.Created++
+			case stored.DeletedAt != nil:
+				result.Errors = append(result.Errors, &models.ImportRowError{Row: i + 1, Field: "id", Message: "customer is deleted; restore it first"})
+				continue
+			default:
+				before := stored
+				old = &before
+				previous = old.Credits
+				stored.Version++
+				if c.Password != "" {
+					stored.Password, stored.Salt = c.Password, c.Salt
+				}
+				result.Updated++
+			}
+			stored.Email, stored.Name, stored.Credits, stored.Rank, stored.Role = c.Email, c.Name, c.Credits, c.Rank, c.Role
+			stored.NotifyEmail, stored.NotifyInApp = c.NotifyEmail, c.NotifyInApp
+			s.data.customers.put(tx, c.ID, stored)
+			if c.Credits != previous {
+				err := s.data.recordCreditChange(tx, &models.CreditChange{
+					CustomerID: c.ID, Previous: previous, Credits: c.Credits, Rank: c.Rank, Reason: models.CreditReasonImport, At: now,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if err := s.recordAudit(tx, c.ID, old, &stored); err != nil {
+				return err
+			}
+		}
+		if dryRun || len(result.Errors) > 0 {
+			return errDiscard
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Bulk Export Implementation
+
+// each calls fn for every row list returns, sorted by compare. The rows are
+// copied under the read lock and fn runs outside it, so fn may use the store.
+func each[T any](s *MemoryStore, ctx context.Context, list func() []*T, compare func(a, b *T) int, fn func(*T) error) error {
+	var rows []*T
+	err := s.view(ctx, func() error {
+		rows = list()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(rows, compare)
+	for _, r := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EachProduct calls fn for every product that isn't deleted, ordered by name.
+func (s *MemoryStore) EachProduct(ctx context.Context, fn func(*models.Product) error) error {
+	return each(s, ctx,
+		func() []*models.Product {
+			return s.data.products.list(func(p *models.Product) bool { return p.DeletedAt == nil })
+		},
+		func(a, b *models.Product) int { return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID)) },
+		fn)
+}
+
+// EachActivity calls fn for every activity that isn't deleted; see EachProduct.
+func (s *MemoryStore) EachActivity(ctx context.Context, fn func(*models.Activity) error) error {
+	return each(s, ctx,
+		func() []*models.Activity {
+			return s.data.activities.list(func(a *models.Activity) bool { return a.DeletedAt == nil })
+		},
+		func(a, b *models.Activity) int { return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID)) },
+		fn)
+}
+
+// EachCustomer calls fn for every customer that isn't deleted; see EachProduct.
+func (s *MemoryStore) EachCustomer(ctx context.Context, fn func(*models.Customer) error) error {
+	return each(s, ctx,
+		func() []*models.Customer {
+			return s.data.customers.list(func(c *models.Customer) bool { return c.DeletedAt == nil })
+		},
+		func(a, b *models.Customer) int { return cmp.Compare(a.Email, b.Email) },
+		fn)
+}
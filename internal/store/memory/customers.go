@@ -0,0 +1,363 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"farm/internal/models"
+	"farm/internal/store"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Customer Implementation
+
+// emailTaken reports whether a customer other than id, deleted or not, uses
+// email.
+func (d *data) emailTaken(email, id string) bool {
+	for _, r := range d.customers.rows {
+		if r.v.Email == email && r.v.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// activeCustomer returns the customer unless they don't exist or are deleted.
+func (d *data) activeCustomer(id string) (models.Customer, error) {
+	c, ok := d.customers.get(id)
+	if !ok || c.DeletedAt != nil {
+		return c, notFound("customer")
+	}
+	return c, nil
+}
+
+func (s *MemoryStore) AddCustomer(ctx context.Context, c *models.Customer) error {
+	c.Rank = store.RankFor(&s.Config.Ranks, c.Credits) // Ensure rank is set correctly on creation
+	c.Version = 1
+	return s.update(ctx, func(tx *tx) error {
+		if _, ok := s.data.customers.get(c.ID); ok || s.data.emailTaken(c.Email, c.ID) {
+			return conflict("customer")
+		}
+		s.data.customers.put(tx, c.ID, models.Customer{
+			ID: c.ID, Email: c.Email, Password: c.Password, Salt: c.Salt, Name: c.Name, Credits: c.Credits, Rank: c.Rank,
+			Role: c.Role, NotifyEmail: c.NotifyEmail, NotifyInApp: c.NotifyInApp, Version: 1,
+		})
+		return nil
+	})
+}
+
+func (s *MemoryStore) GetCustomer(ctx context.Context, id string) (*models.Customer, error) {
+	var c models.Customer
+	err := s.view(ctx, func() (err error) {
+		c, err = s.data.activeCustomer(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *MemoryStore) GetCustomerByEmail(ctx context.Context, email string) (*models.Customer, error) {
+	var customers []*models.Customer
+	err := s.view(ctx, func() error {
+		customers = s.data.customers.list(func(c *models.Customer) bool { return c.Email == email && c.DeletedAt == nil })
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(customers) == 0 {
+		return nil, notFound("customer")
+	}
+	return customers[0], nil
+}
+
+func (s *MemoryStore) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	return s.listCustomers(ctx, func(c *models.Customer) bool { return c.DeletedAt == nil })
+}
+
+func (s *MemoryStore) GetDeletedCustomers(ctx context.Context) ([]*models.Customer, error) {
+	customers, err := s.listCustomers(ctx, func(c *models.Customer) bool { return c.DeletedAt != nil })
+	slices.SortStableFunc(customers, func(a, b *models.Customer) int { return b.DeletedAt.Compare(*a.DeletedAt) })
+	return customers, err
+}
+
+func (s *MemoryStore) listCustomers(ctx context.Context, keep func(*models.Customer) bool) ([]*models.Customer, error) {
+	var customers []*models.Customer
+	err := s.view(ctx, func() error {
+		customers = s.data.customers.list(keep)
+		return nil
+	})
+	return customers, err
+}
+
+func (s *MemoryStore) UpdateCustomerCredits(ctx context.Context, id string, credits, version int) (*models.Customer, error) {
+	return s.updateCustomer(ctx, id, version, func(tx *tx, c *models.Customer) error {
+		previous := c.Credits
+		c.Credits, c.Rank = credits, store.RankFor(&s.Config.Ranks, credits)
+		return s.data.recordCreditChange(tx, &models.CreditChange{
+			CustomerID: id, Previous: previous, Credits: credits, Rank: c.Rank, Reason: models.CreditReasonAdmin, At: time.Now(),
+		})
+	})
+}
+
+func (s *MemoryStore) UpdateCustomerRole(ctx context.Context, id string, role string, version int) (*models.Customer, error) {
+	return s.updateCustomer(ctx, id, version, func(tx *tx, c *models.Customer) error {
+		c.Role = role
+		return nil
+	})
+}
+
+func (s *MemoryStore) ClearCustomerBan(ctx context.Context, id string) (*models.Customer, error) {
+	return s.updateCustomer(ctx, id, 0, func(tx *tx, c *models.Customer) error {
+		c.BannedUntil = nil
+		return nil
+	})
+}
+
+// updateCustomer applies change to a customer who isn't deleted, audits it
+// and returns the updated customer. A non-zero version must be current.
+func (s *MemoryStore) updateCustomer(ctx context.Context, id string, version int, change func(*tx, *models.Customer) error) (*models.Customer, error) {
+	var after models.Customer
+	err := s.update(ctx, func(tx *tx) error {
+		before, err := s.data.activeCustomer(id)
+		if err != nil {
+			return err
+		}
+		if err := store.CheckVersion("customer", before.Version, version); err != nil {
+			return err
+		}
+		after = before
+		after.Version++
+		if err := change(tx, &after); err != nil {
+			return err
+		}
+		s.data.customers.put(tx, id, after)
+		return s.recordAudit(tx, id, &before, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// setCustomer applies change to a customer who isn't deleted without
+// auditing it, as the self-service updates do.
+func (s *MemoryStore) setCustomer(ctx context.Context, id string, change func(*models.Customer)) (*models.Customer, error) {
+	var c models.Customer
+	err := s.update(ctx, func(tx *tx) (err error) {
+		if c, err = s.data.activeCustomer(id); err != nil {
+			return err
+		}
+		change(&c)
+		c.Version++
+		s.data.customers.put(tx, id, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *MemoryStore) UpdateCustomerName(ctx context.Context, id string, name string) (*models.Customer, error) {
+	return s.setCustomer(ctx, id, func(c *models.Customer) { c.Name = name })
+}
+
+func (s *MemoryStore) UpdateCustomerPreferences(ctx context.Context, id string, notifyEmail, notifyInApp bool) (*models.Customer, error) {
+	return s.setCustomer(ctx, id, func(c *models.Customer) { c.NotifyEmail, c.NotifyInApp = notifyEmail, notifyInApp })
+}
+
+// DeleteCustomer soft-deletes a customer: they can no longer sign in, and
+// their reservations are kept until the account is purged.
+func (s *MemoryStore) DeleteCustomer(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *tx) error {
+		if err := s.data.checkNoActiveReservations(func(r *models.Reservation) bool { return r.CustomerID == id }); err != nil {
+			return err
+		}
+		c, err := s.data.activeCustomer(id)
+		if err != nil {
+			return err
+		}
+		before := c
+		now := time.Now()
+		c.DeletedAt = &now
+		c.Version++
+		s.data.customers.put(tx, id, c)
+		return s.recordAudit(tx, id, &before, nil)
+	})
+}
+
+// RestoreCustomer undoes DeleteCustomer, returning store.ErrNotFound if the
+// customer doesn't exist or isn't deleted.
+func (s *MemoryStore) RestoreCustomer(ctx context.Context, id string) (*models.Customer, error) {
+	var after models.Customer
+	err := s.update(ctx, func(tx *tx) error {
+		before, ok := s.data.customers.get(id)
+		if !ok || before.DeletedAt == nil {
+			return notFound("customer")
+		}
+		after = before
+		after.DeletedAt = nil
+		after.Version++
+		s.data.customers.put(tx, id, after)
+		return s.recordAudit(tx, id, &before, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// recordCreditChange appends to the customer's credit history and publishes
+// the change.
+func (d *data) recordCreditChange(tx *tx, change *models.CreditChange) error {
+	appendRow(tx, &d.creditHistory, *change)
+	return d.enqueueEvent(tx, models.EventCreditsUpdated, change)
+}
+
+func (s *MemoryStore) GetCreditHistory(ctx context.Context, customerID string) ([]*models.CreditChange, error) {
+	var history []*models.CreditChange
+	err := s.view(ctx, func() error {
+		for _, c := range s.data.creditHistory {
+			if c.CustomerID == customerID {
+				history = append(history, &c)
+			}
+		}
+		return nil
+	})
+	return history, err
+}
+
+// Erasure Implementation
+
+// ScheduleErasure marks the customer's account for erasure at at; a nil at
+// cancels a pending request.
+func (s *MemoryStore) ScheduleErasure(ctx context.Context, id string, at *time.Time) (*models.Customer, error) {
+	return s.setCustomer(ctx, id, func(c *models.Customer) { c.EraseAfter = at })
+}
+
+func (s *MemoryStore) GetCustomersDueForErasure(ctx context.Context, now time.Time) ([]*models.Customer, error) {
+	return s.listCustomers(ctx, func(c *models.Customer) bool { return c.EraseAfter != nil && !c.EraseAfter.After(now) })
+}
+
+// EraseCustomer removes a customer's personal data. Their reservations, status
+// history, credit history and audit entries are kept for stock accounting but
+// re-keyed to a new pseudonymous, soft-deleted customer whose ID is returned,
+// and audit snapshots and event payloads naming them are redacted;
+// notifications and stored idempotent responses are deleted. Reservations still
+// pending, waitlisted or confirmed are cancelled first, in the same
+// transaction, so a failed erasure leaves the customer as they were.
+func (s *MemoryStore) EraseCustomer(ctx context.Context, id string) (string, error) {
+	pseudonym := uuid.New().String()
+	err := s.update(ctx, func(tx *tx) error {
+		if _, ok := s.data.customers.get(id); !ok {
+			return notFound("customer")
+		}
+		for _, r := range s.data.reservations.list(func(r *models.Reservation) bool { return r.CustomerID == id && active(r) }) {
+			// Read again: cancelling one may have confirmed another from the
+			// waitlist
+			r, _ := s.data.reservations.get(r.ID)
+			r.History = nil
+			if err := s.transition(tx, &r, models.StatusCancelled, models.ActorSystem); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		s.data.customers.put(tx, pseudonym, models.Customer{
+			ID: pseudonym, Email: pseudonym + models.ErasedEmailDomain, Name: models.ErasedName,
+			Rank: models.RankBronze, Role: models.RoleCustomer, DeletedAt: &now, Version: 1,
+		})
+		for _, r := range s.data.reservations.list(nil) {
+			changed := r.CustomerID == id
+			if changed {
+				r.CustomerID = pseudonym
+			}
+			history := make([]*models.Transition, len(r.History))
+			for i, t := range r.History {
+				if t.ActorID == id {
+					rekeyed := *t
+					rekeyed.ActorID = pseudonym
+					t, changed = &rekeyed, true
+				}
+				history[i] = t
+			}
+			if changed {
+				r.History = history
+				s.data.reservations.put(tx, r.ID, *r)
+			}
+		}
+		history := slices.Clone(s.data.creditHistory)
+		for i := range history {
+			if history[i].CustomerID == id {
+				history[i].CustomerID = pseudonym
+			}
+		}
+		setRows(tx, &s.data.creditHistory, history)
+		if err := s.data.redactDocuments(tx, id, pseudonym); err != nil {
+			return err
+		}
+		for _, n := range s.data.notifications.list(func(n *models.Notification) bool { return n.CustomerID == id }) {
+			s.data.notifications.delete(tx, n.ID)
+		}
+		for _, k := range s.data.keys.list(func(k *models.IdempotencyKey) bool { return k.CustomerID == id }) {
+			s.data.keys.delete(tx, keyID(k.CustomerID, k.Key))
+		}
+		s.data.customers.delete(tx, id)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return pseudonym, nil
+}
+
+// redactDocuments re-keys the audit log to pseudonym and redacts the erased
+// customer from audit snapshots, events and webhook deliveries.
+func (d *data) redactDocuments(tx *tx, id, pseudonym string) error {
+	redact := func(doc json.RawMessage) (json.RawMessage, error) {
+		if !bytes.Contains(doc, []byte(id)) {
+			return doc, nil
+		}
+		return store.RedactCustomer(doc, id, pseudonym)
+	}
+
+	var err error
+	auditLog := slices.Clone(d.auditLog)
+	for i := range auditLog {
+		e := &auditLog[i]
+		if e.ActorID == id {
+			e.ActorID = pseudonym
+		}
+		if e.TargetType == models.AuditTargetCustomer && e.TargetID == id {
+			e.TargetID = pseudonym
+		}
+		if e.Before, err = redact(e.Before); err != nil {
+			return err
+		}
+		if e.After, err = redact(e.After); err != nil {
+			return err
+		}
+	}
+	events := slices.Clone(d.events)
+	for i := range events {
+		if events[i].Payload, err = redact(events[i].Payload); err != nil {
+			return err
+		}
+	}
+	deliveries := slices.Clone(d.deliveries)
+	for i := range deliveries {
+		if deliveries[i].Payload, err = redact(deliveries[i].Payload); err != nil {
+			return err
+		}
+	}
+	setRows(tx, &d.auditLog, auditLog)
+	setRows(tx, &d.events, events)
+	setRows(tx, &d.deliveries, deliveries)
+	return nil
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"farm/internal/models"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Idempotency Key Implementation
+
+// keyID is the keys table's ID for a customer's key.
+func keyID(customerID, key string) string {
+	return customerID + "\x00" + key
+}
+
+func (s *MemoryStore) ClaimIdempotencyKey(ctx context.Context, k *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	var existing *models.IdempotencyKey
+	err := s.update(ctx, func(tx *tx) error {
+		id := keyID(k.CustomerID, k.Key)
+		if stored, ok := s.data.keys.get(id); ok {
+			if stored.ExpiresAt.After(k.CreatedAt) {
+				stored.Headers = maps.Clone(stored.Headers)
+				stored.Body = slices.Clone(stored.Body)
+				existing = &stored
+				return nil
+			}
+			// Expired but not purged yet: the key is free again
+			s.data.keys.delete(tx, id)
+		}
+		s.data.keys.put(tx, id, models.IdempotencyKey{
+			CustomerID: k.CustomerID, Key: k.Key, RequestHash: k.RequestHash, CreatedAt: k.CreatedAt, ExpiresAt: k.ExpiresAt,
+		})
+		return nil
+	})
+	return existing, err
+}
+
+func (s *MemoryStore) CompleteIdempotencyKey(ctx context.Context, k *models.IdempotencyKey) error {
+	return s.update(ctx, func(tx *tx) error {
+		id := keyID(k.CustomerID, k.Key)
+		stored, ok := s.data.keys.get(id)
+		if !ok {
+			return notFound("idempotency key")
+		}
+		stored.Status, stored.Headers, stored.Body = k.Status, maps.Clone(k.Headers), slices.Clone(k.Body)
+		s.data.keys.put(tx, id, stored)
+		return nil
+	})
+}
+
+func (s *MemoryStore) ReleaseIdempotencyKey(ctx context.Context, customerID, key string) error {
+	return s.update(ctx, func(tx *tx) error {
+		id := keyID(customerID, key)
+		if stored, ok := s.data.keys.get(id); ok && stored.Status == 0 {
+			s.data.keys.delete(tx, id)
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	var n int64
+	err := s.update(ctx, func(tx *tx) error {
+		for id, r := range s.data.keys.rows {
+			if r.v.ExpiresAt.Before(now) {
+				s.data.keys.delete(tx, id)
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
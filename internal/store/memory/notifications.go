@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"farm/internal/models"
+	"slices"
+	"time"
+)
+
+// Notification Implementation
+
+func (s *MemoryStore) AddNotification(ctx context.Context, n *models.Notification) error {
+	return s.update(ctx, func(tx *tx) error {
+		if _, ok := s.data.notifications.get(n.ID); ok {
+			return conflict("notification")
+		}
+		stored := *n
+		stored.ReadAt = nil
+		s.data.notifications.put(tx, n.ID, stored)
+		return nil
+	})
+}
+
+func (s *MemoryStore) GetNotifications(ctx context.Context, customerID string, unreadOnly bool) ([]*models.Notification, error) {
+	var notifications []*models.Notification
+	err := s.view(ctx, func() error {
+		notifications = s.data.notifications.list(func(n *models.Notification) bool {
+			return n.CustomerID == customerID && (!unreadOnly || n.ReadAt == nil)
+		})
+		return nil
+	})
+	slices.SortStableFunc(notifications, func(a, b *models.Notification) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return notifications, err
+}
+
+// MarkNotificationRead marks one of the customer's notifications as read,
+// returning store.ErrNotFound if it does not exist or belongs to someone else.
+func (s *MemoryStore) MarkNotificationRead(ctx context.Context, customerID, id string) error {
+	return s.update(ctx, func(tx *tx) error {
+		n, ok := s.data.notifications.get(id)
+		if !ok || n.CustomerID != customerID {
+			return notFound("notification")
+		}
+		if n.ReadAt == nil {
+			now := time.Now()
+			n.ReadAt = &now
+			s.data.notifications.put(tx, id, n)
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) MarkAllNotificationsRead(ctx context.Context, customerID string) error {
+	return s.update(ctx, func(tx *tx) error {
+		now := time.Now()
+		for _, n := range s.data.notifications.list(func(n *models.Notification) bool { return n.CustomerID == customerID && n.ReadAt == nil }) {
+			n.ReadAt = &now
+			s.data.notifications.put(tx, n.ID, *n)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"farm/internal/models"
+	"slices"
+	"time"
+)
+
+// Outbox Implementation
+
+// enqueueEvent records a domain event as part of tx, so it is published if and
+// only if the change it describes commits.
+func (d *data) enqueueEvent(tx *tx, eventType string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	id := int64(1)
+	if n := len(d.events); n > 0 {
+		id = d.events[n-1].ID + 1
+	}
+	appendRow(tx, &d.events, models.Event{ID: id, Type: eventType, Payload: b, CreatedAt: time.Now()})
+	return nil
+}
+
+// enqueueOutOfStock records that a product has run out.
+func (d *data) enqueueOutOfStock(tx *tx, productID string) error {
+	p, _ := d.products.get(productID)
+	return d.enqueueEvent(tx, models.EventProductOutOfStock, map[string]string{"product_id": productID, "name": p.Name})
+}
+
+// enqueueInventory records the current stock of an item after a change.
+func (d *data) enqueueInventory(tx *tx, itemType models.ReservationType, id string) error {
+	change := models.InventoryChange{Type: itemType, ItemID: id, Deleted: true}
+	if itemType == models.ReservationActivity {
+		if a, ok := d.activities.get(id); ok {
+			change.Name, change.Available, change.Visible, change.Deleted = a.Name, a.Capacity, a.Visible, a.DeletedAt != nil
+		}
+	} else if p, ok := d.products.get(id); ok {
+		change.Name, change.Available, change.Visible, change.Deleted = p.Name, p.Quantity, p.Visible, p.DeletedAt != nil
+	}
+	return d.enqueueEvent(tx, models.EventInventoryChanged, change)
+}
+
+// eventsAfter returns up to n events with IDs above id, oldest first.
+func (d *data) eventsAfter(id int64, n int) []*models.Event {
+	var events []*models.Event
+	for _, e := range d.events {
+		if e.ID > id {
+			events = append(events, &e)
+		}
+	}
+	return limit(events, n)
+}
+
+func (s *MemoryStore) GetOutboxEvents(ctx context.Context, consumer string, limit int) ([]*models.Event, error) {
+	var events []*models.Event
+	err := s.view(ctx, func() error {
+		events = s.data.eventsAfter(s.data.cursors[consumer], limit)
+		return nil
+	})
+	return events, err
+}
+
+func (s *MemoryStore) GetOutboxEventsAfter(ctx context.Context, afterID int64, limit int) ([]*models.Event, error) {
+	var events []*models.Event
+	err := s.view(ctx, func() error {
+		events = s.data.eventsAfter(afterID, limit)
+		return nil
+	})
+	return events, err
+}
+
+func (s *MemoryStore) GetLatestOutboxEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := s.view(ctx, func() error {
+		if n := len(s.data.events); n > 0 {
+			id = s.data.events[n-1].ID
+		}
+		return nil
+	})
+	return id, err
+}
+
+// lease records which holder reads a consumer's events, and until when.
+type lease struct {
+	holder string
+	until  time.Time
+}
+
+func (s *MemoryStore) LeaseOutboxConsumer(ctx context.Context, consumer, holder string, now, until time.Time) (bool, error) {
+	var ok bool
+	err := s.update(ctx, func(tx *tx) error {
+		l, held := s.data.leases[consumer]
+		if held && l.holder != holder && !l.until.Before(now) {
+			return nil
+		}
+		s.data.leases[consumer], ok = lease{holder: holder, until: until}, true
+		return nil
+	})
+	return ok, err
+}
+
+// PurgeOutbox keeps the newest event and delivery whatever their age, as new
+// IDs follow on from them.
+func (s *MemoryStore) PurgeOutbox(ctx context.Context, cutoff time.Time) (int64, error) {
+	var n int64
+	err := s.update(ctx, func(tx *tx) error {
+		events := slices.Clone(s.data.events)
+		events = slices.DeleteFunc(events[:max(len(events)-1, 0)], func(e models.Event) bool { return e.CreatedAt.Before(cutoff) })
+		if len(s.data.events) > 0 {
+			events = append(events, s.data.events[len(s.data.events)-1])
+		}
+		deliveries := slices.Clone(s.data.deliveries)
+		deliveries = slices.DeleteFunc(deliveries[:max(len(deliveries)-1, 0)], func(d models.WebhookDelivery) bool {
+			return d.Status != models.DeliveryPending && d.CreatedAt.Before(cutoff)
+		})
+		if len(s.data.deliveries) > 0 {
+			deliveries = append(deliveries, s.data.deliveries[len(s.data.deliveries)-1])
+		}
+		n = int64(len(s.data.events) - len(events) + len(s.data.deliveries) - len(deliveries))
+		setRows(tx, &s.data.events, events)
+		setRows(tx, &s.data.deliveries, deliveries)
+		return nil
+	})
+	return n, err
+}
+
+func (s *MemoryStore) AdvanceOutboxCursor(ctx context.Context, consumer string, lastID int64) error {
+	return s.update(ctx, func(tx *tx) error {
+		s.data.cursors[consumer] = lastID
+		return nil
+	})
+}
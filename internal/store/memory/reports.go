@@ -0,0 +1,251 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"farm/internal/models"
+	"slices"
+	"time"
+)
+
+// Report Implementation
+
+// period returns the first day of the period containing t, as YYYY-MM-DD.
+// Like the SQL stores it goes by the date t was recorded with.
+func period(p string, t time.Time) string {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case models.PeriodWeek:
+		day = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case models.PeriodMonth:
+		day = day.AddDate(0, 0, 1-day.Day())
+	}
+	return day.Format(time.DateOnly)
+}
+
+// matches reports whether r was made in the filter's range and matches its
+// type and rank.
+func matches(f models.ReportFilter, r *models.Reservation) bool {
+	return !r.Timestamp.Before(f.From) && r.Timestamp.Before(f.To) &&
+		(f.Type == "" || r.Type == f.Type) && (f.Rank < 0 || r.PriorityRank == f.Rank)
+}
+
+// itemName returns the name of a reservation's item, deleted or not, or ""
+// once it has been purged.
+func (d *data) itemName(r *models.Reservation) string {
+	if r.Type == models.ReservationActivity {
+		a, _ := d.activities.get(r.ItemID)
+		return a.Name
+	}
+	p, _ := d.products.get(r.ItemID)
+	return p.Name
+}
+
+// rate is n / total, or 0 when total is 0.
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func (s *MemoryStore) GetReservationCounts(ctx context.Context, f models.ReportFilter) ([]*models.ReservationCount, error) {
+	counts := []*models.ReservationCount{}
+	err := s.view(ctx, func() error {
+		type key struct {
+			period, itemID string
+			typ            models.ReservationType
+		}
+		byKey := make(map[key]*models.ReservationCount)
+		for _, r := range s.data.reservations.rows {
+			if !matches(f, &r.v) {
+				continue
+			}
+			k := key{period(f.Period, r.v.Timestamp), r.v.ItemID, r.v.Type}
+			c, ok := byKey[k]
+			if !ok {
+				c = &models.ReservationCount{Period: k.period, ItemID: k.itemID, ItemName: s.data.itemName(&r.v), Type: k.typ}
+				byKey[k] = c
+				counts = append(counts, c)
+			}
+			c.Reservations++
+			if r.v.Status == models.StatusCancelled {
+				c.Cancelled++
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(counts, func(a, b *models.ReservationCount) int {
+		return cmp.Or(cmp.Compare(a.Period, b.Period), cmp.Compare(a.ItemName, b.ItemName), cmp.Compare(a.ItemID, b.ItemID), cmp.Compare(a.Type, b.Type))
+	})
+	return counts, err
+}
+
+// GetActivityFill reports every activity that isn't deleted. Seats left is the
+// current capacity, so the fill rate is exact when the range covers all of an
+// activity's reservations.
+func (s *MemoryStore) GetActivityFill(ctx context.Context, f models.ReportFilter) ([]*models.ActivityFill, error) {
+	fill := []*models.ActivityFill{}
+	err := s.view(ctx, func() error {
+		byID := make(map[string]*models.ActivityFill)
+		for _, a := range s.data.activities.list(func(a *models.Activity) bool { return a.DeletedAt == nil }) {
+			byID[a.ID] = &models.ActivityFill{ActivityID: a.ID, Name: a.Name, SeatsLeft: a.Capacity}
+			fill = append(fill, byID[a.ID])
+		}
+		for _, r := range s.data.reservations.rows {
+			a, ok := byID[r.v.ItemID]
+			if !ok || r.v.Type != models.ReservationActivity || r.v.Timestamp.Before(f.From) || !r.v.Timestamp.Before(f.To) {
+				continue
+			}
+			switch r.v.Status {
+			case models.StatusConfirmed:
+				a.Booked++
+			case models.StatusCheckedIn:
+				a.Booked++
+				a.CheckedIn++
+			case models.StatusNoShow:
+				a.NoShows++
+			}
+		}
+		for _, a := range fill {
+			a.FillRate = rate(a.Booked, a.Booked+a.SeatsLeft)
+		}
+		return nil
+	})
+	slices.SortFunc(fill, func(a, b *models.ActivityFill) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ActivityID, b.ActivityID))
+	})
+	return fill, err
+}
+
+// GetProductSellThrough reports every product that isn't deleted; on hand is
+// the current stock.
+func (s *MemoryStore) GetProductSellThrough(ctx context.Context, f models.ReportFilter) ([]*models.ProductSellThrough, error) {
+	products := []*models.ProductSellThrough{}
+	err := s.view(ctx, func() error {
+		byID := make(map[string]*models.ProductSellThrough)
+		for _, p := range s.data.products.list(func(p *models.Product) bool { return p.DeletedAt == nil }) {
+			byID[p.ID] = &models.ProductSellThrough{ProductID: p.ID, Name: p.Name, OnHand: p.Quantity}
+			products = append(products, byID[p.ID])
+		}
+		for _, r := range s.data.reservations.rows {
+			p, ok := byID[r.v.ItemID]
+			if !ok || r.v.Type != models.ReservationProduct || r.v.Timestamp.Before(f.From) || !r.v.Timestamp.Before(f.To) {
+				continue
+			}
+			switch r.v.Status {
+			case models.StatusFulfilled:
+				p.Fulfilled++
+			case models.StatusConfirmed:
+				p.Held++
+			case models.StatusExpired:
+				p.Expired++
+			}
+		}
+		for _, p := range products {
+			p.SellThrough = rate(p.Fulfilled, p.Fulfilled+p.Held+p.OnHand)
+		}
+		return nil
+	})
+	slices.SortFunc(products, func(a, b *models.ProductSellThrough) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ProductID, b.ProductID))
+	})
+	return products, err
+}
+
+// GetRankDistribution counts active customers (not staff or admins) per rank,
+// including ranks nobody holds.
+func (s *MemoryStore) GetRankDistribution(ctx context.Context) ([]*models.RankCount, error) {
+	counts := make(map[models.Rank]int)
+	err := s.view(ctx, func() error {
+		for _, c := range s.data.customers.rows {
+			if c.v.DeletedAt == nil && c.v.Role == models.RoleCustomer {
+				counts[c.v.Rank]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var dist []*models.RankCount
+	for _, rank := range []models.Rank{models.RankBronze, models.RankSilver, models.RankGold} {
+		dist = append(dist, &models.RankCount{Rank: rank, RankName: rank.String(), Customers: counts[rank]})
+	}
+	return dist, nil
+}
+
+// GetNoShowRates lists items with at least one attended or missed reservation.
+func (s *MemoryStore) GetNoShowRates(ctx context.Context, f models.ReportFilter) ([]*models.NoShowRate, error) {
+	rates := []*models.NoShowRate{}
+	err := s.view(ctx, func() error {
+		type key struct {
+			itemID string
+			typ    models.ReservationType
+		}
+		byKey := make(map[key]*models.NoShowRate)
+		for _, r := range s.data.reservations.rows {
+			attended := r.v.Status == models.StatusCheckedIn || r.v.Status == models.StatusFulfilled
+			if !matches(f, &r.v) || (!attended && r.v.Status != models.StatusNoShow) {
+				continue
+			}
+			k := key{r.v.ItemID, r.v.Type}
+			n, ok := byKey[k]
+			if !ok {
+				n = &models.NoShowRate{ItemID: k.itemID, ItemName: s.data.itemName(&r.v), Type: k.typ}
+				byKey[k] = n
+				rates = append(rates, n)
+			}
+			if attended {
+				n.Attended++
+			} else {
+				n.NoShows++
+			}
+		}
+		for _, n := range rates {
+			n.Rate = rate(n.NoShows, n.Attended+n.NoShows)
+		}
+		return nil
+	})
+	slices.SortFunc(rates, func(a, b *models.NoShowRate) int {
+		return cmp.Or(cmp.Compare(b.Rate, a.Rate), cmp.Compare(a.ItemName, b.ItemName), cmp.Compare(a.ItemID, b.ItemID), cmp.Compare(a.Type, b.Type))
+	})
+	return rates, err
+}
+
+// GetTopCustomers ranks customers by reservations made in the range, not
+// counting cancelled ones.
+func (s *MemoryStore) GetTopCustomers(ctx context.Context, f models.ReportFilter) ([]*models.TopCustomer, error) {
+	top := []*models.TopCustomer{}
+	err := s.view(ctx, func() error {
+		byID := make(map[string]*models.TopCustomer)
+		for _, r := range s.data.reservations.rows {
+			if !matches(f, &r.v) || r.v.Status == models.StatusCancelled {
+				continue
+			}
+			t, ok := byID[r.v.CustomerID]
+			if !ok {
+				c, found := s.data.customers.get(r.v.CustomerID)
+				if !found {
+					continue
+				}
+				t = &models.TopCustomer{CustomerID: c.ID, Name: c.Name, Email: c.Email, Rank: c.Rank}
+				byID[c.ID] = t
+				top = append(top, t)
+			}
+			t.Reservations++
+			switch r.v.Status {
+			case models.StatusCheckedIn, models.StatusFulfilled:
+				t.Completed++
+			case models.StatusNoShow:
+				t.NoShows++
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(top, func(a, b *models.TopCustomer) int {
+		return cmp.Or(cmp.Compare(b.Reservations, a.Reservations), cmp.Compare(b.Completed, a.Completed), cmp.Compare(a.CustomerID, b.CustomerID))
+	})
+	return limit(top, f.Limit), err
+}
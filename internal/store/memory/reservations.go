@@ -0,0 +1,420 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Reservation Implementation
+
+// listReservations returns the reservations keep accepts without their
+// history, which only GetReservation loads.
+func (d *data) listReservations(keep func(*models.Reservation) bool) []*models.Reservation {
+	reservations := d.reservations.list(keep)
+	for _, r := range reservations {
+		r.History = nil
+	}
+	return reservations
+}
+
+func (s *MemoryStore) queryReservations(ctx context.Context, keep func(*models.Reservation) bool) ([]*models.Reservation, error) {
+	var reservations []*models.Reservation
+	err := s.view(ctx, func() error {
+		reservations = s.data.listReservations(keep)
+		return nil
+	})
+	return reservations, err
+}
+
+func (s *MemoryStore) AddReservation(ctx context.Context, r *models.Reservation) error {
+	return s.update(ctx, func(tx *tx) error {
+		if _, ok := s.data.reservations.get(r.ID); ok {
+			return conflict("reservation")
+		}
+		stored := *r
+		stored.History = nil
+		s.data.reservations.put(tx, r.ID, stored)
+		return nil
+	})
+}
+
+func (s *MemoryStore) GetAllReservations(ctx context.Context) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, nil)
+}
+
+func (s *MemoryStore) GetReservationsByCustomerID(ctx context.Context, customerID string) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, func(r *models.Reservation) bool { return r.CustomerID == customerID })
+}
+
+func (s *MemoryStore) GetReservationsByPickupRange(ctx context.Context, from, to time.Time) ([]*models.Reservation, error) {
+	reservations, err := s.queryReservations(ctx, func(r *models.Reservation) bool {
+		return r.Pickup != nil && !r.Pickup.Start.Before(from) && r.Pickup.Start.Before(to)
+	})
+	slices.SortStableFunc(reservations, func(a, b *models.Reservation) int {
+		return cmp.Or(a.Pickup.Start.Compare(b.Pickup.Start), cmp.Compare(a.Pickup.LocationID, b.Pickup.LocationID), a.Timestamp.Compare(b.Timestamp))
+	})
+	return reservations, err
+}
+
+// countPickup counts the reservations holding a place in a pickup slot,
+// including those already collected.
+func (d *data) countPickup(locationID string, start time.Time) int {
+	n := 0
+	for _, r := range d.reservations.rows {
+		p := r.v.Pickup
+		if p != nil && p.LocationID == locationID && p.Start.Equal(start) && slices.Contains(slotHeld, r.v.Status) {
+			n++
+		}
+	}
+	return n
+}
+
+// slotHeld are the statuses of reservations holding a place in their pickup
+// slot.
+var slotHeld = []models.ReservationStatus{models.StatusConfirmed, models.StatusCheckedIn, models.StatusFulfilled}
+
+// slotID is the slots table's ID for a pickup slot.
+func slotID(locationID string, start time.Time) string {
+	return locationID + "\x00" + start.UTC().Format(time.RFC3339Nano)
+}
+
+// slotFull reports whether every place in the recorded pickup slot at
+// locationID starting at start is taken. Slots without a capacity are never
+// full.
+func (d *data) slotFull(locationID string, start time.Time) bool {
+	slot, ok := d.slots.get(slotID(locationID, start))
+	return ok && slot.Capacity > 0 && d.countPickup(locationID, start) >= slot.Capacity
+}
+
+func (s *MemoryStore) CountPickupReservations(ctx context.Context, locationID string, start time.Time) (int, error) {
+	var count int
+	err := s.view(ctx, func() error {
+		count = s.data.countPickup(locationID, start)
+		return nil
+	})
+	return count, err
+}
+
+func (s *MemoryStore) DeleteReservation(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *tx) error {
+		before, ok := s.data.reservations.get(id)
+		if !ok {
+			return notFound("reservation")
+		}
+		before.History = nil
+		s.data.reservations.delete(tx, id)
+		return s.recordAudit(tx, id, &before, nil)
+	})
+}
+
+func (s *MemoryStore) GetReservation(ctx context.Context, id string) (*models.Reservation, error) {
+	var r models.Reservation
+	err := s.view(ctx, func() error {
+		var ok bool
+		if r, ok = s.data.reservations.get(id); !ok {
+			return notFound("reservation")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.History = slices.Clone(r.History)
+	return &r, nil
+}
+
+func (s *MemoryStore) ReserveItem(ctx context.Context, r *models.Reservation) error {
+	return s.update(ctx, func(tx *tx) error {
+		// 1. Verify Customer
+		if _, err := s.data.activeCustomer(r.CustomerID); err != nil {
+			return err
+		}
+
+		// 2. Check and Decrement Stock
+		waitlist := r.Status == models.StatusWaitlist
+		inStock, soldOut := true, false
+		switch r.Type {
+		case models.ReservationProduct:
+			p, err := s.data.activeProduct(r.ItemID)
+			if err != nil {
+				return err
+			}
+			if p.Quantity <= 0 && !waitlist {
+				return store.ErrOutOfStock
+			}
+			inStock, soldOut = p.Quantity > 0, p.Quantity == 1
+			if r.Pickup != nil {
+				// Waitlisted reservations record the slot too, for promotion
+				s.data.slots.put(tx, slotID(r.Pickup.LocationID, r.Pickup.Start), *r.Pickup)
+			}
+			if r.Pickup != nil && inStock && s.data.slotFull(r.Pickup.LocationID, r.Pickup.Start) {
+				return store.ErrSlotFull
+			}
+			if inStock {
+				p.Quantity--
+				p.Version++
+				s.data.products.put(tx, p.ID, p)
+			}
+		case models.ReservationActivity:
+			if r.Pickup != nil {
+				return fmt.Errorf("%w: pickup slots apply to product reservations only", store.ErrInvalid)
+			}
+			a, err := s.data.activeActivity(r.ItemID)
+			if err != nil {
+				return err
+			}
+			if a.Capacity <= 0 && !waitlist {
+				return store.ErrFullyBooked
+			}
+			inStock = a.Capacity > 0
+			if inStock {
+				a.Capacity--
+				a.Version++
+				s.data.activities.put(tx, a.ID, a)
+			}
+		default:
+			return fmt.Errorf("%w: unknown reservation type %q", store.ErrInvalid, r.Type)
+		}
+
+		// 3. Create Reservation
+		if _, ok := s.data.reservations.get(r.ID); ok {
+			return conflict("reservation")
+		}
+		r.Status = models.StatusConfirmed
+		if !inStock {
+			r.Status = models.StatusWaitlist
+		}
+		stored := *r
+		stored.History = nil
+		s.data.reservations.put(tx, r.ID, stored)
+		s.data.recordTransition(tx, r.ID, models.StatusPending, r.Status, r.CustomerID, r.Timestamp)
+		if err := s.data.enqueueEvent(tx, models.EventReservationCreated, r); err != nil {
+			return err
+		}
+		if inStock {
+			if err := s.data.enqueueInventory(tx, r.Type, r.ItemID); err != nil {
+				return err
+			}
+		}
+		if soldOut {
+			return s.data.enqueueOutOfStock(tx, r.ItemID)
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) TransitionReservation(ctx context.Context, id string, to models.ReservationStatus, actorID string) (*models.Reservation, error) {
+	err := s.update(ctx, func(tx *tx) error {
+		r, ok := s.data.reservations.get(id)
+		if !ok {
+			return notFound("reservation")
+		}
+		r.History = nil
+		if err := s.transition(tx, &r, to, actorID); err != nil {
+			return err
+		}
+		after := r
+		after.Status = to
+		return s.recordAudit(tx, id, &r, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetReservation(ctx, id)
+}
+
+// transition moves r to status to, handing back the unit it held and
+// counting no-shows as the change requires.
+func (s *MemoryStore) transition(tx *tx, r *models.Reservation, to models.ReservationStatus, actorID string) error {
+	if !r.CanTransition(to) {
+		return fmt.Errorf("%w: %s -> %s", store.ErrInvalidTransition, r.Status, to)
+	}
+
+	now := time.Now()
+	s.data.setStatus(tx, r.ID, to)
+	s.data.recordTransition(tx, r.ID, r.Status, to, actorID, now)
+	if err := s.data.enqueueStatusChange(tx, r, to, actorID, now); err != nil {
+		return err
+	}
+
+	// Terminal states other than check-in/fulfilment hand the unit back,
+	// first to the waitlist and otherwise to stock.
+	if r.Status.HoldsStock() && (to == models.StatusCancelled || to == models.StatusNoShow || to == models.StatusExpired) {
+		if err := s.data.addUnits(tx, r.Type, r.ItemID, 1, now); err != nil {
+			return err
+		}
+	}
+	if r.Status == models.StatusConfirmed && (to == models.StatusNoShow || to == models.StatusExpired) {
+		return s.recordNoShow(tx, r.CustomerID, now)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetExpiredReservations(ctx context.Context, cutoff time.Time) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, func(r *models.Reservation) bool {
+		end := r.Timestamp
+		if r.Pickup != nil {
+			end = r.Pickup.End
+		}
+		return r.Type == models.ReservationProduct && r.Status == models.StatusConfirmed && end.Before(cutoff)
+	})
+}
+
+// recordNoShow counts a missed reservation against the customer and applies
+// the configured credit penalty and reservation ban.
+func (s *MemoryStore) recordNoShow(tx *tx, customerID string, at time.Time) error {
+	c, ok := s.data.customers.get(customerID)
+	if !ok {
+		return notFound("customer")
+	}
+
+	cfg := s.Config.Reservations
+	c.NoShowCount++
+	previous := c.Credits
+	c.Credits = max(c.Credits-cfg.NoShowPenalty, 0)
+	c.Rank = store.RankFor(&s.Config.Ranks, c.Credits)
+	c.Version++
+	if c.Credits != previous {
+		err := s.data.recordCreditChange(tx, &models.CreditChange{
+			CustomerID: customerID, Previous: previous, Credits: c.Credits, Rank: c.Rank, Reason: models.CreditReasonNoShow, At: at,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.BanAfterNoShows > 0 && c.NoShowCount >= cfg.BanAfterNoShows {
+		until := at.Add(time.Duration(cfg.BanDuration))
+		c.BannedUntil = &until
+		c.Version++
+	}
+	s.data.customers.put(tx, customerID, c)
+	return nil
+}
+
+// active reports whether r still claims its item: it is pending, waitlisted
+// or confirmed.
+func active(r *models.Reservation) bool {
+	switch r.Status {
+	case models.StatusPending, models.StatusWaitlist, models.StatusConfirmed:
+		return true
+	}
+	return false
+}
+
+// checkNoActiveReservations fails with store.ErrActiveReservations if any
+// active reservation matches.
+func (d *data) checkNoActiveReservations(match func(*models.Reservation) bool) error {
+	n := 0
+	for _, r := range d.reservations.rows {
+		if active(&r.v) && match(&r.v) {
+			n++
+		}
+	}
+	if n > 0 {
+		return fmt.Errorf("%w (%d); cancel them first", store.ErrActiveReservations, n)
+	}
+	return nil
+}
+
+// addUnits adds n units, or removes them if n is negative, to an item's stock
+// or capacity. Added units go to waitlisted reservations first.
+func (d *data) addUnits(tx *tx, typ models.ReservationType, itemID string, n int, at time.Time) error {
+	n, err := d.fillWaitlist(tx, typ, itemID, n, at)
+	if err != nil || n == 0 {
+		return err
+	}
+
+	if typ == models.ReservationProduct {
+		if p, ok := d.products.get(itemID); ok {
+			p.Quantity += n
+			p.Version++
+			d.products.put(tx, itemID, p)
+		}
+	} else if a, ok := d.activities.get(itemID); ok {
+		a.Capacity += n
+		a.Version++
+		d.activities.put(tx, itemID, a)
+	}
+	return d.enqueueInventory(tx, typ, itemID)
+}
+
+// setUnits returns the stock or capacity to store when an edit changes an
+// item's from old to n. As with addUnits, units added go to waitlisted
+// reservations first.
+func (d *data) setUnits(tx *tx, typ models.ReservationType, itemID string, old, n int, at time.Time) (int, error) {
+	if n <= old {
+		return n, nil
+	}
+	left, err := d.fillWaitlist(tx, typ, itemID, n-old, at)
+	return old + left, err
+}
+
+// fillWaitlist confirms up to n waitlisted reservations for the item in
+// priority order, returning how many of the n units are left over.
+func (d *data) fillWaitlist(tx *tx, typ models.ReservationType, itemID string, n int, at time.Time) (int, error) {
+	for ; n > 0; n-- {
+		promoted, err := d.promoteWaitlisted(tx, typ, itemID, at)
+		if err != nil || !promoted {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// promoteWaitlisted confirms the highest-priority, longest waiting
+// reservation on the item's waitlist whose pickup slot, if any, has room,
+// reporting whether there was one.
+func (d *data) promoteWaitlisted(tx *tx, typ models.ReservationType, itemID string, at time.Time) (bool, error) {
+	waiting := d.listReservations(func(r *models.Reservation) bool {
+		return r.Type == typ && r.ItemID == itemID && r.Status == models.StatusWaitlist &&
+			(r.Pickup == nil || !d.slotFull(r.Pickup.LocationID, r.Pickup.Start))
+	})
+	if len(waiting) == 0 {
+		return false, nil
+	}
+	next := slices.MinFunc(waiting, func(a, b *models.Reservation) int {
+		return cmp.Or(cmp.Compare(b.PriorityRank, a.PriorityRank), a.Timestamp.Compare(b.Timestamp))
+	})
+	d.setStatus(tx, next.ID, models.StatusConfirmed)
+	d.recordTransition(tx, next.ID, models.StatusWaitlist, models.StatusConfirmed, models.ActorSystem, at)
+	return true, d.enqueueStatusChange(tx, next, models.StatusConfirmed, models.ActorSystem, at)
+}
+
+func (d *data) setStatus(tx *tx, id string, status models.ReservationStatus) {
+	r, _ := d.reservations.get(id)
+	r.Status = status
+	d.reservations.put(tx, id, r)
+}
+
+func (d *data) recordTransition(tx *tx, id string, from, to models.ReservationStatus, actorID string, at time.Time) {
+	r, _ := d.reservations.get(id)
+	r.History = append(slices.Clip(r.History), &models.Transition{From: from, To: to, ActorID: actorID, At: at})
+	d.reservations.put(tx, id, r)
+}
+
+// enqueueStatusChange publishes r moving from its current status to to.
+func (d *data) enqueueStatusChange(tx *tx, r *models.Reservation, to models.ReservationStatus, actorID string, at time.Time) error {
+	change := &models.StatusChange{
+		ReservationID: r.ID,
+		CustomerID:    r.CustomerID,
+		ItemID:        r.ItemID,
+		Type:          r.Type,
+		From:          r.Status,
+		To:            to,
+		ActorID:       actorID,
+		At:            at,
+	}
+	if err := d.enqueueEvent(tx, models.EventReservationStatusChanged, change); err != nil {
+		return err
+	}
+	if to == models.StatusCancelled {
+		return d.enqueueEvent(tx, models.EventReservationCancelled, change)
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+package memory
+
+import (
+	"context"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Product Implementation
+
+// activeProduct returns the product unless it doesn't exist or is deleted.
+func (d *data) activeProduct(id string) (models.Product, error) {
+	p, ok := d.products.get(id)
+	if !ok || p.DeletedAt != nil {
+		return p, notFound("product")
+	}
+	return p, nil
+}
+
+func (s *MemoryStore) AddProduct(ctx context.Context, p *models.Product) error {
+	p.Version = 1
+	return s.update(ctx, func(tx *tx) error {
+		if _, ok := s.data.products.get(p.ID); ok {
+			return conflict("product")
+		}
+		s.data.products.put(tx, p.ID, models.Product{
+			ID: p.ID, Name: p.Name, Description: p.Description, ImageURL: p.ImageURL, Quantity: p.Quantity, Visible: p.Visible, Version: 1,
+		})
+		if err := s.data.enqueueInventory(tx, models.ReservationProduct, p.ID); err != nil {
+			return err
+		}
+		return s.recordAudit(tx, p.ID, nil, p)
+	})
+}
+
+func (s *MemoryStore) GetProduct(ctx context.Context, id string) (*models.Product, error) {
+	var p models.Product
+	err := s.view(ctx, func() error {
+		var ok bool
+		if p, ok = s.data.products.get(id); !ok {
+			return notFound("product")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *MemoryStore) GetAllProducts(ctx context.Context, visibleOnly bool) ([]*models.Product, error) {
+	return s.listProducts(ctx, func(p *models.Product) bool { return p.DeletedAt == nil && (p.Visible || !visibleOnly) })
+}
+
+func (s *MemoryStore) GetDeletedProducts(ctx context.Context) ([]*models.Product, error) {
+	products, err := s.listProducts(ctx, func(p *models.Product) bool { return p.DeletedAt != nil })
+	slices.SortStableFunc(products, func(a, b *models.Product) int { return b.DeletedAt.Compare(*a.DeletedAt) })
+	return products, err
+}
+
+func (s *MemoryStore) listProducts(ctx context.Context, keep func(*models.Product) bool) ([]*models.Product, error) {
+	var products []*models.Product
+	err := s.view(ctx, func() error {
+		products = s.data.products.list(keep)
+		return nil
+	})
+	return products, err
+}
+
+func (s *MemoryStore) UpdateProduct(ctx context.Context, p *models.Product) error {
+	updated, err := s.PatchProduct(ctx, p.ID, p.Version, func(cur *models.Product) error {
+		*cur = *p
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*p = *updated
+	return nil
+}
+
+func (s *MemoryStore) PatchProduct(ctx context.Context, id string, version int, patch func(*models.Product) error) (*models.Product, error) {
+	var p models.Product
+	err := s.update(ctx, func(tx *tx) error {
+		old, err := s.data.activeProduct(id)
+		if err != nil {
+			return err
+		}
+		if err := store.CheckVersion("product", old.Version, version); err != nil {
+			return err
+		}
+		p = old
+		if err := patch(&p); err != nil {
+			return err
+		}
+		p.ID, p.DeletedAt, p.Version = old.ID, old.DeletedAt, old.Version+1
+		if p.Quantity, err = s.data.setUnits(tx, models.ReservationProduct, id, old.Quantity, p.Quantity, time.Now()); err != nil {
+			return err
+		}
+		s.data.products.put(tx, id, p)
+		if err := s.data.enqueueInventory(tx, models.ReservationProduct, id); err != nil {
+			return err
+		}
+		if old.Quantity > 0 && p.Quantity <= 0 {
+			if err := s.data.enqueueOutOfStock(tx, id); err != nil {
+				return err
+			}
+		}
+		return s.recordAudit(tx, id, &old, &p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AdjustProductStock adds delta units to the product's stock, or removes them
+// if delta is negative. Added units go to waitlisted reservations first.
+func (s *MemoryStore) AdjustProductStock(ctx context.Context, id string, delta int) (*models.Product, error) {
+	var p models.Product
+	err := s.update(ctx, func(tx *tx) error {
+		old, err := s.data.activeProduct(id)
+		if err != nil {
+			return err
+		}
+		if old.Quantity+delta < 0 {
+			return fmt.Errorf("%w: only %d left", store.ErrOutOfStock, old.Quantity)
+		}
+		if err := s.data.addUnits(tx, models.ReservationProduct, id, delta, time.Now()); err != nil {
+			return err
+		}
+		p, _ = s.data.products.get(id)
+		if old.Quantity > 0 && p.Quantity <= 0 {
+			if err := s.data.enqueueOutOfStock(tx, id); err != nil {
+				return err
+			}
+		}
+		return s.recordAudit(tx, id, &old, &p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteProduct soft-deletes a product: it disappears from listings and can
+// no longer be reserved, but stays available to reservation history until
+// purged.
+func (s *MemoryStore) DeleteProduct(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *tx) error {
+		if err := s.data.checkNoActiveReservations(func(r *models.Reservation) bool {
+			return r.Type == models.ReservationProduct && r.ItemID == id
+		}); err != nil {
+			return err
+		}
+		old, err := s.data.activeProduct(id)
+		if err != nil {
+			return err
+		}
+		p := old
+		now := time.Now()
+		p.DeletedAt = &now
+		p.Version++
+		s.data.products.put(tx, id, p)
+		if err := s.data.enqueueInventory(tx, models.ReservationProduct, id); err != nil {
+			return err
+		}
+		return s.recordAudit(tx, id, &old, nil)
+	})
+}
+
+// RestoreProduct undoes DeleteProduct, returning store.ErrNotFound if the product
+// doesn't exist or isn't deleted.
+func (s *MemoryStore) RestoreProduct(ctx context.Context, id string) (*models.Product, error) {
+	var restored models.Product
+	err := s.update(ctx, func(tx *tx) error {
+		old, ok := s.data.products.get(id)
+		if !ok || old.DeletedAt == nil {
+			return notFound("product")
+		}
+		restored = old
+		restored.DeletedAt = nil
+		restored.Version++
+		s.data.products.put(tx, id, restored)
+		if err := s.data.enqueueInventory(tx, models.ReservationProduct, id); err != nil {
+			return err
+		}
+		return s.recordAudit(tx, id, &old, &restored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// Activity Implementation
+
+// activeActivity returns the activity unless it doesn't exist or is deleted.
+func (d *data) activeActivity(id string) (models.Activity, error) {
+	a, ok := d.activities.get(id)
+	if !ok || a.DeletedAt != nil {
+		return a, notFound("activity")
+	}
+	return a, nil
+}
+
+func (s *MemoryStore) AddActivity(ctx context.Context, a *models.Activity) error {
+	a.Version = 1
+	return s.update(ctx, func(tx *tx) error {
+		if _, ok := s.data.activities.get(a.ID); ok {
+			return conflict("activity")
+		}
+		s.data.activities.put(tx, a.ID, models.Activity{
+			ID: a.ID, Name: a.Name, Description: a.Description, ImageURL: a.ImageURL, Capacity: a.Capacity, Visible: a.Visible, Version: 1,
+		})
+		if err := s.data.enqueueInventory(tx, models.ReservationActivity, a.ID); err != nil {
+			return err
+		}
+		return s.recordAudit(tx, a.ID, nil, a)
+	})
+}
+
+func (s *MemoryStore) GetActivity(ctx context.Context, id string) (*models.Activity, error) {
+	var a models.Activity
+	err := s.view(ctx, func() error {
+		var ok bool
+		if a, ok = s.data.activities.get(id); !ok {
+			return notFound("activity")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *MemoryStore) GetAllActivities(ctx context.Context, visibleOnly bool) ([]*models.Activity, error) {
+	return s.listActivities(ctx, func(a *models.Activity) bool { return a.DeletedAt == nil && (a.Visible || !visibleOnly) })
+}
+
+func (s *MemoryStore) GetDeletedActivities(ctx context.Context) ([]*models.Activity, error) {
+	activities, err := s.listActivities(ctx, func(a *models.Activity) bool { return a.DeletedAt != nil })
+	slices.SortStableFunc(activities, func(a, b *models.Activity) int { return b.DeletedAt.Compare(*a.DeletedAt) })
+	return activities, err
+}
+
+func (s *MemoryStore) listActivities(ctx context.Context, keep func(*models.Activity) bool) ([]*models.Activity, error) {
+	var activities []*models.Activity
+	err := s.view(ctx, func() error {
+		activities = s.data.activities.list(keep)
+		return nil
+	})
+	return activities, err
+}
+
+func (s *MemoryStore) UpdateActivity(ctx context.Context, a *models.Activity) error {
+	updated, err := s.PatchActivity(ctx, a.ID, a.Version, func(cur *models.Activity) error {
+		*cur = *a
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*a = *updated
+	return nil
+}
+
+func (s *MemoryStore) PatchActivity(ctx context.Context, id string, version int, patch func(*models.Activity) error) (*models.Activity, error) {
+	var a models.Activity
+	err := s.update(ctx, func(tx *tx) error {
+		old, err := s.data.activeActivity(id)
+		if err != nil {
+			return err
+		}
+		if err := store.CheckVersion("activity", old.Version, version); err != nil {
+			return err
+		}
+		a = old
+		if err := patch(&a); err != nil {
+			return err
+		}
+		a.ID, a.DeletedAt, a.Version = old.ID, old.DeletedAt, old.Version+1
+		if a.Capacity, err = s.data.setUnits(tx, models.ReservationActivity, id, old.Capacity, a.Capacity, time.Now()); err != nil {
+			return err
+		}
+		s.data.activities.put(tx, id, a)
+		if err := s.data.enqueueInventory(tx, models.ReservationActivity, id); err != nil {
+			return err
+		}
+		return s.recordAudit(tx, id, &old, &a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// AdjustActivityCapacity is AdjustProductStock for an activity's remaining
+// places.
+func (s *MemoryStore) AdjustActivityCapacity(ctx context.Context, id string, delta int) (*models.Activity, error) {
+	var a models.Activity
+	err := s.update(ctx, func(tx *tx) error {
+		old, err := s.data.activeActivity(id)
+		if err != nil {
+			return err
+		}
+		if old.Capacity+delta < 0 {
+			return fmt.Errorf("%w: only %d places left", store.ErrFullyBooked, old.Capacity)
+		}
+		if err := s.data.addUnits(tx, models.ReservationActivity, id, delta, time.Now()); err != nil {
+			return err
+		}
+		a, _ = s.data.activities.get(id)
+		return s.recordAudit(tx, id, &old, &a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteActivity soft-deletes an activity; see DeleteProduct.
+func (s *MemoryStore) DeleteActivity(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *tx) error {
+		if err := s.data.checkNoActiveReservations(func(r *models.Reservation) bool {
+			return r.Type == models.ReservationActivity && r.ItemID == id
+		}); err != nil {
+			return err
+		}
+		old, err := s.data.activeActivity(id)
+		if err != nil {
+			return err
+		}
+		a := old
+		now := time.Now()
+		a.DeletedAt = &now
+		a.Version++
+		s.data.activities.put(tx, id, a)
+		if err := s.data.enqueueInventory(tx, models.ReservationActivity, id); err != nil {
+			return err
+		}
+		return s.recordAudit(tx, id, &old, nil)
+	})
+}
+
+// RestoreActivity undoes DeleteActivity, returning store.ErrNotFound if the
+// activity doesn't exist or isn't deleted.
+func (s *MemoryStore) RestoreActivity(ctx context.Context, id string) (*models.Activity, error) {
+	var restored models.Activity
+	err := s.update(ctx, func(tx *tx) error {
+		old, ok := s.data.activities.get(id)
+		if !ok || old.DeletedAt == nil {
+			return notFound("activity")
+		}
+		restored = old
+		restored.DeletedAt = nil
+		restored.Version++
+		s.data.activities.put(tx, id, restored)
+		if err := s.data.enqueueInventory(tx, models.ReservationActivity, id); err != nil {
+			return err
+		}
+		return s.recordAudit(tx, id, &old, &restored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// PurgeDeleted permanently removes products, activities and customers that
+// were soft-deleted before cutoff, returning how many rows went. Rows still
+// referenced by reservations are kept.
+func (s *MemoryStore) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := s.update(ctx, func(tx *tx) error {
+		referenced := make(map[string]bool)
+		for _, r := range s.data.reservations.rows {
+			referenced[string(r.v.Type)+":"+r.v.ItemID] = true
+			referenced["customer:"+r.v.CustomerID] = true
+		}
+		due := func(deletedAt *time.Time, key string) bool {
+			return deletedAt != nil && deletedAt.Before(cutoff) && !referenced[key]
+		}
+
+		for _, p := range s.data.products.list(func(p *models.Product) bool { return due(p.DeletedAt, "product:"+p.ID) }) {
+			s.data.products.delete(tx, p.ID)
+			purged++
+		}
+		for _, a := range s.data.activities.list(func(a *models.Activity) bool { return due(a.DeletedAt, "activity:"+a.ID) }) {
+			s.data.activities.delete(tx, a.ID)
+			purged++
+		}
+		for _, c := range s.data.customers.list(func(c *models.Customer) bool { return due(c.DeletedAt, "customer:"+c.ID) }) {
+			for _, n := range s.data.notifications.list(func(n *models.Notification) bool { return n.CustomerID == c.ID }) {
+				s.data.notifications.delete(tx, n.ID)
+			}
+			s.data.customers.delete(tx, c.ID)
+			purged++
+		}
+		return nil
+	})
+	return purged, err
+}
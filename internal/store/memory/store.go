@@ -0,0 +1,215 @@
+// Package memory implements store.Repository in process memory, for tests and
+// demos. It behaves like the SQL stores, transactions included, but nothing
+// survives a restart.
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+type MemoryStore struct {
+	data   *data
+	Config *config.Config
+	audit  *models.AuditEntry // Set on views returned by WithAudit
+}
+
+// data holds every table. One lock serialises writers, so each write is a
+// transaction that other callers see whole or not at all.
+type data struct {
+	mu sync.RWMutex
+
+	customers     *table[models.Customer]
+	products      *table[models.Product]
+	activities    *table[models.Activity]
+	reservations  *table[models.Reservation] // History is kept here too
+	webhooks      *table[models.Webhook]
+	notifications *table[models.Notification]
+	keys          *table[models.IdempotencyKey] // Keyed by customer and key
+	slots         *table[models.PickupSlot]     // Pickup slots booked, keyed by slotID
+
+	creditHistory []models.CreditChange
+	events        []models.Event
+	cursors       map[string]int64
+	leases        map[string]lease // Keyed by consumer
+	deliveries    []models.WebhookDelivery
+	auditLog      []models.AuditEntry
+}
+
+func NewMemoryStore(cfg *config.Config) *MemoryStore {
+	return &MemoryStore{
+		Config: cfg,
+		data: &data{
+			customers:     newTable[models.Customer](),
+			products:      newTable[models.Product](),
+			activities:    newTable[models.Activity](),
+			reservations:  newTable[models.Reservation](),
+			webhooks:      newTable[models.Webhook](),
+			notifications: newTable[models.Notification](),
+			keys:          newTable[models.IdempotencyKey](),
+			slots:         newTable[models.PickupSlot](),
+			cursors:       make(map[string]int64),
+			leases:        make(map[string]lease),
+		},
+	}
+}
+
+// errDiscard makes update roll back without failing, as dry runs do.
+var errDiscard = errors.New("discard transaction")
+
+// tx records how to undo each change made in a transaction.
+type tx struct {
+	undo []func()
+}
+
+func (tx *tx) onRollback(f func()) {
+	tx.undo = append(tx.undo, f)
+}
+
+func (tx *tx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+}
+
+// update runs fn under the write lock. If fn fails every change it made is
+// undone; errDiscard undoes them without failing.
+func (s *MemoryStore) update(ctx context.Context, fn func(tx *tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+
+	tx := &tx{}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		if errors.Is(err, errDiscard) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// view runs fn under the read lock.
+func (s *MemoryStore) view(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	return fn()
+}
+
+// table is a set of rows keyed by ID. Rows are stored and returned by value,
+// so callers can't change them behind the store's back, and are listed in
+// insertion order like a table scan.
+type table[T any] struct {
+	rows map[string]*row[T]
+	seq  int64
+}
+
+type row[T any] struct {
+	seq int64
+	v   T
+}
+
+func newTable[T any]() *table[T] {
+	return &table[T]{rows: make(map[string]*row[T])}
+}
+
+func (t *table[T]) get(id string) (T, bool) {
+	r, ok := t.rows[id]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return r.v, true
+}
+
+// put inserts or replaces the row with ID id.
+func (t *table[T]) put(tx *tx, id string, v T) {
+	if old, ok := t.rows[id]; ok {
+		t.rows[id] = &row[T]{seq: old.seq, v: v}
+		tx.onRollback(func() { t.rows[id] = old })
+		return
+	}
+	t.seq++
+	t.rows[id] = &row[T]{seq: t.seq, v: v}
+	tx.onRollback(func() { delete(t.rows, id) })
+}
+
+func (t *table[T]) delete(tx *tx, id string) {
+	old, ok := t.rows[id]
+	if !ok {
+		return
+	}
+	delete(t.rows, id)
+	tx.onRollback(func() { t.rows[id] = old })
+}
+
+// list returns the rows keep accepts, or every row if keep is nil, in
+// insertion order.
+func (t *table[T]) list(keep func(*T) bool) []*T {
+	rows := make([]*row[T], 0, len(t.rows))
+	for _, r := range t.rows {
+		if keep == nil || keep(&r.v) {
+			rows = append(rows, r)
+		}
+	}
+	slices.SortFunc(rows, func(a, b *row[T]) int { return cmp.Compare(a.seq, b.seq) })
+
+	out := make([]*T, len(rows))
+	for i, r := range rows {
+		v := r.v
+		out[i] = &v
+	}
+	return out
+}
+
+// appendRow appends v to *rows until the transaction is rolled back.
+func appendRow[T any](tx *tx, rows *[]T, v T) {
+	n := len(*rows)
+	*rows = append(*rows, v)
+	tx.onRollback(func() { *rows = (*rows)[:n] })
+}
+
+// setRows replaces *rows with v until the transaction is rolled back.
+func setRows[T any](tx *tx, rows *[]T, v []T) {
+	old := *rows
+	*rows = v
+	tx.onRollback(func() { *rows = old })
+}
+
+// limit truncates rows to n; a negative n, as in SQLite, means no limit.
+func limit[T any](rows []T, n int) []T {
+	if n >= 0 && len(rows) > n {
+		return rows[:n]
+	}
+	return rows
+}
+
+func notFound(what string) error {
+	return fmt.Errorf("%s %w", what, store.ErrNotFound)
+}
+
+func conflict(what string) error {
+	return fmt.Errorf("%s %w", what, store.ErrConflict)
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// MigrationStatus reports no migrations: the schema is the Go types.
+func (s *MemoryStore) MigrationStatus(ctx context.Context) (applied, latest int, err error) {
+	return 0, 0, ctx.Err()
+}
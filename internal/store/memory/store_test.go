@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"farm/internal/config"
+	"farm/internal/store"
+	"farm/internal/store/storetest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, cfg *config.Config) store.Repository {
+		cfg.Database.Driver = "memory"
+		return NewMemoryStore(cfg)
+	})
+}
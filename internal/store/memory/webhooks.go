@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"farm/internal/models"
+	"slices"
+	"time"
+)
+
+// Webhook Implementation
+
+func (s *MemoryStore) AddWebhook(ctx context.Context, w *models.Webhook) error {
+	return s.update(ctx, func(tx *tx) error {
+		if _, ok := s.data.webhooks.get(w.ID); ok {
+			return conflict("webhook")
+		}
+		stored := *w
+		stored.Events = slices.Clone(w.Events)
+		s.data.webhooks.put(tx, w.ID, stored)
+		return s.recordAudit(tx, w.ID, nil, w)
+	})
+}
+
+func (s *MemoryStore) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	var w models.Webhook
+	err := s.view(ctx, func() error {
+		var ok bool
+		if w, ok = s.data.webhooks.get(id); !ok {
+			return notFound("webhook")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.Events = slices.Clone(w.Events)
+	return &w, nil
+}
+
+func (s *MemoryStore) GetAllWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	err := s.view(ctx, func() error {
+		webhooks = s.data.webhooks.list(nil)
+		return nil
+	})
+	for _, w := range webhooks {
+		w.Events = slices.Clone(w.Events)
+	}
+	slices.SortStableFunc(webhooks, func(a, b *models.Webhook) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return webhooks, err
+}
+
+func (s *MemoryStore) DeleteWebhook(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *tx) error {
+		before, ok := s.data.webhooks.get(id)
+		if !ok {
+			return notFound("webhook")
+		}
+		deliveries := slices.DeleteFunc(slices.Clone(s.data.deliveries), func(d models.WebhookDelivery) bool { return d.WebhookID == id })
+		setRows(tx, &s.data.deliveries, deliveries)
+		s.data.webhooks.delete(tx, id)
+		return s.recordAudit(tx, id, &before, nil)
+	})
+}
+
+// Delivery Implementation
+
+// queryDeliveries returns copies of the deliveries keep accepts, in ID order.
+func (s *MemoryStore) queryDeliveries(ctx context.Context, keep func(*models.WebhookDelivery) bool) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	err := s.view(ctx, func() error {
+		for _, d := range s.data.deliveries {
+			if keep(&d) {
+				deliveries = append(deliveries, &d)
+			}
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// AddWebhookDeliveries queues deliveries, skipping any event already queued
+// for the same webhook so that replaying the outbox is harmless.
+func (s *MemoryStore) AddWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	return s.update(ctx, func(tx *tx) error {
+		for _, d := range deliveries {
+			queued := slices.ContainsFunc(s.data.deliveries, func(q models.WebhookDelivery) bool {
+				return q.WebhookID == d.WebhookID && q.EventID == d.EventID
+			})
+			if queued {
+				continue
+			}
+			stored := *d
+			stored.ID = 1
+			if n := len(s.data.deliveries); n > 0 {
+				stored.ID = s.data.deliveries[n-1].ID + 1
+			}
+			stored.DeliveredAt = nil
+			appendRow(tx, &s.data.deliveries, stored)
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) GetDueWebhookDeliveries(ctx context.Context, now time.Time, n int) ([]*models.WebhookDelivery, error) {
+	deliveries, err := s.queryDeliveries(ctx, func(d *models.WebhookDelivery) bool {
+		return d.Status == models.DeliveryPending && !d.NextAttemptAt.After(now)
+	})
+	slices.SortStableFunc(deliveries, func(a, b *models.WebhookDelivery) int { return a.NextAttemptAt.Compare(b.NextAttemptAt) })
+	return limit(deliveries, n), err
+}
+
+func (s *MemoryStore) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) (bool, error) {
+	var ok bool
+	err := s.update(ctx, func(tx *tx) error {
+		i := slices.IndexFunc(s.data.deliveries, func(d models.WebhookDelivery) bool { return d.ID == id })
+		if i < 0 || s.data.deliveries[i].Status != models.DeliveryPending || s.data.deliveries[i].NextAttemptAt.After(now) {
+			return nil
+		}
+		deliveries := slices.Clone(s.data.deliveries)
+		deliveries[i].NextAttemptAt, ok = until, true
+		setRows(tx, &s.data.deliveries, deliveries)
+		return nil
+	})
+	return ok, err
+}
+
+func (s *MemoryStore) GetWebhookDeliveries(ctx context.Context, webhookID string, n int) ([]*models.WebhookDelivery, error) {
+	deliveries, err := s.queryDeliveries(ctx, func(d *models.WebhookDelivery) bool { return d.WebhookID == webhookID })
+	slices.SortFunc(deliveries, func(a, b *models.WebhookDelivery) int { return cmp.Compare(b.ID, a.ID) })
+	return limit(deliveries, n), err
+}
+
+func (s *MemoryStore) UpdateWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	return s.update(ctx, func(tx *tx) error {
+		i := slices.IndexFunc(s.data.deliveries, func(q models.WebhookDelivery) bool { return q.ID == d.ID })
+		if i < 0 {
+			return nil
+		}
+		deliveries := slices.Clone(s.data.deliveries)
+		q := &deliveries[i]
+		q.Status, q.Attempts, q.ResponseCode, q.LastError, q.NextAttemptAt, q.DeliveredAt = d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt, d.DeliveredAt
+		setRows(tx, &s.data.deliveries, deliveries)
+		return nil
+	})
+}
@@ -0,0 +1,287 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/store/sqlstore"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// NewMySQLStore connects to the MySQL or MariaDB database named in cfg,
+// bringing its schema up to date.
+func NewMySQLStore(cfg *config.Config) (*sqlstore.Store, error) {
+	c, err := gomysql.ParseDSN(cfg.Database.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+	// Times are written and read in UTC, and UPDATE reports rows matched
+	// rather than changed, as the other databases do. The shared queries
+	// quote identifiers MySQL reserves, such as rank, with double quotes.
+	c.ParseTime = true
+	c.Loc = time.UTC
+	c.ClientFoundRows = true
+	if c.Params == nil {
+		c.Params = make(map[string]string)
+	}
+	mode := "@@sql_mode"
+	if m, ok := c.Params["sql_mode"]; ok {
+		mode = m
+	}
+	c.Params["sql_mode"] = "CONCAT(" + mode + ", ',ANSI_QUOTES')"
+
+	connector, err := gomysql.NewConnector(c)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	store.ConfigurePool(db, &cfg.Database)
+	return sqlstore.New(db, dialect{}, cfg)
+}
+
+// dialect adapts the shared queries to MySQL and MariaDB.
+type dialect struct{}
+
+func (dialect) Rebind(query string) string { return query }
+
+func (dialect) Bool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+// Upsert uses ON DUPLICATE KEY UPDATE, which applies to any unique key
+// rather than the one named. A column assigned to itself skips the row.
+func (dialect) Upsert(key []string, update ...string) string {
+	var set []string
+	for _, col := range update {
+		set = append(set, col+" = VALUES("+col+")")
+	}
+	if len(set) == 0 {
+		set = append(set, key[0]+" = "+key[0])
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+// ForUpdate locks the rows read: InnoDB's plain reads see the snapshot the
+// transaction started with.
+func (dialect) ForUpdate() string { return " FOR UPDATE" }
+
+func (dialect) Period(period, column string) string {
+	switch period {
+	case models.PeriodWeek:
+		return "DATE_FORMAT(DATE_SUB(" + column + ", INTERVAL WEEKDAY(" + column + ") DAY), '%Y-%m-%d')"
+	case models.PeriodMonth:
+		return "DATE_FORMAT(" + column + ", '%Y-%m-01')"
+	default:
+		return "DATE_FORMAT(" + column + ", '%Y-%m-%d')"
+	}
+}
+
+func (dialect) IsUniqueViolation(err error) bool {
+	var me *gomysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1062 // ER_DUP_ENTRY
+}
+
+func (dialect) Schema() ([]string, [][]string) {
+	return setup, migrations
+}
+
+// tableOptions follows every CREATE TABLE. The binary collation compares and
+// sorts text by code point, as SQLite does, so that emails and other keys
+// are case-sensitive.
+const tableOptions = " ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin"
+
+// setup creates the table recording which migrations have run.
+var setup = []string{
+	`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME(6)
+	)` + tableOptions,
+}
+
+// migrations build the schema. MySQL commits DDL as it goes rather than
+// inside the migration's transaction, so every statement must be safe to
+// rerun after a failure part way through. Append only: never edit or reorder
+// an entry that has shipped.
+//
+// Keys and indexed text are VARCHAR(255), the longest that fits InnoDB's
+// index limit in pairs; other text is TEXT or LONGTEXT. Foreign keys must be
+// declared as table constraints, as InnoDB ignores inline REFERENCES.
+var migrations = [][]string{
+	// 1: schema as of SQLite and PostgreSQL migration 11
+	{
+		`CREATE TABLE IF NOT EXISTS customers (
+			id VARCHAR(255) PRIMARY KEY,
+			email VARCHAR(255) UNIQUE,
+			password TEXT,
+			salt TEXT,
+			name TEXT,
+			credits INTEGER,
+			"rank" INTEGER,
+			role VARCHAR(32),
+			no_show_count INTEGER NOT NULL DEFAULT 0,
+			banned_until DATETIME(6),
+			notify_email BOOLEAN NOT NULL DEFAULT TRUE,
+			notify_in_app BOOLEAN NOT NULL DEFAULT TRUE,
+			deleted_at DATETIME(6),
+			erase_after DATETIME(6),
+			version INTEGER NOT NULL DEFAULT 1
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS products (
+			id VARCHAR(255) PRIMARY KEY,
+			name TEXT,
+			description TEXT,
+			image_url TEXT,
+			quantity INTEGER,
+			visible BOOLEAN,
+			deleted_at DATETIME(6),
+			version INTEGER NOT NULL DEFAULT 1
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS activities (
+			id VARCHAR(255) PRIMARY KEY,
+			name TEXT,
+			description TEXT,
+			image_url TEXT,
+			capacity INTEGER,
+			visible BOOLEAN,
+			deleted_at DATETIME(6),
+			version INTEGER NOT NULL DEFAULT 1
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS reservations (
+			id VARCHAR(255) PRIMARY KEY,
+			customer_id VARCHAR(255) NOT NULL,
+			product_id VARCHAR(255),
+			activity_id VARCHAR(255),
+			type VARCHAR(32) NOT NULL,
+			priority_rank INTEGER,
+			timestamp DATETIME(6),
+			status VARCHAR(32),
+			pickup_location_id VARCHAR(255),
+			pickup_start DATETIME(6),
+			pickup_end DATETIME(6),
+			INDEX idx_reservations_pickup (pickup_start, pickup_location_id),
+			INDEX idx_reservations_product_status (product_id, status),
+			INDEX idx_reservations_activity_status (activity_id, status),
+			INDEX idx_reservations_customer (customer_id),
+			FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE RESTRICT,
+			FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE RESTRICT,
+			FOREIGN KEY (activity_id) REFERENCES activities (id) ON DELETE RESTRICT,
+			CHECK ((type = 'product' AND product_id IS NOT NULL AND activity_id IS NULL)
+				OR (type = 'activity' AND activity_id IS NOT NULL AND product_id IS NULL))
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS reservation_transitions (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			reservation_id VARCHAR(255),
+			from_status VARCHAR(32),
+			to_status VARCHAR(32),
+			actor_id VARCHAR(255),
+			at DATETIME(6),
+			INDEX idx_reservation_transitions_reservation (reservation_id)
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS outbox_events (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			type VARCHAR(255),
+			payload LONGTEXT,
+			created_at DATETIME(6)
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS outbox_cursors (
+			consumer VARCHAR(255) PRIMARY KEY,
+			last_id BIGINT
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id VARCHAR(255) PRIMARY KEY,
+			url TEXT,
+			secret TEXT,
+			events TEXT,
+			active BOOLEAN,
+			created_at DATETIME(6)
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			webhook_id VARCHAR(255),
+			event_id BIGINT,
+			event_type VARCHAR(255),
+			payload LONGTEXT,
+			status VARCHAR(32),
+			attempts INTEGER,
+			response_code INTEGER,
+			last_error TEXT,
+			next_attempt_at DATETIME(6),
+			delivered_at DATETIME(6),
+			created_at DATETIME(6),
+			UNIQUE (webhook_id, event_id),
+			INDEX idx_webhook_deliveries_due (status, next_attempt_at)
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id VARCHAR(255) PRIMARY KEY,
+			customer_id VARCHAR(255),
+			event VARCHAR(255),
+			subject TEXT,
+			body TEXT,
+			created_at DATETIME(6),
+			read_at DATETIME(6),
+			INDEX idx_notifications_customer (customer_id, created_at)
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			actor_id VARCHAR(255),
+			action VARCHAR(255),
+			target_type VARCHAR(255),
+			target_id VARCHAR(255),
+			before_state LONGTEXT,
+			after_state LONGTEXT,
+			request_id VARCHAR(255),
+			client_ip VARCHAR(255),
+			created_at DATETIME(6),
+			INDEX idx_audit_log_target (target_type, target_id),
+			INDEX idx_audit_log_actor (actor_id),
+			INDEX idx_audit_log_created (created_at)
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS credit_history (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			customer_id VARCHAR(255) NOT NULL,
+			previous INTEGER,
+			credits INTEGER,
+			"rank" INTEGER,
+			reason VARCHAR(255),
+			created_at DATETIME(6),
+			INDEX idx_credit_history_customer (customer_id),
+			FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE CASCADE
+		)` + tableOptions,
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			customer_id VARCHAR(255) NOT NULL,
+			idempotency_key VARCHAR(255) NOT NULL,
+			request_hash VARCHAR(255) NOT NULL,
+			status INTEGER NOT NULL DEFAULT 0,
+			headers TEXT,
+			body LONGBLOB,
+			created_at DATETIME(6),
+			expires_at DATETIME(6),
+			PRIMARY KEY (customer_id, idempotency_key),
+			INDEX idx_idempotency_keys_expires_at (expires_at)
+		)` + tableOptions,
+	},
+	// 2: outbox consumer leases, so one instance reads each consumer's events
+	{
+		`ALTER TABLE outbox_cursors ADD COLUMN holder VARCHAR(255), ADD COLUMN lease_until DATETIME(6)`,
+		`CREATE INDEX idx_outbox_events_created ON outbox_events (created_at)`,
+	},
+	// 3: the caller's request ID, kept apart from the server's
+	{
+		`ALTER TABLE audit_log ADD COLUMN client_request_id VARCHAR(255)`,
+	},
+	// 4: pickup slot capacities, locked while places in them are taken
+	{
+		`CREATE TABLE IF NOT EXISTS pickup_slots (
+			location_id VARCHAR(255) NOT NULL,
+			start_at DATETIME(6) NOT NULL,
+			capacity INTEGER NOT NULL,
+			PRIMARY KEY (location_id, start_at)
+		)` + tableOptions,
+	},
+}
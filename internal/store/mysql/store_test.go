@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"database/sql"
+	"farm/internal/config"
+	"farm/internal/store"
+	"farm/internal/store/storetest"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// dsnEnv names the variable holding the DSN of a MySQL or MariaDB server the
+// tests may create databases on. Without it the tests are skipped.
+const dsnEnv = "FARM_TEST_MYSQL_DSN"
+
+func TestConformance(t *testing.T) {
+	admin, dsn := connect(t)
+	storetest.Run(t, func(t *testing.T, cfg *config.Config) store.Repository {
+		s, _ := openTest(t, admin, dsn, cfg)
+		return s
+	})
+}
+
+func TestInterleaved(t *testing.T) {
+	admin, dsn := connect(t)
+	storetest.RunInterleaved(t, func(t *testing.T, cfg *config.Config) (store.Repository, *sql.DB) {
+		return openTest(t, admin, dsn, cfg)
+	})
+}
+
+// connect returns a connection to the server named by dsnEnv, skipping t
+// without one.
+func connect(t *testing.T) (*sql.DB, string) {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", dsnEnv)
+	}
+	admin, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	return admin, dsn
+}
+
+// openTest opens a store in a database of its own, dropped after t, and a
+// second pool of connections to that database.
+func openTest(t *testing.T, admin *sql.DB, dsn string, cfg *config.Config) (store.Repository, *sql.DB) {
+	name := fmt.Sprintf("farm_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP DATABASE " + name) })
+
+	c, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.DBName = name
+	cfg.Database = config.DatabaseConfig{
+		Driver:           "mysql",
+		ConnectionString: c.FormatDSN(),
+		MaxOpenConns:     4,
+		MaxIdleConns:     4,
+	}
+	s, err := NewMySQLStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	db, err := sql.Open("mysql", cfg.Database.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return s, db
+}
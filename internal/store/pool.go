@@ -0,0 +1,15 @@
+package store
+
+import (
+	"database/sql"
+	"farm/internal/config"
+	"time"
+)
+
+// ConfigurePool applies the connection pool limits in c to db.
+func ConfigurePool(db *sql.DB, c *config.DatabaseConfig) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(c.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(time.Duration(c.ConnMaxIdleTime))
+}
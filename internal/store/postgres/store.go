@@ -1,89 +1,366 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"farm/internal/config"
 	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/store/sqlstore"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
-type PostgresStore struct {
-	db     *sql.DB
-	Config *config.Config
-}
-
-func NewPostgresStore(cfg *config.Config) (*PostgresStore, error) {
-	// For pgx/stdlib, the driver name is "pgx"
-	db, err := sql.Open("pgx", cfg.Database.ConnectionString)
+// NewPostgresStore connects to the database named in cfg, bringing its schema
+// up to date, and to any read replicas listed.
+func NewPostgresStore(cfg *config.Config) (*sqlstore.Store, error) {
+	db, _, err := open(cfg.Database.ConnectionString, &cfg.Database)
 	if err != nil {
 		return nil, err
 	}
-	if err := db.Ping(); err != nil {
+	s, err := sqlstore.New(db, dialect{}, cfg)
+	if err != nil {
 		return nil, err
 	}
 
-	store := &PostgresStore{db: db, Config: cfg}
-	if err := store.InitDB(); err != nil {
-		return nil, err
+	var replicas []sqlstore.Replica
+	for i, connStr := range cfg.Database.Replicas.ConnectionStrings {
+		db, host, err := open(connStr, &cfg.Database)
+		if err != nil {
+			for _, r := range replicas {
+				r.DB.Close()
+			}
+			s.Close()
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		replicas = append(replicas, sqlstore.Replica{Name: host, DB: db})
+	}
+	if len(replicas) > 0 {
+		s.UseReplicas(replicas)
 	}
-	return store, nil
+	return s, nil
 }
 
-func (s *PostgresStore) InitDB() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS customers (
-			id TEXT PRIMARY KEY,
-			email TEXT UNIQUE,
-			password TEXT,
-			salt TEXT,
-			name TEXT,
-			credits INTEGER,
-			rank INTEGER,
-			role TEXT
-		);`,
-		`CREATE TABLE IF NOT EXISTS products (
-			id TEXT PRIMARY KEY,
-			name TEXT,
-			description TEXT,
-			image_url TEXT,
-			quantity INTEGER,
-			visible BOOLEAN
-		);`,
-		`CREATE TABLE IF NOT EXISTS activities (
-			id TEXT PRIMARY KEY,
-			name TEXT,
-			description TEXT,
-			image_url TEXT,
-			capacity INTEGER,
-			visible BOOLEAN
-		);`,
-		`CREATE TABLE IF NOT EXISTS reservations (
-			id TEXT PRIMARY KEY,
-			customer_id TEXT,
-			item_id TEXT,
-			type TEXT,
-			priority_rank INTEGER,
-			timestamp TIMESTAMP,
-			status TEXT
-		);`,
+// open returns a pool of connections to connStr, and the host:port it names.
+func open(connStr string, c *config.DatabaseConfig) (*sql.DB, string, error) {
+	connConfig, err := pgx.ParseConfig(connStr)
+	if err != nil {
+		return nil, "", err
+	}
+	// Session settings go in the startup message, so they hold for every
+	// pooled connection without a round trip.
+	pg := &c.Postgres
+	if pg.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(time.Duration(pg.StatementTimeout).Milliseconds(), 10)
 	}
+	if pg.ApplicationName != "" {
+		connConfig.RuntimeParams["application_name"] = pg.ApplicationName
+	}
+
+	db := stdlib.OpenDB(*connConfig)
+	store.ConfigurePool(db, c)
+	return db, net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port))), nil
+}
 
-	for _, q := range queries {
-		if _, err := s.db.Exec(q); err != nil {
-			return err
+// dialect adapts the shared queries to PostgreSQL.
+type dialect struct{}
+
+// Rebind numbers the placeholders $1, $2 and so on, leaving any ? inside a
+// string literal alone.
+func (dialect) Rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n, quoted := 0, false
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
 		}
+		b.WriteByte(query[i])
 	}
-	return nil
+	return b.String()
 }
 
-// CalculateRank Helper
-func (s *PostgresStore) calculateRank(credits int) models.Rank {
-	if credits <= s.Config.Ranks.BronzeMax {
-		return models.RankBronze
-	} else if credits <= s.Config.Ranks.SilverMax {
-		return models.RankSilver
-	} else {
-		return models.RankGold
+func (dialect) Bool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+func (dialect) Upsert(key []string, update ...string) string {
+	return sqlstore.OnConflict(key, update...)
+}
+
+func (dialect) ForUpdate() string { return " FOR UPDATE" }
+
+func (dialect) Period(period, column string) string {
+	switch period {
+	case models.PeriodWeek:
+		return "to_char(date_trunc('week', " + column + "), 'YYYY-MM-DD')"
+	case models.PeriodMonth:
+		return "to_char(date_trunc('month', " + column + "), 'YYYY-MM-DD')"
+	default:
+		return "to_char(" + column + ", 'YYYY-MM-DD')"
 	}
 }
+
+func (dialect) IsUniqueViolation(err error) bool {
+	var pe *pgconn.PgError
+	return errors.As(err, &pe) && pe.Code == "23505" // unique_violation
+}
+
+// ReplicaLag is how long ago a replica replayed the last transaction it has
+// received, or 0 once it has replayed everything: an idle primary sends
+// nothing new, which isn't lag.
+func (dialect) ReplicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds float64
+	err := db.QueryRowContext(ctx, `SELECT (CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END)::float8`).Scan(&seconds)
+	return time.Duration(seconds * float64(time.Second)), err
+}
+
+func (dialect) Schema() ([]string, [][]string) {
+	return setup, migrations
+}
+
+// setup creates the base schema, which migrations evolve, and the table
+// recording which migrations have run.
+var setup = []string{
+	`CREATE TABLE IF NOT EXISTS customers (
+		id TEXT PRIMARY KEY,
+		email TEXT UNIQUE,
+		password TEXT,
+		salt TEXT,
+		name TEXT,
+		credits INTEGER,
+		rank INTEGER,
+		role TEXT
+	);`,
+	`CREATE TABLE IF NOT EXISTS products (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		description TEXT,
+		image_url TEXT,
+		quantity INTEGER,
+		visible BOOLEAN
+	);`,
+	`CREATE TABLE IF NOT EXISTS activities (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		description TEXT,
+		image_url TEXT,
+		capacity INTEGER,
+		visible BOOLEAN
+	);`,
+	`CREATE TABLE IF NOT EXISTS reservations (
+		id TEXT PRIMARY KEY,
+		customer_id TEXT,
+		item_id TEXT,
+		type TEXT,
+		priority_rank INTEGER,
+		timestamp TIMESTAMP,
+		status TEXT
+	);`,
+	`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP
+	);`,
+}
+
+// migrations evolve the base schema above. Each entry runs once, in order,
+// inside its own transaction; its position is recorded in schema_migrations.
+// Append only: never edit or reorder an entry that has shipped.
+var migrations = [][]string{
+	// 1: pickup slots for product reservations
+	{
+		`ALTER TABLE reservations ADD COLUMN pickup_location_id TEXT`,
+		`ALTER TABLE reservations ADD COLUMN pickup_start TIMESTAMP`,
+		`ALTER TABLE reservations ADD COLUMN pickup_end TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_reservations_pickup ON reservations (pickup_start, pickup_location_id)`,
+	},
+	// 2: reservation status history
+	{
+		`CREATE TABLE IF NOT EXISTS reservation_transitions (
+			id BIGSERIAL PRIMARY KEY,
+			reservation_id TEXT,
+			from_status TEXT,
+			to_status TEXT,
+			actor_id TEXT,
+			at TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_reservation_transitions_reservation ON reservation_transitions (reservation_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_reservations_item_status ON reservations (item_id, status)`,
+	},
+	// 3: no-show tracking
+	{
+		`ALTER TABLE customers ADD COLUMN no_show_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE customers ADD COLUMN banned_until TIMESTAMP`,
+	},
+	// 4: transactional outbox and webhook delivery
+	{
+		`CREATE TABLE IF NOT EXISTS outbox_events (
+			id BIGSERIAL PRIMARY KEY,
+			type TEXT,
+			payload TEXT,
+			created_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS outbox_cursors (
+			consumer TEXT PRIMARY KEY,
+			last_id BIGINT
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			url TEXT,
+			secret TEXT,
+			events TEXT,
+			active BOOLEAN,
+			created_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id BIGSERIAL PRIMARY KEY,
+			webhook_id TEXT,
+			event_id BIGINT,
+			event_type TEXT,
+			payload TEXT,
+			status TEXT,
+			attempts INTEGER,
+			response_code INTEGER,
+			last_error TEXT,
+			next_attempt_at TIMESTAMP,
+			delivered_at TIMESTAMP,
+			created_at TIMESTAMP,
+			UNIQUE (webhook_id, event_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+	},
+	// 5: customer notifications
+	{
+		`ALTER TABLE customers ADD COLUMN notify_email BOOLEAN NOT NULL DEFAULT TRUE`,
+		`ALTER TABLE customers ADD COLUMN notify_in_app BOOLEAN NOT NULL DEFAULT TRUE`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id TEXT PRIMARY KEY,
+			customer_id TEXT,
+			event TEXT,
+			subject TEXT,
+			body TEXT,
+			created_at TIMESTAMP,
+			read_at TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_customer ON notifications (customer_id, created_at)`,
+	},
+	// 6: audit log
+	{
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			actor_id TEXT,
+			action TEXT,
+			target_type TEXT,
+			target_id TEXT,
+			before_state TEXT,
+			after_state TEXT,
+			request_id TEXT,
+			client_ip TEXT,
+			created_at TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log (target_type, target_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log (created_at)`,
+	},
+	// 7: soft deletion
+	{
+		`ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP`,
+		`ALTER TABLE activities ADD COLUMN deleted_at TIMESTAMP`,
+		`ALTER TABLE customers ADD COLUMN deleted_at TIMESTAMP`,
+	},
+	// 8: reservations reference products, activities and customers through
+	// foreign keys; rows pointing at missing items or customers are moved to
+	// reservations_orphaned instead of being dropped.
+	{
+		`CREATE TABLE reservations_orphaned AS SELECT * FROM reservations
+			WHERE customer_id IS NULL OR item_id IS NULL OR type NOT IN ('product', 'activity')
+				OR NOT EXISTS (SELECT 1 FROM customers WHERE customers.id = reservations.customer_id)
+				OR (type = 'product' AND NOT EXISTS (SELECT 1 FROM products WHERE products.id = reservations.item_id))
+				OR (type = 'activity' AND NOT EXISTS (SELECT 1 FROM activities WHERE activities.id = reservations.item_id))`,
+		`DELETE FROM reservations WHERE id IN (SELECT id FROM reservations_orphaned)`,
+		`ALTER TABLE reservations
+			ADD COLUMN product_id TEXT REFERENCES products (id) ON DELETE RESTRICT,
+			ADD COLUMN activity_id TEXT REFERENCES activities (id) ON DELETE RESTRICT`,
+		`UPDATE reservations SET product_id = item_id WHERE type = 'product'`,
+		`UPDATE reservations SET activity_id = item_id WHERE type = 'activity'`,
+		`ALTER TABLE reservations
+			DROP COLUMN item_id,
+			ALTER COLUMN customer_id SET NOT NULL,
+			ALTER COLUMN type SET NOT NULL,
+			ADD CONSTRAINT reservations_customer_fk FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE RESTRICT,
+			ADD CONSTRAINT reservations_item_check CHECK ((type = 'product' AND product_id IS NOT NULL AND activity_id IS NULL)
+				OR (type = 'activity' AND activity_id IS NOT NULL AND product_id IS NULL))`,
+		`CREATE INDEX IF NOT EXISTS idx_reservations_product_status ON reservations (product_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_reservations_activity_status ON reservations (activity_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_reservations_customer ON reservations (customer_id)`,
+	},
+	// 9: credit history and account erasure
+	{
+		`ALTER TABLE customers ADD COLUMN erase_after TIMESTAMP`,
+		`CREATE TABLE IF NOT EXISTS credit_history (
+			id BIGSERIAL PRIMARY KEY,
+			customer_id TEXT NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+			previous INTEGER,
+			credits INTEGER,
+			rank INTEGER,
+			reason TEXT,
+			created_at TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_credit_history_customer ON credit_history (customer_id)`,
+	},
+	// 10: row versions for optimistic concurrency
+	{
+		`ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE activities ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE customers ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	},
+	// 11: idempotency keys for retried requests
+	{
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			customer_id TEXT NOT NULL,
+			idempotency_key TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			status INTEGER NOT NULL DEFAULT 0,
+			headers TEXT,
+			body BYTEA,
+			created_at TIMESTAMP,
+			expires_at TIMESTAMP,
+			PRIMARY KEY (customer_id, idempotency_key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+	},
+	// 12: outbox consumer leases, so one instance reads each consumer's events
+	{
+		`ALTER TABLE outbox_cursors ADD COLUMN holder TEXT`,
+		`ALTER TABLE outbox_cursors ADD COLUMN lease_until TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_created ON outbox_events (created_at)`,
+	},
+	// 13: the caller's request ID, kept apart from the server's
+	{
+		`ALTER TABLE audit_log ADD COLUMN client_request_id TEXT`,
+	},
+	// 14: pickup slot capacities, locked while places in them are taken
+	{
+		`CREATE TABLE IF NOT EXISTS pickup_slots (
+			location_id TEXT NOT NULL,
+			start_at TIMESTAMP NOT NULL,
+			capacity INTEGER NOT NULL,
+			PRIMARY KEY (location_id, start_at)
+		)`,
+	},
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"database/sql"
+	"farm/internal/config"
+	"farm/internal/store"
+	"farm/internal/store/storetest"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// dsnEnv names the variable holding the DSN of a Postgres database the tests
+// may create schemas in. Without it the tests are skipped.
+const dsnEnv = "FARM_TEST_POSTGRES_DSN"
+
+func TestConformance(t *testing.T) {
+	admin, dsn := connect(t)
+	storetest.Run(t, func(t *testing.T, cfg *config.Config) store.Repository {
+		s, _ := openTest(t, admin, dsn, cfg)
+		return s
+	})
+}
+
+func TestInterleaved(t *testing.T) {
+	admin, dsn := connect(t)
+	storetest.RunInterleaved(t, func(t *testing.T, cfg *config.Config) (store.Repository, *sql.DB) {
+		return openTest(t, admin, dsn, cfg)
+	})
+}
+
+// connect returns a connection to the database named by dsnEnv, skipping t
+// without one.
+func connect(t *testing.T) (*sql.DB, string) {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", dsnEnv)
+	}
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	return admin, dsn
+}
+
+// openTest opens a store in a schema of its own, dropped after t, and a
+// second pool of connections to that schema.
+func openTest(t *testing.T, admin *sql.DB, dsn string, cfg *config.Config) (store.Repository, *sql.DB) {
+	schema := fmt.Sprintf("farm_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + pgx.Identifier{schema}.Sanitize()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + pgx.Identifier{schema}.Sanitize() + " CASCADE") })
+
+	cfg.Database = config.DatabaseConfig{
+		Driver:           "postgres",
+		ConnectionString: withSearchPath(dsn, schema),
+		MaxOpenConns:     4,
+		MaxIdleConns:     4,
+	}
+	s, err := NewPostgresStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	db, err := sql.Open("pgx", cfg.Database.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return s, db
+}
+
+// withSearchPath sets search_path in dsn, which may be a URL or key/value
+// pairs. pgx sends unknown parameters to the server as settings.
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+func TestOpenConfiguresPool(t *testing.T) {
+	c := &config.DatabaseConfig{MaxOpenConns: 7, MaxIdleConns: 3}
+	db, host, err := open("postgres://farm@db.internal:6432/farm?sslmode=disable", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if host != "db.internal:6432" {
+		t.Errorf("host = %s", host)
+	}
+	if n := db.Stats().MaxOpenConnections; n != 7 {
+		t.Errorf("max open connections = %d, want 7", n)
+	}
+	if _, _, err := open("postgres://farm@db.internal:port/farm", c); err == nil {
+		t.Error("open accepted an invalid connection string")
+	}
+}
@@ -0,0 +1,17 @@
+package store
+
+import (
+	"farm/internal/config"
+	"farm/internal/models"
+)
+
+// RankFor returns the rank a customer holding credits earns under c.
+func RankFor(c *config.RankConfig, credits int) models.Rank {
+	if credits <= c.BronzeMax {
+		return models.RankBronze
+	} else if credits <= c.SilverMax {
+		return models.RankSilver
+	} else {
+		return models.RankGold
+	}
+}
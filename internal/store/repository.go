@@ -1,30 +1,171 @@
 package store
 
-import "farm/internal/models"
+import (
+	"context"
+	"farm/internal/models"
+	"time"
+)
 
 type Repository interface {
-	AddCustomer(c *models.Customer) error
-	GetCustomer(id string) (*models.Customer, error)
-	GetCustomerByEmail(email string) (*models.Customer, error)
-	GetAllCustomers() ([]*models.Customer, error)
-	UpdateCustomerCredits(id string, credits int) (*models.Customer, error)
-	UpdateCustomerRole(id string, role string) (*models.Customer, error)
-	UpdateCustomerName(id string, name string) (*models.Customer, error)
-	AddProduct(p *models.Product) error
-	GetProduct(id string) (*models.Product, error)
-	GetAllProducts(visibleOnly bool) ([]*models.Product, error)
-	UpdateProduct(p *models.Product) error
-	AddActivity(a *models.Activity) error
-	GetActivity(id string) (*models.Activity, error)
-	GetAllActivities(visibleOnly bool) ([]*models.Activity, error)
-	UpdateActivity(a *models.Activity) error
-	AddReservation(r *models.Reservation) error
-	GetAllReservations() ([]*models.Reservation, error)
-	GetReservationsByCustomerID(customerID string) ([]*models.Reservation, error)
-	ReserveItem(r *models.Reservation) error
-
-	DeleteProduct(id string) error
-	DeleteActivity(id string) error
-	DeleteReservation(id string) error
-	DeleteCustomer(id string) error
+	// Ping checks that the database is reachable.
+	Ping(ctx context.Context) error
+	// MigrationStatus reports the schema version applied to the database and
+	// the latest version this build knows.
+	MigrationStatus(ctx context.Context) (applied, latest int, err error)
+
+	AddCustomer(ctx context.Context, c *models.Customer) error
+	GetCustomer(ctx context.Context, id string) (*models.Customer, error)
+	GetCustomerByEmail(ctx context.Context, email string) (*models.Customer, error)
+	GetAllCustomers(ctx context.Context) ([]*models.Customer, error)
+	// UpdateCustomerCredits and UpdateCustomerRole fail with ErrStale if
+	// version isn't zero and the customer has changed since that version.
+	UpdateCustomerCredits(ctx context.Context, id string, credits, version int) (*models.Customer, error)
+	UpdateCustomerRole(ctx context.Context, id string, role string, version int) (*models.Customer, error)
+	UpdateCustomerName(ctx context.Context, id string, name string) (*models.Customer, error)
+	UpdateCustomerPreferences(ctx context.Context, id string, notifyEmail, notifyInApp bool) (*models.Customer, error)
+	ClearCustomerBan(ctx context.Context, id string) (*models.Customer, error)
+	GetCreditHistory(ctx context.Context, customerID string) ([]*models.CreditChange, error)
+	// ScheduleErasure sets or, with a nil at, clears a pending account erasure.
+	ScheduleErasure(ctx context.Context, id string, at *time.Time) (*models.Customer, error)
+	GetCustomersDueForErasure(ctx context.Context, now time.Time) ([]*models.Customer, error)
+	// EraseCustomer cancels the customer's pending, waitlisted and confirmed
+	// reservations and replaces the customer with a pseudonymous placeholder,
+	// keeping their reservations, in one transaction. It returns the
+	// placeholder's ID.
+	EraseCustomer(ctx context.Context, id string) (string, error)
+	AddProduct(ctx context.Context, p *models.Product) error
+	GetProduct(ctx context.Context, id string) (*models.Product, error)
+	GetAllProducts(ctx context.Context, visibleOnly bool) ([]*models.Product, error)
+	// UpdateProduct replaces the product's fields. p.Version is the version
+	// the caller last read; see PatchProduct.
+	UpdateProduct(ctx context.Context, p *models.Product) error
+	// PatchProduct loads the product, lets patch modify it and saves the
+	// result in one transaction. An error from patch aborts the update and is
+	// returned unchanged. The ID, deletion time and version can't be patched.
+	// If version isn't zero and the product has changed since then, it fails
+	// with ErrStale without calling patch.
+	PatchProduct(ctx context.Context, id string, version int, patch func(*models.Product) error) (*models.Product, error)
+	// AdjustProductStock adds delta units to the product's stock, or removes
+	// them if delta is negative, without regard to its version. Added units go
+	// to waitlisted reservations first; removing more than are left fails
+	// with ErrOutOfStock.
+	AdjustProductStock(ctx context.Context, id string, delta int) (*models.Product, error)
+	AddActivity(ctx context.Context, a *models.Activity) error
+	GetActivity(ctx context.Context, id string) (*models.Activity, error)
+	GetAllActivities(ctx context.Context, visibleOnly bool) ([]*models.Activity, error)
+	UpdateActivity(ctx context.Context, a *models.Activity) error
+	// PatchActivity is PatchProduct for activities.
+	PatchActivity(ctx context.Context, id string, version int, patch func(*models.Activity) error) (*models.Activity, error)
+	// AdjustActivityCapacity is AdjustProductStock for the activity's
+	// remaining places; it fails with ErrFullyBooked.
+	AdjustActivityCapacity(ctx context.Context, id string, delta int) (*models.Activity, error)
+	AddReservation(ctx context.Context, r *models.Reservation) error
+	GetAllReservations(ctx context.Context) ([]*models.Reservation, error)
+	GetReservationsByCustomerID(ctx context.Context, customerID string) ([]*models.Reservation, error)
+	GetReservationsByPickupRange(ctx context.Context, from, to time.Time) ([]*models.Reservation, error)
+	CountPickupReservations(ctx context.Context, locationID string, start time.Time) (int, error)
+	GetReservation(ctx context.Context, id string) (*models.Reservation, error)
+	// ReserveItem confirms r and takes one unit of stock. If r.Status is
+	// StatusWaitlist on entry, an item with no stock left puts r on the
+	// waitlist instead of failing.
+	ReserveItem(ctx context.Context, r *models.Reservation) error
+	// TransitionReservation moves a reservation to another status. Leaving a
+	// confirmed reservation as a no-show or expired also counts a no-show
+	// against the customer.
+	TransitionReservation(ctx context.Context, id string, to models.ReservationStatus, actorID string) (*models.Reservation, error)
+	// GetExpiredReservations lists confirmed product reservations whose pickup
+	// window (or booking time, without one) ended before cutoff.
+	GetExpiredReservations(ctx context.Context, cutoff time.Time) ([]*models.Reservation, error)
+
+	// GetOutboxEvents returns events after consumer's cursor, oldest first.
+	// It and GetOutboxEventsAfter stop short of any event whose predecessor
+	// may still commit, so a cursor advanced past the last one never skips
+	// an event.
+	GetOutboxEvents(ctx context.Context, consumer string, limit int) ([]*models.Event, error)
+	GetOutboxEventsAfter(ctx context.Context, afterID int64, limit int) ([]*models.Event, error)
+	GetLatestOutboxEventID(ctx context.Context) (int64, error)
+	AdvanceOutboxCursor(ctx context.Context, consumer string, lastID int64) error
+	// LeaseOutboxConsumer makes holder the only reader of consumer's events
+	// until until, unless another holder's lease is still current at now. It
+	// reports whether holder has the lease; a holder can always renew its own.
+	LeaseOutboxConsumer(ctx context.Context, consumer, holder string, now, until time.Time) (bool, error)
+	// PurgeOutbox deletes events recorded before cutoff, and webhook
+	// deliveries created before it that are no longer pending.
+	PurgeOutbox(ctx context.Context, cutoff time.Time) (int64, error)
+
+	AddWebhook(ctx context.Context, w *models.Webhook) error
+	GetWebhook(ctx context.Context, id string) (*models.Webhook, error)
+	GetAllWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	// DeleteWebhook removes the webhook and its deliveries, or returns
+	// ErrNotFound.
+	DeleteWebhook(ctx context.Context, id string) error
+	AddWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error
+	GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	// ClaimWebhookDelivery takes a delivery due at now by putting its next
+	// attempt back to until, so others polling the queue pass over it. It
+	// reports whether the delivery was still due, and so is the caller's.
+	ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) (bool, error)
+	GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]*models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error
+
+	AddNotification(ctx context.Context, n *models.Notification) error
+	GetNotifications(ctx context.Context, customerID string, unreadOnly bool) ([]*models.Notification, error)
+	MarkNotificationRead(ctx context.Context, customerID, id string) error
+	MarkAllNotificationsRead(ctx context.Context, customerID string) error
+
+	// ClaimIdempotencyKey records that the request k describes is in
+	// progress. If the customer already used the key and it hasn't expired,
+	// nothing is written and the existing record is returned instead; a
+	// concurrent claim of the same key fails with ErrConflict.
+	ClaimIdempotencyKey(ctx context.Context, k *models.IdempotencyKey) (*models.IdempotencyKey, error)
+	// CompleteIdempotencyKey stores the response to a claimed request.
+	CompleteIdempotencyKey(ctx context.Context, k *models.IdempotencyKey) error
+	// ReleaseIdempotencyKey drops a claim that never completed so the
+	// request can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, customerID, key string) error
+	// PurgeIdempotencyKeys deletes keys that expired before now.
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+
+	// WithAudit returns a view of the repository whose mutations also record
+	// e, with before/after snapshots, in the audit log in the same transaction.
+	WithAudit(e *models.AuditEntry) Repository
+	GetAuditEntries(ctx context.Context, f models.AuditFilter) ([]*models.AuditEntry, error)
+
+	// DeleteProduct, DeleteActivity and DeleteCustomer soft-delete: the row
+	// drops out of GetAll* and, for customers, GetCustomer*, but products and
+	// activities stay readable by ID for reservation history. They return
+	// ErrNotFound, recording nothing, for rows that are missing or already
+	// deleted. DeleteReservation removes the reservation outright and returns
+	// ErrNotFound if there is none.
+	DeleteProduct(ctx context.Context, id string) error
+	DeleteActivity(ctx context.Context, id string) error
+	DeleteReservation(ctx context.Context, id string) error
+	DeleteCustomer(ctx context.Context, id string) error
+	GetDeletedProducts(ctx context.Context) ([]*models.Product, error)
+	GetDeletedActivities(ctx context.Context) ([]*models.Activity, error)
+	GetDeletedCustomers(ctx context.Context) ([]*models.Customer, error)
+	RestoreProduct(ctx context.Context, id string) (*models.Product, error)
+	RestoreActivity(ctx context.Context, id string) (*models.Activity, error)
+	RestoreCustomer(ctx context.Context, id string) (*models.Customer, error)
+	// PurgeDeleted hard-deletes rows soft-deleted before cutoff.
+	PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// Reports aggregate reservations made within the filter's range.
+	GetReservationCounts(ctx context.Context, f models.ReportFilter) ([]*models.ReservationCount, error)
+	GetActivityFill(ctx context.Context, f models.ReportFilter) ([]*models.ActivityFill, error)
+	GetProductSellThrough(ctx context.Context, f models.ReportFilter) ([]*models.ProductSellThrough, error)
+	GetRankDistribution(ctx context.Context) ([]*models.RankCount, error)
+	GetNoShowRates(ctx context.Context, f models.ReportFilter) ([]*models.NoShowRate, error)
+	GetTopCustomers(ctx context.Context, f models.ReportFilter) ([]*models.TopCustomer, error)
+
+	// ImportProducts, ImportActivities and ImportCustomers upsert by ID in one
+	// transaction, committing only if every row succeeds and dryRun is false.
+	ImportProducts(ctx context.Context, products []*models.Product, dryRun bool) (*models.ImportResult, error)
+	ImportActivities(ctx context.Context, activities []*models.Activity, dryRun bool) (*models.ImportResult, error)
+	ImportCustomers(ctx context.Context, customers []*models.Customer, dryRun bool) (*models.ImportResult, error)
+	// EachProduct, EachActivity and EachCustomer stream rows that aren't
+	// deleted to fn, stopping at the first error it returns.
+	EachProduct(ctx context.Context, fn func(*models.Product) error) error
+	EachActivity(ctx context.Context, fn func(*models.Activity) error) error
+	EachCustomer(ctx context.Context, fn func(*models.Customer) error) error
 }
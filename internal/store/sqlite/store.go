@@ -2,87 +2,355 @@ package sqlite
 
 import (
 	"database/sql"
+	"errors"
 	"farm/internal/config"
 	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/store/sqlstore"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
-	_ "modernc.org/sqlite" // Pure Go SQLite driver
+	"modernc.org/sqlite" // Pure Go SQLite driver
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
-type SQLiteStore struct {
-	db     *sql.DB
-	Config *config.Config
-}
-
-func NewSQLiteStore(cfg *config.Config) (*SQLiteStore, error) {
-	db, err := sql.Open(cfg.Database.Driver, cfg.Database.ConnectionString)
+// NewSQLiteStore opens the SQLite database named in cfg, bringing its schema
+// up to date.
+func NewSQLiteStore(cfg *config.Config) (*sqlstore.Store, error) {
+	connStr, err := dsn(cfg.Database.ConnectionString, &cfg.Database.SQLite)
 	if err != nil {
 		return nil, err
 	}
-	if err := db.Ping(); err != nil {
+	db, err := sql.Open(cfg.Database.Driver, connStr)
+	if err != nil {
 		return nil, err
 	}
+	store.ConfigurePool(db, &cfg.Database)
+	return sqlstore.New(db, dialect{}, cfg)
+}
 
-	store := &SQLiteStore{db: db, Config: cfg}
-	if err := store.InitDB(); err != nil {
-		return nil, err
+// dialect adapts the shared queries to SQLite.
+type dialect struct{}
+
+func (dialect) Rebind(query string) string { return query }
+
+// Bool returns 1 or 0: SQLite stores booleans as integers.
+func (dialect) Bool(b bool) string {
+	if b {
+		return "1"
 	}
-	return store, nil
+	return "0"
 }
 
-func (s *SQLiteStore) InitDB() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS customers (
-			id TEXT PRIMARY KEY,
-			email TEXT UNIQUE,
-			password TEXT,
-			salt TEXT,
-			name TEXT,
-			credits INTEGER,
-			rank INTEGER,
-			role TEXT
-		);`,
-		`CREATE TABLE IF NOT EXISTS products (
-			id TEXT PRIMARY KEY,
-			name TEXT,
-			description TEXT,
-			image_url TEXT,
-			quantity INTEGER,
-			visible BOOLEAN
-		);`,
-		`CREATE TABLE IF NOT EXISTS activities (
-			id TEXT PRIMARY KEY,
-			name TEXT,
-			description TEXT,
-			image_url TEXT,
-			capacity INTEGER,
-			visible BOOLEAN
-		);`,
-		`CREATE TABLE IF NOT EXISTS reservations (
-			id TEXT PRIMARY KEY,
-			customer_id TEXT,
-			item_id TEXT,
-			type TEXT,
-			priority_rank INTEGER,
-			timestamp DATETIME,
-			status TEXT
-		);`,
+func (dialect) Upsert(key []string, update ...string) string {
+	return sqlstore.OnConflict(key, update...)
+}
+
+// ForUpdate returns "": transactions begin immediate (see dsn), so they hold
+// the database's write lock throughout.
+func (dialect) ForUpdate() string { return "" }
+
+// Period reads the date from the start of column: timestamps are stored as
+// text starting with the local date.
+func (dialect) Period(period, column string) string {
+	switch period {
+	case models.PeriodWeek:
+		return "date(substr(" + column + ", 1, 10), 'weekday 0', '-6 days')"
+	case models.PeriodMonth:
+		return "substr(" + column + ", 1, 7) || '-01'"
+	default:
+		return "substr(" + column + ", 1, 10)"
 	}
+}
+
+func (dialect) IsUniqueViolation(err error) bool {
+	var se *sqlite.Error
+	return errors.As(err, &se) && (se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || se.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
+
+func (dialect) Schema() ([]string, [][]string) {
+	return setup, migrations
+}
 
-	for _, q := range queries {
-		if _, err := s.db.Exec(q); err != nil {
-			return err
+var (
+	journalModes = []string{"delete", "truncate", "persist", "memory", "wal", "off"}
+	syncModes    = []string{"off", "normal", "full", "extra"}
+)
+
+// dsn adds the connection pragmas every pooled connection needs. Foreign keys
+// are off by default in SQLite and enabled per connection. Transactions take
+// the write lock when they begin, so one that reads and then writes waits out
+// busy_timeout behind another writer instead of failing with SQLITE_BUSY.
+func dsn(connStr string, c *config.SQLiteConfig) (string, error) {
+	pragmas := []string{"foreign_keys(1)"}
+	if c.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("busy_timeout(%d)", time.Duration(c.BusyTimeout).Milliseconds()))
+	}
+	if c.JournalMode != "" {
+		mode := strings.ToLower(c.JournalMode)
+		if !slices.Contains(journalModes, mode) {
+			return "", fmt.Errorf("unknown sqlite journal_mode %q", c.JournalMode)
 		}
+		pragmas = append(pragmas, "journal_mode("+mode+")")
+	}
+	if c.Synchronous != "" {
+		mode := strings.ToLower(c.Synchronous)
+		if !slices.Contains(syncModes, mode) {
+			return "", fmt.Errorf("unknown sqlite synchronous %q", c.Synchronous)
+		}
+		pragmas = append(pragmas, "synchronous("+mode+")")
 	}
-	return nil
-}
 
-// CalculateRank Helper
-func (s *SQLiteStore) calculateRank(credits int) models.Rank {
-	if credits <= s.Config.Ranks.BronzeMax {
-		return models.RankBronze
-	} else if credits <= s.Config.Ranks.SilverMax {
-		return models.RankSilver
-	} else {
-		return models.RankGold
+	q := url.Values{"_pragma": pragmas, "_txlock": {"immediate"}}
+	sep := "?"
+	if strings.Contains(connStr, "?") {
+		sep = "&"
 	}
+	return connStr + sep + q.Encode(), nil
+}
+
+// setup creates the base schema, which migrations evolve, and the table
+// recording which migrations have run.
+var setup = []string{
+	`CREATE TABLE IF NOT EXISTS customers (
+		id TEXT PRIMARY KEY,
+		email TEXT UNIQUE,
+		password TEXT,
+		salt TEXT,
+		name TEXT,
+		credits INTEGER,
+		rank INTEGER,
+		role TEXT
+	);`,
+	`CREATE TABLE IF NOT EXISTS products (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		description TEXT,
+		image_url TEXT,
+		quantity INTEGER,
+		visible BOOLEAN
+	);`,
+	`CREATE TABLE IF NOT EXISTS activities (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		description TEXT,
+		image_url TEXT,
+		capacity INTEGER,
+		visible BOOLEAN
+	);`,
+	`CREATE TABLE IF NOT EXISTS reservations (
+		id TEXT PRIMARY KEY,
+		customer_id TEXT,
+		item_id TEXT,
+		type TEXT,
+		priority_rank INTEGER,
+		timestamp DATETIME,
+		status TEXT
+	);`,
+	`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME
+	);`,
+}
+
+// migrations evolve the base schema above. Each entry runs once, in order,
+// inside its own transaction; its position is recorded in schema_migrations.
+// Append only: never edit or reorder an entry that has shipped.
+var migrations = [][]string{
+	// 1: pickup slots for product reservations
+	{
+		`ALTER TABLE reservations ADD COLUMN pickup_location_id TEXT`,
+		`ALTER TABLE reservations ADD COLUMN pickup_start DATETIME`,
+		`ALTER TABLE reservations ADD COLUMN pickup_end DATETIME`,
+		`CREATE INDEX IF NOT EXISTS idx_reservations_pickup ON reservations (pickup_start, pickup_location_id)`,
+	},
+	// 2: reservation status history
+	{
+		`CREATE TABLE IF NOT EXISTS reservation_transitions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			reservation_id TEXT,
+			from_status TEXT,
+			to_status TEXT,
+			actor_id TEXT,
+			at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_reservation_transitions_reservation ON reservation_transitions (reservation_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_reservations_item_status ON reservations (item_id, status)`,
+	},
+	// 3: no-show tracking
+	{
+		`ALTER TABLE customers ADD COLUMN no_show_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE customers ADD COLUMN banned_until DATETIME`,
+	},
+	// 4: transactional outbox and webhook delivery
+	{
+		`CREATE TABLE IF NOT EXISTS outbox_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT,
+			payload TEXT,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS outbox_cursors (
+			consumer TEXT PRIMARY KEY,
+			last_id BIGINT
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			url TEXT,
+			secret TEXT,
+			events TEXT,
+			active BOOLEAN,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id TEXT,
+			event_id BIGINT,
+			event_type TEXT,
+			payload TEXT,
+			status TEXT,
+			attempts INTEGER,
+			response_code INTEGER,
+			last_error TEXT,
+			next_attempt_at DATETIME,
+			delivered_at DATETIME,
+			created_at DATETIME,
+			UNIQUE (webhook_id, event_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+	},
+	// 5: customer notifications
+	{
+		`ALTER TABLE customers ADD COLUMN notify_email BOOLEAN NOT NULL DEFAULT 1`,
+		`ALTER TABLE customers ADD COLUMN notify_in_app BOOLEAN NOT NULL DEFAULT 1`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id TEXT PRIMARY KEY,
+			customer_id TEXT,
+			event TEXT,
+			subject TEXT,
+			body TEXT,
+			created_at DATETIME,
+			read_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_customer ON notifications (customer_id, created_at)`,
+	},
+	// 6: audit log
+	{
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor_id TEXT,
+			action TEXT,
+			target_type TEXT,
+			target_id TEXT,
+			before_state TEXT,
+			after_state TEXT,
+			request_id TEXT,
+			client_ip TEXT,
+			created_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log (target_type, target_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log (created_at)`,
+	},
+	// 7: soft deletion
+	{
+		`ALTER TABLE products ADD COLUMN deleted_at DATETIME`,
+		`ALTER TABLE activities ADD COLUMN deleted_at DATETIME`,
+		`ALTER TABLE customers ADD COLUMN deleted_at DATETIME`,
+	},
+	// 8: reservations reference products, activities and customers through
+	// foreign keys. SQLite can't add constraints in place, so the table is
+	// rebuilt; rows pointing at missing items or customers are moved to
+	// reservations_orphaned instead of being dropped.
+	{
+		`CREATE TABLE reservations_orphaned AS SELECT * FROM reservations
+			WHERE customer_id IS NULL OR item_id IS NULL OR type NOT IN ('product', 'activity')
+				OR NOT EXISTS (SELECT 1 FROM customers WHERE customers.id = reservations.customer_id)
+				OR (type = 'product' AND NOT EXISTS (SELECT 1 FROM products WHERE products.id = reservations.item_id))
+				OR (type = 'activity' AND NOT EXISTS (SELECT 1 FROM activities WHERE activities.id = reservations.item_id))`,
+		`CREATE TABLE reservations_new (
+			id TEXT PRIMARY KEY,
+			customer_id TEXT NOT NULL REFERENCES customers (id) ON DELETE RESTRICT,
+			product_id TEXT REFERENCES products (id) ON DELETE RESTRICT,
+			activity_id TEXT REFERENCES activities (id) ON DELETE RESTRICT,
+			type TEXT NOT NULL,
+			priority_rank INTEGER,
+			timestamp DATETIME,
+			status TEXT,
+			pickup_location_id TEXT,
+			pickup_start DATETIME,
+			pickup_end DATETIME,
+			CHECK ((type = 'product' AND product_id IS NOT NULL AND activity_id IS NULL)
+				OR (type = 'activity' AND activity_id IS NOT NULL AND product_id IS NULL))
+		)`,
+		`INSERT INTO reservations_new
+			SELECT id, customer_id,
+				CASE WHEN type = 'product' THEN item_id END,
+				CASE WHEN type = 'activity' THEN item_id END,
+				type, priority_rank, timestamp, status, pickup_location_id, pickup_start, pickup_end
+			FROM reservations WHERE id NOT IN (SELECT id FROM reservations_orphaned)`,
+		`DROP TABLE reservations`,
+		`ALTER TABLE reservations_new RENAME TO reservations`,
+		`CREATE INDEX idx_reservations_pickup ON reservations (pickup_start, pickup_location_id)`,
+		`CREATE INDEX idx_reservations_product_status ON reservations (product_id, status)`,
+		`CREATE INDEX idx_reservations_activity_status ON reservations (activity_id, status)`,
+		`CREATE INDEX idx_reservations_customer ON reservations (customer_id)`,
+	},
+	// 9: credit history and account erasure
+	{
+		`ALTER TABLE customers ADD COLUMN erase_after DATETIME`,
+		`CREATE TABLE IF NOT EXISTS credit_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id TEXT NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+			previous INTEGER,
+			credits INTEGER,
+			rank INTEGER,
+			reason TEXT,
+			created_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_credit_history_customer ON credit_history (customer_id)`,
+	},
+	// 10: row versions for optimistic concurrency
+	{
+		`ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE activities ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE customers ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	},
+	// 11: idempotency keys for retried requests
+	{
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			customer_id TEXT NOT NULL,
+			idempotency_key TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			status INTEGER NOT NULL DEFAULT 0,
+			headers TEXT,
+			body BLOB,
+			created_at DATETIME,
+			expires_at DATETIME,
+			PRIMARY KEY (customer_id, idempotency_key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+	},
+	// 12: outbox consumer leases, so one instance reads each consumer's events
+	{
+		`ALTER TABLE outbox_cursors ADD COLUMN holder TEXT`,
+		`ALTER TABLE outbox_cursors ADD COLUMN lease_until DATETIME`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_created ON outbox_events (created_at)`,
+	},
+	// 13: the caller's request ID, kept apart from the server's
+	{
+		`ALTER TABLE audit_log ADD COLUMN client_request_id TEXT`,
+	},
+	// 14: pickup slot capacities, locked while places in them are taken
+	{
+		`CREATE TABLE IF NOT EXISTS pickup_slots (
+			location_id TEXT NOT NULL,
+			start_at DATETIME NOT NULL,
+			capacity INTEGER NOT NULL,
+			PRIMARY KEY (location_id, start_at)
+		)`,
+	},
 }
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"database/sql"
+	"farm/internal/config"
+	"farm/internal/store"
+	"farm/internal/store/storetest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, cfg *config.Config) store.Repository {
+		cfg.Database = config.DatabaseConfig{
+			Driver:           "sqlite",
+			ConnectionString: filepath.Join(t.TempDir(), "farm.db"),
+			MaxOpenConns:     4,
+			MaxIdleConns:     4,
+			SQLite: config.SQLiteConfig{
+				JournalMode: "wal",
+				BusyTimeout: config.Duration(5 * time.Second),
+				Synchronous: "normal",
+			},
+		}
+		s, err := NewSQLiteStore(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestForeignKeys(t *testing.T) {
+	cfg := &config.Config{Database: config.DatabaseConfig{Driver: "sqlite", ConnectionString: filepath.Join(t.TempDir(), "farm.db")}}
+	s, err := NewSQLiteStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	connStr, err := dsn(cfg.Database.ConnectionString, &cfg.Database.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // Every statement sees the connection's pragmas
+
+	for _, q := range []string{
+		"INSERT INTO customers (id, email, name, role) VALUES ('alice', 'alice@example.com', 'Alice', 'customer')",
+		"INSERT INTO products (id, name, quantity) VALUES ('eggs', 'Eggs', 1)",
+		"INSERT INTO reservations (id, customer_id, product_id, type) VALUES ('r1', 'alice', 'eggs', 'product')",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name, query string
+	}{
+		{"unknown customer", "INSERT INTO reservations (id, customer_id, product_id, type) VALUES ('r2', 'bob', 'eggs', 'product')"},
+		{"unknown product", "INSERT INTO reservations (id, customer_id, product_id, type) VALUES ('r2', 'alice', 'milk', 'product')"},
+		{"referenced product", "DELETE FROM products WHERE id = 'eggs'"},
+		{"referenced customer", "DELETE FROM customers WHERE id = 'alice'"},
+	}
+	for _, tt := range tests {
+		if _, err := db.Exec(tt.query); err == nil || !strings.Contains(err.Error(), "FOREIGN KEY") {
+			t.Errorf("%s: err = %v, want a foreign key violation", tt.name, err)
+		}
+	}
+}
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		connStr string
+		cfg     config.SQLiteConfig
+		want    string
+	}{
+		{"farm.db", config.SQLiteConfig{}, "farm.db?_pragma=foreign_keys%281%29&_txlock=immediate"},
+		{
+			"file:farm.db?cache=shared",
+			config.SQLiteConfig{JournalMode: "WAL", BusyTimeout: config.Duration(5 * time.Second), Synchronous: "normal"},
+			"file:farm.db?cache=shared&_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&_pragma=journal_mode%28wal%29&_pragma=synchronous%28normal%29&_txlock=immediate",
+		},
+	}
+	for _, tt := range tests {
+		got, err := dsn(tt.connStr, &tt.cfg)
+		if err != nil || got != tt.want {
+			t.Errorf("dsn(%s, %+v) = %s, %v; want %s", tt.connStr, tt.cfg, got, err, tt.want)
+		}
+	}
+	for _, cfg := range []config.SQLiteConfig{{JournalMode: "fast"}, {Synchronous: "sometimes"}} {
+		if _, err := dsn("farm.db", &cfg); err == nil {
+			t.Errorf("dsn accepted %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"farm/internal/models"
+	"farm/internal/store"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Audit Implementation
+
+// WithAudit returns a view of the store that appends e to the audit log, with
+// before/after snapshots of the target, in the same transaction as each
+// mutation made through it.
+func (s *Store) WithAudit(e *models.AuditEntry) store.Repository {
+	view := *s
+	view.audit = e
+	return &view
+}
+
+// recordAudit writes the view's pending audit entry, if any, within tx. A nil
+// before or after is stored as NULL.
+func (s *Store) recordAudit(ctx context.Context, tx *txn, targetID string, before, after any) error {
+	if s.audit == nil {
+		return nil
+	}
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+	e := *s.audit // Imports record many targets through one view
+	if e.TargetID == "" {
+		e.TargetID = targetID
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO audit_log (actor_id, action, target_type, target_id, before_state, after_state, request_id, client_request_id, client_ip, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		e.ActorID, e.Action, e.TargetType, e.TargetID, beforeJSON, afterJSON, e.RequestID, e.ClientRequestID, e.ClientIP, time.Now())
+	return err
+}
+
+// snapshot serialises a target for the audit log, leaving out secrets.
+func snapshot(v any) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return sql.NullString{}, nil
+	}
+	if w, ok := v.(*models.Webhook); ok {
+		redacted := *w
+		redacted.Secret = ""
+		v = &redacted
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+func (s *Store) GetAuditEntries(ctx context.Context, f models.AuditFilter) ([]*models.AuditEntry, error) {
+	to := f.To
+	if to.IsZero() {
+		to = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	beforeID := f.BeforeID
+	if beforeID <= 0 {
+		beforeID = math.MaxInt64
+	}
+	rows, err := s.reader(ctx).QueryContext(ctx, `SELECT id, actor_id, action, target_type, target_id, before_state, after_state, request_id, client_request_id, client_ip, created_at
+		FROM audit_log
+		WHERE (? = '' OR actor_id = ?) AND (? = '' OR action = ?) AND (? = '' OR target_type = ?) AND (? = '' OR target_id = ?)
+			AND created_at >= ? AND created_at < ? AND id < ?
+		ORDER BY id DESC LIMIT ?`,
+		f.ActorID, f.ActorID, f.Action, f.Action, f.TargetType, f.TargetType, f.TargetID, f.TargetID,
+		f.From, to, beforeID, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after, clientRequestID sql.NullString
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &before, &after,
+			&e.RequestID, &clientRequestID, &e.ClientIP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.ClientRequestID = clientRequestID.String
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,254 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"farm/internal/models"
+	"farm/internal/store"
+	"time"
+)
+
+// Bulk Import Implementation
+
+// ImportProducts upserts products by ID in a single transaction. Rows naming a
+// deleted product, or failing store.CheckImport, are rejected; if any row is,
+// or on a dry run, nothing is committed. Added stock goes to waitlisted
+// reservations first.
+func (s *Store) ImportProducts(ctx context.Context, products []*models.Product, dryRun bool) (*models.ImportResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(products), Errors: []*models.ImportRowError{}}
+	for i, p := range products {
+		old, err := scanProduct(tx.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ?"+tx.forUpdate(), p.ID))
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			old = nil
+			_, err = tx.ExecContext(ctx, "INSERT INTO products (id, name, description, image_url, quantity, visible) VALUES (?, ?, ?, ?, ?, ?)",
+				p.ID, p.Name, p.Description, p.ImageURL, p.Quantity, p.Visible)
+			result.Created++
+		case err != nil:
+			return nil, err
+		case old.DeletedAt != nil:
+			result.Errors = append(result.Errors, &models.ImportRowError{Row: i + 1, Field: "id", Message: "product is deleted; restore it first"})
+			continue
+		default:
+			if rowErr := store.CheckImport(i+1, "quantity", old.Version, p.Version, p.Quantity != old.Quantity); rowErr != nil {
+				result.Errors = append(result.Errors, rowErr)
+				continue
+			}
+			if p.Quantity, err = setUnits(ctx, tx, models.ReservationProduct, p.ID, old.Quantity, p.Quantity, time.Now()); err != nil {
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, name = ?, description = ?, image_url = ?, quantity = ?, visible = ? WHERE id = ?",
+				p.Name, p.Description, p.ImageURL, p.Quantity, p.Visible, p.ID)
+			result.Updated++
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := enqueueInventory(ctx, tx, models.ReservationProduct, p.ID); err != nil {
+			return nil, err
+		}
+		if old != nil && old.Quantity > 0 && p.Quantity <= 0 {
+			if err := enqueueOutOfStock(ctx, tx, p.ID); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.recordAudit(ctx, tx, p.ID, old, p); err != nil {
+			return nil, err
+		}
+	}
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+	return result, tx.Commit()
+}
+
+// ImportActivities upserts activities by ID; see ImportProducts.
+func (s *Store) ImportActivities(ctx context.Context, activities []*models.Activity, dryRun bool) (*models.ImportResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(activities), Errors: []*models.ImportRowError{}}
+	for i, a := range activities {
+		old, err := scanActivity(tx.QueryRowContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE id = ?"+tx.forUpdate(), a.ID))
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			old = nil
+			_, err = tx.ExecContext(ctx, "INSERT INTO activities (id, name, description, image_url, capacity, visible) VALUES (?, ?, ?, ?, ?, ?)",
+				a.ID, a.Name, a.Description, a.ImageURL, a.Capacity, a.Visible)
+			result.Created++
+		case err != nil:
+			return nil, err
+		case old.DeletedAt != nil:
+			result.Errors = append(result.Errors, &models.ImportRowError{Row: i + 1, Field: "id", Message: "activity is deleted; restore it first"})
+			continue
+		default:
+			if rowErr := store.CheckImport(i+1, "capacity", old.Version, a.Version, a.Capacity != old.Capacity); rowErr != nil {
+				result.Errors = append(result.Errors, rowErr)
+				continue
+			}
+			if a.Capacity, err = setUnits(ctx, tx, models.ReservationActivity, a.ID, old.Capacity, a.Capacity, time.Now()); err != nil {
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, "UPDATE activities SET version = version + 1, name = ?, description = ?, image_url = ?, capacity = ?, visible = ? WHERE id = ?",
+				a.Name, a.Description, a.ImageURL, a.Capacity, a.Visible, a.ID)
+			result.Updated++
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := enqueueInventory(ctx, tx, models.ReservationActivity, a.ID); err != nil {
+			return nil, err
+		}
+		if err := s.recordAudit(ctx, tx, a.ID, old, a); err != nil {
+			return nil, err
+		}
+	}
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+	return result, tx.Commit()
+}
+
+// ImportCustomers upserts customers by ID; see ImportProducts. An empty
+// Password leaves an existing customer's password unchanged. Rows whose email
+// belongs to another customer are rejected, and credit changes are recorded
+// in the credit history.
+func (s *Store) ImportCustomers(ctx context.Context, customers []*models.Customer, dryRun bool) (*models.ImportResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(customers), Errors: []*models.ImportRowError{}}
+	for i, c := range customers {
+		var other string
+		err := tx.QueryRowContext(ctx, "SELECT id FROM customers WHERE email = ? AND id <> ?", c.Email, c.ID).Scan(&other)
+		if err == nil {
+			result.Errors = append(result.Errors, &models.ImportRowError{Row: i + 1, Field: "email", Message: "email already in use"})
+			continue
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		c.Rank = store.RankFor(&s.Config.Ranks, c.Credits)
+		previous := 0
+		old, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ?"+tx.forUpdate(), c.ID))
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			old = nil
+			_, err = tx.ExecContext(ctx, `INSERT INTO customers (id, email, password, salt, name, credits, "rank", role, notify_email, notify_in_app) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				c.ID, c.Email, c.Password, c.Salt, c.Name, c.Credits, c.Rank, c.Role, c.NotifyEmail, c.NotifyInApp)
+			result.Created++
+		case err != nil:
+			return nil, err
+		case old.DeletedAt != nil:
+			result.Errors = append(result.Errors, &models.ImportRowError{Row: i + 1, Field: "id", Message: "customer is deleted; restore it first"})
+			continue
+		default:
+			previous = old.Credits
+			_, err = tx.ExecContext(ctx, `UPDATE customers SET version = version + 1, email = ?, name = ?, credits = ?, "rank" = ?, role = ?, notify_email = ?, notify_in_app = ? WHERE id = ?`,
+				c.Email, c.Name, c.Credits, c.Rank, c.Role, c.NotifyEmail, c.NotifyInApp, c.ID)
+			if err == nil && c.Password != "" {
+				_, err = tx.ExecContext(ctx, "UPDATE customers SET password = ?, salt = ? WHERE id = ?", c.Password, c.Salt, c.ID)
+			}
+			result.Updated++
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.Credits != previous {
+			err := recordCreditChange(ctx, tx, &models.CreditChange{
+				CustomerID: c.ID, Previous: previous, Credits: c.Credits, Rank: c.Rank, Reason: models.CreditReasonImport, At: now,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		after, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ?"+tx.forUpdate(), c.ID))
+		if err != nil {
+			return nil, err
+		}
+		if err := s.recordAudit(ctx, tx, c.ID, old, after); err != nil {
+			return nil, err
+		}
+	}
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+	return result, tx.Commit()
+}
+
+// Bulk Export Implementation
+
+// EachProduct calls fn for every product that isn't deleted, ordered by name,
+// without loading them all into memory.
+func (s *Store) EachProduct(ctx context.Context, fn func(*models.Product) error) error {
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT "+productColumns+" FROM products WHERE deleted_at IS NULL ORDER BY name, id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EachActivity calls fn for every activity that isn't deleted; see EachProduct.
+func (s *Store) EachActivity(ctx context.Context, fn func(*models.Activity) error) error {
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE deleted_at IS NULL ORDER BY name, id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanActivity(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EachCustomer calls fn for every customer that isn't deleted; see EachProduct.
+func (s *Store) EachCustomer(ctx context.Context, fn func(*models.Customer) error) error {
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE deleted_at IS NULL ORDER BY email")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,419 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Customer Implementation
+
+// customerColumns quotes rank, which MySQL reserves; queries naming it must too.
+const customerColumns = `id, email, password, salt, name, credits, "rank", role, no_show_count, banned_until, notify_email, notify_in_app, deleted_at, erase_after, version`
+
+func scanCustomer(row rowScanner) (*models.Customer, error) {
+	var c models.Customer
+	var bannedUntil, deletedAt, eraseAfter sql.NullTime
+	if err := row.Scan(&c.ID, &c.Email, &c.Password, &c.Salt, &c.Name, &c.Credits, &c.Rank, &c.Role,
+		&c.NoShowCount, &bannedUntil, &c.NotifyEmail, &c.NotifyInApp, &deletedAt, &eraseAfter, &c.Version); err != nil {
+		return nil, notFound(err, "customer")
+	}
+	if bannedUntil.Valid {
+		c.BannedUntil = &bannedUntil.Time
+	}
+	if deletedAt.Valid {
+		c.DeletedAt = &deletedAt.Time
+	}
+	if eraseAfter.Valid {
+		c.EraseAfter = &eraseAfter.Time
+	}
+	return &c, nil
+}
+
+func (s *Store) AddCustomer(ctx context.Context, c *models.Customer) error {
+	c.Rank = store.RankFor(&s.Config.Ranks, c.Credits) // Ensure rank is set correctly on creation
+	c.Version = 1
+	_, err := s.db.ExecContext(ctx, `INSERT INTO customers (id, email, password, salt, name, credits, "rank", role, notify_email, notify_in_app) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID, c.Email, c.Password, c.Salt, c.Name, c.Credits, c.Rank, c.Role, c.NotifyEmail, c.NotifyInApp)
+	return s.conflict(err, "customer")
+}
+
+func (s *Store) GetCustomer(ctx context.Context, id string) (*models.Customer, error) {
+	return scanCustomer(s.db.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ? AND deleted_at IS NULL", id))
+}
+
+func (s *Store) GetCustomerByEmail(ctx context.Context, email string) (*models.Customer, error) {
+	return scanCustomer(s.db.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE email = ? AND deleted_at IS NULL", email))
+}
+
+func (s *Store) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	return s.queryCustomers(ctx, s.reader(ctx), "SELECT "+customerColumns+" FROM customers WHERE deleted_at IS NULL")
+}
+
+func (s *Store) GetDeletedCustomers(ctx context.Context) ([]*models.Customer, error) {
+	return s.queryCustomers(ctx, s.reader(ctx), "SELECT "+customerColumns+" FROM customers WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+}
+
+func (s *Store) queryCustomers(ctx context.Context, db querier, query string, args ...any) ([]*models.Customer, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []*models.Customer
+	for rows.Next() {
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+func (s *Store) UpdateCustomerCredits(ctx context.Context, id string, credits, version int) (*models.Customer, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CheckVersion("customer", before.Version, version); err != nil {
+		return nil, err
+	}
+	rank := store.RankFor(&s.Config.Ranks, credits)
+	if _, err := tx.ExecContext(ctx, `UPDATE customers SET version = version + 1, credits = ?, "rank" = ? WHERE id = ?`, credits, rank, id); err != nil {
+		return nil, err
+	}
+	err = recordCreditChange(ctx, tx, &models.CreditChange{
+		CustomerID: id, Previous: before.Credits, Credits: credits, Rank: rank, Reason: models.CreditReasonAdmin, At: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.commitCustomerUpdate(ctx, tx, before)
+}
+
+func (s *Store) UpdateCustomerRole(ctx context.Context, id string, role string, version int) (*models.Customer, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CheckVersion("customer", before.Version, version); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE customers SET version = version + 1, role = ? WHERE id = ?", role, id); err != nil {
+		return nil, err
+	}
+	return s.commitCustomerUpdate(ctx, tx, before)
+}
+
+// commitCustomerUpdate audits and commits a change to a customer, returning
+// the updated row.
+func (s *Store) commitCustomerUpdate(ctx context.Context, tx *txn, before *models.Customer) (*models.Customer, error) {
+	after, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ?"+tx.forUpdate(), before.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recordAudit(ctx, tx, before.ID, before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func (s *Store) UpdateCustomerName(ctx context.Context, id string, name string) (*models.Customer, error) {
+	_, err := s.db.ExecContext(ctx, "UPDATE customers SET version = version + 1, name = ? WHERE id = ? AND deleted_at IS NULL", name, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCustomer(ctx, id)
+}
+
+func (s *Store) UpdateCustomerPreferences(ctx context.Context, id string, notifyEmail, notifyInApp bool) (*models.Customer, error) {
+	_, err := s.db.ExecContext(ctx, "UPDATE customers SET version = version + 1, notify_email = ?, notify_in_app = ? WHERE id = ? AND deleted_at IS NULL", notifyEmail, notifyInApp, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCustomer(ctx, id)
+}
+
+func (s *Store) ClearCustomerBan(ctx context.Context, id string) (*models.Customer, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE customers SET version = version + 1, banned_until = NULL WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	return s.commitCustomerUpdate(ctx, tx, before)
+}
+
+// DeleteCustomer soft-deletes a customer: they can no longer sign in, and
+// their reservations are kept until the account is purged.
+func (s *Store) DeleteCustomer(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return err
+	}
+	if err := checkNoActiveReservations(ctx, tx, "customer_id", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE customers SET version = version + 1, deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now(), id); err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx, id, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RestoreCustomer undoes DeleteCustomer, returning store.ErrNotFound if the
+// customer doesn't exist or isn't deleted.
+func (s *Store) RestoreCustomer(ctx context.Context, id string) (*models.Customer, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ? AND deleted_at IS NOT NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE customers SET version = version + 1, deleted_at = NULL WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	return s.commitCustomerUpdate(ctx, tx, before)
+}
+
+// recordCreditChange appends to the customer's credit history and publishes
+// the change.
+func recordCreditChange(ctx context.Context, tx *txn, change *models.CreditChange) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO credit_history (customer_id, previous, credits, "rank", reason, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		change.CustomerID, change.Previous, change.Credits, change.Rank, change.Reason, change.At)
+	if err != nil {
+		return err
+	}
+	return enqueueEvent(ctx, tx, models.EventCreditsUpdated, change)
+}
+
+func (s *Store) GetCreditHistory(ctx context.Context, customerID string) ([]*models.CreditChange, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, `SELECT customer_id, previous, credits, "rank", reason, created_at FROM credit_history WHERE customer_id = ? ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*models.CreditChange
+	for rows.Next() {
+		var c models.CreditChange
+		if err := rows.Scan(&c.CustomerID, &c.Previous, &c.Credits, &c.Rank, &c.Reason, &c.At); err != nil {
+			return nil, err
+		}
+		history = append(history, &c)
+	}
+	return history, rows.Err()
+}
+
+// Erasure Implementation
+
+// ScheduleErasure marks the customer's account for erasure at at; a nil at
+// cancels a pending request.
+func (s *Store) ScheduleErasure(ctx context.Context, id string, at *time.Time) (*models.Customer, error) {
+	_, err := s.db.ExecContext(ctx, "UPDATE customers SET version = version + 1, erase_after = ? WHERE id = ? AND deleted_at IS NULL", at, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCustomer(ctx, id)
+}
+
+// GetCustomersDueForErasure reads from the primary, as the erasure job acts
+// on what it returns.
+func (s *Store) GetCustomersDueForErasure(ctx context.Context, now time.Time) ([]*models.Customer, error) {
+	return s.queryCustomers(ctx, s.db, "SELECT "+customerColumns+" FROM customers WHERE erase_after IS NOT NULL AND erase_after <= ?", now)
+}
+
+// documents lists the columns holding JSON that can name a customer.
+var documents = []struct {
+	table   string
+	columns []string
+}{
+	{"audit_log", []string{"before_state", "after_state"}},
+	{"outbox_events", []string{"payload"}},
+	{"webhook_deliveries", []string{"payload"}},
+}
+
+// redactDocuments runs every stored JSON document naming the customer id
+// through store.RedactCustomer.
+func redactDocuments(ctx context.Context, tx *txn, id, pseudonym string) error {
+	pattern := "%" + id + "%"
+	for _, d := range documents {
+		where := make([]string, len(d.columns))
+		args := make([]any, len(d.columns))
+		for i, col := range d.columns {
+			where[i], args[i] = col+" LIKE ?", pattern
+		}
+		rows, err := tx.QueryContext(ctx, "SELECT id, "+strings.Join(d.columns, ", ")+" FROM "+d.table+" WHERE "+strings.Join(where, " OR "), args...)
+		if err != nil {
+			return err
+		}
+		// Read them all first: a connection can't update while it reads
+		type document struct {
+			id     int64
+			values []sql.NullString
+		}
+		var found []document
+		for rows.Next() {
+			doc := document{values: make([]sql.NullString, len(d.columns))}
+			dest := []any{&doc.id}
+			for i := range doc.values {
+				dest = append(dest, &doc.values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return err
+			}
+			found = append(found, doc)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		set := make([]string, len(d.columns))
+		for i, col := range d.columns {
+			set[i] = col + " = ?"
+		}
+		for _, doc := range found {
+			args := make([]any, 0, len(doc.values)+1)
+			for _, v := range doc.values {
+				if v.Valid {
+					b, err := store.RedactCustomer([]byte(v.String), id, pseudonym)
+					if err != nil {
+						return fmt.Errorf("redacting %s %d: %w", d.table, doc.id, err)
+					}
+					v.String = string(b)
+				}
+				args = append(args, v)
+			}
+			args = append(args, doc.id)
+			if _, err := tx.ExecContext(ctx, "UPDATE "+d.table+" SET "+strings.Join(set, ", ")+" WHERE id = ?", args...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EraseCustomer removes a customer's personal data. Their reservations, status
+// history, credit history and audit entries are kept for stock accounting but
+// re-keyed to a new pseudonymous, soft-deleted customer whose ID is returned,
+// and audit snapshots and event payloads naming them are redacted;
+// notifications and stored idempotent responses are deleted. Reservations still
+// pending, waitlisted or confirmed are cancelled first, in the same
+// transaction, so a failed erasure leaves the customer as they were.
+func (s *Store) EraseCustomer(ctx context.Context, id string) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := scanCustomer(tx.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = ?"+tx.forUpdate(), id)); err != nil {
+		return "", err
+	}
+	var active []string
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM reservations WHERE customer_id = ? AND status IN "+activeStatuses+" ORDER BY id", id)
+	if err != nil {
+		return "", err
+	}
+	for rows.Next() {
+		var rid string
+		if err := rows.Scan(&rid); err != nil {
+			rows.Close()
+			return "", err
+		}
+		active = append(active, rid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	for _, rid := range active {
+		// Read again: cancelling one may have confirmed another from the
+		// waitlist
+		r, err := lockReservation(ctx, tx, rid)
+		if err != nil {
+			return "", err
+		}
+		if err := s.transition(ctx, tx, r, models.StatusCancelled, models.ActorSystem); err != nil {
+			return "", err
+		}
+	}
+
+	pseudonym := uuid.New().String()
+	_, err = tx.ExecContext(ctx, `INSERT INTO customers (id, email, password, salt, name, credits, "rank", role, notify_email, notify_in_app, deleted_at) VALUES (?, ?, '', '', ?, 0, ?, ?, ?, ?, ?)`,
+		pseudonym, pseudonym+models.ErasedEmailDomain, models.ErasedName, models.RankBronze, models.RoleCustomer, false, false, time.Now())
+	if err != nil {
+		return "", err
+	}
+	for _, q := range []string{
+		"UPDATE reservations SET customer_id = ? WHERE customer_id = ?",
+		"UPDATE reservation_transitions SET actor_id = ? WHERE actor_id = ?",
+		"UPDATE credit_history SET customer_id = ? WHERE customer_id = ?",
+		"UPDATE audit_log SET actor_id = ? WHERE actor_id = ?",
+		"UPDATE audit_log SET target_id = ? WHERE target_id = ? AND target_type = '" + models.AuditTargetCustomer + "'",
+	} {
+		if _, err := tx.ExecContext(ctx, q, pseudonym, id); err != nil {
+			return "", err
+		}
+	}
+	if err := redactDocuments(ctx, tx, id, pseudonym); err != nil {
+		return "", err
+	}
+	// Stored responses may hold the profile, and the customer can't retry
+	for _, q := range []string{
+		"DELETE FROM notifications WHERE customer_id = ?",
+		"DELETE FROM idempotency_keys WHERE customer_id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return "", err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM customers WHERE id = ?", id); err != nil {
+		return "", err
+	}
+	return pseudonym, tx.Commit()
+}
@@ -0,0 +1,46 @@
+package sqlstore
+
+import "strings"
+
+// Dialect adapts the shared queries to one database. Queries are written once
+// with ? placeholders in SQL common to every supported database; anything that
+// differs goes through one of these methods.
+type Dialect interface {
+	// Rebind rewrites the ? placeholders in query into the database's own.
+	Rebind(query string) string
+	// Bool returns the literal for b.
+	Bool(b bool) string
+	// Upsert returns the clause that follows an INSERT so that a row clashing
+	// on the key columns updates the given columns from the new row instead,
+	// or is skipped if none are given.
+	Upsert(key []string, update ...string) string
+	// ForUpdate returns the clause that locks the rows a SELECT reads within
+	// a transaction until it ends, or "" if transactions already hold the
+	// write lock from the start.
+	ForUpdate() string
+	// Period returns SQL for the first day, as YYYY-MM-DD, of the day, week
+	// (from Monday) or month containing column.
+	Period(period, column string) string
+	// IsUniqueViolation reports whether err is a unique or primary key
+	// violation.
+	IsUniqueViolation(err error) bool
+	// Schema returns the statements run on every start, which must be safe
+	// to repeat, and the migrations that evolve the schema from there. Each
+	// migration runs once, in order, and its position is recorded in the
+	// schema_migrations table the setup statements create.
+	Schema() (setup []string, migrations [][]string)
+}
+
+// OnConflict is Upsert for databases with INSERT ... ON CONFLICT, as in
+// PostgreSQL and SQLite.
+func OnConflict(key []string, update ...string) string {
+	clause := "ON CONFLICT (" + strings.Join(key, ", ") + ")"
+	if len(update) == 0 {
+		return clause + " DO NOTHING"
+	}
+	set := make([]string, len(update))
+	for i, col := range update {
+		set[i] = col + " = excluded." + col
+	}
+	return clause + " DO UPDATE SET " + strings.Join(set, ", ")
+}
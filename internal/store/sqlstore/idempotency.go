@@ -0,0 +1,90 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"time"
+)
+
+// Idempotency Key Implementation
+
+const idempotencyKeyColumns = "customer_id, idempotency_key, request_hash, status, headers, body, created_at, expires_at"
+
+func scanIdempotencyKey(row rowScanner) (*models.IdempotencyKey, error) {
+	var k models.IdempotencyKey
+	var headers string
+	if err := row.Scan(&k.CustomerID, &k.Key, &k.RequestHash, &k.Status, &headers, &k.Body, &k.CreatedAt, &k.ExpiresAt); err != nil {
+		return nil, notFound(err, "idempotency key")
+	}
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &k.Headers); err != nil {
+			return nil, err
+		}
+	}
+	return &k, nil
+}
+
+func (s *Store) ClaimIdempotencyKey(ctx context.Context, k *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	existing, err := scanIdempotencyKey(tx.QueryRowContext(ctx, "SELECT "+idempotencyKeyColumns+" FROM idempotency_keys WHERE customer_id = ? AND idempotency_key = ?", k.CustomerID, k.Key))
+	switch {
+	case err == nil && existing.ExpiresAt.After(k.CreatedAt):
+		return existing, nil
+	case err == nil:
+		// Expired but not purged yet: the key is free again
+		if _, err := tx.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE customer_id = ? AND idempotency_key = ?", k.CustomerID, k.Key); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO idempotency_keys (customer_id, idempotency_key, request_hash, status, headers, body, created_at, expires_at) VALUES (?, ?, ?, 0, '', NULL, ?, ?)",
+		k.CustomerID, k.Key, k.RequestHash, k.CreatedAt, k.ExpiresAt)
+	if err != nil {
+		return nil, s.conflict(err, "idempotency key")
+	}
+	return nil, tx.Commit()
+}
+
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, k *models.IdempotencyKey) error {
+	headers, err := json.Marshal(k.Headers)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE idempotency_keys SET status = ?, headers = ?, body = ? WHERE customer_id = ? AND idempotency_key = ?",
+		k.Status, string(headers), k.Body, k.CustomerID, k.Key)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("idempotency key %w", store.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, customerID, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE customer_id = ? AND idempotency_key = ? AND status = 0", customerID, key)
+	return err
+}
+
+func (s *Store) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at < ?", now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,67 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"time"
+)
+
+// Notification Implementation
+
+func (s *Store) AddNotification(ctx context.Context, n *models.Notification) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO notifications (id, customer_id, event, subject, body, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		n.ID, n.CustomerID, n.Event, n.Subject, n.Body, n.CreatedAt)
+	return err
+}
+
+func (s *Store) GetNotifications(ctx context.Context, customerID string, unreadOnly bool) ([]*models.Notification, error) {
+	query := "SELECT id, customer_id, event, subject, body, created_at, read_at FROM notifications WHERE customer_id = ?"
+	if unreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY created_at DESC", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		var n models.Notification
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.CustomerID, &n.Event, &n.Subject, &n.Body, &n.CreatedAt, &readAt); err != nil {
+			return nil, err
+		}
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, &n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkNotificationRead marks one of the customer's notifications as read,
+// returning store.ErrNotFound if it does not exist or belongs to someone else.
+func (s *Store) MarkNotificationRead(ctx context.Context, customerID, id string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE notifications SET read_at = COALESCE(read_at, ?) WHERE id = ? AND customer_id = ?",
+		time.Now(), id, customerID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("notification %w", store.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, customerID string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE notifications SET read_at = ? WHERE customer_id = ? AND read_at IS NULL", time.Now(), customerID)
+	return err
+}
@@ -0,0 +1,149 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"farm/internal/models"
+	"time"
+)
+
+// Outbox Implementation
+
+// enqueueEvent records a domain event as part of tx, so it is published if and
+// only if the change it describes commits.
+func enqueueEvent(ctx context.Context, tx *txn, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox_events (type, payload, created_at) VALUES (?, ?, ?)",
+		eventType, string(data), time.Now())
+	return err
+}
+
+// enqueueOutOfStock records that a product has run out.
+func enqueueOutOfStock(ctx context.Context, tx *txn, productID string) error {
+	var name string
+	if err := tx.QueryRowContext(ctx, "SELECT name FROM products WHERE id = ?", productID).Scan(&name); err != nil {
+		return err
+	}
+	return enqueueEvent(ctx, tx, models.EventProductOutOfStock, map[string]string{"product_id": productID, "name": name})
+}
+
+// enqueueInventory records the current stock of an item after a change.
+func enqueueInventory(ctx context.Context, tx *txn, itemType models.ReservationType, id string) error {
+	query := "SELECT name, quantity, visible, deleted_at IS NOT NULL FROM products WHERE id = ?"
+	if itemType == models.ReservationActivity {
+		query = "SELECT name, capacity, visible, deleted_at IS NOT NULL FROM activities WHERE id = ?"
+	}
+	change := models.InventoryChange{Type: itemType, ItemID: id}
+	err := tx.QueryRowContext(ctx, query, id).Scan(&change.Name, &change.Available, &change.Visible, &change.Deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		change.Deleted = true
+	} else if err != nil {
+		return err
+	}
+	return enqueueEvent(ctx, tx, models.EventInventoryChanged, change)
+}
+
+func (s *Store) queryEvents(ctx context.Context, query string, args ...any) ([]*models.Event, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.Event
+	for rows.Next() {
+		var e models.Event
+		var payload string
+		if err := rows.Scan(&e.ID, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = json.RawMessage(payload)
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
+
+func (s *Store) GetOutboxEvents(ctx context.Context, consumer string, limit int) ([]*models.Event, error) {
+	var afterID int64
+	err := s.db.QueryRowContext(ctx, "SELECT last_id FROM outbox_cursors WHERE consumer = ?", consumer).Scan(&afterID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return s.GetOutboxEventsAfter(ctx, afterID, limit)
+}
+
+func (s *Store) GetOutboxEventsAfter(ctx context.Context, afterID int64, limit int) ([]*models.Event, error) {
+	events, err := s.queryEvents(ctx, "SELECT id, type, payload, created_at FROM outbox_events WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return settled(events, afterID, time.Now().Add(-time.Duration(s.Config.Outbox.Settle))), nil
+}
+
+// settled returns the events, which follow afterID in ID order, up to the
+// first gap in their IDs that may yet be filled. IDs are handed out as rows
+// are inserted but become visible as transactions commit, so on databases
+// with concurrent writers a missing ID can be an event still to commit;
+// passing it would lose that event for good. A gap is only passed once the
+// event after it was recorded before cutoff, by which time the transaction
+// holding the missing ID must have committed or rolled back.
+func settled(events []*models.Event, afterID int64, cutoff time.Time) []*models.Event {
+	next := afterID + 1
+	for i, e := range events {
+		if e.ID != next && e.CreatedAt.After(cutoff) {
+			return events[:i]
+		}
+		next = e.ID + 1
+	}
+	return events
+}
+
+func (s *Store) GetLatestOutboxEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM outbox_events").Scan(&id)
+	return id, err
+}
+
+func (s *Store) LeaseOutboxConsumer(ctx context.Context, consumer, holder string, now, until time.Time) (bool, error) {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO outbox_cursors (consumer, last_id) VALUES (?, 0) "+s.dialect.Upsert([]string{"consumer"}), consumer)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE outbox_cursors SET holder = ?, lease_until = ?
+		WHERE consumer = ? AND (holder = ? OR holder IS NULL OR lease_until < ?)`, holder, until, consumer, holder, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *Store) PurgeOutbox(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	for _, q := range []string{
+		"DELETE FROM outbox_events WHERE created_at < ?",
+		"DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < ?",
+	} {
+		res, err := s.db.ExecContext(ctx, q, cutoff)
+		if err != nil {
+			return purged, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+	return purged, nil
+}
+
+func (s *Store) AdvanceOutboxCursor(ctx context.Context, consumer string, lastID int64) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO outbox_cursors (consumer, last_id) VALUES (?, ?) "+s.dialect.Upsert([]string{"consumer"}, "last_id"),
+		consumer, lastID)
+	return err
+}
@@ -0,0 +1,39 @@
+package sqlstore
+
+import (
+	"farm/internal/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSettled(t *testing.T) {
+	now := time.Now()
+	cutoff := now.Add(-time.Minute)
+	old, recent := now.Add(-time.Hour), now
+
+	tests := []struct {
+		name    string
+		afterID int64
+		events  []*models.Event
+		want    []int64
+	}{
+		{"none", 0, nil, nil},
+		{"contiguous", 3, []*models.Event{{ID: 4, CreatedAt: recent}, {ID: 5, CreatedAt: recent}}, []int64{4, 5}},
+		{"gap before the first", 3, []*models.Event{{ID: 5, CreatedAt: recent}}, []int64{}},
+		{"gap in the middle", 3, []*models.Event{{ID: 4, CreatedAt: recent}, {ID: 6, CreatedAt: recent}, {ID: 7, CreatedAt: recent}}, []int64{4}},
+		{"old gap is passed", 3, []*models.Event{{ID: 5, CreatedAt: old}, {ID: 6, CreatedAt: recent}}, []int64{5, 6}},
+		{"old gap then a recent one", 3, []*models.Event{{ID: 5, CreatedAt: old}, {ID: 8, CreatedAt: recent}}, []int64{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			for _, e := range settled(tt.events, tt.afterID, cutoff) {
+				got = append(got, e.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("settled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"farm/internal/models"
+	"time"
+)
+
+// Pickup Slot Implementation
+
+// slotHeld matches the reservations holding a place in their pickup slot,
+// including those already collected.
+const slotHeld = "status IN ('confirmed', 'checked_in', 'fulfilled')"
+
+// lockSlot records slot with its current capacity and locks it until tx
+// ends, so the places in it are counted and taken one reservation at a time.
+func lockSlot(ctx context.Context, tx *txn, slot *models.PickupSlot) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO pickup_slots (location_id, start_at, capacity) VALUES (?, ?, ?) "+tx.dialect.Upsert([]string{"location_id", "start_at"}, "capacity"),
+		slot.LocationID, slot.Start.UTC(), slot.Capacity)
+	return err
+}
+
+// slotFull locks the pickup slot at locationID starting at start, if it has
+// been recorded, and reports whether every place in it is taken. Slots
+// without a capacity are never full.
+func slotFull(ctx context.Context, tx *txn, locationID string, start time.Time) (bool, error) {
+	var capacity int
+	err := tx.QueryRowContext(ctx, "SELECT capacity FROM pickup_slots WHERE location_id = ? AND start_at = ?"+tx.forUpdate(), locationID, start.UTC()).Scan(&capacity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil // Booked before slots were recorded
+	}
+	if err != nil || capacity <= 0 {
+		return false, err
+	}
+	var booked int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM reservations WHERE pickup_location_id = ? AND pickup_start = ? AND "+slotHeld,
+		locationID, start.UTC()).Scan(&booked)
+	return booked >= capacity, err
+}
@@ -0,0 +1,220 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"farm/internal/config"
+	"farm/internal/store"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replica is a read-only copy of the database.
+type Replica struct {
+	Name string // Identifies the replica in logs, so must not hold credentials
+	DB   *sql.DB
+}
+
+// lagReporter is implemented by dialects that can tell how far a replica has
+// fallen behind the primary.
+type lagReporter interface {
+	ReplicaLag(ctx context.Context, db *sql.DB) (time.Duration, error)
+}
+
+type replica struct {
+	Replica
+	conn    *conn
+	healthy atomic.Bool
+	checked bool // Whether a health check has run; only touched by check
+}
+
+// replicaSet spreads reads across the replicas that passed their last health
+// check, and remembers which customers must read from the primary.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	config   *config.ReplicaConfig
+	lag      lagReporter // nil if the dialect can't measure lag
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu     sync.Mutex
+	pinned map[string]time.Time // Customer ID to when their reads may leave the primary
+}
+
+// UseReplicas sends listings, reports and exports to the replicas, spread
+// evenly over those whose last health check passed. Everything else stays on
+// the primary, as does every read when no replica is healthy or a query on
+// one fails. A customer's reads also stay on the primary for PinDuration
+// after they write, so they see their own changes. Replicas are checked
+// before UseReplicas returns and then every HealthInterval, and closed with
+// the store.
+func (s *Store) UseReplicas(replicas []Replica) {
+	rs := &replicaSet{
+		config: &s.Config.Database.Replicas,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		pinned: make(map[string]time.Time),
+	}
+	rs.lag, _ = s.dialect.(lagReporter)
+	for _, r := range replicas {
+		rs.replicas = append(rs.replicas, &replica{Replica: r, conn: &conn{db: r.DB, dialect: s.dialect}})
+	}
+	rs.check()
+	go rs.run()
+	s.db.replicas = rs
+}
+
+func (rs *replicaSet) run() {
+	defer close(rs.done)
+	interval := time.Duration(rs.config.HealthInterval)
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.check()
+		}
+	}
+}
+
+// check probes every replica at once, logging any whose health changed, and
+// forgets lapsed pins.
+func (rs *replicaSet) check() {
+	var wg sync.WaitGroup
+	for _, r := range rs.replicas {
+		wg.Go(func() {
+			err := rs.probe(r)
+			healthy := err == nil
+			if r.healthy.Swap(healthy) == healthy && r.checked {
+				return
+			}
+			r.checked = true
+			if healthy {
+				slog.Info("Database replica healthy", "replica", r.Name)
+			} else {
+				slog.Warn("Database replica unhealthy, reading from the primary instead", "replica", r.Name, "error", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	now := time.Now()
+	rs.mu.Lock()
+	for id, until := range rs.pinned {
+		if now.After(until) {
+			delete(rs.pinned, id)
+		}
+	}
+	rs.mu.Unlock()
+}
+
+// probe returns why r shouldn't serve reads: it can't be reached, or is more
+// than MaxLag behind the primary.
+func (rs *replicaSet) probe(r *replica) error {
+	timeout := time.Duration(rs.config.HealthInterval)
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := r.DB.PingContext(ctx); err != nil {
+		return err
+	}
+	maxLag := time.Duration(rs.config.MaxLag)
+	if rs.lag == nil || maxLag <= 0 {
+		return nil
+	}
+	lag, err := rs.lag.ReplicaLag(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+	if lag > maxLag {
+		return fmt.Errorf("%s behind the primary", lag.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// pick returns the next healthy replica in turn, or nil if there is none.
+func (rs *replicaSet) pick() *replica {
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := range n {
+		if r := rs.replicas[(start+i)%n]; r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// pin keeps customerID's reads on the primary for PinDuration.
+func (rs *replicaSet) pin(customerID string) {
+	if rs == nil || customerID == "" || rs.config.PinDuration <= 0 {
+		return
+	}
+	rs.mu.Lock()
+	rs.pinned[customerID] = time.Now().Add(time.Duration(rs.config.PinDuration))
+	rs.mu.Unlock()
+}
+
+// isPinned reports whether customerID's reads must stay on the primary.
+func (rs *replicaSet) isPinned(customerID string) bool {
+	if customerID == "" {
+		return false
+	}
+	rs.mu.Lock()
+	until, ok := rs.pinned[customerID]
+	rs.mu.Unlock()
+	return ok && time.Now().Before(until)
+}
+
+func (rs *replicaSet) close() {
+	close(rs.stop)
+	<-rs.done
+	for _, r := range rs.replicas {
+		r.DB.Close()
+	}
+}
+
+// querier runs read-only queries.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// reader returns where a listing, report or export made for ctx reads from:
+// a healthy replica, unless there is none or the customer behind ctx wrote
+// recently, and otherwise the primary.
+func (s *Store) reader(ctx context.Context) querier {
+	rs := s.db.replicas
+	if rs == nil || rs.isPinned(store.CustomerFromContext(ctx)) {
+		return s.db
+	}
+	r := rs.pick()
+	if r == nil {
+		return s.db
+	}
+	return &replicaReader{replica: r.conn, primary: s.db}
+}
+
+// replicaReader retries on the primary any query the replica fails, so a
+// replica going down between health checks doesn't fail requests.
+type replicaReader struct {
+	replica, primary *conn
+}
+
+func (r *replicaReader) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := r.replica.QueryContext(ctx, query, args...)
+	if err != nil && ctx.Err() == nil {
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
@@ -0,0 +1,204 @@
+package sqlstore
+
+import (
+	"cmp"
+	"context"
+	"farm/internal/models"
+	"slices"
+)
+
+// Report Implementation
+
+// rate is n / total, or 0 when total is 0. Rates are worked out here rather
+// than in SQL, where division and casts to floating point vary by dialect.
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func (s *Store) GetReservationCounts(ctx context.Context, f models.ReportFilter) ([]*models.ReservationCount, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT "+s.dialect.Period(f.Period, "r.timestamp")+` AS period,
+			COALESCE(r.product_id, r.activity_id), COALESCE(p.name, a.name, ''), r.type,
+			COUNT(*), SUM(CASE WHEN r.status = 'cancelled' THEN 1 ELSE 0 END)
+		FROM reservations r
+		LEFT JOIN products p ON p.id = r.product_id
+		LEFT JOIN activities a ON a.id = r.activity_id
+		WHERE r.timestamp >= ? AND r.timestamp < ? AND (? = '' OR r.type = ?) AND (? < 0 OR r.priority_rank = ?)
+		GROUP BY 1, 2, 3, 4
+		ORDER BY 1, 3, 2`,
+		f.From, f.To, f.Type, f.Type, f.Rank, f.Rank)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*models.ReservationCount{}
+	for rows.Next() {
+		var c models.ReservationCount
+		if err := rows.Scan(&c.Period, &c.ItemID, &c.ItemName, &c.Type, &c.Reservations, &c.Cancelled); err != nil {
+			return nil, err
+		}
+		counts = append(counts, &c)
+	}
+	return counts, rows.Err()
+}
+
+// GetActivityFill reports every activity that isn't deleted. Seats left is the
+// current capacity, so the fill rate is exact when the range covers all of an
+// activity's reservations.
+func (s *Store) GetActivityFill(ctx context.Context, f models.ReportFilter) ([]*models.ActivityFill, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, `SELECT a.id, a.name,
+			COALESCE(SUM(CASE WHEN r.status IN ('confirmed', 'checked_in') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.status = 'checked_in' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.status = 'no_show' THEN 1 ELSE 0 END), 0),
+			a.capacity
+		FROM activities a
+		LEFT JOIN reservations r ON r.activity_id = a.id AND r.timestamp >= ? AND r.timestamp < ?
+		WHERE a.deleted_at IS NULL
+		GROUP BY a.id, a.name, a.capacity
+		ORDER BY a.name, a.id`,
+		f.From, f.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fill := []*models.ActivityFill{}
+	for rows.Next() {
+		var a models.ActivityFill
+		if err := rows.Scan(&a.ActivityID, &a.Name, &a.Booked, &a.CheckedIn, &a.NoShows, &a.SeatsLeft); err != nil {
+			return nil, err
+		}
+		a.FillRate = rate(a.Booked, a.Booked+a.SeatsLeft)
+		fill = append(fill, &a)
+	}
+	return fill, rows.Err()
+}
+
+// GetProductSellThrough reports every product that isn't deleted; on hand is
+// the current stock.
+func (s *Store) GetProductSellThrough(ctx context.Context, f models.ReportFilter) ([]*models.ProductSellThrough, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, `SELECT p.id, p.name,
+			COALESCE(SUM(CASE WHEN r.status = 'fulfilled' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.status = 'confirmed' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.status = 'expired' THEN 1 ELSE 0 END), 0),
+			p.quantity
+		FROM products p
+		LEFT JOIN reservations r ON r.product_id = p.id AND r.timestamp >= ? AND r.timestamp < ?
+		WHERE p.deleted_at IS NULL
+		GROUP BY p.id, p.name, p.quantity
+		ORDER BY p.name, p.id`,
+		f.From, f.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []*models.ProductSellThrough{}
+	for rows.Next() {
+		var p models.ProductSellThrough
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Fulfilled, &p.Held, &p.Expired, &p.OnHand); err != nil {
+			return nil, err
+		}
+		p.SellThrough = rate(p.Fulfilled, p.Fulfilled+p.Held+p.OnHand)
+		products = append(products, &p)
+	}
+	return products, rows.Err()
+}
+
+// GetRankDistribution counts active customers (not staff or admins) per rank,
+// including ranks nobody holds.
+func (s *Store) GetRankDistribution(ctx context.Context) ([]*models.RankCount, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, `SELECT "rank", COUNT(*) FROM customers WHERE deleted_at IS NULL AND role = ? GROUP BY "rank"`, models.RoleCustomer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[models.Rank]int)
+	for rows.Next() {
+		var rank models.Rank
+		var n int
+		if err := rows.Scan(&rank, &n); err != nil {
+			return nil, err
+		}
+		counts[rank] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var dist []*models.RankCount
+	for _, rank := range []models.Rank{models.RankBronze, models.RankSilver, models.RankGold} {
+		dist = append(dist, &models.RankCount{Rank: rank, RankName: rank.String(), Customers: counts[rank]})
+	}
+	return dist, nil
+}
+
+// GetNoShowRates lists items with at least one attended or missed reservation.
+func (s *Store) GetNoShowRates(ctx context.Context, f models.ReportFilter) ([]*models.NoShowRate, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, `SELECT COALESCE(r.product_id, r.activity_id), COALESCE(p.name, a.name, ''), r.type,
+			SUM(CASE WHEN r.status IN ('checked_in', 'fulfilled') THEN 1 ELSE 0 END),
+			SUM(CASE WHEN r.status = 'no_show' THEN 1 ELSE 0 END)
+		FROM reservations r
+		LEFT JOIN products p ON p.id = r.product_id
+		LEFT JOIN activities a ON a.id = r.activity_id
+		WHERE r.timestamp >= ? AND r.timestamp < ? AND (? = '' OR r.type = ?) AND (? < 0 OR r.priority_rank = ?)
+			AND r.status IN ('checked_in', 'fulfilled', 'no_show')
+		GROUP BY 1, 2, 3
+		ORDER BY 2, 1`,
+		f.From, f.To, f.Type, f.Type, f.Rank, f.Rank)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []*models.NoShowRate{}
+	for rows.Next() {
+		var r models.NoShowRate
+		if err := rows.Scan(&r.ItemID, &r.ItemName, &r.Type, &r.Attended, &r.NoShows); err != nil {
+			return nil, err
+		}
+		r.Rate = rate(r.NoShows, r.Attended+r.NoShows)
+		rates = append(rates, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(rates, func(a, b *models.NoShowRate) int {
+		return cmp.Compare(b.Rate, a.Rate)
+	})
+	return rates, nil
+}
+
+// GetTopCustomers ranks customers by reservations made in the range, not
+// counting cancelled ones.
+func (s *Store) GetTopCustomers(ctx context.Context, f models.ReportFilter) ([]*models.TopCustomer, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, `SELECT c.id, c.name, c.email, c."rank", COUNT(*),
+			SUM(CASE WHEN r.status IN ('checked_in', 'fulfilled') THEN 1 ELSE 0 END),
+			SUM(CASE WHEN r.status = 'no_show' THEN 1 ELSE 0 END)
+		FROM reservations r
+		JOIN customers c ON c.id = r.customer_id
+		WHERE r.timestamp >= ? AND r.timestamp < ? AND (? = '' OR r.type = ?) AND (? < 0 OR r.priority_rank = ?)
+			AND r.status <> 'cancelled'
+		GROUP BY c.id, c.name, c.email, c."rank"
+		ORDER BY 5 DESC, 6 DESC, c.id
+		LIMIT ?`,
+		f.From, f.To, f.Type, f.Type, f.Rank, f.Rank, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	top := []*models.TopCustomer{}
+	for rows.Next() {
+		var c models.TopCustomer
+		if err := rows.Scan(&c.CustomerID, &c.Name, &c.Email, &c.Rank, &c.Reservations, &c.Completed, &c.NoShows); err != nil {
+			return nil, err
+		}
+		top = append(top, &c)
+	}
+	return top, rows.Err()
+}
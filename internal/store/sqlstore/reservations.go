@@ -0,0 +1,517 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"time"
+)
+
+// Reservation Implementation
+
+// reservationColumns reads a reservation's product_id or activity_id back as
+// its ItemID.
+const reservationColumns = "id, customer_id, COALESCE(product_id, activity_id), type, priority_rank, timestamp, status, pickup_location_id, pickup_start, pickup_end"
+
+const insertReservation = "INSERT INTO reservations (id, customer_id, product_id, activity_id, type, priority_rank, timestamp, status, pickup_location_id, pickup_start, pickup_end) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// activeStatuses are the reservation statuses that still claim an item.
+const activeStatuses = "('pending', 'waitlist', 'confirmed')"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReservation(row rowScanner) (*models.Reservation, error) {
+	var r models.Reservation
+	var pickupLocation sql.NullString
+	var pickupStart, pickupEnd sql.NullTime
+	if err := row.Scan(&r.ID, &r.CustomerID, &r.ItemID, &r.Type, &r.PriorityRank, &r.Timestamp, &r.Status,
+		&pickupLocation, &pickupStart, &pickupEnd); err != nil {
+		return nil, notFound(err, "reservation")
+	}
+	if pickupLocation.Valid {
+		r.Pickup = &models.PickupSlot{
+			LocationID: pickupLocation.String,
+			Start:      pickupStart.Time,
+			End:        pickupEnd.Time,
+		}
+	}
+	return &r, nil
+}
+
+func (s *Store) queryReservations(ctx context.Context, db querier, query string, args ...any) ([]*models.Reservation, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []*models.Reservation
+	for rows.Next() {
+		r, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, rows.Err()
+}
+
+// pickupArgs flattens an optional pickup slot into nullable column values.
+func pickupArgs(p *models.PickupSlot) (sql.NullString, sql.NullTime, sql.NullTime) {
+	if p == nil {
+		return sql.NullString{}, sql.NullTime{}, sql.NullTime{}
+	}
+	return sql.NullString{String: p.LocationID, Valid: true},
+		sql.NullTime{Time: p.Start.UTC(), Valid: true},
+		sql.NullTime{Time: p.End.UTC(), Valid: true}
+}
+
+// itemColumn is the reservations column referencing items of type t.
+func itemColumn(t models.ReservationType) string {
+	if t == models.ReservationActivity {
+		return "activity_id"
+	}
+	return "product_id"
+}
+
+// itemArgs splits r.ItemID into the product_id and activity_id columns.
+func itemArgs(r *models.Reservation) (productID, activityID sql.NullString) {
+	switch r.Type {
+	case models.ReservationProduct:
+		productID = sql.NullString{String: r.ItemID, Valid: true}
+	case models.ReservationActivity:
+		activityID = sql.NullString{String: r.ItemID, Valid: true}
+	}
+	return productID, activityID
+}
+
+func (s *Store) AddReservation(ctx context.Context, r *models.Reservation) error {
+	productID, activityID := itemArgs(r)
+	loc, start, end := pickupArgs(r.Pickup)
+	_, err := s.db.ExecContext(ctx, insertReservation,
+		r.ID, r.CustomerID, productID, activityID, r.Type, r.PriorityRank, r.Timestamp, r.Status, loc, start, end)
+	return err
+}
+
+func (s *Store) GetAllReservations(ctx context.Context) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, s.reader(ctx), "SELECT "+reservationColumns+" FROM reservations")
+}
+
+func (s *Store) GetReservationsByCustomerID(ctx context.Context, customerID string) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, s.reader(ctx), "SELECT "+reservationColumns+" FROM reservations WHERE customer_id = ?", customerID)
+}
+
+func (s *Store) GetReservationsByPickupRange(ctx context.Context, from, to time.Time) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, s.reader(ctx), "SELECT "+reservationColumns+" FROM reservations WHERE pickup_start >= ? AND pickup_start < ? ORDER BY pickup_start, pickup_location_id, timestamp",
+		from.UTC(), to.UTC())
+}
+
+func (s *Store) CountPickupReservations(ctx context.Context, locationID string, start time.Time) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM reservations WHERE pickup_location_id = ? AND pickup_start = ? AND "+slotHeld,
+		locationID, start.UTC()).Scan(&count)
+	return count, err
+}
+
+func (s *Store) DeleteReservation(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := scanReservation(tx.QueryRowContext(ctx, "SELECT "+reservationColumns+" FROM reservations WHERE id = ?"+tx.forUpdate(), id))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM reservation_transitions WHERE reservation_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM reservations WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx, id, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) GetReservation(ctx context.Context, id string) (*models.Reservation, error) {
+	r, err := scanReservation(s.db.QueryRowContext(ctx, "SELECT "+reservationColumns+" FROM reservations WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT from_status, to_status, actor_id, at FROM reservation_transitions WHERE reservation_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.Transition
+		if err := rows.Scan(&t.From, &t.To, &t.ActorID, &t.At); err != nil {
+			return nil, err
+		}
+		r.History = append(r.History, &t)
+	}
+	return r, rows.Err()
+}
+
+func (s *Store) ReserveItem(ctx context.Context, r *models.Reservation) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 1. Verify Customer
+	var count int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM customers WHERE id = ? AND deleted_at IS NULL", r.CustomerID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("customer %w", store.ErrNotFound)
+	}
+
+	// 2. Check and Decrement Stock
+	waitlist := r.Status == models.StatusWaitlist
+	inStock, soldOut := true, false
+	switch r.Type {
+	case models.ReservationProduct:
+		var qty int
+		err = tx.QueryRowContext(ctx, "SELECT quantity FROM products WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), r.ItemID).Scan(&qty)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("product %w", store.ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if qty <= 0 && !waitlist {
+			return store.ErrOutOfStock
+		}
+		inStock, soldOut = qty > 0, qty == 1
+		if r.Pickup != nil {
+			// Waitlisted reservations record the slot too, for promotion
+			if err := lockSlot(ctx, tx, r.Pickup); err != nil {
+				return err
+			}
+		}
+		if r.Pickup != nil && inStock {
+			full, err := slotFull(ctx, tx, r.Pickup.LocationID, r.Pickup.Start)
+			if err != nil {
+				return err
+			}
+			if full {
+				return store.ErrSlotFull
+			}
+		}
+		if inStock {
+			if _, err := tx.ExecContext(ctx, "UPDATE products SET version = version + 1, quantity = quantity - 1 WHERE id = ?", r.ItemID); err != nil {
+				return err
+			}
+		}
+	case models.ReservationActivity:
+		if r.Pickup != nil {
+			return fmt.Errorf("%w: pickup slots apply to product reservations only", store.ErrInvalid)
+		}
+		var cap int
+		err = tx.QueryRowContext(ctx, "SELECT capacity FROM activities WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), r.ItemID).Scan(&cap)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("activity %w", store.ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if cap <= 0 && !waitlist {
+			return store.ErrFullyBooked
+		}
+		inStock = cap > 0
+		if inStock {
+			if _, err := tx.ExecContext(ctx, "UPDATE activities SET version = version + 1, capacity = capacity - 1 WHERE id = ?", r.ItemID); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown reservation type %q", store.ErrInvalid, r.Type)
+	}
+
+	// 3. Create Reservation
+	r.Status = models.StatusConfirmed
+	if !inStock {
+		r.Status = models.StatusWaitlist
+	}
+	productID, activityID := itemArgs(r)
+	loc, start, end := pickupArgs(r.Pickup)
+	_, err = tx.ExecContext(ctx, insertReservation,
+		r.ID, r.CustomerID, productID, activityID, r.Type, r.PriorityRank, r.Timestamp, r.Status, loc, start, end)
+	if err != nil {
+		return err
+	}
+	if err := recordTransition(ctx, tx, r.ID, models.StatusPending, r.Status, r.CustomerID, r.Timestamp); err != nil {
+		return err
+	}
+	if err := enqueueEvent(ctx, tx, models.EventReservationCreated, r); err != nil {
+		return err
+	}
+	if inStock {
+		if err := enqueueInventory(ctx, tx, r.Type, r.ItemID); err != nil {
+			return err
+		}
+	}
+	if soldOut {
+		if err := enqueueOutOfStock(ctx, tx, r.ItemID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) TransitionReservation(ctx context.Context, id string, to models.ReservationStatus, actorID string) (*models.Reservation, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r, err := lockReservation(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.transition(ctx, tx, r, to, actorID); err != nil {
+		return nil, err
+	}
+	after := *r
+	after.Status = to
+	if err := s.recordAudit(ctx, tx, id, r, &after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetReservation(ctx, id)
+}
+
+func lockReservation(ctx context.Context, tx *txn, id string) (*models.Reservation, error) {
+	return scanReservation(tx.QueryRowContext(ctx, "SELECT "+reservationColumns+" FROM reservations WHERE id = ?"+tx.forUpdate(), id))
+}
+
+// transition moves r, locked by tx, to status to, handing back the unit it
+// held and counting no-shows as the change requires.
+func (s *Store) transition(ctx context.Context, tx *txn, r *models.Reservation, to models.ReservationStatus, actorID string) error {
+	if !r.CanTransition(to) {
+		return fmt.Errorf("%w: %s -> %s", store.ErrInvalidTransition, r.Status, to)
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, "UPDATE reservations SET status = ? WHERE id = ?", to, r.ID); err != nil {
+		return err
+	}
+	if err := recordTransition(ctx, tx, r.ID, r.Status, to, actorID, now); err != nil {
+		return err
+	}
+	if err := enqueueStatusChange(ctx, tx, r, to, actorID, now); err != nil {
+		return err
+	}
+
+	// Terminal states other than check-in/fulfilment hand the unit back,
+	// first to the waitlist and otherwise to stock.
+	if r.Status.HoldsStock() && (to == models.StatusCancelled || to == models.StatusNoShow || to == models.StatusExpired) {
+		if err := releaseUnit(ctx, tx, r, now); err != nil {
+			return err
+		}
+	}
+	if r.Status == models.StatusConfirmed && (to == models.StatusNoShow || to == models.StatusExpired) {
+		return s.recordNoShow(ctx, tx, r.CustomerID, now)
+	}
+	return nil
+}
+
+// GetExpiredReservations reads from the primary, as the expiry job acts on
+// what it returns.
+func (s *Store) GetExpiredReservations(ctx context.Context, cutoff time.Time) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, s.db, "SELECT "+reservationColumns+" FROM reservations WHERE type = 'product' AND status = 'confirmed' AND COALESCE(pickup_end, timestamp) < ?",
+		cutoff)
+}
+
+// recordNoShow counts a missed reservation against the customer and applies
+// the configured credit penalty and reservation ban.
+func (s *Store) recordNoShow(ctx context.Context, tx *txn, customerID string, at time.Time) error {
+	var credits, noShows int
+	err := tx.QueryRowContext(ctx, "SELECT credits, no_show_count FROM customers WHERE id = ?", customerID).Scan(&credits, &noShows)
+	if err != nil {
+		return err
+	}
+
+	cfg := s.Config.Reservations
+	noShows++
+	previous := credits
+	credits = max(credits-cfg.NoShowPenalty, 0)
+	rank := store.RankFor(&s.Config.Ranks, credits)
+	if _, err := tx.ExecContext(ctx, `UPDATE customers SET version = version + 1, no_show_count = ?, credits = ?, "rank" = ? WHERE id = ?`,
+		noShows, credits, rank, customerID); err != nil {
+		return err
+	}
+	if credits != previous {
+		err := recordCreditChange(ctx, tx, &models.CreditChange{
+			CustomerID: customerID, Previous: previous, Credits: credits, Rank: rank, Reason: models.CreditReasonNoShow, At: at,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.BanAfterNoShows > 0 && noShows >= cfg.BanAfterNoShows {
+		until := at.Add(time.Duration(cfg.BanDuration))
+		if _, err := tx.ExecContext(ctx, "UPDATE customers SET version = version + 1, banned_until = ? WHERE id = ?", until, customerID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNoActiveReservations fails with store.ErrActiveReservations if any
+// pending, waitlisted or confirmed reservation references id in column.
+func checkNoActiveReservations(ctx context.Context, tx *txn, column, id string) error {
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM reservations WHERE "+column+" = ? AND status IN "+activeStatuses, id).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w (%d); cancel them first", store.ErrActiveReservations, n)
+	}
+	return nil
+}
+
+// releaseUnit returns the unit held by r: the highest-priority, longest
+// waiting reservation on the waitlist is confirmed in its place, otherwise the
+// item's stock or capacity is incremented.
+func releaseUnit(ctx context.Context, tx *txn, r *models.Reservation, at time.Time) error {
+	return addUnits(ctx, tx, r.Type, r.ItemID, 1, at)
+}
+
+// addUnits adds n units, or removes them if n is negative, to an item's stock
+// or capacity. Added units go to waitlisted reservations first.
+func addUnits(ctx context.Context, tx *txn, typ models.ReservationType, itemID string, n int, at time.Time) error {
+	n, err := fillWaitlist(ctx, tx, typ, itemID, n, at)
+	if err != nil || n == 0 {
+		return err
+	}
+
+	if typ == models.ReservationProduct {
+		_, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, quantity = quantity + ? WHERE id = ?", n, itemID)
+	} else {
+		_, err = tx.ExecContext(ctx, "UPDATE activities SET version = version + 1, capacity = capacity + ? WHERE id = ?", n, itemID)
+	}
+	if err != nil {
+		return err
+	}
+	return enqueueInventory(ctx, tx, typ, itemID)
+}
+
+// setUnits returns the stock or capacity to store when an edit changes an
+// item's from old to n. As with addUnits, units added go to waitlisted
+// reservations first.
+func setUnits(ctx context.Context, tx *txn, typ models.ReservationType, itemID string, old, n int, at time.Time) (int, error) {
+	if n <= old {
+		return n, nil
+	}
+	left, err := fillWaitlist(ctx, tx, typ, itemID, n-old, at)
+	return old + left, err
+}
+
+// fillWaitlist confirms up to n waitlisted reservations for the item in
+// priority order, returning how many of the n units are left over.
+func fillWaitlist(ctx context.Context, tx *txn, typ models.ReservationType, itemID string, n int, at time.Time) (int, error) {
+	for ; n > 0; n-- {
+		promoted, err := promoteWaitlisted(ctx, tx, typ, itemID, at)
+		if err != nil || !promoted {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// promoteWaitlisted confirms the highest-priority, longest waiting
+// reservation on the item's waitlist whose pickup slot, if any, has room,
+// reporting whether there was one.
+func promoteWaitlisted(ctx context.Context, tx *txn, typ models.ReservationType, itemID string, at time.Time) (bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT "+reservationColumns+" FROM reservations WHERE "+itemColumn(typ)+" = ? AND status = 'waitlist' ORDER BY priority_rank DESC, timestamp ASC",
+		itemID)
+	if err != nil {
+		return false, err
+	}
+	var waiting []*models.Reservation
+	for rows.Next() {
+		r, err := scanReservation(rows)
+		if err != nil {
+			rows.Close()
+			return false, err
+		}
+		waiting = append(waiting, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	var next *models.Reservation
+	for _, r := range waiting {
+		if r.Pickup == nil {
+			next = r
+			break
+		}
+		full, err := slotFull(ctx, tx, r.Pickup.LocationID, r.Pickup.Start)
+		if err != nil {
+			return false, err
+		}
+		if !full {
+			next = r
+			break
+		}
+	}
+	if next == nil {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE reservations SET status = 'confirmed' WHERE id = ?", next.ID); err != nil {
+		return false, err
+	}
+	if err := recordTransition(ctx, tx, next.ID, models.StatusWaitlist, models.StatusConfirmed, models.ActorSystem, at); err != nil {
+		return false, err
+	}
+	return true, enqueueStatusChange(ctx, tx, next, models.StatusConfirmed, models.ActorSystem, at)
+}
+
+func recordTransition(ctx context.Context, tx *txn, id string, from, to models.ReservationStatus, actorID string, at time.Time) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO reservation_transitions (reservation_id, from_status, to_status, actor_id, at) VALUES (?, ?, ?, ?, ?)",
+		id, from, to, actorID, at)
+	return err
+}
+
+// enqueueStatusChange publishes r moving from its current status to to.
+func enqueueStatusChange(ctx context.Context, tx *txn, r *models.Reservation, to models.ReservationStatus, actorID string, at time.Time) error {
+	change := &models.StatusChange{
+		ReservationID: r.ID,
+		CustomerID:    r.CustomerID,
+		ItemID:        r.ItemID,
+		Type:          r.Type,
+		From:          r.Status,
+		To:            to,
+		ActorID:       actorID,
+		At:            at,
+	}
+	if err := enqueueEvent(ctx, tx, models.EventReservationStatusChanged, change); err != nil {
+		return err
+	}
+	if to == models.StatusCancelled {
+		return enqueueEvent(ctx, tx, models.EventReservationCancelled, change)
+	}
+	return nil
+}
@@ -0,0 +1,471 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"time"
+)
+
+// Product Implementation
+
+const productColumns = "id, name, description, image_url, quantity, visible, deleted_at, version"
+
+func scanProduct(row rowScanner) (*models.Product, error) {
+	var p models.Product
+	var deletedAt sql.NullTime
+	if err := row.Scan(&p.ID, &p.Name, &p.Description, &p.ImageURL, &p.Quantity, &p.Visible, &deletedAt, &p.Version); err != nil {
+		return nil, notFound(err, "product")
+	}
+	if deletedAt.Valid {
+		p.DeletedAt = &deletedAt.Time
+	}
+	return &p, nil
+}
+
+func (s *Store) AddProduct(ctx context.Context, p *models.Product) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	p.Version = 1
+	_, err = tx.ExecContext(ctx, "INSERT INTO products (id, name, description, image_url, quantity, visible) VALUES (?, ?, ?, ?, ?, ?)",
+		p.ID, p.Name, p.Description, p.ImageURL, p.Quantity, p.Visible)
+	if err != nil {
+		return s.conflict(err, "product")
+	}
+	if err := enqueueInventory(ctx, tx, models.ReservationProduct, p.ID); err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx, p.ID, nil, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) GetProduct(ctx context.Context, id string) (*models.Product, error) {
+	return scanProduct(s.db.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ?", id))
+}
+
+func (s *Store) GetAllProducts(ctx context.Context, visibleOnly bool) ([]*models.Product, error) {
+	query := "SELECT " + productColumns + " FROM products WHERE deleted_at IS NULL"
+	if visibleOnly {
+		query += " AND visible = " + s.dialect.Bool(true)
+	}
+	return s.queryProducts(ctx, query)
+}
+
+func (s *Store) GetDeletedProducts(ctx context.Context) ([]*models.Product, error) {
+	return s.queryProducts(ctx, "SELECT "+productColumns+" FROM products WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+}
+
+func (s *Store) queryProducts(ctx context.Context, query string) ([]*models.Product, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []*models.Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+func (s *Store) UpdateProduct(ctx context.Context, p *models.Product) error {
+	updated, err := s.PatchProduct(ctx, p.ID, p.Version, func(cur *models.Product) error {
+		*cur = *p
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*p = *updated
+	return nil
+}
+
+func (s *Store) PatchProduct(ctx context.Context, id string, version int, patch func(*models.Product) error) (*models.Product, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanProduct(tx.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CheckVersion("product", old.Version, version); err != nil {
+		return nil, err
+	}
+	p := *old
+	if err := patch(&p); err != nil {
+		return nil, err
+	}
+	p.ID, p.DeletedAt, p.Version = old.ID, old.DeletedAt, old.Version+1
+	if p.Quantity, err = setUnits(ctx, tx, models.ReservationProduct, p.ID, old.Quantity, p.Quantity, time.Now()); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1, name = ?, description = ?, image_url = ?, quantity = ?, visible = ? WHERE id = ? AND deleted_at IS NULL",
+		p.Name, p.Description, p.ImageURL, p.Quantity, p.Visible, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := enqueueInventory(ctx, tx, models.ReservationProduct, p.ID); err != nil {
+		return nil, err
+	}
+	if old.Quantity > 0 && p.Quantity <= 0 {
+		if err := enqueueOutOfStock(ctx, tx, p.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.recordAudit(ctx, tx, p.ID, old, &p); err != nil {
+		return nil, err
+	}
+	return &p, tx.Commit()
+}
+
+// AdjustProductStock adds delta units to the product's stock, or removes them
+// if delta is negative. Added units go to waitlisted reservations first.
+func (s *Store) AdjustProductStock(ctx context.Context, id string, delta int) (*models.Product, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanProduct(tx.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if old.Quantity+delta < 0 {
+		return nil, fmt.Errorf("%w: only %d left", store.ErrOutOfStock, old.Quantity)
+	}
+	if err := addUnits(ctx, tx, models.ReservationProduct, id, delta, time.Now()); err != nil {
+		return nil, err
+	}
+	p, err := scanProduct(tx.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ?"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if old.Quantity > 0 && p.Quantity <= 0 {
+		if err := enqueueOutOfStock(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.recordAudit(ctx, tx, id, old, p); err != nil {
+		return nil, err
+	}
+	return p, tx.Commit()
+}
+
+// DeleteProduct soft-deletes a product: it disappears from listings and can
+// no longer be reserved, but stays available to reservation history until
+// purged.
+func (s *Store) DeleteProduct(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := scanProduct(tx.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return err
+	}
+	if err := checkNoActiveReservations(ctx, tx, "product_id", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET version = version + 1, deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now(), id); err != nil {
+		return err
+	}
+	if err := enqueueInventory(ctx, tx, models.ReservationProduct, id); err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx, id, old, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RestoreProduct undoes DeleteProduct, returning store.ErrNotFound if the product
+// doesn't exist or isn't deleted.
+func (s *Store) RestoreProduct(ctx context.Context, id string) (*models.Product, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanProduct(tx.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NOT NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET version = version + 1, deleted_at = NULL WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := enqueueInventory(ctx, tx, models.ReservationProduct, id); err != nil {
+		return nil, err
+	}
+	restored := *old
+	restored.DeletedAt = nil
+	restored.Version++
+	if err := s.recordAudit(ctx, tx, id, old, &restored); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// Activity Implementation
+
+const activityColumns = "id, name, description, image_url, capacity, visible, deleted_at, version"
+
+func scanActivity(row rowScanner) (*models.Activity, error) {
+	var a models.Activity
+	var deletedAt sql.NullTime
+	if err := row.Scan(&a.ID, &a.Name, &a.Description, &a.ImageURL, &a.Capacity, &a.Visible, &deletedAt, &a.Version); err != nil {
+		return nil, notFound(err, "activity")
+	}
+	if deletedAt.Valid {
+		a.DeletedAt = &deletedAt.Time
+	}
+	return &a, nil
+}
+
+func (s *Store) AddActivity(ctx context.Context, a *models.Activity) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	a.Version = 1
+	_, err = tx.ExecContext(ctx, "INSERT INTO activities (id, name, description, image_url, capacity, visible) VALUES (?, ?, ?, ?, ?, ?)",
+		a.ID, a.Name, a.Description, a.ImageURL, a.Capacity, a.Visible)
+	if err != nil {
+		return s.conflict(err, "activity")
+	}
+	if err := enqueueInventory(ctx, tx, models.ReservationActivity, a.ID); err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx, a.ID, nil, a); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) GetActivity(ctx context.Context, id string) (*models.Activity, error) {
+	return scanActivity(s.db.QueryRowContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE id = ?", id))
+}
+
+func (s *Store) GetAllActivities(ctx context.Context, visibleOnly bool) ([]*models.Activity, error) {
+	query := "SELECT " + activityColumns + " FROM activities WHERE deleted_at IS NULL"
+	if visibleOnly {
+		query += " AND visible = " + s.dialect.Bool(true)
+	}
+	return s.queryActivities(ctx, query)
+}
+
+func (s *Store) GetDeletedActivities(ctx context.Context) ([]*models.Activity, error) {
+	return s.queryActivities(ctx, "SELECT "+activityColumns+" FROM activities WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+}
+
+func (s *Store) queryActivities(ctx context.Context, query string) ([]*models.Activity, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []*models.Activity
+	for rows.Next() {
+		a, err := scanActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return activities, rows.Err()
+}
+
+func (s *Store) UpdateActivity(ctx context.Context, a *models.Activity) error {
+	updated, err := s.PatchActivity(ctx, a.ID, a.Version, func(cur *models.Activity) error {
+		*cur = *a
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*a = *updated
+	return nil
+}
+
+func (s *Store) PatchActivity(ctx context.Context, id string, version int, patch func(*models.Activity) error) (*models.Activity, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanActivity(tx.QueryRowContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CheckVersion("activity", old.Version, version); err != nil {
+		return nil, err
+	}
+	a := *old
+	if err := patch(&a); err != nil {
+		return nil, err
+	}
+	a.ID, a.DeletedAt, a.Version = old.ID, old.DeletedAt, old.Version+1
+	if a.Capacity, err = setUnits(ctx, tx, models.ReservationActivity, a.ID, old.Capacity, a.Capacity, time.Now()); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE activities SET version = version + 1, name = ?, description = ?, image_url = ?, capacity = ?, visible = ? WHERE id = ? AND deleted_at IS NULL",
+		a.Name, a.Description, a.ImageURL, a.Capacity, a.Visible, a.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := enqueueInventory(ctx, tx, models.ReservationActivity, a.ID); err != nil {
+		return nil, err
+	}
+	if err := s.recordAudit(ctx, tx, a.ID, old, &a); err != nil {
+		return nil, err
+	}
+	return &a, tx.Commit()
+}
+
+// AdjustActivityCapacity is AdjustProductStock for an activity's remaining
+// places.
+func (s *Store) AdjustActivityCapacity(ctx context.Context, id string, delta int) (*models.Activity, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanActivity(tx.QueryRowContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if old.Capacity+delta < 0 {
+		return nil, fmt.Errorf("%w: only %d places left", store.ErrFullyBooked, old.Capacity)
+	}
+	if err := addUnits(ctx, tx, models.ReservationActivity, id, delta, time.Now()); err != nil {
+		return nil, err
+	}
+	a, err := scanActivity(tx.QueryRowContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE id = ?"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recordAudit(ctx, tx, id, old, a); err != nil {
+		return nil, err
+	}
+	return a, tx.Commit()
+}
+
+// DeleteActivity soft-deletes an activity; see DeleteProduct.
+func (s *Store) DeleteActivity(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := scanActivity(tx.QueryRowContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE id = ? AND deleted_at IS NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return err
+	}
+	if err := checkNoActiveReservations(ctx, tx, "activity_id", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE activities SET version = version + 1, deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now(), id); err != nil {
+		return err
+	}
+	if err := enqueueInventory(ctx, tx, models.ReservationActivity, id); err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx, id, old, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RestoreActivity undoes DeleteActivity, returning store.ErrNotFound if the
+// activity doesn't exist or isn't deleted.
+func (s *Store) RestoreActivity(ctx context.Context, id string) (*models.Activity, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanActivity(tx.QueryRowContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE id = ? AND deleted_at IS NOT NULL"+tx.forUpdate(), id))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE activities SET version = version + 1, deleted_at = NULL WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := enqueueInventory(ctx, tx, models.ReservationActivity, id); err != nil {
+		return nil, err
+	}
+	restored := *old
+	restored.DeletedAt = nil
+	restored.Version++
+	if err := s.recordAudit(ctx, tx, id, old, &restored); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// PurgeDeleted permanently removes products, activities and customers that
+// were soft-deleted before cutoff, returning how many rows went. Rows still
+// referenced by reservations are kept.
+func (s *Store) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM notifications WHERE customer_id IN (
+		SELECT id FROM customers WHERE deleted_at < ? AND NOT EXISTS (SELECT 1 FROM reservations WHERE customer_id = customers.id))`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, q := range []string{
+		"DELETE FROM products WHERE deleted_at < ? AND NOT EXISTS (SELECT 1 FROM reservations WHERE product_id = products.id)",
+		"DELETE FROM activities WHERE deleted_at < ? AND NOT EXISTS (SELECT 1 FROM reservations WHERE activity_id = activities.id)",
+		"DELETE FROM customers WHERE deleted_at < ? AND NOT EXISTS (SELECT 1 FROM reservations WHERE customer_id = customers.id)",
+	} {
+		res, err := tx.ExecContext(ctx, q, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += n
+	}
+	return purged, tx.Commit()
+}
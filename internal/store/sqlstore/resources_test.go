@@ -0,0 +1,67 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+var errLostConnection = errors.New("connection lost")
+
+// brokenDriver serves queries whose rows fail after the first.
+type brokenDriver struct{}
+
+func (brokenDriver) Open(string) (driver.Conn, error) { return brokenConn{}, nil }
+
+type brokenConn struct{ driver.Conn }
+
+func (brokenConn) Close() error { return nil }
+
+func (brokenConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &brokenRows{}, nil
+}
+
+type brokenRows struct{ sent bool }
+
+func (r *brokenRows) Columns() []string {
+	return []string{"id", "name", "description", "image_url", "count", "visible", "deleted_at", "version"}
+}
+
+func (r *brokenRows) Close() error { return nil }
+
+func (r *brokenRows) Next(dest []driver.Value) error {
+	if r.sent {
+		return errLostConnection
+	}
+	r.sent = true
+	copy(dest, []driver.Value{"eggs", "Eggs", "", "", int64(1), true, nil, int64(1)})
+	return nil
+}
+
+// plain is a Dialect that leaves queries as written.
+type plain struct{ Dialect }
+
+func (plain) Rebind(query string) string { return query }
+
+func init() {
+	sql.Register("sqlstore-broken", brokenDriver{})
+}
+
+func TestListingsReportRowErrors(t *testing.T) {
+	db, err := sql.Open("sqlstore-broken", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := &Store{db: &conn{db: db, dialect: plain{}}, dialect: plain{}}
+	ctx := context.Background()
+
+	if products, err := s.GetAllProducts(ctx, false); !errors.Is(err, errLostConnection) {
+		t.Errorf("GetAllProducts = %d products, %v; want the row error", len(products), err)
+	}
+	if activities, err := s.GetAllActivities(ctx, false); !errors.Is(err, errLostConnection) {
+		t.Errorf("GetAllActivities = %d activities, %v; want the row error", len(activities), err)
+	}
+}
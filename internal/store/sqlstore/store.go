@@ -0,0 +1,179 @@
+// Package sqlstore implements store.Repository on a SQL database. The sqlite,
+// postgres and mysql packages open the connection and supply the Dialect and
+// schema; everything else is shared, so features land once and behave the
+// same on every database.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"time"
+)
+
+type Store struct {
+	db      *conn
+	Config  *config.Config
+	dialect Dialect
+	audit   *models.AuditEntry // Set on views returned by WithAudit
+}
+
+// New returns a store on db, first bringing its schema up to date.
+func New(db *sql.DB, d Dialect, cfg *config.Config) (*Store, error) {
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	s := &Store{db: &conn{db: db, dialect: d}, Config: cfg, dialect: d}
+	if err := s.migrate(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// conn is a connection pool that rebinds each query for its dialect.
+type conn struct {
+	db       *sql.DB
+	dialect  Dialect
+	replicas *replicaSet // Set on the primary by UseReplicas
+}
+
+// ExecContext runs a write. A customer behind ctx is pinned to the primary
+// if the database has replicas.
+func (c *conn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := c.db.ExecContext(ctx, c.dialect.Rebind(query), args...)
+	if err == nil {
+		c.replicas.pin(store.CustomerFromContext(ctx))
+	}
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.db.QueryContext(ctx, c.dialect.Rebind(query), args...)
+}
+
+func (c *conn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return c.db.QueryRowContext(ctx, c.dialect.Rebind(query), args...)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*txn, error) {
+	tx, err := c.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &txn{tx: tx, dialect: c.dialect, replicas: c.replicas, customer: store.CustomerFromContext(ctx)}, nil
+}
+
+// txn is a transaction that rebinds each query for its dialect.
+type txn struct {
+	tx       *sql.Tx
+	dialect  Dialect
+	replicas *replicaSet
+	customer string // Pinned to the primary on commit
+}
+
+func (t *txn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *txn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *txn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *txn) Commit() error {
+	if err := t.tx.Commit(); err != nil {
+		return err
+	}
+	t.replicas.pin(t.customer)
+	return nil
+}
+
+func (t *txn) Rollback() error { return t.tx.Rollback() }
+
+// forUpdate locks the rows read by the SELECT it ends until t does, so a
+// snapshot taken before a change is still current when the change is made.
+func (t *txn) forUpdate() string {
+	return t.dialect.ForUpdate()
+}
+
+// notFound reports a missing row as store.ErrNotFound, naming what was
+// looked for.
+func notFound(err error, what string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %w", what, store.ErrNotFound)
+	}
+	return err
+}
+
+// conflict reports a unique or primary key violation as store.ErrConflict.
+func (s *Store) conflict(err error, what string) error {
+	if err != nil && s.dialect.IsUniqueViolation(err) {
+		return fmt.Errorf("%s %w", what, store.ErrConflict)
+	}
+	return err
+}
+
+func (s *Store) migrate(ctx context.Context) error {
+	setup, migrations := s.dialect.Schema()
+	for _, q := range setup {
+		if _, err := s.db.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+
+	var current int
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, q := range migrations[i] {
+			if _, err := tx.ExecContext(ctx, q); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d: %w", i+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", i+1, time.Now()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.db.PingContext(ctx)
+}
+
+func (s *Store) MigrationStatus(ctx context.Context) (applied, latest int, err error) {
+	_, migrations := s.dialect.Schema()
+	err = s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&applied)
+	return applied, len(migrations), err
+}
+
+// Stats reports connection pool statistics.
+func (s *Store) Stats() sql.DBStats {
+	return s.db.db.Stats()
+}
+
+// Close closes the connection pools, stopping any replica health checks.
+func (s *Store) Close() error {
+	if s.db.replicas != nil {
+		s.db.replicas.close()
+	}
+	return s.db.db.Close()
+}
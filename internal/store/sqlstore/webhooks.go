@@ -0,0 +1,176 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"farm/internal/models"
+	"strings"
+	"time"
+)
+
+// Webhook Implementation
+
+const webhookColumns = "id, url, secret, events, active, created_at"
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var w models.Webhook
+	var events string
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Active, &w.CreatedAt); err != nil {
+		return nil, notFound(err, "webhook")
+	}
+	if events != "" {
+		w.Events = strings.Split(events, ",")
+	}
+	return &w, nil
+}
+
+func (s *Store) AddWebhook(ctx context.Context, w *models.Webhook) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO webhooks ("+webhookColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		w.ID, w.URL, w.Secret, strings.Join(w.Events, ","), w.Active, w.CreatedAt)
+	if err != nil {
+		return s.conflict(err, "webhook")
+	}
+	if err := s.recordAudit(ctx, tx, w.ID, nil, w); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	return scanWebhook(s.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+}
+
+func (s *Store) GetAllWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := scanWebhook(tx.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?"+tx.forUpdate(), id))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx, id, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delivery Implementation
+
+const deliveryColumns = "id, webhook_id, event_id, event_type, payload, status, attempts, response_code, last_error, next_attempt_at, delivered_at, created_at"
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	var deliveredAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.ResponseCode, &d.LastError, &d.NextAttemptAt, &deliveredAt, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return &d, nil
+}
+
+func (s *Store) queryDeliveries(ctx context.Context, query string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// AddWebhookDeliveries queues deliveries, skipping any event already queued
+// for the same webhook so that replaying the outbox is harmless.
+func (s *Store) AddWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, d := range deliveries {
+		_, err := tx.ExecContext(ctx, `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, attempts, response_code, last_error, next_attempt_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `+s.dialect.Upsert([]string{"webhook_id", "event_id"}),
+			d.WebhookID, d.EventID, d.EventType, string(d.Payload), d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt, d.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return s.queryDeliveries(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?",
+		now, limit)
+}
+
+func (s *Store) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = 'pending' AND next_attempt_at <= ?",
+		until, id, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *Store) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]*models.WebhookDelivery, error) {
+	return s.queryDeliveries(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?",
+		webhookID, limit)
+}
+
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	var deliveredAt sql.NullTime
+	if d.DeliveredAt != nil {
+		deliveredAt = sql.NullTime{Time: *d.DeliveredAt, Valid: true}
+	}
+	_, err := s.db.ExecContext(ctx, "UPDATE webhook_deliveries SET status = ?, attempts = ?, response_code = ?, last_error = ?, next_attempt_at = ?, delivered_at = ? WHERE id = ?",
+		d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt, deliveredAt, d.ID)
+	return err
+}
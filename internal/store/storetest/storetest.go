@@ -0,0 +1,1554 @@
+// Package storetest is a conformance suite for store.Repository. Each
+// implementation runs it from its own tests, so every backend is held to the
+// same behaviour.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// NewStore opens an empty store configured by cfg. It is called once per
+// test, and should register any cleanup with t.
+type NewStore func(t *testing.T, cfg *config.Config) store.Repository
+
+// Config returns the configuration the suite runs with. Backends fill in the
+// database settings they need before opening the store.
+func Config() *config.Config {
+	return &config.Config{
+		Ranks: config.RankConfig{BronzeMax: 99, SilverMax: 499},
+		Reservations: config.ReservationConfig{
+			NoShowPenalty:   50,
+			BanAfterNoShows: 2,
+			BanDuration:     config.Duration(24 * time.Hour),
+		},
+		// Long enough that a gap in event IDs is never skipped, whatever
+		// time zone the database stores timestamps in
+		Outbox: config.OutboxConfig{Settle: config.Duration(24 * time.Hour)},
+	}
+}
+
+// Run runs the suite against the stores newStore opens.
+func Run(t *testing.T, newStore NewStore) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Repository)
+	}{
+		{"Customers", testCustomers},
+		{"CustomerVersions", testCustomerVersions},
+		{"Products", testProducts},
+		{"DeleteAndRestore", testDeleteAndRestore},
+		{"ReserveItem", testReserveItem},
+		{"ReserveItemConcurrently", testReserveItemConcurrently},
+		{"PickupSlots", testPickupSlots},
+		{"PickupSlotConcurrently", testPickupSlotConcurrently},
+		{"Waitlist", testWaitlist},
+		{"AdjustStock", testAdjustStock},
+		{"RaiseStock", testRaiseStock},
+		{"Transitions", testTransitions},
+		{"NoShows", testNoShows},
+		{"ActiveReservations", testActiveReservations},
+		{"Outbox", testOutbox},
+		{"OutboxLeases", testOutboxLeases},
+		{"PurgeOutbox", testPurgeOutbox},
+		{"Audit", testAudit},
+		{"Import", testImport},
+		{"Erase", testErase},
+		{"EraseCancelsReservations", testEraseCancelsReservations},
+		{"Purge", testPurge},
+		{"Notifications", testNotifications},
+		{"Webhooks", testWebhooks},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"Reports", testReports},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t, Config()))
+		})
+	}
+}
+
+// NewSQLStore opens an empty store like NewStore, and returns a second pool
+// of connections to the same database alongside it.
+type NewSQLStore func(t *testing.T, cfg *config.Config) (store.Repository, *sql.DB)
+
+// RunInterleaved runs the tests that hold a transaction open on db while the
+// store writes. SQLite allows one writer at a time, so only the stores on
+// databases with concurrent writers run them.
+func RunInterleaved(t *testing.T, newStore NewSQLStore) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Repository, db *sql.DB)
+	}{
+		{"OutboxCommitOrder", testOutboxCommitOrder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newStore(t, Config())
+			tt.fn(t, s, db)
+		})
+	}
+}
+
+var ctx = context.Background()
+
+// base is a fixed time, truncated so every backend stores it exactly.
+var base = time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+func addCustomer(t *testing.T, s store.Repository, id string, credits int) *models.Customer {
+	t.Helper()
+	c := &models.Customer{ID: id, Email: id + "@example.com", Name: id, Credits: credits, Role: models.RoleCustomer, NotifyInApp: true}
+	check(t, s.AddCustomer(ctx, c))
+	return c
+}
+
+func addProduct(t *testing.T, s store.Repository, id string, quantity int) *models.Product {
+	t.Helper()
+	p := &models.Product{ID: id, Name: "Product " + id, Quantity: quantity, Visible: true}
+	check(t, s.AddProduct(ctx, p))
+	return p
+}
+
+func addActivity(t *testing.T, s store.Repository, id string, capacity int) *models.Activity {
+	t.Helper()
+	a := &models.Activity{ID: id, Name: "Activity " + id, Capacity: capacity, Visible: true}
+	check(t, s.AddActivity(ctx, a))
+	return a
+}
+
+// reserve reserves itemID for customerID, made minutes after base.
+func reserve(s store.Repository, id, customerID string, typ models.ReservationType, itemID string, minutes int, waitlist bool) (*models.Reservation, error) {
+	r := &models.Reservation{
+		ID: id, CustomerID: customerID, ItemID: itemID, Type: typ,
+		Timestamp: base.Add(time.Duration(minutes) * time.Minute),
+	}
+	if c, err := s.GetCustomer(ctx, customerID); err == nil {
+		r.PriorityRank = c.Rank
+	}
+	if waitlist {
+		r.Status = models.StatusWaitlist
+	}
+	return r, s.ReserveItem(ctx, r)
+}
+
+func mustReserve(t *testing.T, s store.Repository, id, customerID string, typ models.ReservationType, itemID string, minutes int) *models.Reservation {
+	t.Helper()
+	r, err := reserve(s, id, customerID, typ, itemID, minutes, false)
+	check(t, err)
+	return r
+}
+
+func quantity(t *testing.T, s store.Repository, id string) int {
+	t.Helper()
+	p, err := s.GetProduct(ctx, id)
+	check(t, err)
+	return p.Quantity
+}
+
+func status(t *testing.T, s store.Repository, id string) models.ReservationStatus {
+	t.Helper()
+	r, err := s.GetReservation(ctx, id)
+	check(t, err)
+	return r.Status
+}
+
+func testCustomers(t *testing.T, s store.Repository) {
+	c := addCustomer(t, s, "alice", 150)
+	if c.Rank != models.RankSilver || c.Version != 1 {
+		t.Errorf("new customer has rank %v, version %d; want Silver, 1", c.Rank, c.Version)
+	}
+
+	wantErr(t, s.AddCustomer(ctx, &models.Customer{ID: "alice", Email: "other@example.com"}), store.ErrConflict)
+	wantErr(t, s.AddCustomer(ctx, &models.Customer{ID: "bob", Email: "alice@example.com"}), store.ErrConflict)
+
+	got, err := s.GetCustomerByEmail(ctx, "alice@example.com")
+	check(t, err)
+	if got.ID != "alice" || got.Credits != 150 || !got.NotifyInApp {
+		t.Errorf("GetCustomerByEmail = %+v", got)
+	}
+	_, err = s.GetCustomer(ctx, "nobody")
+	wantErr(t, err, store.ErrNotFound)
+
+	got, err = s.UpdateCustomerCredits(ctx, "alice", 600, 0)
+	check(t, err)
+	if got.Rank != models.RankGold || got.Credits != 600 {
+		t.Errorf("after update, credits %d rank %v; want 600 Gold", got.Credits, got.Rank)
+	}
+	history, err := s.GetCreditHistory(ctx, "alice")
+	check(t, err)
+	if len(history) != 1 || history[0].Previous != 150 || history[0].Credits != 600 || history[0].Reason != models.CreditReasonAdmin {
+		t.Errorf("credit history = %+v", history)
+	}
+
+	got, err = s.UpdateCustomerName(ctx, "alice", "Alice")
+	check(t, err)
+	if got.Name != "Alice" {
+		t.Errorf("name = %q, want Alice", got.Name)
+	}
+	got, err = s.UpdateCustomerPreferences(ctx, "alice", true, false)
+	check(t, err)
+	if !got.NotifyEmail || got.NotifyInApp {
+		t.Errorf("preferences = %v, %v; want true, false", got.NotifyEmail, got.NotifyInApp)
+	}
+
+	addCustomer(t, s, "bob", 0)
+	all, err := s.GetAllCustomers(ctx)
+	check(t, err)
+	if len(all) != 2 {
+		t.Errorf("GetAllCustomers returned %d customers, want 2", len(all))
+	}
+}
+
+func testCustomerVersions(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+
+	c, err := s.UpdateCustomerRole(ctx, "alice", models.RoleStaff, 1)
+	check(t, err)
+	if c.Version != 2 || c.Role != models.RoleStaff {
+		t.Errorf("after role change, version %d role %q; want 2 staff", c.Version, c.Role)
+	}
+	_, err = s.UpdateCustomerCredits(ctx, "alice", 10, 1)
+	wantErr(t, err, store.ErrStale)
+
+	c, err = s.UpdateCustomerCredits(ctx, "alice", 10, 2)
+	check(t, err)
+	if c.Version != 3 {
+		t.Errorf("version = %d, want 3", c.Version)
+	}
+	stored, err := s.GetCustomer(ctx, "alice")
+	check(t, err)
+	if stored.Version != 3 || stored.Credits != 10 {
+		t.Errorf("stored version %d credits %d; want 3, 10", stored.Version, stored.Credits)
+	}
+}
+
+func testProducts(t *testing.T, s store.Repository) {
+	p := addProduct(t, s, "eggs", 3)
+	if p.Version != 1 {
+		t.Errorf("new product version = %d, want 1", p.Version)
+	}
+	wantErr(t, s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs"}), store.ErrConflict)
+	addProduct(t, s, "hidden", 1)
+	_, err := s.PatchProduct(ctx, "hidden", 0, func(p *models.Product) error {
+		p.Visible = false
+		return nil
+	})
+	check(t, err)
+
+	visible, err := s.GetAllProducts(ctx, true)
+	check(t, err)
+	if len(visible) != 1 || visible[0].ID != "eggs" {
+		t.Errorf("visible products = %v", visible)
+	}
+
+	patched, err := s.PatchProduct(ctx, "eggs", 1, func(p *models.Product) error {
+		p.ID, p.Name, p.Version = "changed", "Eggs", 99
+		return nil
+	})
+	check(t, err)
+	if patched.ID != "eggs" || patched.Name != "Eggs" || patched.Version != 2 {
+		t.Errorf("patched = %+v; want ID eggs, name Eggs, version 2", patched)
+	}
+	_, err = s.PatchProduct(ctx, "eggs", 1, func(p *models.Product) error {
+		t.Error("patch called on a stale version")
+		return nil
+	})
+	wantErr(t, err, store.ErrStale)
+
+	errPatch := errors.New("rejected")
+	_, err = s.PatchProduct(ctx, "eggs", 0, func(p *models.Product) error {
+		p.Quantity = 0
+		return errPatch
+	})
+	wantErr(t, err, errPatch)
+	if q := quantity(t, s, "eggs"); q != 3 {
+		t.Errorf("quantity after a failed patch = %d, want 3", q)
+	}
+
+	check(t, s.UpdateProduct(ctx, &models.Product{ID: "eggs", Name: "Brown eggs", Quantity: 5, Visible: true, Version: 2}))
+	got, err := s.GetProduct(ctx, "eggs")
+	check(t, err)
+	if got.Name != "Brown eggs" || got.Quantity != 5 || got.Version != 3 {
+		t.Errorf("after UpdateProduct = %+v", got)
+	}
+	wantErr(t, s.UpdateProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Version: 2}), store.ErrStale)
+	wantErr(t, s.UpdateProduct(ctx, &models.Product{ID: "nothing", Name: "Nothing"}), store.ErrNotFound)
+}
+
+func testDeleteAndRestore(t *testing.T, s store.Repository) {
+	addProduct(t, s, "eggs", 3)
+	addActivity(t, s, "tour", 5)
+	addCustomer(t, s, "alice", 0)
+
+	check(t, s.DeleteProduct(ctx, "eggs"))
+	check(t, s.DeleteActivity(ctx, "tour"))
+	check(t, s.DeleteCustomer(ctx, "alice"))
+
+	// Deleting what is missing or already deleted changes nothing.
+	latest, err := s.GetLatestOutboxEventID(ctx)
+	check(t, err)
+	audited := s.WithAudit(&models.AuditEntry{ActorID: "admin", Action: models.AuditActionDelete, TargetType: models.AuditTargetProduct})
+	for _, id := range []string{"eggs", "nothing"} {
+		wantErr(t, audited.DeleteProduct(ctx, id), store.ErrNotFound)
+		wantErr(t, audited.DeleteActivity(ctx, id), store.ErrNotFound)
+		wantErr(t, audited.DeleteCustomer(ctx, id), store.ErrNotFound)
+	}
+	wantErr(t, audited.DeleteCustomer(ctx, "alice"), store.ErrNotFound)
+	wantErr(t, audited.DeleteReservation(ctx, "nothing"), store.ErrNotFound)
+	wantErr(t, audited.DeleteWebhook(ctx, "nothing"), store.ErrNotFound)
+	if id, err := s.GetLatestOutboxEventID(ctx); err != nil || id != latest {
+		t.Errorf("failed deletes recorded events: latest id %d, was %d (%v)", id, latest, err)
+	}
+	entries, err := s.GetAuditEntries(ctx, models.AuditFilter{Limit: 10})
+	check(t, err)
+	if len(entries) != 0 {
+		t.Errorf("failed deletes recorded %d audit entries", len(entries))
+	}
+
+	products, err := s.GetAllProducts(ctx, false)
+	check(t, err)
+	if len(products) != 0 {
+		t.Errorf("GetAllProducts returned deleted products: %v", products)
+	}
+	p, err := s.GetProduct(ctx, "eggs")
+	check(t, err)
+	if p.DeletedAt == nil {
+		t.Error("deleted product has no DeletedAt")
+	}
+	_, err = s.GetCustomer(ctx, "alice")
+	wantErr(t, err, store.ErrNotFound)
+	_, err = s.PatchProduct(ctx, "eggs", 0, func(*models.Product) error { return nil })
+	wantErr(t, err, store.ErrNotFound)
+	_, err = reserve(s, "r1", "alice", models.ReservationProduct, "eggs", 0, false)
+	wantErr(t, err, store.ErrNotFound)
+
+	deleted, err := s.GetDeletedProducts(ctx)
+	check(t, err)
+	if len(deleted) != 1 {
+		t.Errorf("GetDeletedProducts returned %d products, want 1", len(deleted))
+	}
+	deletedActivities, err := s.GetDeletedActivities(ctx)
+	check(t, err)
+	deletedCustomers, err := s.GetDeletedCustomers(ctx)
+	check(t, err)
+	if len(deletedActivities) != 1 || len(deletedCustomers) != 1 {
+		t.Errorf("deleted activities %d, customers %d; want 1 each", len(deletedActivities), len(deletedCustomers))
+	}
+
+	restored, err := s.RestoreProduct(ctx, "eggs")
+	check(t, err)
+	stored, err := s.GetProduct(ctx, "eggs")
+	check(t, err)
+	if restored.DeletedAt != nil || restored.Version != stored.Version {
+		t.Errorf("restored %+v, stored version %d", restored, stored.Version)
+	}
+	_, err = s.RestoreProduct(ctx, "eggs")
+	wantErr(t, err, store.ErrNotFound)
+	_, err = s.RestoreActivity(ctx, "tour")
+	check(t, err)
+	_, err = s.RestoreCustomer(ctx, "alice")
+	check(t, err)
+	mustReserve(t, s, "r1", "alice", models.ReservationProduct, "eggs", 0)
+}
+
+func testReserveItem(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addProduct(t, s, "eggs", 1)
+	addActivity(t, s, "tour", 0)
+
+	r := mustReserve(t, s, "r1", "alice", models.ReservationProduct, "eggs", 0)
+	if r.Status != models.StatusConfirmed {
+		t.Errorf("status = %s, want confirmed", r.Status)
+	}
+	p, err := s.GetProduct(ctx, "eggs")
+	check(t, err)
+	if p.Quantity != 0 || p.Version != 2 {
+		t.Errorf("product quantity %d version %d; want 0, 2", p.Quantity, p.Version)
+	}
+
+	_, err = reserve(s, "r2", "alice", models.ReservationProduct, "eggs", 1, false)
+	wantErr(t, err, store.ErrOutOfStock)
+	_, err = reserve(s, "r3", "alice", models.ReservationActivity, "tour", 1, false)
+	wantErr(t, err, store.ErrFullyBooked)
+	_, err = reserve(s, "r4", "nobody", models.ReservationProduct, "eggs", 1, false)
+	wantErr(t, err, store.ErrNotFound)
+	_, err = reserve(s, "r5", "alice", models.ReservationProduct, "nothing", 1, false)
+	wantErr(t, err, store.ErrNotFound)
+	_, err = reserve(s, "r6", "alice", "plant", "eggs", 1, false)
+	wantErr(t, err, store.ErrInvalid)
+
+	got, err := s.GetReservation(ctx, "r1")
+	check(t, err)
+	if got.CustomerID != "alice" || got.ItemID != "eggs" || got.Type != models.ReservationProduct || !got.Timestamp.Equal(base) {
+		t.Errorf("GetReservation = %+v", got)
+	}
+	if len(got.History) != 1 || got.History[0].From != models.StatusPending || got.History[0].To != models.StatusConfirmed || got.History[0].ActorID != "alice" {
+		t.Errorf("history = %+v", got.History)
+	}
+	all, err := s.GetAllReservations(ctx)
+	check(t, err)
+	if len(all) != 1 || all[0].History != nil {
+		t.Errorf("GetAllReservations = %+v; want one reservation without history", all)
+	}
+	mine, err := s.GetReservationsByCustomerID(ctx, "alice")
+	check(t, err)
+	if len(mine) != 1 {
+		t.Errorf("GetReservationsByCustomerID returned %d reservations, want 1", len(mine))
+	}
+}
+
+func testReserveItemConcurrently(t *testing.T, s store.Repository) {
+	const stock, customers = 5, 20
+	addProduct(t, s, "eggs", stock)
+	for i := range customers {
+		addCustomer(t, s, fmt.Sprintf("c%d", i), 0)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, customers)
+	for i := range customers {
+		wg.Go(func() {
+			_, errs[i] = reserve(s, fmt.Sprintf("r%d", i), fmt.Sprintf("c%d", i), models.ReservationProduct, "eggs", i, false)
+		})
+	}
+	wg.Wait()
+
+	confirmed := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			confirmed++
+		case !errors.Is(err, store.ErrOutOfStock):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if confirmed != stock {
+		t.Errorf("%d reservations confirmed, want %d", confirmed, stock)
+	}
+	if q := quantity(t, s, "eggs"); q != 0 {
+		t.Errorf("quantity = %d, want 0", q)
+	}
+}
+
+func testPickupSlots(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addProduct(t, s, "eggs", 5)
+	addActivity(t, s, "tour", 5)
+	slot := func() *models.PickupSlot {
+		return &models.PickupSlot{LocationID: "barn", Start: base.Add(24 * time.Hour), End: base.Add(25 * time.Hour), Capacity: 1}
+	}
+
+	r := &models.Reservation{ID: "r1", CustomerID: "alice", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: base, Pickup: slot()}
+	check(t, s.ReserveItem(ctx, r))
+	r = &models.Reservation{ID: "r2", CustomerID: "alice", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: base, Pickup: slot()}
+	wantErr(t, s.ReserveItem(ctx, r), store.ErrSlotFull)
+	r = &models.Reservation{ID: "r3", CustomerID: "alice", ItemID: "tour", Type: models.ReservationActivity, Timestamp: base, Pickup: slot()}
+	wantErr(t, s.ReserveItem(ctx, r), store.ErrInvalid)
+
+	n, err := s.CountPickupReservations(ctx, "barn", base.Add(24*time.Hour))
+	check(t, err)
+	if n != 1 {
+		t.Errorf("CountPickupReservations = %d, want 1", n)
+	}
+	inRange, err := s.GetReservationsByPickupRange(ctx, base, base.Add(48*time.Hour))
+	check(t, err)
+	if len(inRange) != 1 || inRange[0].Pickup == nil || inRange[0].Pickup.LocationID != "barn" || !inRange[0].Pickup.End.Equal(base.Add(25*time.Hour)) {
+		t.Errorf("GetReservationsByPickupRange = %+v", inRange)
+	}
+	if q := quantity(t, s, "eggs"); q != 4 {
+		t.Errorf("quantity = %d, want 4", q)
+	}
+
+	expired, err := s.GetExpiredReservations(ctx, base.Add(26*time.Hour))
+	check(t, err)
+	if len(expired) != 1 {
+		t.Errorf("GetExpiredReservations after the slot = %d reservations, want 1", len(expired))
+	}
+	expired, err = s.GetExpiredReservations(ctx, base.Add(24*time.Hour))
+	check(t, err)
+	if len(expired) != 0 {
+		t.Errorf("GetExpiredReservations during the slot = %d reservations, want 0", len(expired))
+	}
+
+	// The same instant in another zone is the same slot
+	zoned := slot()
+	zoned.Start = zoned.Start.In(time.FixedZone("UTC+2", 2*60*60))
+	r = &models.Reservation{ID: "r4", CustomerID: "alice", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: base, Pickup: zoned}
+	wantErr(t, s.ReserveItem(ctx, r), store.ErrSlotFull)
+	n, err = s.CountPickupReservations(ctx, "barn", zoned.Start)
+	check(t, err)
+	if n != 1 {
+		t.Errorf("CountPickupReservations in another zone = %d, want 1", n)
+	}
+
+	// Collecting the order doesn't free its place
+	_, err = s.TransitionReservation(ctx, "r1", models.StatusFulfilled, "staff")
+	check(t, err)
+	r = &models.Reservation{ID: "r5", CustomerID: "alice", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: base, Pickup: slot()}
+	wantErr(t, s.ReserveItem(ctx, r), store.ErrSlotFull)
+
+	// Nor does the waitlist jump a full slot
+	addCustomer(t, s, "bob", 0)
+	addProduct(t, s, "milk", 0)
+	r = &models.Reservation{ID: "w-alice", CustomerID: "alice", ItemID: "milk", Type: models.ReservationProduct, Timestamp: base, Status: models.StatusWaitlist, Pickup: slot()}
+	check(t, s.ReserveItem(ctx, r))
+	_, err = reserve(s, "w-bob", "bob", models.ReservationProduct, "milk", 1, true)
+	check(t, err)
+	_, err = s.AdjustProductStock(ctx, "milk", 1)
+	check(t, err)
+	if got := status(t, s, "w-alice"); got != models.StatusWaitlist {
+		t.Errorf("w-alice, waiting for a full slot, is %s", got)
+	}
+	if got := status(t, s, "w-bob"); got != models.StatusConfirmed {
+		t.Errorf("w-bob is %s, want confirmed", got)
+	}
+
+	// A window without a capacity takes any number of reservations
+	for _, id := range []string{"u1", "u2"} {
+		unlimited := slot()
+		unlimited.Start, unlimited.Capacity = base.Add(48*time.Hour), 0
+		r = &models.Reservation{ID: id, CustomerID: "alice", ItemID: "eggs", Type: models.ReservationProduct, Timestamp: base, Pickup: unlimited}
+		check(t, s.ReserveItem(ctx, r))
+	}
+}
+
+func testPickupSlotConcurrently(t *testing.T, s store.Repository) {
+	const capacity, customers = 3, 12
+	addCustomer(t, s, "alice", 0)
+	for i := range customers {
+		addProduct(t, s, fmt.Sprintf("p%d", i), 1)
+	}
+
+	// Each reservation locks a different product, so only the slot keeps
+	// them from overbooking it
+	var wg sync.WaitGroup
+	errs := make([]error, customers)
+	for i := range customers {
+		wg.Go(func() {
+			errs[i] = s.ReserveItem(ctx, &models.Reservation{
+				ID: fmt.Sprintf("r%d", i), CustomerID: "alice", ItemID: fmt.Sprintf("p%d", i), Type: models.ReservationProduct, Timestamp: base,
+				Pickup: &models.PickupSlot{LocationID: "barn", Start: base.Add(24 * time.Hour), End: base.Add(25 * time.Hour), Capacity: capacity},
+			})
+		})
+	}
+	wg.Wait()
+
+	confirmed := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			confirmed++
+		case !errors.Is(err, store.ErrSlotFull):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if confirmed != capacity {
+		t.Errorf("%d reservations confirmed into a slot for %d", confirmed, capacity)
+	}
+}
+
+func testWaitlist(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addCustomer(t, s, "bob", 0)
+	addCustomer(t, s, "carol", 1000)
+	addActivity(t, s, "tour", 1)
+
+	mustReserve(t, s, "r1", "alice", models.ReservationActivity, "tour", 0)
+	for i, id := range []string{"bob", "carol"} {
+		r, err := reserve(s, "w-"+id, id, models.ReservationActivity, "tour", i+1, true)
+		check(t, err)
+		if r.Status != models.StatusWaitlist {
+			t.Errorf("%s's reservation is %s, want waitlist", id, r.Status)
+		}
+	}
+
+	// Carol joined later but outranks Bob
+	_, err := s.TransitionReservation(ctx, "r1", models.StatusCancelled, "alice")
+	check(t, err)
+	promoted, err := s.GetReservation(ctx, "w-carol")
+	check(t, err)
+	if promoted.Status != models.StatusConfirmed {
+		t.Fatalf("carol's reservation is %s, want confirmed", promoted.Status)
+	}
+	if last := promoted.History[len(promoted.History)-1]; last.From != models.StatusWaitlist || last.ActorID != models.ActorSystem {
+		t.Errorf("promotion recorded as %+v", last)
+	}
+	if got := status(t, s, "w-bob"); got != models.StatusWaitlist {
+		t.Errorf("bob's reservation is %s, want waitlist", got)
+	}
+	a, err := s.GetActivity(ctx, "tour")
+	check(t, err)
+	if a.Capacity != 0 {
+		t.Errorf("capacity = %d, want 0", a.Capacity)
+	}
+
+	// Cancelling from the waitlist frees nothing
+	_, err = s.TransitionReservation(ctx, "w-bob", models.StatusCancelled, "bob")
+	check(t, err)
+	a, err = s.GetActivity(ctx, "tour")
+	check(t, err)
+	if a.Capacity != 0 {
+		t.Errorf("capacity after a waitlist cancellation = %d, want 0", a.Capacity)
+	}
+	_, err = s.TransitionReservation(ctx, "w-carol", models.StatusCancelled, "carol")
+	check(t, err)
+	a, err = s.GetActivity(ctx, "tour")
+	check(t, err)
+	if a.Capacity != 1 {
+		t.Errorf("capacity = %d, want 1", a.Capacity)
+	}
+}
+
+func testAdjustStock(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addCustomer(t, s, "bob", 0)
+	addProduct(t, s, "eggs", 0)
+	for i, id := range []string{"alice", "bob"} {
+		_, err := reserve(s, "w-"+id, id, models.ReservationProduct, "eggs", i, true)
+		check(t, err)
+	}
+
+	p, err := s.AdjustProductStock(ctx, "eggs", 3)
+	check(t, err)
+	if p.Quantity != 1 {
+		t.Errorf("quantity after adding 3 for 2 waiting = %d, want 1", p.Quantity)
+	}
+	for _, id := range []string{"w-alice", "w-bob"} {
+		if got := status(t, s, id); got != models.StatusConfirmed {
+			t.Errorf("%s is %s, want confirmed", id, got)
+		}
+	}
+
+	_, err = s.AdjustProductStock(ctx, "eggs", -2)
+	wantErr(t, err, store.ErrOutOfStock)
+	p, err = s.AdjustProductStock(ctx, "eggs", -1)
+	check(t, err)
+	if p.Quantity != 0 {
+		t.Errorf("quantity = %d, want 0", p.Quantity)
+	}
+	_, err = s.AdjustProductStock(ctx, "nothing", 1)
+	wantErr(t, err, store.ErrNotFound)
+
+	addActivity(t, s, "tour", 1)
+	_, err = s.AdjustActivityCapacity(ctx, "tour", -2)
+	wantErr(t, err, store.ErrFullyBooked)
+	a, err := s.AdjustActivityCapacity(ctx, "tour", 2)
+	check(t, err)
+	if a.Capacity != 3 {
+		t.Errorf("capacity = %d, want 3", a.Capacity)
+	}
+}
+
+// testRaiseStock checks that edits raising stock or capacity, like
+// adjustments, confirm waitlisted reservations before adding units.
+func testRaiseStock(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addCustomer(t, s, "bob", 0)
+	addCustomer(t, s, "carol", 0)
+	addProduct(t, s, "eggs", 0)
+	addActivity(t, s, "tour", 0)
+	waitFor := func(id, customerID string, typ models.ReservationType, itemID string) {
+		t.Helper()
+		_, err := reserve(s, id, customerID, typ, itemID, 0, true)
+		check(t, err)
+	}
+	waitFor("eggs-alice", "alice", models.ReservationProduct, "eggs")
+	waitFor("eggs-bob", "bob", models.ReservationProduct, "eggs")
+	waitFor("eggs-carol", "carol", models.ReservationProduct, "eggs")
+	waitFor("tour-alice", "alice", models.ReservationActivity, "tour")
+
+	p, err := s.PatchProduct(ctx, "eggs", 0, func(p *models.Product) error {
+		p.Quantity = 1
+		return nil
+	})
+	check(t, err)
+	if p.Quantity != 0 || status(t, s, "eggs-alice") != models.StatusConfirmed {
+		t.Errorf("after patching in 1 for 3 waiting: quantity %d, eggs-alice %s", p.Quantity, status(t, s, "eggs-alice"))
+	}
+	update := &models.Product{ID: "eggs", Name: "Eggs", Quantity: 1, Visible: true}
+	check(t, s.UpdateProduct(ctx, update))
+	if update.Quantity != 0 || status(t, s, "eggs-bob") != models.StatusConfirmed {
+		t.Errorf("after updating to 1 for 2 waiting: quantity %d, eggs-bob %s", update.Quantity, status(t, s, "eggs-bob"))
+	}
+	current, err := s.GetProduct(ctx, "eggs")
+	check(t, err)
+	result, err := s.ImportProducts(ctx, []*models.Product{{ID: "eggs", Name: "Eggs", Quantity: 3, Visible: true, Version: current.Version}}, false)
+	check(t, err)
+	if len(result.Errors) != 0 {
+		t.Fatalf("import result = %+v", result)
+	}
+	if q := quantity(t, s, "eggs"); q != 2 || status(t, s, "eggs-carol") != models.StatusConfirmed {
+		t.Errorf("after importing 3 for 1 waiting: quantity %d, eggs-carol %s", q, status(t, s, "eggs-carol"))
+	}
+
+	a, err := s.PatchActivity(ctx, "tour", 0, func(a *models.Activity) error {
+		a.Capacity = 2
+		return nil
+	})
+	check(t, err)
+	if a.Capacity != 1 || status(t, s, "tour-alice") != models.StatusConfirmed {
+		t.Errorf("after patching in 2 places for 1 waiting: capacity %d, tour-alice %s", a.Capacity, status(t, s, "tour-alice"))
+	}
+}
+
+func testTransitions(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addProduct(t, s, "eggs", 2)
+	addActivity(t, s, "tour", 2)
+	mustReserve(t, s, "p1", "alice", models.ReservationProduct, "eggs", 0)
+	mustReserve(t, s, "a1", "alice", models.ReservationActivity, "tour", 0)
+
+	_, err := s.TransitionReservation(ctx, "p1", models.StatusCheckedIn, "staff")
+	wantErr(t, err, store.ErrInvalidTransition)
+	_, err = s.TransitionReservation(ctx, "a1", models.StatusFulfilled, "staff")
+	wantErr(t, err, store.ErrInvalidTransition)
+	_, err = s.TransitionReservation(ctx, "nothing", models.StatusCancelled, "staff")
+	wantErr(t, err, store.ErrNotFound)
+
+	r, err := s.TransitionReservation(ctx, "p1", models.StatusFulfilled, "staff")
+	check(t, err)
+	if r.Status != models.StatusFulfilled || len(r.History) != 2 || r.History[1].ActorID != "staff" {
+		t.Errorf("after fulfilment = %+v", r)
+	}
+	_, err = s.TransitionReservation(ctx, "p1", models.StatusCancelled, "staff")
+	wantErr(t, err, store.ErrInvalidTransition)
+	if q := quantity(t, s, "eggs"); q != 1 {
+		t.Errorf("quantity after fulfilment = %d, want 1", q)
+	}
+
+	_, err = s.TransitionReservation(ctx, "a1", models.StatusCheckedIn, "staff")
+	check(t, err)
+	check(t, s.DeleteReservation(ctx, "a1"))
+	_, err = s.GetReservation(ctx, "a1")
+	wantErr(t, err, store.ErrNotFound)
+	wantErr(t, s.DeleteReservation(ctx, "a1"), store.ErrNotFound)
+}
+
+func testNoShows(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 120)
+	addProduct(t, s, "eggs", 3)
+	mustReserve(t, s, "r1", "alice", models.ReservationProduct, "eggs", 0)
+	mustReserve(t, s, "r2", "alice", models.ReservationProduct, "eggs", 1)
+
+	_, err := s.TransitionReservation(ctx, "r1", models.StatusNoShow, "staff")
+	check(t, err)
+	c, err := s.GetCustomer(ctx, "alice")
+	check(t, err)
+	if c.Credits != 70 || c.Rank != models.RankBronze || c.NoShowCount != 1 || c.BannedUntil != nil {
+		t.Errorf("after one no-show = %+v; want 70 credits, Bronze, 1 no-show, no ban", c)
+	}
+	if q := quantity(t, s, "eggs"); q != 2 {
+		t.Errorf("quantity after a no-show = %d, want 2", q)
+	}
+
+	_, err = s.TransitionReservation(ctx, "r2", models.StatusExpired, models.ActorSystem)
+	check(t, err)
+	c, err = s.GetCustomer(ctx, "alice")
+	check(t, err)
+	if c.Credits != 20 || c.NoShowCount != 2 || c.BannedUntil == nil {
+		t.Errorf("after two no-shows = %+v; want 20 credits, 2 no-shows, banned", c)
+	}
+	if c.BannedUntil != nil && !c.Banned(time.Now()) {
+		t.Errorf("banned until %v, which has passed", c.BannedUntil)
+	}
+
+	history, err := s.GetCreditHistory(ctx, "alice")
+	check(t, err)
+	if len(history) != 2 || history[0].Reason != models.CreditReasonNoShow || history[1].Previous != 70 {
+		t.Errorf("credit history = %+v", history)
+	}
+
+	c, err = s.ClearCustomerBan(ctx, "alice")
+	check(t, err)
+	if c.BannedUntil != nil {
+		t.Error("ban not cleared")
+	}
+}
+
+func testActiveReservations(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addProduct(t, s, "eggs", 1)
+	addActivity(t, s, "tour", 1)
+	mustReserve(t, s, "r1", "alice", models.ReservationProduct, "eggs", 0)
+	mustReserve(t, s, "r2", "alice", models.ReservationActivity, "tour", 0)
+
+	wantErr(t, s.DeleteProduct(ctx, "eggs"), store.ErrActiveReservations)
+	wantErr(t, s.DeleteActivity(ctx, "tour"), store.ErrActiveReservations)
+	wantErr(t, s.DeleteCustomer(ctx, "alice"), store.ErrActiveReservations)
+
+	for _, id := range []string{"r1", "r2"} {
+		_, err := s.TransitionReservation(ctx, id, models.StatusCancelled, "alice")
+		check(t, err)
+	}
+	check(t, s.DeleteProduct(ctx, "eggs"))
+	check(t, s.DeleteActivity(ctx, "tour"))
+	check(t, s.DeleteCustomer(ctx, "alice"))
+}
+
+func testOutbox(t *testing.T, s store.Repository) {
+	latest, err := s.GetLatestOutboxEventID(ctx)
+	check(t, err)
+	if latest != 0 {
+		t.Errorf("latest event in an empty store = %d, want 0", latest)
+	}
+
+	addCustomer(t, s, "alice", 0)
+	addProduct(t, s, "eggs", 1)
+	mustReserve(t, s, "r1", "alice", models.ReservationProduct, "eggs", 0)
+
+	// A failed reservation publishes nothing
+	_, err = reserve(s, "r2", "alice", models.ReservationProduct, "eggs", 1, false)
+	wantErr(t, err, store.ErrOutOfStock)
+
+	events, err := s.GetOutboxEvents(ctx, "test", 100)
+	check(t, err)
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []string{models.EventInventoryChanged, models.EventReservationCreated, models.EventInventoryChanged, models.EventProductOutOfStock}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	var change models.InventoryChange
+	check(t, json.Unmarshal(events[2].Payload, &change))
+	if change.ItemID != "eggs" || change.Available != 0 {
+		t.Errorf("inventory change = %+v", change)
+	}
+
+	check(t, s.AdvanceOutboxCursor(ctx, "test", events[1].ID))
+	rest, err := s.GetOutboxEvents(ctx, "test", 1)
+	check(t, err)
+	if len(rest) != 1 || rest[0].ID != events[2].ID {
+		t.Errorf("events after the cursor = %+v", rest)
+	}
+	other, err := s.GetOutboxEvents(ctx, "other", 100)
+	check(t, err)
+	if len(other) != len(events) {
+		t.Errorf("another consumer sees %d events, want %d", len(other), len(events))
+	}
+	after, err := s.GetOutboxEventsAfter(ctx, events[2].ID, 100)
+	check(t, err)
+	if len(after) != 1 {
+		t.Errorf("GetOutboxEventsAfter returned %d events, want 1", len(after))
+	}
+	latest, err = s.GetLatestOutboxEventID(ctx)
+	check(t, err)
+	if latest != events[3].ID {
+		t.Errorf("latest event = %d, want %d", latest, events[3].ID)
+	}
+}
+
+func testOutboxLeases(t *testing.T, s store.Repository) {
+	lease := func(holder string, now time.Time) bool {
+		t.Helper()
+		ok, err := s.LeaseOutboxConsumer(ctx, "test", holder, now, now.Add(time.Minute))
+		check(t, err)
+		return ok
+	}
+	if !lease("a", base) {
+		t.Fatal("first lease refused")
+	}
+	if lease("b", base.Add(30*time.Second)) {
+		t.Error("lease taken while another holder's was current")
+	}
+	if !lease("a", base.Add(30*time.Second)) {
+		t.Error("holder couldn't renew its own lease")
+	}
+	if ok, err := s.LeaseOutboxConsumer(ctx, "other", "b", base, base.Add(time.Minute)); err != nil || !ok {
+		t.Errorf("lease on another consumer = %v, %v", ok, err)
+	}
+	if !lease("b", base.Add(2*time.Minute)) {
+		t.Error("lapsed lease not taken over")
+	}
+	if lease("a", base.Add(2*time.Minute)) {
+		t.Error("former holder renewed a lease it lost")
+	}
+
+	// Leasing leaves the cursor where it was
+	addProduct(t, s, "eggs", 1)
+	events, err := s.GetOutboxEvents(ctx, "test", 100)
+	check(t, err)
+	if len(events) != 1 {
+		t.Fatalf("events after leasing = %d, want 1", len(events))
+	}
+	check(t, s.AdvanceOutboxCursor(ctx, "test", events[0].ID))
+	if !lease("b", base.Add(2*time.Minute)) {
+		t.Error("lease lost after advancing the cursor")
+	}
+	events, err = s.GetOutboxEvents(ctx, "test", 100)
+	check(t, err)
+	if len(events) != 0 {
+		t.Errorf("events after the cursor moved and the lease was renewed = %d, want 0", len(events))
+	}
+}
+
+func testPurgeOutbox(t *testing.T, s store.Repository) {
+	addProduct(t, s, "eggs", 1)
+	addProduct(t, s, "milk", 1)
+	check(t, s.AddWebhook(ctx, &models.Webhook{ID: "w1", URL: "https://example.com/hook", Active: true, CreatedAt: base}))
+	old := base.Add(-48 * time.Hour)
+	var deliveries []*models.WebhookDelivery
+	for i, status := range []string{models.DeliveryDelivered, models.DeliveryFailed, models.DeliveryPending} {
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			WebhookID: "w1", EventID: int64(i + 1), EventType: models.EventInventoryChanged, Payload: json.RawMessage(`{}`),
+			Status: status, NextAttemptAt: old, CreatedAt: old,
+		})
+	}
+	deliveries = append(deliveries, &models.WebhookDelivery{
+		WebhookID: "w1", EventID: 4, EventType: models.EventInventoryChanged, Payload: json.RawMessage(`{}`),
+		Status: models.DeliveryDelivered, NextAttemptAt: base, CreatedAt: base,
+	})
+	check(t, s.AddWebhookDeliveries(ctx, deliveries))
+
+	// Pending deliveries, and anything from the last hour, are kept
+	n, err := s.PurgeOutbox(ctx, base.Add(-time.Hour))
+	check(t, err)
+	if n != 2 {
+		t.Errorf("purged %d, want the 2 finished deliveries from two days ago", n)
+	}
+	left, err := s.GetWebhookDeliveries(ctx, "w1", 10)
+	check(t, err)
+	if len(left) != 2 {
+		t.Errorf("%d deliveries left, want the pending one and the recent one", len(left))
+	}
+	events, err := s.GetOutboxEventsAfter(ctx, 0, 100)
+	check(t, err)
+	if len(events) != 2 {
+		t.Fatalf("%d events left, want 2", len(events))
+	}
+
+	latest, err := s.GetLatestOutboxEventID(ctx)
+	check(t, err)
+	_, err = s.PurgeOutbox(ctx, time.Now().Add(time.Hour))
+	check(t, err)
+	addProduct(t, s, "jam", 1)
+	events, err = s.GetOutboxEventsAfter(ctx, latest, 100)
+	check(t, err)
+	if len(events) != 1 || events[0].ID <= latest {
+		t.Errorf("events recorded after a purge = %+v; want one with an ID above %d", events, latest)
+	}
+}
+
+// testOutboxCommitOrder commits an event after one with a higher ID, as two
+// concurrent reservations can, and checks consumers still see both.
+func testOutboxCommitOrder(t *testing.T, s store.Repository, db *sql.DB) {
+	addProduct(t, s, "eggs", 1)
+	seen := map[string]bool{}
+	var relayed int64
+	consume := func() {
+		t.Helper()
+		events, err := s.GetOutboxEvents(ctx, "test", 100)
+		check(t, err)
+		for _, e := range events {
+			seen[e.Type] = true
+		}
+		if len(events) > 0 {
+			check(t, s.AdvanceOutboxCursor(ctx, "test", events[len(events)-1].ID))
+		}
+		// The inventory stream keeps its cursor in memory instead
+		after, err := s.GetOutboxEventsAfter(ctx, relayed, 100)
+		check(t, err)
+		if len(after) > 0 {
+			relayed = after[len(after)-1].ID
+		}
+	}
+	consume()
+
+	tx, err := db.BeginTx(ctx, nil)
+	check(t, err)
+	defer tx.Rollback()
+	// No placeholders, which differ between databases
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox_events (type, payload, created_at) VALUES ('test.slow', '{}', '"+
+		time.Now().UTC().Format("2006-01-02 15:04:05")+"')")
+	check(t, err)
+
+	addCustomer(t, s, "alice", 0)
+	mustReserve(t, s, "r1", "alice", models.ReservationProduct, "eggs", 0)
+	consume()
+	if seen[models.EventReservationCreated] {
+		t.Error("events after an uncommitted one were passed")
+	}
+
+	check(t, tx.Commit())
+	consume()
+	for _, typ := range []string{"test.slow", models.EventReservationCreated} {
+		if !seen[typ] {
+			t.Errorf("%s event never seen", typ)
+		}
+	}
+	latest, err := s.GetLatestOutboxEventID(ctx)
+	check(t, err)
+	if relayed != latest {
+		t.Errorf("relay cursor = %d, want %d", relayed, latest)
+	}
+}
+
+func testAudit(t *testing.T, s store.Repository) {
+	audited := func(action string) store.Repository {
+		return s.WithAudit(&models.AuditEntry{ActorID: "admin", Action: action, TargetType: models.AuditTargetProduct, RequestID: "req", ClientRequestID: "client-req"})
+	}
+	check(t, audited(models.AuditActionCreate).AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Quantity: 1}))
+	_, err := audited(models.AuditActionUpdate).PatchProduct(ctx, "eggs", 0, func(p *models.Product) error {
+		p.Quantity = 2
+		return nil
+	})
+	check(t, err)
+	addProduct(t, s, "milk", 1) // Not audited
+
+	entries, err := s.GetAuditEntries(ctx, models.AuditFilter{Limit: 10})
+	check(t, err)
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2", len(entries))
+	}
+	update, create := entries[0], entries[1]
+	if update.Action != models.AuditActionUpdate || update.TargetID != "eggs" || update.RequestID != "req" || update.ClientRequestID != "client-req" || update.ID <= create.ID {
+		t.Errorf("newest entry = %+v", update)
+	}
+	if create.Before != nil || create.After == nil {
+		t.Errorf("create entry has before %s, after %s", create.Before, create.After)
+	}
+	var before, after models.Product
+	check(t, json.Unmarshal(update.Before, &before))
+	check(t, json.Unmarshal(update.After, &after))
+	if before.Quantity != 1 || after.Quantity != 2 {
+		t.Errorf("update snapshots have quantity %d -> %d, want 1 -> 2", before.Quantity, after.Quantity)
+	}
+
+	older, err := s.GetAuditEntries(ctx, models.AuditFilter{BeforeID: update.ID, Limit: 10})
+	check(t, err)
+	if len(older) != 1 || older[0].ID != create.ID {
+		t.Errorf("entries before %d = %+v", update.ID, older)
+	}
+	creates, err := s.GetAuditEntries(ctx, models.AuditFilter{Action: models.AuditActionCreate, Limit: 10})
+	check(t, err)
+	if len(creates) != 1 {
+		t.Errorf("got %d create entries, want 1", len(creates))
+	}
+
+	// A failed mutation leaves no entry
+	_, err = audited(models.AuditActionUpdate).PatchProduct(ctx, "eggs", 1, func(*models.Product) error { return nil })
+	wantErr(t, err, store.ErrStale)
+	entries, err = s.GetAuditEntries(ctx, models.AuditFilter{Limit: 10})
+	check(t, err)
+	if len(entries) != 2 {
+		t.Errorf("got %d audit entries after a failed update, want 2", len(entries))
+	}
+}
+
+func testImport(t *testing.T, s store.Repository) {
+	addProduct(t, s, "eggs", 1)
+	rows := []*models.Product{
+		{ID: "eggs", Name: "Eggs", Quantity: 5, Visible: true, Version: 1},
+		{ID: "milk", Name: "Milk", Quantity: 2, Visible: true},
+	}
+
+	result, err := s.ImportProducts(ctx, rows, true)
+	check(t, err)
+	if !result.DryRun || result.Created != 1 || result.Updated != 1 || len(result.Errors) != 0 {
+		t.Errorf("dry run result = %+v", result)
+	}
+	if q := quantity(t, s, "eggs"); q != 1 {
+		t.Errorf("dry run changed quantity to %d", q)
+	}
+	_, err = s.GetProduct(ctx, "milk")
+	wantErr(t, err, store.ErrNotFound)
+
+	addProduct(t, s, "gone", 1)
+	check(t, s.DeleteProduct(ctx, "gone"))
+	result, err = s.ImportProducts(ctx, append(rows, &models.Product{ID: "gone", Name: "Gone"}), false)
+	check(t, err)
+	if len(result.Errors) != 1 || result.Errors[0].Row != 3 {
+		t.Errorf("import with a deleted row = %+v", result)
+	}
+	_, err = s.GetProduct(ctx, "milk")
+	wantErr(t, err, store.ErrNotFound)
+
+	result, err = s.ImportProducts(ctx, rows, false)
+	check(t, err)
+	if result.Created != 1 || result.Updated != 1 {
+		t.Errorf("import result = %+v", result)
+	}
+	if q := quantity(t, s, "eggs"); q != 5 {
+		t.Errorf("quantity after import = %d, want 5", q)
+	}
+
+	// Eggs are at version 2 now
+	result, err = s.ImportProducts(ctx, rows[:1], false)
+	check(t, err)
+	if len(result.Errors) != 1 || result.Errors[0].Field != "version" {
+		t.Errorf("import of a stale row = %+v", result)
+	}
+	result, err = s.ImportProducts(ctx, []*models.Product{{ID: "eggs", Name: "Eggs", Quantity: 7, Visible: true}}, false)
+	check(t, err)
+	if len(result.Errors) != 1 || result.Errors[0].Field != "quantity" {
+		t.Errorf("import changing quantity without a version = %+v", result)
+	}
+	result, err = s.ImportProducts(ctx, []*models.Product{{ID: "eggs", Name: "Eggs", Description: "Free range", Quantity: 5, Visible: true}}, false)
+	check(t, err)
+	if result.Updated != 1 || len(result.Errors) != 0 {
+		t.Errorf("import keeping quantity without a version = %+v", result)
+	}
+
+	addCustomer(t, s, "alice", 10)
+	result, err = s.ImportCustomers(ctx, []*models.Customer{
+		{ID: "alice", Email: "alice@example.com", Name: "Alice", Credits: 200, Role: models.RoleCustomer},
+		{ID: "bob", Email: "bob@example.com", Name: "Bob", Credits: 0, Role: models.RoleCustomer, Password: "hash", Salt: "salt"},
+	}, false)
+	check(t, err)
+	if result.Created != 1 || result.Updated != 1 || len(result.Errors) != 0 {
+		t.Errorf("customer import result = %+v", result)
+	}
+	c, err := s.GetCustomer(ctx, "alice")
+	check(t, err)
+	if c.Credits != 200 || c.Rank != models.RankSilver || c.Name != "Alice" {
+		t.Errorf("imported customer = %+v", c)
+	}
+	result, err = s.ImportCustomers(ctx, []*models.Customer{{ID: "carol", Email: "bob@example.com", Name: "Carol"}}, false)
+	check(t, err)
+	if len(result.Errors) != 1 || result.Errors[0].Field != "email" {
+		t.Errorf("import with a taken email = %+v", result)
+	}
+
+	var names []string
+	check(t, s.EachProduct(ctx, func(p *models.Product) error {
+		names = append(names, p.Name)
+		return nil
+	}))
+	if fmt.Sprint(names) != "[Eggs Milk]" {
+		t.Errorf("EachProduct visited %v, want [Eggs Milk]", names)
+	}
+	errStop := errors.New("stop")
+	visits := 0
+	err = s.EachCustomer(ctx, func(*models.Customer) error {
+		visits++
+		return errStop
+	})
+	wantErr(t, err, errStop)
+	if visits != 1 {
+		t.Errorf("EachCustomer visited %d customers after an error, want 1", visits)
+	}
+}
+
+func testErase(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addProduct(t, s, "eggs", 1)
+	mustReserve(t, s, "r1", "alice", models.ReservationProduct, "eggs", 0)
+	_, err := s.TransitionReservation(ctx, "r1", models.StatusFulfilled, "alice")
+	check(t, err)
+	audited := s.WithAudit(&models.AuditEntry{ActorID: "alice", Action: "update", TargetType: models.AuditTargetCustomer})
+	_, err = audited.UpdateCustomerCredits(ctx, "alice", 5, 0)
+	check(t, err)
+	check(t, s.AddNotification(ctx, &models.Notification{ID: "n1", CustomerID: "alice", Subject: "Hi", CreatedAt: base}))
+	check(t, s.AddWebhook(ctx, &models.Webhook{ID: "w1", URL: "https://example.com/hook", Active: true, CreatedAt: base}))
+	check(t, s.AddWebhookDeliveries(ctx, []*models.WebhookDelivery{{
+		WebhookID: "w1", EventID: 1, EventType: models.EventReservationCreated,
+		Payload: json.RawMessage(`{"customer_id":"alice","email":"alice@example.com"}`),
+		Status:  models.DeliveryPending, NextAttemptAt: base, CreatedAt: base,
+	}}))
+	k := &models.IdempotencyKey{CustomerID: "alice", Key: "k1", RequestHash: "hash", CreatedAt: base, ExpiresAt: base.Add(time.Hour)}
+	_, err = s.ClaimIdempotencyKey(ctx, k)
+	check(t, err)
+	k.Status, k.Body = 200, []byte(`{"email":"alice@example.com"}`)
+	check(t, s.CompleteIdempotencyKey(ctx, k))
+
+	at := base
+	c, err := s.ScheduleErasure(ctx, "alice", &at)
+	check(t, err)
+	if c.EraseAfter == nil || !c.EraseAfter.Equal(base) {
+		t.Errorf("EraseAfter = %v, want %v", c.EraseAfter, base)
+	}
+	due, err := s.GetCustomersDueForErasure(ctx, base.Add(time.Minute))
+	check(t, err)
+	if len(due) != 1 {
+		t.Errorf("%d customers due for erasure, want 1", len(due))
+	}
+
+	pseudonym, err := s.EraseCustomer(ctx, "alice")
+	check(t, err)
+	_, err = s.GetCustomerByEmail(ctx, "alice@example.com")
+	wantErr(t, err, store.ErrNotFound)
+	r, err := s.GetReservation(ctx, "r1")
+	check(t, err)
+	if r.CustomerID != pseudonym || r.History[0].ActorID != pseudonym || r.History[1].ActorID != pseudonym {
+		t.Errorf("reservation after erasure = %+v; want it re-keyed to %s", r, pseudonym)
+	}
+	history, err := s.GetCreditHistory(ctx, pseudonym)
+	check(t, err)
+	if len(history) != 1 {
+		t.Errorf("%d credit changes re-keyed, want 1", len(history))
+	}
+	notifications, err := s.GetNotifications(ctx, pseudonym, false)
+	check(t, err)
+	if len(notifications) != 0 {
+		t.Errorf("%d notifications kept, want 0", len(notifications))
+	}
+
+	// Nothing stored may still name her
+	entries, err := s.GetAuditEntries(ctx, models.AuditFilter{TargetType: models.AuditTargetCustomer, Limit: 10})
+	check(t, err)
+	if len(entries) != 1 || entries[0].TargetID != pseudonym || entries[0].ActorID != pseudonym {
+		t.Errorf("audit entries after erasure = %+v; want one re-keyed to %s", entries, pseudonym)
+	}
+	var documents []json.RawMessage
+	for _, e := range entries {
+		documents = append(documents, e.Before, e.After)
+	}
+	events, err := s.GetOutboxEvents(ctx, "test", 100)
+	check(t, err)
+	if len(events) == 0 {
+		t.Error("no events recorded for the reservation")
+	}
+	for _, e := range events {
+		documents = append(documents, e.Payload)
+	}
+	deliveries, err := s.GetWebhookDeliveries(ctx, "w1", 10)
+	check(t, err)
+	for _, d := range deliveries {
+		documents = append(documents, d.Payload)
+	}
+	for _, doc := range documents {
+		if strings.Contains(string(doc), "alice") {
+			t.Errorf("document kept after erasure: %s", doc)
+		}
+	}
+	existing, err := s.ClaimIdempotencyKey(ctx, k)
+	check(t, err)
+	if existing != nil {
+		t.Errorf("idempotency key kept after erasure: %+v", existing)
+	}
+
+	_, err = s.EraseCustomer(ctx, "alice")
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func testEraseCancelsReservations(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addCustomer(t, s, "bob", 0)
+	addProduct(t, s, "eggs", 1)
+	addActivity(t, s, "tour", 2)
+	mustReserve(t, s, "r1", "alice", models.ReservationProduct, "eggs", 0)
+	_, err := reserve(s, "w1", "alice", models.ReservationProduct, "eggs", 1, true)
+	check(t, err)
+	_, err = reserve(s, "w2", "bob", models.ReservationProduct, "eggs", 2, true)
+	check(t, err)
+	mustReserve(t, s, "r2", "alice", models.ReservationActivity, "tour", 0)
+	mustReserve(t, s, "r3", "alice", models.ReservationActivity, "tour", 1)
+	_, err = s.TransitionReservation(ctx, "r3", models.StatusCheckedIn, "staff")
+	check(t, err)
+
+	pseudonym, err := s.EraseCustomer(ctx, "alice")
+	check(t, err)
+	// Her waitlisted reservation, confirmed when r1 was cancelled, is
+	// cancelled too, and the unit passes on to bob
+	for id, want := range map[string]models.ReservationStatus{
+		"r1": models.StatusCancelled, "w1": models.StatusCancelled, "r2": models.StatusCancelled,
+		"r3": models.StatusCheckedIn, "w2": models.StatusConfirmed,
+	} {
+		r, err := s.GetReservation(ctx, id)
+		check(t, err)
+		if r.Status != want {
+			t.Errorf("%s is %s, want %s", id, r.Status, want)
+		}
+		if r.CustomerID == "alice" || (id != "w2" && r.CustomerID != pseudonym) {
+			t.Errorf("%s belongs to %s after erasure", id, r.CustomerID)
+		}
+	}
+	if q := quantity(t, s, "eggs"); q != 0 {
+		t.Errorf("quantity = %d, want 0", q)
+	}
+	a, err := s.GetActivity(ctx, "tour")
+	check(t, err)
+	if a.Capacity != 1 {
+		t.Errorf("capacity = %d, want 1", a.Capacity)
+	}
+}
+
+func testPurge(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addProduct(t, s, "eggs", 1)
+	addProduct(t, s, "milk", 1)
+	mustReserve(t, s, "r1", "alice", models.ReservationProduct, "milk", 0)
+	_, err := s.TransitionReservation(ctx, "r1", models.StatusCancelled, "alice")
+	check(t, err)
+	check(t, s.DeleteProduct(ctx, "eggs"))
+	check(t, s.DeleteProduct(ctx, "milk"))
+
+	n, err := s.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+	check(t, err)
+	if n != 0 {
+		t.Errorf("purged %d rows deleted after the cutoff", n)
+	}
+	n, err = s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+	check(t, err)
+	if n != 1 {
+		t.Errorf("purged %d rows, want 1", n)
+	}
+	_, err = s.GetProduct(ctx, "eggs")
+	wantErr(t, err, store.ErrNotFound)
+	// Milk is still referenced by a reservation
+	_, err = s.GetProduct(ctx, "milk")
+	check(t, err)
+}
+
+func testNotifications(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addCustomer(t, s, "bob", 0)
+	for i, id := range []string{"n1", "n2", "n3"} {
+		check(t, s.AddNotification(ctx, &models.Notification{ID: id, CustomerID: "alice", Event: "test", Subject: id, CreatedAt: base.Add(time.Duration(i) * time.Minute)}))
+	}
+
+	check(t, s.MarkNotificationRead(ctx, "alice", "n2"))
+	wantErr(t, s.MarkNotificationRead(ctx, "bob", "n1"), store.ErrNotFound)
+	wantErr(t, s.MarkNotificationRead(ctx, "alice", "nothing"), store.ErrNotFound)
+
+	unread, err := s.GetNotifications(ctx, "alice", true)
+	check(t, err)
+	if len(unread) != 2 || unread[0].ID != "n3" || unread[1].ID != "n1" {
+		t.Errorf("unread notifications = %+v; want n3, n1", unread)
+	}
+	all, err := s.GetNotifications(ctx, "alice", false)
+	check(t, err)
+	if len(all) != 3 || all[1].ReadAt == nil {
+		t.Errorf("notifications = %+v", all)
+	}
+
+	check(t, s.MarkAllNotificationsRead(ctx, "alice"))
+	unread, err = s.GetNotifications(ctx, "alice", true)
+	check(t, err)
+	if len(unread) != 0 {
+		t.Errorf("%d notifications unread after marking all read", len(unread))
+	}
+}
+
+func testWebhooks(t *testing.T, s store.Repository) {
+	w := &models.Webhook{ID: "w1", URL: "https://example.com/hook", Secret: "secret", Events: []string{models.EventReservationCreated}, Active: true, CreatedAt: base}
+	check(t, s.AddWebhook(ctx, w))
+	wantErr(t, s.AddWebhook(ctx, w), store.ErrConflict)
+	got, err := s.GetWebhook(ctx, "w1")
+	check(t, err)
+	if got.Secret != "secret" || len(got.Events) != 1 || !got.Active {
+		t.Errorf("GetWebhook = %+v", got)
+	}
+
+	delivery := func(eventID int64, next time.Time) *models.WebhookDelivery {
+		return &models.WebhookDelivery{
+			WebhookID: "w1", EventID: eventID, EventType: models.EventReservationCreated, Payload: json.RawMessage(`{}`),
+			Status: models.DeliveryPending, NextAttemptAt: next, CreatedAt: base,
+		}
+	}
+	check(t, s.AddWebhookDeliveries(ctx, []*models.WebhookDelivery{delivery(1, base.Add(time.Minute)), delivery(2, base)}))
+	check(t, s.AddWebhookDeliveries(ctx, []*models.WebhookDelivery{delivery(1, base)})) // Replayed
+
+	due, err := s.GetDueWebhookDeliveries(ctx, base.Add(time.Hour), 10)
+	check(t, err)
+	if len(due) != 2 || due[0].EventID != 2 {
+		t.Fatalf("due deliveries = %+v; want events 2, 1", due)
+	}
+	// Whoever claims a due delivery first has it until the claim lapses
+	ok, err := s.ClaimWebhookDelivery(ctx, due[1].ID, base.Add(time.Hour), base.Add(2*time.Hour))
+	check(t, err)
+	if !ok {
+		t.Error("due delivery not claimed")
+	}
+	ok, err = s.ClaimWebhookDelivery(ctx, due[1].ID, base.Add(time.Hour), base.Add(2*time.Hour))
+	check(t, err)
+	if ok {
+		t.Error("delivery claimed twice")
+	}
+	if claimed, err := s.GetDueWebhookDeliveries(ctx, base.Add(time.Hour), 10); err != nil || len(claimed) != 1 {
+		t.Errorf("due deliveries while one is claimed = %d, %v; want 1", len(claimed), err)
+	}
+	due[1].NextAttemptAt = base
+	check(t, s.UpdateWebhookDelivery(ctx, due[1]))
+
+	delivered := base.Add(time.Second)
+	d := due[0]
+	d.Status, d.Attempts, d.ResponseCode, d.DeliveredAt = models.DeliveryDelivered, 1, 200, &delivered
+	check(t, s.UpdateWebhookDelivery(ctx, d))
+
+	due, err = s.GetDueWebhookDeliveries(ctx, base.Add(time.Hour), 10)
+	check(t, err)
+	if len(due) != 1 || due[0].EventID != 1 {
+		t.Errorf("due deliveries after one succeeded = %+v", due)
+	}
+	recent, err := s.GetWebhookDeliveries(ctx, "w1", 1)
+	check(t, err)
+	if len(recent) != 1 || recent[0].EventID != 2 || recent[0].DeliveredAt == nil || recent[0].ResponseCode != 200 {
+		t.Errorf("latest delivery = %+v", recent)
+	}
+
+	check(t, s.DeleteWebhook(ctx, "w1"))
+	_, err = s.GetWebhook(ctx, "w1")
+	wantErr(t, err, store.ErrNotFound)
+	wantErr(t, s.DeleteWebhook(ctx, "w1"), store.ErrNotFound)
+	all, err := s.GetWebhookDeliveries(ctx, "w1", 10)
+	check(t, err)
+	if len(all) != 0 {
+		t.Errorf("%d deliveries left after deleting the webhook", len(all))
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	k := &models.IdempotencyKey{CustomerID: "alice", Key: "k1", RequestHash: "hash", CreatedAt: base, ExpiresAt: base.Add(time.Hour)}
+
+	existing, err := s.ClaimIdempotencyKey(ctx, k)
+	check(t, err)
+	if existing != nil {
+		t.Fatalf("first claim returned %+v", existing)
+	}
+	existing, err = s.ClaimIdempotencyKey(ctx, k)
+	check(t, err)
+	if existing == nil || existing.Status != 0 || existing.RequestHash != "hash" {
+		t.Fatalf("second claim returned %+v; want the claim in progress", existing)
+	}
+
+	check(t, s.ReleaseIdempotencyKey(ctx, "alice", "k1"))
+	existing, err = s.ClaimIdempotencyKey(ctx, k)
+	check(t, err)
+	if existing != nil {
+		t.Fatalf("claim after release returned %+v", existing)
+	}
+
+	done := *k
+	done.Status, done.Headers, done.Body = 201, map[string]string{"Location": "/r/1"}, []byte(`{"id":"1"}`)
+	check(t, s.CompleteIdempotencyKey(ctx, &done))
+	check(t, s.ReleaseIdempotencyKey(ctx, "alice", "k1")) // Completed keys stay
+	existing, err = s.ClaimIdempotencyKey(ctx, k)
+	check(t, err)
+	if existing == nil || existing.Status != 201 || existing.Headers["Location"] != "/r/1" || string(existing.Body) != `{"id":"1"}` {
+		t.Errorf("claim of a completed key returned %+v", existing)
+	}
+
+	other := *k
+	other.CustomerID = "bob"
+	wantErr(t, s.CompleteIdempotencyKey(ctx, &other), store.ErrNotFound)
+
+	later := *k
+	later.CreatedAt, later.ExpiresAt = base.Add(2*time.Hour), base.Add(3*time.Hour)
+	existing, err = s.ClaimIdempotencyKey(ctx, &later)
+	check(t, err)
+	if existing != nil {
+		t.Errorf("claim of an expired key returned %+v", existing)
+	}
+
+	n, err := s.PurgeIdempotencyKeys(ctx, base.Add(4*time.Hour))
+	check(t, err)
+	if n != 1 {
+		t.Errorf("purged %d keys, want 1", n)
+	}
+}
+
+func testReports(t *testing.T, s store.Repository) {
+	addCustomer(t, s, "alice", 0)
+	addCustomer(t, s, "bob", 1000)
+	staff := &models.Customer{ID: "staff", Email: "staff@example.com", Role: models.RoleStaff}
+	check(t, s.AddCustomer(ctx, staff))
+	addProduct(t, s, "eggs", 5)
+	addActivity(t, s, "tour", 3)
+
+	// Monday 2 June and Wednesday 11 June 2025
+	mustReserve(t, s, "p1", "alice", models.ReservationProduct, "eggs", 0)
+	mustReserve(t, s, "p2", "bob", models.ReservationProduct, "eggs", 9*24*60)
+	mustReserve(t, s, "a1", "alice", models.ReservationActivity, "tour", 60)
+	mustReserve(t, s, "a2", "bob", models.ReservationActivity, "tour", 120)
+	mustReserve(t, s, "a3", "bob", models.ReservationActivity, "tour", 180)
+	transition := func(id string, to models.ReservationStatus) {
+		t.Helper()
+		_, err := s.TransitionReservation(ctx, id, to, "staff")
+		check(t, err)
+	}
+	transition("p1", models.StatusFulfilled)
+	transition("a1", models.StatusCheckedIn)
+	transition("a2", models.StatusNoShow)
+	transition("a3", models.StatusCancelled)
+
+	f := models.ReportFilter{From: base.Add(-time.Hour), To: base.Add(30 * 24 * time.Hour), Period: models.PeriodWeek, Rank: -1, Limit: 10}
+	counts, err := s.GetReservationCounts(ctx, f)
+	check(t, err)
+	var got []string
+	for _, c := range counts {
+		got = append(got, fmt.Sprintf("%s %s %d/%d", c.Period, c.ItemID, c.Reservations, c.Cancelled))
+	}
+	want := []string{"2025-06-02 tour 3/1", "2025-06-02 eggs 1/0", "2025-06-09 eggs 1/0"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("weekly counts = %v, want %v", got, want)
+	}
+	f.Period = models.PeriodMonth
+	counts, err = s.GetReservationCounts(ctx, f)
+	check(t, err)
+	if len(counts) != 2 || counts[0].Period != "2025-06-01" || counts[1].Reservations != 2 {
+		t.Errorf("monthly counts = %+v", counts)
+	}
+	f.Type = models.ReservationProduct
+	counts, err = s.GetReservationCounts(ctx, f)
+	check(t, err)
+	if len(counts) != 1 {
+		t.Errorf("product counts = %+v", counts)
+	}
+	f.Type = ""
+
+	fill, err := s.GetActivityFill(ctx, f)
+	check(t, err)
+	// Alice's seat is still taken; Bob's no-show and cancellation freed two
+	if len(fill) != 1 || fill[0].Booked != 1 || fill[0].CheckedIn != 1 || fill[0].NoShows != 1 || fill[0].SeatsLeft != 2 {
+		t.Errorf("activity fill = %+v", fill)
+	} else if fill[0].FillRate < 0.33 || fill[0].FillRate > 0.34 {
+		t.Errorf("fill rate = %v, want 1/3", fill[0].FillRate)
+	}
+
+	sell, err := s.GetProductSellThrough(ctx, f)
+	check(t, err)
+	if len(sell) != 1 || sell[0].Fulfilled != 1 || sell[0].Held != 1 || sell[0].OnHand != 3 || sell[0].SellThrough != 0.2 {
+		t.Errorf("sell-through = %+v", sell)
+	}
+
+	dist, err := s.GetRankDistribution(ctx)
+	check(t, err)
+	if len(dist) != 3 || dist[0].Customers != 1 || dist[1].Customers != 0 || dist[2].Customers != 1 || dist[2].RankName != "Gold" {
+		t.Errorf("rank distribution = %+v", dist)
+	}
+
+	rates, err := s.GetNoShowRates(ctx, f)
+	check(t, err)
+	if len(rates) != 2 || rates[0].ItemID != "tour" || rates[0].Rate != 0.5 || rates[1].ItemID != "eggs" || rates[1].Rate != 0 {
+		t.Errorf("no-show rates = %+v", rates)
+	}
+
+	top, err := s.GetTopCustomers(ctx, f)
+	check(t, err)
+	if len(top) != 2 || top[0].CustomerID != "alice" || top[0].Reservations != 2 || top[0].Completed != 2 || top[1].NoShows != 1 {
+		t.Errorf("top customers = %+v", top)
+	}
+	f.Limit = 1
+	top, err = s.GetTopCustomers(ctx, f)
+	check(t, err)
+	if len(top) != 1 {
+		t.Errorf("top customers with limit 1 = %+v", top)
+	}
+}
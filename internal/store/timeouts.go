@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// transactional lists the methods that run a transaction; they are bounded
+// by the transaction timeout rather than the query timeout.
+var transactional = map[string]bool{
+	"UpdateCustomerCredits":  true,
+	"UpdateCustomerRole":     true,
+	"ClearCustomerBan":       true,
+	"DeleteCustomer":         true,
+	"RestoreCustomer":        true,
+	"EraseCustomer":          true,
+	"AddProduct":             true,
+	"UpdateProduct":          true,
+	"PatchProduct":           true,
+	"AdjustProductStock":     true,
+	"DeleteProduct":          true,
+	"RestoreProduct":         true,
+	"AddActivity":            true,
+	"UpdateActivity":         true,
+	"PatchActivity":          true,
+	"AdjustActivityCapacity": true,
+	"DeleteActivity":         true,
+	"RestoreActivity":        true,
+	"ReserveItem":            true,
+	"TransitionReservation":  true,
+	"DeleteReservation":      true,
+	"AddWebhook":             true,
+	"DeleteWebhook":          true,
+	"AddWebhookDeliveries":   true,
+	"ClaimIdempotencyKey":    true,
+	"PurgeDeleted":           true,
+	"ImportProducts":         true,
+	"ImportActivities":       true,
+	"ImportCustomers":        true,
+}
+
+// streaming lists the methods that hand rows to a callback as they are read,
+// such as CSV exports; they run for as long as the caller's context allows.
+var streaming = map[string]bool{
+	"EachProduct":  true,
+	"EachActivity": true,
+	"EachCustomer": true,
+}
+
+// WithTimeouts bounds each call to next by the query timeout or, for calls
+// that run a transaction, the transaction timeout. A sooner deadline on the
+// caller's context still applies, and a zero timeout leaves calls unbounded.
+// Calls that run out of time fail with context.DeadlineExceeded.
+func WithTimeouts(next Repository, query, tx time.Duration) Repository {
+	return Instrument(next, func(ctx context.Context, method string) (context.Context, func(error)) {
+		d := query
+		if transactional[method] {
+			d = tx
+		}
+		if d <= 0 || streaming[method] {
+			return ctx, func(error) {}
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		return ctx, func(error) { cancel() }
+	})
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"farm/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// deadlines records the deadline each call was made with.
+type deadlines struct {
+	Repository
+	got map[string]time.Duration // Remaining time; 0 means none
+}
+
+func (d *deadlines) record(ctx context.Context, method string) {
+	var left time.Duration
+	if dl, ok := ctx.Deadline(); ok {
+		left = time.Until(dl)
+	}
+	d.got[method] = left
+}
+
+func (d *deadlines) Ping(ctx context.Context) error {
+	d.record(ctx, "Ping")
+	return nil
+}
+
+func (d *deadlines) AddProduct(ctx context.Context, p *models.Product) error {
+	d.record(ctx, "AddProduct")
+	return nil
+}
+
+func (d *deadlines) EachProduct(ctx context.Context, fn func(*models.Product) error) error {
+	d.record(ctx, "EachProduct")
+	return nil
+}
+
+func TestWithTimeouts(t *testing.T) {
+	ctx := context.Background()
+	near := func(got, want time.Duration) bool { return got > want-time.Second && got <= want }
+
+	d := &deadlines{got: map[string]time.Duration{}}
+	s := WithTimeouts(d, time.Minute, time.Hour)
+	s.Ping(ctx)
+	s.AddProduct(ctx, &models.Product{})
+	s.EachProduct(ctx, nil)
+	if !near(d.got["Ping"], time.Minute) || !near(d.got["AddProduct"], time.Hour) || d.got["EachProduct"] != 0 {
+		t.Errorf("deadlines = %v; want a minute for queries, an hour for transactions and none for streams", d.got)
+	}
+
+	// A sooner deadline from the caller wins
+	short, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	s.AddProduct(short, &models.Product{})
+	if d.got["AddProduct"] > time.Second {
+		t.Errorf("AddProduct had %s, beyond the caller's deadline", d.got["AddProduct"])
+	}
+
+	d = &deadlines{got: map[string]time.Duration{}}
+	WithTimeouts(d, 0, 0).AddProduct(ctx, &models.Product{})
+	if d.got["AddProduct"] != 0 {
+		t.Errorf("zero timeout gave AddProduct %s", d.got["AddProduct"])
+	}
+}
+
+// TestTimeoutMethodsExist guards the method lists against renames.
+func TestTimeoutMethodsExist(t *testing.T) {
+	repo := reflect.TypeFor[Repository]()
+	for _, list := range []map[string]bool{transactional, streaming} {
+		for name := range list {
+			if _, ok := repo.MethodByName(name); !ok {
+				t.Errorf("%s isn't a Repository method", name)
+			}
+		}
+	}
+}
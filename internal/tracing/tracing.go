@@ -0,0 +1,130 @@
+// Package tracing propagates W3C trace context through requests and store
+// calls and, when enabled, exports spans to an OpenTelemetry collector over
+// OTLP/HTTP.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/store"
+	"farm/internal/version"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("farm")
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. Trace and span IDs are always generated, so logs can be
+// correlated with a caller's traceparent; spans are only recorded and
+// exported when tracing is enabled. The returned function flushes spans
+// still buffered.
+func Setup(ctx context.Context, cfg *config.TracingConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithSampler(sdktrace.NeverSample())}
+	if cfg.Enabled {
+		exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, err
+		}
+		res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(version.Get().Version),
+		))
+		if err != nil {
+			return nil, err
+		}
+		opts = []sdktrace.TracerProviderOption{
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		}
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Middleware starts a server span for each request, continuing the trace in
+// the request's traceparent header if it has one.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			ctx, span := tracer.Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+				))
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+
+			status := c.Response().Status
+			var he *echo.HTTPError
+			if errors.As(err, &he) {
+				status = he.Code
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, strconv.Itoa(status))
+			}
+			return err
+		}
+	}
+}
+
+// Instrument wraps s so every call made with a traced context records a child
+// span named after the Repository method.
+func Instrument(s store.Repository, driver string) store.Repository {
+	system := semconv.DBSystemNameSQLite
+	switch driver {
+	case "postgres":
+		system = semconv.DBSystemNamePostgreSQL
+	case "mysql":
+		system = semconv.DBSystemNameMySQL
+	case "memory":
+		system = semconv.DBSystemNameKey.String("memory") // Not a database semconv knows
+	}
+	return store.Instrument(s, func(ctx context.Context, method string) (context.Context, func(error)) {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return ctx, func(error) {} // Background jobs aren't traced
+		}
+		ctx, span := tracer.Start(ctx, "store."+method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(system, semconv.DBOperationName(method)))
+		return ctx, func(err error) {
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+	})
+}
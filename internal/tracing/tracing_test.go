@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store"
+	"farm/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+// spans records every span. The package's tracer binds to the first global
+// provider installed, so there is one for all tests.
+var spans = tracetest.NewSpanRecorder()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	os.Exit(m.Run())
+}
+
+// ended returns the spans ended since the first n.
+func ended(n int) []sdktrace.ReadOnlySpan {
+	return spans.Ended()[n:]
+}
+
+func TestRequestAndStoreSpans(t *testing.T) {
+	before := len(spans.Ended())
+	s := Instrument(memory.NewMemoryStore(&config.Config{}), "memory")
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/products/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if got := trace.SpanContextFromContext(ctx).TraceID().String(); got != traceID {
+			t.Errorf("handler trace ID = %s, want the caller's", got)
+		}
+		if _, err := s.GetProduct(ctx, c.Param("id")); err != nil {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return nil
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		return s.Ping(context.Background()) // Untraced, as background jobs are
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/eggs", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	got := ended(before)
+	if len(got) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(got))
+	}
+	storeSpan, server := got[0], got[1]
+	if storeSpan.Name() != "store.GetProduct" || storeSpan.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("store span %s has parent %s", storeSpan.Name(), storeSpan.Parent().SpanID())
+	}
+	if storeSpan.Status().Code == codes.Error {
+		t.Error("not found recorded as a store error")
+	}
+	if server.Name() != "GET /products/:id" || server.SpanContext().TraceID().String() != traceID || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("server span %s in trace %s", server.Name(), server.SpanContext().TraceID())
+	}
+	var status int64
+	for _, kv := range server.Attributes() {
+		if kv.Key == "http.response.status_code" {
+			status = kv.Value.AsInt64()
+		}
+	}
+	if status != http.StatusNotFound {
+		t.Errorf("server span status code = %d, want 404", status)
+	}
+	if got[2].Name() != "GET /fail" {
+		t.Errorf("untraced store call recorded span %s", got[2].Name())
+	}
+}
+
+func TestStoreErrorsMarkSpans(t *testing.T) {
+	before := len(spans.Ended())
+	s := Instrument(memory.NewMemoryStore(&config.Config{}), "memory")
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs"}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.UpdateProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Version: 7})
+	if !errors.Is(err, store.ErrStale) {
+		t.Fatalf("stale update = %v", err)
+	}
+	span.End()
+
+	got := ended(before)[1]
+	if got.Name() != "store.UpdateProduct" || got.Status().Code != codes.Error || len(got.Events()) != 1 {
+		t.Errorf("span %s has status %v and %d events; want the error recorded", got.Name(), got.Status(), len(got.Events()))
+	}
+}
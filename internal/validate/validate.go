@@ -0,0 +1,194 @@
+// Package validate checks request payloads before they reach the store.
+// Payloads implement Validatable; Validator runs them from Echo's
+// Context.Validate and reports every failing field at once.
+package validate
+
+import (
+	"farm/internal/config"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on free-text fields.
+const (
+	MaxNameLength = 200
+	MaxTextLength = 5000
+)
+
+// FieldError describes one invalid field. Field is its JSON name, with a dot
+// for nested fields and an index for list items, such as "pickup.start" or
+// "events[2]".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is returned by Validator when any field is invalid.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Validatable is implemented by every request payload. Validate reports each
+// problem through c.
+type Validatable interface {
+	Validate(c *Checker)
+}
+
+// Validator is the Echo validator.
+type Validator struct {
+	passwords config.PasswordPolicy
+}
+
+func New(passwords config.PasswordPolicy) *Validator {
+	return &Validator{passwords: passwords}
+}
+
+// Validate checks i, which must implement Validatable so that no payload
+// reaches a handler unchecked.
+func (v *Validator) Validate(i any) error {
+	p, ok := i.(Validatable)
+	if !ok {
+		return fmt.Errorf("validate: %T does not implement Validatable", i)
+	}
+	if errs := v.Check(p); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Check runs p's validation and returns every failing field, for payloads
+// that don't arrive as a request body, such as imported rows.
+func (v *Validator) Check(p Validatable) Errors {
+	c := &Checker{passwords: v.passwords}
+	p.Validate(c)
+	return c.errs
+}
+
+// Checker collects field errors. Each check names the field it looks at and
+// records at most one error for it.
+type Checker struct {
+	passwords config.PasswordPolicy
+	errs      Errors
+}
+
+// Fail records that field is invalid.
+func (c *Checker) Fail(field, message string) {
+	c.errs = append(c.errs, &FieldError{Field: field, Message: message})
+}
+
+// Required fails if v is empty or only whitespace.
+func (c *Checker) Required(field, v string) bool {
+	if strings.TrimSpace(v) == "" {
+		c.Fail(field, "is required")
+		return false
+	}
+	return true
+}
+
+// MaxLength fails if v is longer than n characters.
+func (c *Checker) MaxLength(field, v string, n int) {
+	if utf8.RuneCountInString(v) > n {
+		c.Fail(field, fmt.Sprintf("must be at most %d characters", n))
+	}
+}
+
+// NotNegative fails if n is below zero.
+func (c *Checker) NotNegative(field string, n int) {
+	if n < 0 {
+		c.Fail(field, "cannot be negative")
+	}
+}
+
+// RequiredTime fails if t is the zero time.
+func (c *Checker) RequiredTime(field string, t time.Time) {
+	if t.IsZero() {
+		c.Fail(field, "is required")
+	}
+}
+
+// OneOf fails if v isn't one of allowed.
+func OneOf[T ~string](c *Checker, field string, v T, allowed ...T) {
+	if slices.Contains(allowed, v) {
+		return
+	}
+	names := make([]string, len(allowed))
+	for i, a := range allowed {
+		names[i] = string(a)
+	}
+	c.Fail(field, "must be one of "+strings.Join(names, ", "))
+}
+
+// Email fails unless v is a bare address such as someone@example.com.
+func (c *Checker) Email(field, v string) {
+	if !c.Required(field, v) {
+		return
+	}
+	if addr, err := mail.ParseAddress(v); err != nil || addr.Address != v {
+		c.Fail(field, "is not a valid address")
+	}
+}
+
+// URL fails unless v is an absolute http or https URL. Empty is allowed
+// unless required.
+func (c *Checker) URL(field, v string, required bool) {
+	if v == "" {
+		if required {
+			c.Fail(field, "is required")
+		}
+		return
+	}
+	u, err := url.ParseRequestURI(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.Fail(field, "must be an absolute http(s) URL")
+	}
+}
+
+// Password fails unless v meets the configured password policy.
+func (c *Checker) Password(field, v string) {
+	p := c.passwords
+	n := utf8.RuneCountInString(v)
+	switch {
+	case n == 0:
+		c.Fail(field, "is required")
+		return
+	case n < p.MinLength:
+		c.Fail(field, fmt.Sprintf("must be at least %d characters", p.MinLength))
+		return
+	case p.MaxLength > 0 && n > p.MaxLength:
+		c.Fail(field, fmt.Sprintf("must be at most %d characters", p.MaxLength))
+		return
+	}
+
+	var missing []string
+	if p.RequireUpper && !strings.ContainsFunc(v, unicode.IsUpper) {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.RequireLower && !strings.ContainsFunc(v, unicode.IsLower) {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.RequireDigit && !strings.ContainsFunc(v, unicode.IsDigit) {
+		missing = append(missing, "a digit")
+	}
+	if p.RequireSymbol && !strings.ContainsFunc(v, isSymbol) {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		c.Fail(field, "must contain "+strings.Join(missing, ", "))
+	}
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
@@ -0,0 +1,136 @@
+package validate
+
+import (
+	"errors"
+	"farm/internal/config"
+	"strings"
+	"testing"
+)
+
+// check runs fn on a fresh Checker and returns the message recorded for
+// field "f", or "" if it passed.
+func check(p config.PasswordPolicy, fn func(c *Checker)) string {
+	c := &Checker{passwords: p}
+	fn(c)
+	if len(c.errs) == 0 {
+		return ""
+	}
+	return c.errs[0].Message
+}
+
+func TestPassword(t *testing.T) {
+	strict := config.PasswordPolicy{MinLength: 8, MaxLength: 12, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	tests := []struct {
+		policy   config.PasswordPolicy
+		password string
+		want     string
+	}{
+		{strict, "", "is required"},
+		{strict, "Ab1!", "must be at least 8 characters"},
+		{strict, "Abcdefgh1!xyz", "must be at most 12 characters"},
+		{strict, "Abcdefg1!", ""},
+		{strict, "abcdefg1!", "must contain an uppercase letter"},
+		{strict, "ABCDEFG1!", "must contain a lowercase letter"},
+		{strict, "abcdefgh", "must contain an uppercase letter, a digit, a symbol"},
+		{strict, "Abcdefg1 x", "must contain a symbol"}, // Spaces aren't symbols
+		{strict, "Äbcdefg1€", ""},
+		{config.PasswordPolicy{MinLength: 8}, "ääääääää", ""}, // Characters, not bytes
+		{config.PasswordPolicy{MinLength: 8}, strings.Repeat("a", 500), ""},
+	}
+	for _, tt := range tests {
+		if got := check(tt.policy, func(c *Checker) { c.Password("f", tt.password) }); got != tt.want {
+			t.Errorf("Password(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestEmail(t *testing.T) {
+	tests := map[string]string{
+		"someone@example.com":           "",
+		"some.one+farm@mail.example.nl": "",
+		"":                              "is required",
+		"  ":                            "is required",
+		"someone":                       "is not a valid address",
+		"Someone <someone@example.com>": "is not a valid address",
+		" someone@example.com":          "is not a valid address",
+		"someone@":                      "is not a valid address",
+	}
+	for email, want := range tests {
+		if got := check(config.PasswordPolicy{}, func(c *Checker) { c.Email("f", email) }); got != want {
+			t.Errorf("Email(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	const invalid = "must be an absolute http(s) URL"
+	tests := []struct {
+		url      string
+		required bool
+		want     string
+	}{
+		{"https://example.com/eggs.jpg", true, ""},
+		{"http://localhost:8080/hook", false, ""},
+		{"", false, ""},
+		{"", true, "is required"},
+		{"/images/eggs.jpg", false, invalid},
+		{"ftp://example.com/eggs.jpg", false, invalid},
+		{"javascript:alert(1)", false, invalid},
+		{"https://", false, invalid},
+		{"example.com", false, invalid},
+	}
+	for _, tt := range tests {
+		if got := check(config.PasswordPolicy{}, func(c *Checker) { c.URL("f", tt.url, tt.required) }); got != tt.want {
+			t.Errorf("URL(%q, %t) = %q, want %q", tt.url, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(c *Checker)
+		want string
+	}{
+		{"required", func(c *Checker) { c.Required("f", "eggs") }, ""},
+		{"required blank", func(c *Checker) { c.Required("f", " \t") }, "is required"},
+		{"max length", func(c *Checker) { c.MaxLength("f", "ééé", 3) }, ""},
+		{"too long", func(c *Checker) { c.MaxLength("f", "eggs", 3) }, "must be at most 3 characters"},
+		{"not negative", func(c *Checker) { c.NotNegative("f", 0) }, ""},
+		{"negative", func(c *Checker) { c.NotNegative("f", -1) }, "cannot be negative"},
+		{"one of", func(c *Checker) { OneOf(c, "f", "b", "a", "b") }, ""},
+		{"not one of", func(c *Checker) { OneOf(c, "f", "c", "a", "b") }, "must be one of a, b"},
+	}
+	for _, tt := range tests {
+		if got := check(config.PasswordPolicy{}, tt.fn); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+type payload struct{ name, email string }
+
+func (p payload) Validate(c *Checker) {
+	c.Required("name", p.name)
+	c.Email("email", p.email)
+}
+
+func TestValidator(t *testing.T) {
+	v := New(config.PasswordPolicy{})
+	if err := v.Validate(payload{"Alice", "alice@example.com"}); err != nil {
+		t.Errorf("valid payload: %v", err)
+	}
+
+	err := v.Validate(payload{})
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Field != "name" || errs[1].Field != "email" {
+		t.Fatalf("Validate reported %v; want name and email", err)
+	}
+	if want := "invalid request: name is required; email is required"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+
+	if err := v.Validate(struct{}{}); err == nil || errors.As(err, &errs) {
+		t.Errorf("payload without Validate gave %v", err)
+	}
+}
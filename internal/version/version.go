@@ -0,0 +1,50 @@
+// Package version describes the running build.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version and Commit may be set at build time:
+//
+//	go build -ldflags "-X farm/internal/version.Version=v1.2.0 -X farm/internal/version.Commit=$(git rev-parse HEAD)" ./cmd/server
+//
+// When unset they fall back to the module version and VCS revision that the
+// Go toolchain embeds in the binary.
+var (
+	Version string
+	Commit  string
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time,omitempty"` // Commit time of the VCS revision
+	Modified  bool   `json:"modified"`             // Built from a working tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			info.BuildTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
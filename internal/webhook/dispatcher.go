@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"farm/internal/config"
+	"farm/internal/logger"
+	"farm/internal/models"
+	"farm/internal/store"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Consumer is the outbox cursor name used by webhook fan-out.
+const Consumer = "webhooks"
+
+const batchSize = 100
+
+// leaseDuration is how long an instance keeps the outbox to itself after each
+// fan out; if it stops, another takes over once the lease lapses.
+const leaseDuration = time.Minute
+
+// Dispatcher turns outbox events into webhook deliveries and sends them,
+// retrying failures with exponential backoff. Every server instance runs one:
+// only the instance holding the outbox lease fans events out, and each
+// delivery is claimed before it is sent, so none goes out twice.
+type Dispatcher struct {
+	store  store.Repository
+	cfg    config.WebhookConfig
+	client *http.Client
+	id     string // Identifies this instance's lease and claims
+}
+
+func NewDispatcher(s store.Repository, cfg config.WebhookConfig) *Dispatcher {
+	return &Dispatcher{
+		store:  s,
+		cfg:    cfg,
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout)},
+		id:     uuid.New().String(),
+	}
+}
+
+// Run performs one pass: fan out new events, then attempt due deliveries.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	if err := d.fanOut(ctx); err != nil {
+		return fmt.Errorf("fan out: %w", err)
+	}
+	return d.deliverDue(ctx)
+}
+
+// fanOut queues a delivery for every active webhook subscribed to each new
+// outbox event, then advances the cursor past them.
+func (d *Dispatcher) fanOut(ctx context.Context) error {
+	now := time.Now()
+	leased, err := d.store.LeaseOutboxConsumer(ctx, Consumer, d.id, now, now.Add(leaseDuration))
+	if err != nil || !leased {
+		return err
+	}
+	events, err := d.store.GetOutboxEvents(ctx, Consumer, batchSize)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	hooks, err := d.store.GetAllWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []*models.WebhookDelivery
+	for _, e := range events {
+		for _, w := range hooks {
+			if !w.Active || !w.Wants(e.Type) {
+				continue
+			}
+			body, err := json.Marshal(map[string]any{
+				"id":         e.ID,
+				"type":       e.Type,
+				"created_at": e.CreatedAt,
+				"data":       e.Payload,
+			})
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, &models.WebhookDelivery{
+				WebhookID:     w.ID,
+				EventID:       e.ID,
+				EventType:     e.Type,
+				Payload:       body,
+				Status:        models.DeliveryPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			})
+		}
+	}
+
+	if len(deliveries) > 0 {
+		if err := d.store.AddWebhookDeliveries(ctx, deliveries); err != nil {
+			return err
+		}
+	}
+	return d.store.AdvanceOutboxCursor(ctx, Consumer, events[len(events)-1].ID)
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	due, err := d.store.GetDueWebhookDeliveries(ctx, time.Now(), batchSize)
+	if err != nil {
+		return err
+	}
+
+	hooks := make(map[string]*models.Webhook)
+	for _, del := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// The claim outlasts the request, and lapses if this instance stops
+		// before recording the outcome, so the delivery is retried.
+		now := time.Now()
+		claimed, err := d.store.ClaimWebhookDelivery(ctx, del.ID, now, now.Add(time.Duration(d.cfg.Timeout)+time.Minute))
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue // Another instance has it
+		}
+
+		w, ok := hooks[del.WebhookID]
+		if !ok {
+			if w, err = d.store.GetWebhook(ctx, del.WebhookID); err != nil {
+				logger.FromContext(ctx).Error("Webhook for delivery not found", "delivery_id", del.ID, "webhook_id", del.WebhookID, "error", err)
+				continue
+			}
+			hooks[del.WebhookID] = w
+		}
+
+		d.attempt(ctx, w, del)
+		if err := d.store.UpdateWebhookDelivery(ctx, del); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attempt sends del once and records the outcome on it.
+func (d *Dispatcher) attempt(ctx context.Context, w *models.Webhook, del *models.WebhookDelivery) {
+	del.Attempts++
+	code, err := d.send(ctx, w, del)
+	del.ResponseCode = code
+
+	if err == nil {
+		now := time.Now()
+		del.Status = models.DeliveryDelivered
+		del.LastError = ""
+		del.DeliveredAt = &now
+		logger.FromContext(ctx).Info("Webhook delivered", "webhook_id", w.ID, "delivery_id", del.ID, "event", del.EventType, "status", code)
+		return
+	}
+
+	del.LastError = err.Error()
+	if !w.Active || del.Attempts >= d.cfg.MaxAttempts {
+		del.Status = models.DeliveryFailed
+		logger.FromContext(ctx).Error("Webhook delivery abandoned", "webhook_id", w.ID, "delivery_id", del.ID, "attempts", del.Attempts, "error", err)
+		return
+	}
+	del.NextAttemptAt = time.Now().Add(d.backoff(del.Attempts))
+	logger.FromContext(ctx).Warn("Webhook delivery failed, will retry", "webhook_id", w.ID, "delivery_id", del.ID, "attempts", del.Attempts,
+		"next_attempt_at", del.NextAttemptAt, "error", err)
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := time.Duration(d.cfg.RetryBackoff)
+	for i := 1; i < attempts && delay < 24*time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, 24*time.Hour)
+}
+
+func (d *Dispatcher) send(ctx context.Context, w *models.Webhook, del *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "farm-webhooks/1")
+	req.Header.Set("X-Farm-Event", del.EventType)
+	req.Header.Set("X-Farm-Event-ID", strconv.FormatInt(del.EventID, 10))
+	req.Header.Set("X-Farm-Delivery", strconv.FormatInt(del.ID, 10))
+	req.Header.Set("X-Farm-Timestamp", timestamp)
+	req.Header.Set("X-Farm-Signature", "sha256="+Sign(w.Secret, timestamp, del.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret,
+// as sent in the X-Farm-Signature header. Receivers should recompute it and
+// reject stale timestamps to prevent replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
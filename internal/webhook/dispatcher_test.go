@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"context"
+	"farm/internal/config"
+	"farm/internal/models"
+	"farm/internal/store/memory"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatchersShareDeliveries(t *testing.T) {
+	var mu sync.Mutex
+	sent := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get("X-Farm-Signature"), "sha256="+Sign("secret", r.Header.Get("X-Farm-Timestamp"), body); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+		mu.Lock()
+		sent[r.Header.Get("X-Farm-Event-ID")]++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s := memory.NewMemoryStore(&config.Config{})
+	if err := s.AddWebhook(ctx, &models.Webhook{ID: "w1", URL: srv.URL, Secret: "secret", Active: true, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"eggs", "milk", "jam"} {
+		if err := s.AddProduct(ctx, &models.Product{ID: id, Name: id, Quantity: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.WebhookConfig{Timeout: config.Duration(time.Second), MaxAttempts: 3, RetryBackoff: config.Duration(time.Minute)}
+	dispatchers := []*Dispatcher{NewDispatcher(s, cfg), NewDispatcher(s, cfg)}
+	for range 3 {
+		var wg sync.WaitGroup
+		for _, d := range dispatchers {
+			wg.Go(func() {
+				if err := d.Run(ctx); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+		wg.Wait()
+	}
+
+	if len(sent) != 3 {
+		t.Errorf("events delivered = %d, want 3", len(sent))
+	}
+	for id, n := range sent {
+		if n != 1 {
+			t.Errorf("event %s delivered %d times", id, n)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, config.WebhookConfig{RetryBackoff: config.Duration(30 * time.Second)})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRetriesThenGivesUp(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s := memory.NewMemoryStore(&config.Config{})
+	hooks := []*models.Webhook{
+		{ID: "failing", URL: srv.URL, Secret: "secret", Active: true, CreatedAt: time.Now()},
+		{ID: "credits", URL: srv.URL, Secret: "secret", Active: true, Events: []string{models.EventCreditsUpdated}, CreatedAt: time.Now()},
+		{ID: "inactive", URL: srv.URL, Secret: "secret", CreatedAt: time.Now()},
+	}
+	for _, w := range hooks {
+		if err := s.AddWebhook(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddProduct(ctx, &models.Product{ID: "eggs", Name: "Eggs", Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A tiny backoff makes each retry due by the next run
+	d := NewDispatcher(s, config.WebhookConfig{Timeout: config.Duration(time.Second), MaxAttempts: 2, RetryBackoff: 1})
+	deliveries := func(webhookID string) []*models.WebhookDelivery {
+		t.Helper()
+		got, err := s.GetWebhookDeliveries(ctx, webhookID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	if err := d.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := deliveries("failing")
+	if len(got) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(got))
+	}
+	if del := got[0]; del.Status != models.DeliveryPending || del.Attempts != 1 || del.ResponseCode != http.StatusServiceUnavailable || del.LastError == "" {
+		t.Errorf("after one failure delivery = %+v", del)
+	}
+	if n := len(deliveries("credits")) + len(deliveries("inactive")); n != 0 {
+		t.Errorf("%d deliveries queued for unsubscribed or inactive webhooks", n)
+	}
+
+	for range 2 {
+		time.Sleep(time.Millisecond)
+		if err := d.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if del := deliveries("failing")[0]; del.Status != models.DeliveryFailed || del.Attempts != 2 || del.DeliveredAt != nil {
+		t.Errorf("after giving up delivery = %+v", del)
+	}
+	if requests != 2 {
+		t.Errorf("sent %d requests, want 2", requests)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	if got, want := Sign("secret", "1700000000", []byte("{}")), "b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"; got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}